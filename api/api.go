@@ -26,6 +26,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"runtime"
+	"strings"
 	"sync"
 	"time"
 
@@ -38,12 +40,34 @@ const (
 	_defaultRequestTimeout = time.Second * 15
 	_apiHost               = "api.ivpn.net"
 	_serversPath           = "v4/servers.json"
+	_serversSignaturePath  = "v4/servers.json.sig"
 	_sessionNewPath        = "v4/session/new"
 	_sessionStatusPath     = "v4/session/status"
 	_sessionDeletePath     = "v4/session/delete"
 	_wgKeySetPath          = "v4/session/wg/set"
+	_geoLookupPath         = "v4/geo-lookup"
+	_portForwardAddPath    = "v4/port-forward/add"
+	_portForwardDeletePath = "v4/port-forward/delete"
+	_deviceListPath        = "v4/device/list"
+	_deviceDeletePath      = "v4/device/delete"
+	// platform-specific info about the latest available versions of the daemon
+	// and the bundled binaries
+	_updateInfoPath          = "v4/updates/" + runtime.GOOS + ".json"
+	_updateInfoSignaturePath = "v4/updates/" + runtime.GOOS + ".json.sig"
 )
 
+// request retry parameters: each API request is retried few times with increasing
+// delay between attempts (a single attempt already tries all known hosts and IPs)
+const (
+	_requestRetryCount       = 3
+	_requestRetryBackoffBase = time.Second
+)
+
+// _apiHostNames - DNS names of the API servers
+// (first is the primary; the rest are alternate names to try when the primary
+// name is unreachable or DNS-blocked; all of them serve the '_apiHost' certificate)
+var _apiHostNames = []string{_apiHost}
+
 var log *logger.Logger
 
 func init() {
@@ -60,11 +84,48 @@ type API struct {
 	mutex               sync.Mutex
 	alternateIPs        []net.IP
 	lastGoodAlternateIP net.IP
+
+	// API reachability state (true - the last request succeeded on some host)
+	isReachable bool
+	// called on each reachability state change (can be nil)
+	reachabilityNotifyFn func(isReachable bool)
 }
 
 // CreateAPI creates new API object
 func CreateAPI() (*API, error) {
-	return &API{}, nil
+	return &API{isReachable: true}, nil
+}
+
+// SetReachabilityNotifier registers a function to be called
+// on each API reachability state change
+func (a *API) SetReachabilityNotifier(fn func(isReachable bool)) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.reachabilityNotifyFn = fn
+}
+
+// IsReachable - the result of the last API request: false when all known API hosts
+// and alternate IPs were tried and none of them responded
+func (a *API) IsReachable() bool {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.isReachable
+}
+
+// updateReachability saves the new reachability state and notifies the subscriber
+// (only when the state changed)
+func (a *API) updateReachability(isReachable bool) {
+	a.mutex.Lock()
+	isChanged := a.isReachable != isReachable
+	a.isReachable = isReachable
+	notifyFn := a.reachabilityNotifyFn
+	a.mutex.Unlock()
+
+	if isChanged && notifyFn != nil {
+		notifyFn(isReachable)
+	}
 }
 
 // IsAlternateIPsInitialized - checks if the alternate IP initialized
@@ -111,60 +172,141 @@ func (a *API) SetAlternateIPs(IPs []string) error {
 }
 
 // DownloadServersList - download servers list form API IVPN server
+// The signature of the downloaded data is verified:
+// a servers list which was not signed by the infrastructure is rejected
 func (a *API) DownloadServersList() (*types.ServersInfoResponse, error) {
-	servers := new(types.ServersInfoResponse)
-	if err := a.request(_serversPath, "GET", "", nil, servers); err != nil {
+	data, err := a.requestRaw(_serversPath, "GET", "", nil)
+	if err != nil {
 		return nil, err
 	}
 
+	signature, err := a.requestRaw(_serversSignaturePath, "GET", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download servers list signature: %w", err)
+	}
+	if err := verifyServersSignature(data, strings.TrimSpace(string(signature))); err != nil {
+		return nil, fmt.Errorf("servers list signature check failed: %w", err)
+	}
+
+	servers := new(types.ServersInfoResponse)
+	if err := json.Unmarshal(data, servers); err != nil {
+		return nil, fmt.Errorf("failed to deserialize servers list: %w", err)
+	}
+
 	// save info about alternate API hosts
 	a.SetAlternateIPs(servers.Config.API.IPAddresses)
 	return servers, nil
 }
 
+// DownloadUpdateInfo - download info about the latest available versions of the daemon
+// and the bundled binaries (platform-specific).
+// The signature of the downloaded data is verified (signed by the same infrastructure
+// key as the servers list); unsigned data is rejected
+func (a *API) DownloadUpdateInfo() (*types.UpdateInfoResponse, error) {
+	data, err := a.requestRaw(_updateInfoPath, "GET", "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := a.requestRaw(_updateInfoSignaturePath, "GET", "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download update info signature: %w", err)
+	}
+	if err := verifyServersSignature(data, strings.TrimSpace(string(signature))); err != nil {
+		return nil, fmt.Errorf("update info signature check failed: %w", err)
+	}
+
+	updateInfo := new(types.UpdateInfoResponse)
+	if err := json.Unmarshal(data, updateInfo); err != nil {
+		return nil, fmt.Errorf("failed to deserialize update info: %w", err)
+	}
+	return updateInfo, nil
+}
+
+// DownloadUpdateArtifact - download an update artifact ('urlPath' - relative URL path
+// on the API infrastructure) and verify its signature
+// ('signatureBase64' - signature of the SHA-256 hash of the artifact data);
+// a corrupted or unsigned artifact is rejected
+func (a *API) DownloadUpdateArtifact(urlPath string, signatureBase64 string) ([]byte, error) {
+	data, err := a.requestRaw(urlPath, "GET", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyServersSignature(data, strings.TrimSpace(signatureBase64)); err != nil {
+		return nil, fmt.Errorf("update artifact signature check failed: %w", err)
+	}
+	return data, nil
+}
+
+// GeoLookup - get information about the current location (as seen by the API servers)
+// When VPN is connected - the request goes through the tunnel
+func (a *API) GeoLookup() (*types.GeoLookupResponse, error) {
+	location := new(types.GeoLookupResponse)
+	if err := a.request(_geoLookupPath, "GET", "", nil, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
 // SessionNew - try to register new session
-func (a *API) SessionNew(accountID string, wgPublicKey string, forceLogin bool) (
+// The 2FA code and the captcha data are defined only for a follow-up login attempt
+// (when the previous attempt was rejected with a '2FA required'\'captcha required' error)
+func (a *API) SessionNew(accountID string, wgPublicKey string, forceLogin bool, captchaID string, captcha string, confirmation2FA string, deviceName string) (
 	*types.SessionNewResponse,
 	*types.SessionNewErrorLimitResponse,
+	*types.SessionNewErrorCaptchaResponse,
 	*types.APIErrorResponse,
 	error) {
 
 	var successResp types.SessionNewResponse
 	var errorLimitResp types.SessionNewErrorLimitResponse
+	var errorCaptchaResp types.SessionNewErrorCaptchaResponse
 	var apiErr types.APIErrorResponse
 
 	request := &types.SessionNewRequest{
-		AccountID:  accountID,
-		PublicKey:  wgPublicKey,
-		ForceLogin: forceLogin}
+		AccountID:       accountID,
+		PublicKey:       wgPublicKey,
+		ForceLogin:      forceLogin,
+		CaptchaID:       captchaID,
+		Captcha:         captcha,
+		Confirmation2FA: confirmation2FA,
+		DeviceName:      deviceName}
 
 	data, err := a.requestRaw(_sessionNewPath, "POST", "application/json", request)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
 	// Check is it API error
 	if err := json.Unmarshal(data, &apiErr); err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
+		return nil, nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
 	}
 
 	// success
 	if apiErr.Status == types.CodeSuccess {
 		if err := json.Unmarshal(data, &successResp); err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
 		}
-		return &successResp, nil, &apiErr, nil
+		return &successResp, nil, nil, &apiErr, nil
 	}
 
 	// Session limit check
 	if apiErr.Status == types.CodeSessionsLimitReached {
 		if err := json.Unmarshal(data, &errorLimitResp); err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
+			return nil, nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
+		}
+		return nil, &errorLimitResp, nil, &apiErr, types.CreateAPIError(apiErr.Status, apiErr.Message)
+	}
+
+	// Captcha challenge check (the response contains the captcha image to be solved)
+	if apiErr.Status == types.CodeCaptchaRequired || apiErr.Status == types.CodeCaptchaInvalid {
+		if err := json.Unmarshal(data, &errorCaptchaResp); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to deserialize API response: %w", err)
 		}
-		return nil, &errorLimitResp, &apiErr, types.CreateAPIError(apiErr.Status, apiErr.Message)
+		return nil, nil, &errorCaptchaResp, &apiErr, types.CreateAPIError(apiErr.Status, apiErr.Message)
 	}
 
-	return nil, nil, &apiErr, types.CreateAPIError(apiErr.Status, apiErr.Message)
+	return nil, nil, nil, &apiErr, types.CreateAPIError(apiErr.Status, apiErr.Message)
 }
 
 // SessionStatus - get session status
@@ -212,6 +354,74 @@ func (a *API) SessionDelete(session string) error {
 	return nil
 }
 
+// PortForwardAdd - allocate a forwarded port for the session
+// (when 'port' is defined - renew the existing allocation instead of creating a new one)
+func (a *API) PortForwardAdd(session string, port int) (int, error) {
+	request := &types.PortForwardAddRequest{Session: session, Port: port}
+	resp := &types.PortForwardResponse{}
+
+	if err := a.request(_portForwardAddPath, "POST", "application/json", request, resp); err != nil {
+		return 0, err
+	}
+
+	if resp.Status != types.CodeSuccess {
+		return 0, types.CreateAPIError(resp.Status, resp.Message)
+	}
+
+	if resp.Port <= 0 {
+		return 0, fmt.Errorf("failed to add port forwarding (unexpected port value in API response)")
+	}
+
+	return resp.Port, nil
+}
+
+// PortForwardDelete - release a forwarded port
+func (a *API) PortForwardDelete(session string, port int) error {
+	request := &types.PortForwardDeleteRequest{Session: session, Port: port}
+	resp := &types.APIErrorResponse{}
+
+	if err := a.request(_portForwardDeletePath, "POST", "application/json", request, resp); err != nil {
+		return err
+	}
+
+	if resp.Status != types.CodeSuccess {
+		return types.CreateAPIError(resp.Status, resp.Message)
+	}
+	return nil
+}
+
+// DeviceList - get devices registered to the account
+func (a *API) DeviceList(session string) ([]types.DeviceInfo, error) {
+	request := &types.DeviceListRequest{Session: session}
+	resp := &types.DeviceListResponse{}
+
+	if err := a.request(_deviceListPath, "POST", "application/json", request, resp); err != nil {
+		return nil, err
+	}
+
+	if resp.Status != types.CodeSuccess {
+		return nil, types.CreateAPIError(resp.Status, resp.Message)
+	}
+
+	return resp.Devices, nil
+}
+
+// DeviceDelete - remove a device registered to the account
+// (logs out the session of that device)
+func (a *API) DeviceDelete(session string, deviceID string) error {
+	request := &types.DeviceDeleteRequest{Session: session, DeviceID: deviceID}
+	resp := &types.APIErrorResponse{}
+
+	if err := a.request(_deviceDeletePath, "POST", "application/json", request, resp); err != nil {
+		return err
+	}
+
+	if resp.Status != types.CodeSuccess {
+		return types.CreateAPIError(resp.Status, resp.Message)
+	}
+	return nil
+}
+
 // WireGuardKeySet - update WG key
 func (a *API) WireGuardKeySet(session string, newPublicWgKey string, activePublicWgKey string) (localIP net.IP, err error) {
 	request := &types.SessionWireGuardKeySetRequest{
@@ -24,14 +24,17 @@ package api
 
 import (
 	"bytes"
-	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"path"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/metrics"
 )
 
 func (a *API) getAlternateIPs() (lastGoodIP net.IP, ipList []net.IP) {
@@ -75,10 +78,12 @@ func (a *API) doRequest(urlPath string, method string, contentType string, reque
 	// When trying to access API server by alternate IPs (not by DNS name)
 	// we need to configure TLS to use api.ivpn.net hostname
 	// (to avoid certificate errors)
+	// The system-wide proxy configuration is intentionally ignored:
+	// a proxy between the daemon and the API is a MITM for us
+	// (certificate pinning would reject such connection anyway)
 	transCfg := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			ServerName: _apiHost,
-		},
+		Proxy:           nil,
+		TLSClientConfig: makeAPITLSConfig(),
 	}
 	// configure http-client with preconfigured TLS transport
 	client := &http.Client{Transport: transCfg, Timeout: _defaultRequestTimeout}
@@ -91,46 +96,47 @@ func (a *API) doRequest(urlPath string, method string, contentType string, reque
 		}
 	}
 
-	bodyBuffer := bytes.NewBuffer(data)
-
-	// access API by last good IP (if defined)
-	if lastIP != nil {
-		req, err := newRequest(getURL(lastIP.String(), urlPath), method, contentType, bodyBuffer)
+	// each attempt must use a fresh request body
+	// (the body buffer is consumed by a failed attempt)
+	tryHost := func(host string) (*http.Response, error) {
+		req, err := newRequest(getURL(host, urlPath), method, contentType, bytes.NewBuffer(data))
 		if err != nil {
 			return nil, err
 		}
+		return client.Do(req)
+	}
 
-		resp, err := client.Do(req)
+	// access API by last good IP (if defined)
+	if lastIP != nil {
+		resp, err := tryHost(lastIP.String())
 		if err == nil {
 			return resp, nil
 		}
 	}
 
-	// try to access API server by host DNS
-	req, err := newRequest(getURL(_apiHost, urlPath), method, contentType, bodyBuffer)
-	if err != nil {
-		return nil, err
-	}
-	firstResp, firstErr := client.Do(req)
-	if firstErr == nil {
-		// save last good IP
-		a.saveLastGoodAlternateIP(nil)
-		return firstResp, firstErr
+	// try to access API server by host DNS names
+	// (alternate names can be in use when the primary name is DNS-blocked)
+	var firstErr error
+	for _, host := range _apiHostNames {
+		resp, err := tryHost(host)
+		if err == nil {
+			// save last good IP
+			a.saveLastGoodAlternateIP(nil)
+			return resp, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		log.Warning("Failed to access " + host)
 	}
-	log.Warning("Failed to access " + _apiHost)
 
 	// try to access API server by alternate IP
 	for i, ip := range ips {
 		log.Info(fmt.Sprintf("Trying to use alternate API IP #%d...", i))
 
-		req, err := newRequest(getURL(ip.String(), urlPath), method, contentType, bodyBuffer)
-		if err != nil {
-			return nil, err
-		}
-		resp, err := client.Do(req)
-
+		resp, err := tryHost(ip.String())
 		if err != nil {
-			fmt.Println("Failed: ", err.Error())
+			log.Warning("Failed: ", err.Error())
 			continue
 		}
 
@@ -138,14 +144,46 @@ func (a *API) doRequest(urlPath string, method string, contentType string, reque
 		a.saveLastGoodAlternateIP(ip)
 
 		log.Info(fmt.Sprintf("Success!"))
-		return resp, err
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("Unable to access IVPN API server: %w", firstErr)
+}
+
+// doRequestWithRetry - request with retries: a transport-level failure
+// (no API host responded) is retried few times with increasing delay between attempts.
+// The resulting API reachability state is saved (subscribers are notified about changes).
+func (a *API) doRequestWithRetry(urlPath string, method string, contentType string, request interface{}) (resp *http.Response, err error) {
+	metrics.IncCounter("ivpn_api_requests_total")
+
+	for attempt := 1; ; attempt++ {
+		resp, err = a.doRequest(urlPath, method, contentType, request)
+		if err == nil {
+			a.updateReachability(true)
+			return resp, nil
+		}
+
+		// certificate pinning failure is not a transient error - no reason to retry
+		if errors.Is(err, errAPICertificatePinning) {
+			break
+		}
+
+		if attempt >= _requestRetryCount {
+			break
+		}
+
+		backoff := _requestRetryBackoffBase * time.Duration(attempt)
+		log.Warning(fmt.Sprintf("API request failed (attempt %d of %d); retrying in %v ...", attempt, _requestRetryCount, backoff))
+		time.Sleep(backoff)
 	}
 
-	return firstResp, fmt.Errorf("Unable to access IVPN API server: %w", firstErr)
+	metrics.IncCounter("ivpn_api_request_errors_total")
+	a.updateReachability(false)
+	return nil, err
 }
 
 func (a *API) requestRaw(urlPath string, method string, contentType string, requestObject interface{}) (responseData []byte, err error) {
-	resp, err := a.doRequest(urlPath, method, contentType, requestObject)
+	resp, err := a.doRequestWithRetry(urlPath, method, contentType, requestObject)
 	if err != nil {
 		return nil, fmt.Errorf("API request failed: %w", err)
 	}
@@ -0,0 +1,79 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+)
+
+// public key for the servers list signature verification
+// (the servers.json file is signed by the infrastructure; ECDSA P-256, SHA-256)
+const _serversSignPublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEIc4PcAiry6RlsCoS9nT5z+dMVKxy
+IyePRc28NVifuNblS1SoTlAgdD6UpQYXBkolUcTeHYwsRWCcsi5kenbbJw==
+-----END PUBLIC KEY-----`
+
+// verifyServersSignature - check ECDSA signature of the servers list data
+// (signature - base64 encoded ASN.1 signature of the SHA-256 hash of the data)
+func verifyServersSignature(data []byte, signatureBase64 string) error {
+	block, _ := pem.Decode([]byte(_serversSignPublicKey))
+	if block == nil {
+		return fmt.Errorf("failed to decode signature verification key")
+	}
+
+	pubKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse signature verification key: %w", err)
+	}
+	ecdsaKey, ok := pubKey.(*ecdsa.PublicKey)
+	if ok == false {
+		return fmt.Errorf("unexpected type of the signature verification key")
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(signatureBase64)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	// ASN.1 encoded signature (r, s)
+	sig := struct {
+		R *big.Int
+		S *big.Int
+	}{}
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return fmt.Errorf("failed to deserialize signature: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	if ecdsa.Verify(ecdsaKey, hash[:], sig.R, sig.S) == false {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// errAPICertificatePinning - pin validation failure
+// The error text is forwarded to clients as-is, so it must clearly explain the situation to the user
+var errAPICertificatePinning = errors.New("API server TLS certificate check failed: the server public key does not match any known pin (the connection might be intercepted)")
+
+// _apiPinnedKeyHashes - base64-encoded SHA-256 hashes of the public keys (SPKI)
+// which are allowed in the API server certificate chain
+// (contains a backup pin: allows the infrastructure to rotate the leaf certificate key)
+var _apiPinnedKeyHashes = []string{
+	"g6WEFnt9DyTi70nW/fufsZNw83vFpcmIhMuDPQ1MFcI=",
+	"KCcpK9y22OrlapwO1/mP8q9LDJvRHnPV5Fr4CWEwFDI=",
+	"iRHkSbdOY/YD8EE5fpl8W0P8EqmfkBRTADEegR2/Wnc=",
+}
+
+// makeAPITLSConfig returns the TLS configuration for the API connections:
+// - server name is always '_apiHost' (we often connect by IP address)
+// - TLS 1.2 or newer is required
+// - the certificate chain must contain a pinned public key
+func makeAPITLSConfig() *tls.Config {
+	return &tls.Config{
+		ServerName:            _apiHost,
+		MinVersion:            tls.VersionTLS12,
+		VerifyPeerCertificate: verifyAPICertificatePins,
+	}
+}
+
+// verifyAPICertificatePins - public-key pinning check of the API server certificate chain
+// (called by the TLS handshake after the standard certificate verification succeeded)
+func verifyAPICertificatePins(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	for _, rawCert := range rawCerts {
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return fmt.Errorf("failed to parse API server certificate: %w", err)
+		}
+
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		keyHashBase64 := base64.StdEncoding.EncodeToString(hash[:])
+
+		for _, pin := range _apiPinnedKeyHashes {
+			if keyHashBase64 == pin {
+				return nil
+			}
+		}
+	}
+
+	return errAPICertificatePinning
+}
@@ -42,6 +42,18 @@ const (
 
 	// AccountNotActive - account should be purchased
 	AccountNotActive int = 702
+
+	// CodeCaptchaRequired - captcha have to be solved to log in
+	CodeCaptchaRequired int = 70001
+
+	// CodeCaptchaInvalid - provided captcha value is not correct
+	CodeCaptchaInvalid int = 70002
+
+	// Code2FARequired - TOTP 2FA code is required to log in
+	Code2FARequired int = 70011
+
+	// Code2FAInvalidCode - provided TOTP 2FA code is not correct
+	Code2FAInvalidCode int = 70012
 )
 
 // APIError - error, user not logged in into account
@@ -27,6 +27,25 @@ type SessionNewRequest struct {
 	AccountID  string `json:"username"`
 	PublicKey  string `json:"wg_public_key"`
 	ForceLogin bool   `json:"force"`
+
+	CaptchaID       string `json:"captcha_id,omitempty"`
+	Captcha         string `json:"captcha,omitempty"`
+	Confirmation2FA string `json:"confirmation,omitempty"`
+
+	// DeviceName - human-readable name of this device
+	// (shown in the device list of the account)
+	DeviceName string `json:"device_name,omitempty"`
+}
+
+// DeviceListRequest request to get devices registered to the account
+type DeviceListRequest struct {
+	Session string `json:"session_token"`
+}
+
+// DeviceDeleteRequest request to remove a device registered to the account
+type DeviceDeleteRequest struct {
+	Session  string `json:"session_token"`
+	DeviceID string `json:"device_id"`
 }
 
 // SessionDeleteRequest request to delete session
@@ -45,3 +64,16 @@ type SessionWireGuardKeySetRequest struct {
 	PublicKey          string `json:"public_key"`
 	ConnectedPublicKey string `json:"connected_public_key"`
 }
+
+// PortForwardAddRequest request to allocate a forwarded port for the session
+// (when 'Port' is defined - renew the existing allocation instead)
+type PortForwardAddRequest struct {
+	Session string `json:"session_token"`
+	Port    int    `json:"port,omitempty"`
+}
+
+// PortForwardDeleteRequest request to release a forwarded port
+type PortForwardDeleteRequest struct {
+	Session string `json:"session_token"`
+	Port    int    `json:"port"`
+}
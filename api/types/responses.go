@@ -71,14 +71,69 @@ type SessionNewErrorLimitResponse struct {
 	SessionLimitData ServiceStatusAPIResp `json:"data"`
 }
 
+// SessionNewErrorCaptchaResponse information about captcha challenge
+// ('captcha required'\'captcha invalid' login errors;
+// the image is a base64-encoded payload to be displayed to the user)
+type SessionNewErrorCaptchaResponse struct {
+	APIErrorResponse
+	CaptchaID    string `json:"captcha_id"`
+	CaptchaImage string `json:"captcha_image"`
+}
+
+// DeviceInfo information about a device registered to the account
+type DeviceInfo struct {
+	DeviceID string `json:"device_id"`
+	Name     string `json:"device_name"`
+	// CreatedAt - Unix time of the device registration
+	CreatedAt int64 `json:"created_at"`
+	// IsCurrent - true for the device of the requesting session
+	IsCurrent bool `json:"is_current"`
+}
+
+// DeviceListResponse devices registered to the account
+type DeviceListResponse struct {
+	APIErrorResponse
+	Devices []DeviceInfo `json:"devices"`
+}
+
 // SessionsWireGuardResponse Sessions WireGuard response
 type SessionsWireGuardResponse struct {
 	APIErrorResponse
 	IPAddress string `json:"ip_address,omitempty"`
 }
 
+// PortForwardResponse port-forwarding response
+type PortForwardResponse struct {
+	APIErrorResponse
+	Port int `json:"port,omitempty"`
+}
+
 // SessionStatusResponse session status response
 type SessionStatusResponse struct {
 	APIErrorResponse
 	ServiceStatus ServiceStatusAPIResp `json:"service_status"`
 }
+
+// GeoLookupResponse information about current location
+type GeoLookupResponse struct {
+	IPAddress    string `json:"ip_address"`
+	CountryCode  string `json:"country_code"`
+	Country      string `json:"country"`
+	City         string `json:"city"`
+	IsIvpnServer bool   `json:"isIvpnServer"`
+}
+
+// UpdateArtifactInfo - info about a downloadable update artifact
+// ('signature' - base64 encoded ECDSA signature of the SHA-256 hash of the artifact data)
+type UpdateArtifactInfo struct {
+	Version   string `json:"version"`
+	URLPath   string `json:"url_path"`
+	Signature string `json:"signature"`
+}
+
+// UpdateInfoResponse - latest available versions of the daemon and the bundled binaries
+type UpdateInfoResponse struct {
+	Daemon    UpdateArtifactInfo `json:"daemon"`
+	OpenVPN   UpdateArtifactInfo `json:"openvpn"`
+	WireGuard UpdateArtifactInfo `json:"wireguard"`
+}
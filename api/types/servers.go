@@ -36,6 +36,8 @@ type WireGuardServerInfo struct {
 	CountryCode string `json:"country_code"`
 	Country     string `json:"country"`
 	City        string `json:"city"`
+	// Load - current server load in percent (0 - no load information)
+	Load int `json:"load,omitempty"`
 
 	Hosts []WireGuardServerHostInfo `json:"hosts"`
 }
@@ -47,6 +49,11 @@ type OpenvpnServerInfo struct {
 	Country     string   `json:"country"`
 	City        string   `json:"city"`
 	IPAddresses []string `json:"ip_addresses"`
+	// Load - current server load in percent (0 - no load information)
+	Load int `json:"load,omitempty"`
+	// Obfs4Cert - obfs4 bridge certificate of the server
+	// (empty when the server has no obfs4 support)
+	Obfs4Cert string `json:"obfs4_cert"`
 }
 
 // DNSInfo contains info about DNS server
@@ -66,10 +73,23 @@ type InfoAPI struct {
 	IPAddresses []string `json:"ips"`
 }
 
+// PortInfo describes a single server connection port
+type PortInfo struct {
+	Type string `json:"type"` // 'TCP' or 'UDP'
+	Port int    `json:"port"`
+}
+
+// PortsInfo - available server connection ports (per VPN protocol)
+type PortsInfo struct {
+	OpenVPN   []PortInfo `json:"openvpn"`
+	WireGuard []PortInfo `json:"wireguard"`
+}
+
 // ConfigInfo contains different configuration info (Antitracker, API ...)
 type ConfigInfo struct {
 	Antitracker AntitrackerInfo `json:"antitracker"`
 	API         InfoAPI         `json:"api"`
+	Ports       PortsInfo       `json:"ports"`
 }
 
 // ServersInfoResponse all info from servers.json
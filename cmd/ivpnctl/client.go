@@ -0,0 +1,371 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/protocol/types"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+)
+
+// Client - connection to the IVPN daemon
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	reqIdx int
+}
+
+// connectToDaemon establishes connection to the daemon and performs 'Hello' handshake
+func connectToDaemon() (*Client, error) {
+	port, secret, err := readDaemonPortAndSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", fmt.Sprintf("127.0.0.1:%d", port), time.Second*5)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+
+	client := &Client{conn: conn, reader: bufio.NewReader(conn)}
+
+	// authenticate
+	// KeepDaemonAlone==true: VPN stays connected when ivpnctl exits
+	helloReq := types.Hello{Secret: secret, Version: "ivpnctl", KeepDaemonAlone: true}
+	if err := client.sendRequest(&helloReq); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if _, err := client.waitForResponse("HelloResp", time.Second*10); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+
+	return client, nil
+}
+
+// Close closes connection to the daemon
+func (c *Client) Close() {
+	c.conn.Close()
+}
+
+// readDaemonPortAndSecret reads daemon connection info ("port:secret") from the service port file
+func readDaemonPortAndSecret() (port int, secret uint64, err error) {
+	data, err := ioutil.ReadFile(platform.ServicePortFile())
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to read daemon port file (is the daemon running? are you root?): %w", err)
+	}
+
+	parts := strings.Split(strings.TrimSpace(string(data)), ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected daemon port file format")
+	}
+
+	port, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to parse daemon port: %w", err)
+	}
+	secret, err = strconv.ParseUint(parts[1], 16, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to parse daemon connection secret: %w", err)
+	}
+
+	return port, secret, nil
+}
+
+// sendRequest initializes 'Idx' of a request and sends it to the daemon
+func (c *Client) sendRequest(request interface{}) error {
+	c.reqIdx++
+	return types.Send(c.conn, request, c.reqIdx)
+}
+
+// waitForResponse reads responses from the daemon until a message of the expected
+// command type arrives (ignoring unsolicited notifications).
+// 'ErrorResp' is converted into an error.
+func (c *Client) waitForResponse(expectedCommand string, timeout time.Duration) ([]byte, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	for {
+		message, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("failed to receive response from daemon: %w", err)
+		}
+
+		messageData := []byte(message)
+		cmd, err := types.GetCommandBase(messageData)
+		if err != nil {
+			return nil, err
+		}
+
+		if cmd.Command == "ErrorResp" {
+			var errResp types.ErrorResp
+			if err := json.Unmarshal(messageData, &errResp); err != nil {
+				return nil, fmt.Errorf("daemon returned an error")
+			}
+			return nil, fmt.Errorf("daemon returned an error: %s", errResp.ErrorMessage)
+		}
+
+		if cmd.Command == expectedCommand {
+			return messageData, nil
+		}
+		// ignoring unsolicited notification
+	}
+}
+
+// CmdStatus prints current VPN connection state
+func (c *Client) CmdStatus() error {
+	if err := c.sendRequest(&types.GetVPNState{}); err != nil {
+		return err
+	}
+
+	// response type depends on the current state
+	c.conn.SetReadDeadline(time.Now().Add(time.Second * 10))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	for {
+		message, err := c.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to receive response from daemon: %w", err)
+		}
+		messageData := []byte(message)
+		cmd, err := types.GetCommandBase(messageData)
+		if err != nil {
+			return err
+		}
+
+		switch cmd.Command {
+		case "ConnectedResp":
+			var resp types.ConnectedResp
+			if err := json.Unmarshal(messageData, &resp); err != nil {
+				return err
+			}
+			fmt.Println("CONNECTED")
+			fmt.Println("    VPN type  :", resp.VpnType)
+			fmt.Println("    Client IP :", resp.ClientIP)
+			fmt.Println("    Server IP :", resp.ServerIP)
+			fmt.Println("    Connected :", time.Unix(resp.TimeSecFrom1970, 0))
+			if len(resp.ManualDNS) > 0 {
+				fmt.Println("    Manual DNS:", resp.ManualDNS)
+			}
+			return nil
+		case "DisconnectedResp":
+			fmt.Println("DISCONNECTED")
+			return nil
+		case "VpnStateResp":
+			var resp types.VpnStateResp
+			if err := json.Unmarshal(messageData, &resp); err != nil {
+				return err
+			}
+			fmt.Println(resp.State)
+			return nil
+		default:
+			// ignoring unsolicited notification
+		}
+	}
+}
+
+// CmdServers prints list of VPN servers
+func (c *Client) CmdServers() error {
+	if err := c.sendRequest(&types.GetServers{}); err != nil {
+		return err
+	}
+	messageData, err := c.waitForResponse("ServerListResp", time.Second*30)
+	if err != nil {
+		return err
+	}
+
+	var resp types.ServerListResp
+	if err := json.Unmarshal(messageData, &resp); err != nil {
+		return err
+	}
+
+	fmt.Println("WireGuard servers:")
+	for _, s := range resp.VpnServers.WireguardServers {
+		fmt.Printf("    %-24s %s, %s\n", s.Gateway, s.City, s.CountryCode)
+	}
+	fmt.Println("OpenVPN servers:")
+	for _, s := range resp.VpnServers.OpenvpnServers {
+		fmt.Printf("    %-24s %s, %s\n", s.Gateway, s.City, s.CountryCode)
+	}
+	return nil
+}
+
+// CmdConnect establishes WireGuard connection to a server defined by gateway name
+func (c *Client) CmdConnect(gateway string) error {
+	if err := c.sendRequest(&types.GetServers{}); err != nil {
+		return err
+	}
+	messageData, err := c.waitForResponse("ServerListResp", time.Second*30)
+	if err != nil {
+		return err
+	}
+	var servers types.ServerListResp
+	if err := json.Unmarshal(messageData, &servers); err != nil {
+		return err
+	}
+
+	connectReq := types.Connect{VpnType: vpn.WireGuard}
+	isServerFound := false
+	for _, s := range servers.VpnServers.WireguardServers {
+		if strings.HasPrefix(strings.ToLower(s.Gateway), strings.ToLower(gateway)) == false {
+			continue
+		}
+		for _, h := range s.Hosts {
+			connectReq.WireGuardParameters.EntryVpnServer.Hosts = append(
+				connectReq.WireGuardParameters.EntryVpnServer.Hosts,
+				types.WGHost{Host: h.Host, PublicKey: h.PublicKey, LocalIP: h.LocalIP})
+		}
+		isServerFound = true
+		break
+	}
+	if isServerFound == false {
+		return fmt.Errorf("server '%s' not found (see 'ivpnctl servers')", gateway)
+	}
+
+	fmt.Println("Connecting...")
+	if err := c.sendRequest(&connectReq); err != nil {
+		return err
+	}
+
+	// waiting for connection result
+	c.conn.SetReadDeadline(time.Now().Add(time.Minute * 3))
+	defer c.conn.SetReadDeadline(time.Time{})
+	for {
+		message, err := c.reader.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("failed to receive response from daemon: %w", err)
+		}
+		messageData := []byte(message)
+		cmd, err := types.GetCommandBase(messageData)
+		if err != nil {
+			return err
+		}
+
+		switch cmd.Command {
+		case "ConnectedResp":
+			var resp types.ConnectedResp
+			if err := json.Unmarshal(messageData, &resp); err != nil {
+				return err
+			}
+			fmt.Println("CONNECTED (client IP: " + resp.ClientIP + ")")
+			return nil
+		case "DisconnectedResp":
+			var resp types.DisconnectedResp
+			if err := json.Unmarshal(messageData, &resp); err != nil {
+				return err
+			}
+			return fmt.Errorf("connection failed: %s", resp.ReasonDescription)
+		case "ErrorResp":
+			var resp types.ErrorResp
+			if err := json.Unmarshal(messageData, &resp); err != nil {
+				return err
+			}
+			return fmt.Errorf("connection failed: %s", resp.ErrorMessage)
+		case "VpnStateResp":
+			var resp types.VpnStateResp
+			if err := json.Unmarshal(messageData, &resp); err == nil {
+				fmt.Println("    ...", resp.State)
+			}
+		default:
+			// ignoring unsolicited notification
+		}
+	}
+}
+
+// CmdDisconnect disconnects active VPN connection
+func (c *Client) CmdDisconnect() error {
+	if err := c.sendRequest(&types.Disconnect{}); err != nil {
+		return err
+	}
+	if _, err := c.waitForResponse("DisconnectedResp", time.Second*30); err != nil {
+		return err
+	}
+	fmt.Println("DISCONNECTED")
+	return nil
+}
+
+// CmdFirewall controls the kill-switch
+func (c *Client) CmdFirewall(arg string) error {
+	switch arg {
+	case "on", "off":
+		if err := c.sendRequest(&types.KillSwitchSetEnabled{IsEnabled: arg == "on"}); err != nil {
+			return err
+		}
+		if _, err := c.waitForResponse("EmptyResp", time.Second*10); err != nil {
+			return err
+		}
+	case "status":
+		// request status (response processed below)
+	default:
+		return fmt.Errorf("unsupported firewall command '%s' (expected: on|off|status)", arg)
+	}
+
+	if err := c.sendRequest(&types.KillSwitchGetStatus{}); err != nil {
+		return err
+	}
+	messageData, err := c.waitForResponse("KillSwitchStatusResp", time.Second*10)
+	if err != nil {
+		return err
+	}
+	var resp types.KillSwitchStatusResp
+	if err := json.Unmarshal(messageData, &resp); err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if resp.IsEnabled {
+		state = "enabled"
+	}
+	fmt.Println("Firewall:", state)
+	fmt.Println("    Persistent     :", resp.IsPersistent)
+	fmt.Println("    Allow LAN      :", resp.IsAllowLAN)
+	fmt.Println("    Allow multicast:", resp.IsAllowMulticast)
+	return nil
+}
+
+// CmdLogs prints the daemon log
+func (c *Client) CmdLogs() error {
+	if err := c.sendRequest(&types.GenerateDiagnostics{}); err != nil {
+		return err
+	}
+	messageData, err := c.waitForResponse("DiagnosticsGeneratedResp", time.Second*30)
+	if err != nil {
+		return err
+	}
+	var resp types.DiagnosticsGeneratedResp
+	if err := json.Unmarshal(messageData, &resp); err != nil {
+		return err
+	}
+	fmt.Println(resp.ServiceLog)
+	return nil
+}
@@ -0,0 +1,92 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// ivpnctl is a minimalistic command-line client for the IVPN daemon.
+// It communicates with the daemon using the same JSON protocol as the official UI,
+// so headless users and scripts can control the daemon without the Electron UI.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func printUsage() {
+	fmt.Println("Usage: ivpnctl <command> [arguments]")
+	fmt.Println("")
+	fmt.Println("Commands:")
+	fmt.Println("  status                  show current VPN connection state")
+	fmt.Println("  servers                 print list of VPN servers")
+	fmt.Println("  connect <gateway>       connect to a server (WireGuard; gateway from 'servers' output)")
+	fmt.Println("  disconnect              disconnect active VPN connection")
+	fmt.Println("  firewall <on|off|status> control the kill-switch")
+	fmt.Println("  logs                    print daemon log")
+	fmt.Println("")
+	fmt.Println("Note: access to the daemon requires privileged rights (to read the daemon port file)")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	client, err := connectToDaemon()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: failed to connect to IVPN daemon:", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	switch strings.ToLower(os.Args[1]) {
+	case "status":
+		err = client.CmdStatus()
+	case "servers":
+		err = client.CmdServers()
+	case "connect":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "Error: 'connect' requires a server gateway argument (see 'ivpnctl servers')")
+			os.Exit(1)
+		}
+		err = client.CmdConnect(os.Args[2])
+	case "disconnect":
+		err = client.CmdDisconnect()
+	case "firewall":
+		arg := "status"
+		if len(os.Args) >= 3 {
+			arg = strings.ToLower(os.Args[2])
+		}
+		err = client.CmdFirewall(arg)
+	case "logs":
+		err = client.CmdLogs()
+	default:
+		fmt.Fprintln(os.Stderr, "Error: unsupported command:", os.Args[1])
+		printUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
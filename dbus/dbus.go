@@ -0,0 +1,64 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package dbus - system D-Bus integration of the daemon (Linux only).
+// Core operations (status, connect, disconnect, kill-switch) are exposed on the
+// system bus as 'net.ivpn.Daemon' so GNOME\KDE applets and NetworkManager-adjacent
+// tooling can integrate without speaking the custom TCP protocol.
+// Mutating operations are authorized via polkit (action 'net.ivpn.daemon.manage').
+// The required D-Bus wire protocol subset is implemented directly (no external dependencies).
+package dbus
+
+import (
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("dbus")
+}
+
+// Requests - daemon operations which are exposed on the bus
+type Requests interface {
+	// Status - current daemon state (JSON)
+	Status() string
+	// ConnectVPN - establish VPN connection using the last known connection parameters
+	ConnectVPN() error
+	// DisconnectVPN - disconnect VPN
+	DisconnectVPN() error
+	// KillSwitchEnabled - current kill-switch (firewall) state
+	KillSwitchEnabled() (bool, error)
+	// SetKillSwitchEnabled - enable\disable kill-switch (firewall)
+	SetKillSwitchEnabled(isEnabled bool) error
+}
+
+// Start - register the daemon service on the system bus (asynchronous)
+// Does nothing on platforms without D-Bus
+func Start(requests Requests) error {
+	return doStart(requests)
+}
+
+// Stop - unregister from the bus (do nothing when not started)
+func Stop() {
+	doStop()
+}
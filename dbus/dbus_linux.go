@@ -0,0 +1,823 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dbus
+
+// Minimal implementation of the D-Bus wire protocol (little-endian subset of the
+// specification: https://dbus.freedesktop.org/doc/dbus-specification.html).
+// Only the types required for the exposed interface and the polkit authorization
+// call are supported: BYTE, BOOLEAN, UINT32, STRING, OBJECT_PATH, SIGNATURE,
+// VARIANT, ARRAY, STRUCT and DICT_ENTRY.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	_busSocketPath = "/var/run/dbus/system_bus_socket"
+
+	_busName       = "net.ivpn.Daemon"
+	_objectPath    = "/net/ivpn/Daemon"
+	_interfaceName = "net.ivpn.Daemon"
+
+	// polkit action required for the mutating operations (connect\disconnect\kill-switch)
+	_polkitActionManage = "net.ivpn.daemon.manage"
+
+	_methodCallTimeout = time.Second * 25
+)
+
+// D-Bus message types
+const (
+	_msgTypeMethodCall   = 1
+	_msgTypeMethodReturn = 2
+	_msgTypeError        = 3
+)
+
+// D-Bus header field codes
+const (
+	_fieldPath        = 1
+	_fieldInterface   = 2
+	_fieldMember      = 3
+	_fieldErrorName   = 4
+	_fieldReplySerial = 5
+	_fieldDestination = 6
+	_fieldSender      = 7
+	_fieldSignature   = 8
+)
+
+const _introspectionXML = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+  <interface name="net.ivpn.Daemon">
+    <method name="Status"><arg name="statusJSON" type="s" direction="out"/></method>
+    <method name="Connect"/>
+    <method name="Disconnect"/>
+    <method name="KillSwitchEnabled"><arg name="isEnabled" type="b" direction="out"/></method>
+    <method name="SetKillSwitchEnabled"><arg name="isEnabled" type="b" direction="in"/></method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Introspectable">
+    <method name="Introspect"><arg name="xml" type="s" direction="out"/></method>
+  </interface>
+  <interface name="org.freedesktop.DBus.Peer">
+    <method name="Ping"/>
+  </interface>
+</node>`
+
+var (
+	activeConnMutex sync.Mutex
+	activeConn      *busConnection
+)
+
+func doStart(requests Requests) error {
+	activeConnMutex.Lock()
+	defer activeConnMutex.Unlock()
+
+	if activeConn != nil {
+		return nil // already started
+	}
+
+	// the bus policy and the polkit action definition must be in place before
+	// RequestName below (the system bus is default-deny). Best effort: on a
+	// read-only filesystem the installer is expected to have placed the files
+	if err := InstallPolicyFiles(); err != nil {
+		log.Warning("Failed to install D-Bus policy files: ", err)
+	}
+
+	conn, err := connectAndAuthenticate()
+	if err != nil {
+		return err
+	}
+
+	c := &busConnection{
+		conn:     conn,
+		requests: requests,
+		pending:  make(map[uint32]chan *busMessage)}
+
+	go c.readLoop()
+
+	// org.freedesktop.DBus.Hello - obtain the unique connection name
+	if _, err := c.methodCall("org.freedesktop.DBus", "/org/freedesktop/DBus",
+		"org.freedesktop.DBus", "Hello", "", nil); err != nil {
+		conn.Close()
+		return fmt.Errorf("D-Bus Hello failed: %w", err)
+	}
+
+	// request the well-known bus name
+	nameArgs := newEncoder()
+	nameArgs.putString(_busName)
+	nameArgs.putUint32(0x4) // DBUS_NAME_FLAG_DO_NOT_QUEUE
+	reply, err := c.methodCall("org.freedesktop.DBus", "/org/freedesktop/DBus",
+		"org.freedesktop.DBus", "RequestName", "su", nameArgs.data)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to request bus name: %w", err)
+	}
+	replyDecoder := newDecoder(reply.body)
+	if result, err := replyDecoder.getUint32(); err != nil || result != 1 /*DBUS_REQUEST_NAME_REPLY_PRIMARY_OWNER*/ {
+		conn.Close()
+		return fmt.Errorf("failed to become the owner of the bus name '%s' (is another daemon instance running?)", _busName)
+	}
+
+	activeConn = c
+	log.Info("D-Bus service registered: ", _busName)
+	return nil
+}
+
+func doStop() {
+	activeConnMutex.Lock()
+	defer activeConnMutex.Unlock()
+
+	if activeConn == nil {
+		return
+	}
+	activeConn.isStopped = true
+	activeConn.conn.Close()
+	activeConn = nil
+}
+
+// connectAndAuthenticate - connect to the system bus socket and pass the
+// SASL EXTERNAL authentication (credentials of the daemon process)
+func connectAndAuthenticate() (net.Conn, error) {
+	conn, err := net.Dial("unix", _busSocketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the system bus: %w", err)
+	}
+
+	// uid as a hex-encoded decimal string (required by the EXTERNAL mechanism)
+	uid := fmt.Sprintf("%d", os.Getuid())
+	uidHex := fmt.Sprintf("%x", []byte(uid))
+
+	if _, err := conn.Write([]byte("\x00AUTH EXTERNAL " + uidHex + "\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bus authentication failed: %w", err)
+	}
+
+	line, err := readAuthLine(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bus authentication failed: %w", err)
+	}
+	if strings.HasPrefix(line, "OK ") == false {
+		conn.Close()
+		return nil, fmt.Errorf("bus authentication rejected: %s", line)
+	}
+
+	if _, err := conn.Write([]byte("BEGIN\r\n")); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("bus authentication failed: %w", err)
+	}
+	return conn, nil
+}
+
+func readAuthLine(conn net.Conn) (string, error) {
+	line := make([]byte, 0, 64)
+	buff := make([]byte, 1)
+	for {
+		if _, err := conn.Read(buff); err != nil {
+			return "", err
+		}
+		if buff[0] == '\n' {
+			return strings.TrimRight(string(line), "\r"), nil
+		}
+		line = append(line, buff[0])
+	}
+}
+
+// ---------------------------------------------------------------------------
+// bus connection
+// ---------------------------------------------------------------------------
+
+type busConnection struct {
+	conn      net.Conn
+	requests  Requests
+	isStopped bool
+
+	serialMutex sync.Mutex
+	lastSerial  uint32
+
+	pendingMutex sync.Mutex
+	pending      map[uint32]chan *busMessage
+
+	writeMutex sync.Mutex
+}
+
+type busMessage struct {
+	msgType byte
+	serial  uint32
+	// header fields: code -> value (string for s\o\g fields, uint32 for u fields)
+	fields map[byte]interface{}
+	body   []byte
+}
+
+func (m *busMessage) stringField(code byte) string {
+	if val, ok := m.fields[code].(string); ok {
+		return val
+	}
+	return ""
+}
+
+func (c *busConnection) nextSerial() uint32 {
+	c.serialMutex.Lock()
+	defer c.serialMutex.Unlock()
+	c.lastSerial++
+	return c.lastSerial
+}
+
+// readLoop - receive and dispatch incoming messages
+func (c *busConnection) readLoop() {
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			if c.isStopped == false {
+				log.Error("D-Bus connection closed: ", err)
+			}
+			return
+		}
+
+		switch msg.msgType {
+		case _msgTypeMethodReturn, _msgTypeError:
+			// route the reply to the waiting request
+			if replySerial, ok := msg.fields[_fieldReplySerial].(uint32); ok {
+				c.pendingMutex.Lock()
+				replyChan := c.pending[replySerial]
+				delete(c.pending, replySerial)
+				c.pendingMutex.Unlock()
+				if replyChan != nil {
+					replyChan <- msg
+				}
+			}
+		case _msgTypeMethodCall:
+			go c.handleMethodCall(msg)
+		default:
+			// signals etc. - nothing to do
+		}
+	}
+}
+
+// readMessage - read one complete message from the bus
+func (c *busConnection) readMessage() (*busMessage, error) {
+	// fixed part of the header + length of the header fields array
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(c.conn, fixed); err != nil {
+		return nil, err
+	}
+	if fixed[0] != 'l' {
+		return nil, fmt.Errorf("unsupported message endianness: '%c'", fixed[0])
+	}
+
+	bodyLen := binary.LittleEndian.Uint32(fixed[4:8])
+	fieldsLen := binary.LittleEndian.Uint32(fixed[12:16])
+
+	// the header is padded to the 8-byte boundary; the body follows
+	headerEnd := 16 + int(fieldsLen)
+	padding := (8 - headerEnd%8) % 8
+	restLen := int(fieldsLen) + padding + int(bodyLen)
+	if restLen > 1024*1024*16 {
+		return nil, fmt.Errorf("message is too big (%d bytes)", restLen)
+	}
+
+	rest := make([]byte, restLen)
+	if _, err := io.ReadFull(c.conn, rest); err != nil {
+		return nil, err
+	}
+
+	msg := &busMessage{
+		msgType: fixed[1],
+		serial:  binary.LittleEndian.Uint32(fixed[8:12]),
+		fields:  make(map[byte]interface{}),
+		body:    rest[int(fieldsLen)+padding:]}
+
+	// parse the header fields: ARRAY of STRUCT(BYTE, VARIANT)
+	fieldsDecoder := newDecoder(rest[:fieldsLen])
+	for fieldsDecoder.isEOF() == false {
+		if err := fieldsDecoder.align(8); err != nil {
+			break
+		}
+		if fieldsDecoder.isEOF() {
+			break
+		}
+		code, err := fieldsDecoder.getByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse message header: %w", err)
+		}
+		value, err := fieldsDecoder.getVariant()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse message header: %w", err)
+		}
+		msg.fields[code] = value
+	}
+
+	return msg, nil
+}
+
+// methodCall - send a method call and wait for the reply
+func (c *busConnection) methodCall(destination string, path string, iface string, member string, signature string, body []byte) (*busMessage, error) {
+	serial := c.nextSerial()
+
+	fields := []headerField{
+		{_fieldPath, "o", path},
+		{_fieldDestination, "s", destination},
+		{_fieldInterface, "s", iface},
+		{_fieldMember, "s", member}}
+	if len(signature) > 0 {
+		fields = append(fields, headerField{_fieldSignature, "g", signature})
+	}
+
+	replyChan := make(chan *busMessage, 1)
+	c.pendingMutex.Lock()
+	c.pending[serial] = replyChan
+	c.pendingMutex.Unlock()
+
+	if err := c.sendMessage(_msgTypeMethodCall, serial, fields, body); err != nil {
+		c.pendingMutex.Lock()
+		delete(c.pending, serial)
+		c.pendingMutex.Unlock()
+		return nil, err
+	}
+
+	select {
+	case reply := <-replyChan:
+		if reply.msgType == _msgTypeError {
+			return nil, fmt.Errorf("D-Bus error: %s", reply.stringField(_fieldErrorName))
+		}
+		return reply, nil
+	case <-time.After(_methodCallTimeout):
+		c.pendingMutex.Lock()
+		delete(c.pending, serial)
+		c.pendingMutex.Unlock()
+		return nil, fmt.Errorf("D-Bus method call timeout (%s.%s)", iface, member)
+	}
+}
+
+type headerField struct {
+	code  byte
+	sig   string
+	value string
+}
+
+// sendMessage - marshal and send one message
+func (c *busConnection) sendMessage(msgType byte, serial uint32, fields []headerField, body []byte) error {
+	e := newEncoder()
+	e.putByte('l') // little-endian
+	e.putByte(msgType)
+	e.putByte(0) // flags
+	e.putByte(1) // protocol version
+	e.putUint32(uint32(len(body)))
+	e.putUint32(serial)
+
+	// header fields: ARRAY of STRUCT(BYTE, VARIANT)
+	lengthPos := len(e.data)
+	e.putUint32(0) // array length (backfilled below)
+	arrayStart := len(e.data)
+	for _, field := range fields {
+		e.align(8)
+		e.putByte(field.code)
+		e.putSignature(field.sig)
+		switch field.sig {
+		case "s", "o":
+			e.putString(field.value)
+		case "g":
+			e.putSignature(field.value)
+		}
+	}
+	binary.LittleEndian.PutUint32(e.data[lengthPos:], uint32(len(e.data)-arrayStart))
+
+	// the header ends on the 8-byte boundary
+	e.align(8)
+	message := append(e.data, body...)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err := c.conn.Write(message)
+	return err
+}
+
+// sendReply - reply to a method call ('signature' and 'body' can be empty)
+func (c *busConnection) sendReply(request *busMessage, signature string, body []byte) {
+	fields := []headerField{
+		{_fieldDestination, "s", request.stringField(_fieldSender)}}
+	if len(signature) > 0 {
+		fields = append(fields, headerField{_fieldSignature, "g", signature})
+	}
+	// reply_serial is an UINT32 field - marshaled separately
+	e := newEncoder()
+	e.putByte('l')
+	e.putByte(_msgTypeMethodReturn)
+	e.putByte(0)
+	e.putByte(1)
+	e.putUint32(uint32(len(body)))
+	e.putUint32(c.nextSerial())
+
+	lengthPos := len(e.data)
+	e.putUint32(0)
+	arrayStart := len(e.data)
+
+	e.align(8)
+	e.putByte(_fieldReplySerial)
+	e.putSignature("u")
+	e.putUint32(request.serial)
+
+	for _, field := range fields {
+		e.align(8)
+		e.putByte(field.code)
+		e.putSignature(field.sig)
+		switch field.sig {
+		case "s", "o":
+			e.putString(field.value)
+		case "g":
+			e.putSignature(field.value)
+		}
+	}
+	binary.LittleEndian.PutUint32(e.data[lengthPos:], uint32(len(e.data)-arrayStart))
+
+	e.align(8)
+	message := append(e.data, body...)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if _, err := c.conn.Write(message); err != nil {
+		log.Error("Failed to send D-Bus reply: ", err)
+	}
+}
+
+// sendErrorReply - reply with a D-Bus error
+func (c *busConnection) sendErrorReply(request *busMessage, errorName string, description string) {
+	e := newEncoder()
+	e.putByte('l')
+	e.putByte(_msgTypeError)
+	e.putByte(0)
+	e.putByte(1)
+
+	bodyEncoder := newEncoder()
+	bodyEncoder.putString(description)
+	body := bodyEncoder.data
+
+	e.putUint32(uint32(len(body)))
+	e.putUint32(c.nextSerial())
+
+	lengthPos := len(e.data)
+	e.putUint32(0)
+	arrayStart := len(e.data)
+
+	e.align(8)
+	e.putByte(_fieldReplySerial)
+	e.putSignature("u")
+	e.putUint32(request.serial)
+
+	e.align(8)
+	e.putByte(_fieldErrorName)
+	e.putSignature("s")
+	e.putString(errorName)
+
+	e.align(8)
+	e.putByte(_fieldDestination)
+	e.putSignature("s")
+	e.putString(request.stringField(_fieldSender))
+
+	e.align(8)
+	e.putByte(_fieldSignature)
+	e.putSignature("g")
+	e.putSignature("s")
+
+	binary.LittleEndian.PutUint32(e.data[lengthPos:], uint32(len(e.data)-arrayStart))
+
+	e.align(8)
+	message := append(e.data, body...)
+
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	if _, err := c.conn.Write(message); err != nil {
+		log.Error("Failed to send D-Bus error reply: ", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// exposed interface
+// ---------------------------------------------------------------------------
+
+func (c *busConnection) handleMethodCall(msg *busMessage) {
+	iface := msg.stringField(_fieldInterface)
+	member := msg.stringField(_fieldMember)
+
+	switch iface {
+	case "org.freedesktop.DBus.Peer":
+		if member == "Ping" {
+			c.sendReply(msg, "", nil)
+			return
+		}
+	case "org.freedesktop.DBus.Introspectable":
+		if member == "Introspect" {
+			e := newEncoder()
+			e.putString(_introspectionXML)
+			c.sendReply(msg, "s", e.data)
+			return
+		}
+	case _interfaceName:
+		c.handleDaemonMethodCall(msg, member)
+		return
+	}
+
+	c.sendErrorReply(msg, "org.freedesktop.DBus.Error.UnknownMethod",
+		fmt.Sprintf("unknown method: %s.%s", iface, member))
+}
+
+func (c *busConnection) handleDaemonMethodCall(msg *busMessage, member string) {
+	// read-only operations: no authorization required
+	if member == "Status" {
+		e := newEncoder()
+		e.putString(c.requests.Status())
+		c.sendReply(msg, "s", e.data)
+		return
+	}
+	if member == "KillSwitchEnabled" {
+		isEnabled, err := c.requests.KillSwitchEnabled()
+		if err != nil {
+			c.sendErrorReply(msg, _interfaceName+".Error.Failed", err.Error())
+			return
+		}
+		e := newEncoder()
+		e.putBool(isEnabled)
+		c.sendReply(msg, "b", e.data)
+		return
+	}
+
+	// mutating operations: polkit authorization of the caller
+	if isAuthorized, err := c.checkPolkitAuthorization(msg.stringField(_fieldSender)); err != nil {
+		c.sendErrorReply(msg, _interfaceName+".Error.AuthorizationFailed", err.Error())
+		return
+	} else if isAuthorized == false {
+		c.sendErrorReply(msg, _interfaceName+".Error.NotAuthorized",
+			fmt.Sprintf("not authorized (polkit action: %s)", _polkitActionManage))
+		return
+	}
+
+	switch member {
+	case "Connect":
+		if err := c.requests.ConnectVPN(); err != nil {
+			c.sendErrorReply(msg, _interfaceName+".Error.Failed", err.Error())
+			return
+		}
+		c.sendReply(msg, "", nil)
+	case "Disconnect":
+		if err := c.requests.DisconnectVPN(); err != nil {
+			c.sendErrorReply(msg, _interfaceName+".Error.Failed", err.Error())
+			return
+		}
+		c.sendReply(msg, "", nil)
+	case "SetKillSwitchEnabled":
+		bodyDecoder := newDecoder(msg.body)
+		isEnabled, err := bodyDecoder.getBool()
+		if err != nil {
+			c.sendErrorReply(msg, "org.freedesktop.DBus.Error.InvalidArgs", "boolean argument expected")
+			return
+		}
+		if err := c.requests.SetKillSwitchEnabled(isEnabled); err != nil {
+			c.sendErrorReply(msg, _interfaceName+".Error.Failed", err.Error())
+			return
+		}
+		c.sendReply(msg, "", nil)
+	default:
+		c.sendErrorReply(msg, "org.freedesktop.DBus.Error.UnknownMethod",
+			fmt.Sprintf("unknown method: %s.%s", _interfaceName, member))
+	}
+}
+
+// checkPolkitAuthorization - ask polkit whether the calling bus client is allowed
+// to perform the daemon management action
+// (org.freedesktop.PolicyKit1.Authority.CheckAuthorization)
+func (c *busConnection) checkPolkitAuthorization(sender string) (bool, error) {
+	if len(sender) == 0 {
+		return false, fmt.Errorf("message sender is not defined")
+	}
+
+	// arguments signature: (sa{sv})sa{ss}us
+	e := newEncoder()
+	// subject: STRUCT ("system-bus-name", {"name": variant(sender)})
+	e.align(8)
+	e.putString("system-bus-name")
+	detailsLengthPos := e.beginArray(8)
+	e.putString("name")
+	e.putSignature("s")
+	e.putString(sender)
+	e.endArray(detailsLengthPos)
+	// action id
+	e.putString(_polkitActionManage)
+	// details: empty a{ss}
+	emptyLengthPos := e.beginArray(8)
+	e.endArray(emptyLengthPos)
+	// flags: 0 (no user interaction: the daemon can not wait for an interactive dialog)
+	e.putUint32(0)
+	// cancellation id
+	e.putString("")
+
+	reply, err := c.methodCall("org.freedesktop.PolicyKit1",
+		"/org/freedesktop/PolicyKit1/Authority",
+		"org.freedesktop.PolicyKit1.Authority",
+		"CheckAuthorization", "(sa{sv})sa{ss}us", e.data)
+	if err != nil {
+		return false, fmt.Errorf("polkit authorization check failed: %w", err)
+	}
+
+	// reply signature: (bba{ss}) - the first boolean is 'is_authorized'
+	replyDecoder := newDecoder(reply.body)
+	if err := replyDecoder.align(8); err != nil {
+		return false, fmt.Errorf("unexpected polkit response: %w", err)
+	}
+	isAuthorized, err := replyDecoder.getBool()
+	if err != nil {
+		return false, fmt.Errorf("unexpected polkit response: %w", err)
+	}
+	return isAuthorized, nil
+}
+
+// ---------------------------------------------------------------------------
+// wire format marshaling
+// ---------------------------------------------------------------------------
+
+type encoder struct {
+	data []byte
+}
+
+func newEncoder() *encoder {
+	return &encoder{data: make([]byte, 0, 128)}
+}
+
+func (e *encoder) align(boundary int) {
+	for len(e.data)%boundary != 0 {
+		e.data = append(e.data, 0)
+	}
+}
+
+func (e *encoder) putByte(b byte) {
+	e.data = append(e.data, b)
+}
+
+func (e *encoder) putUint32(v uint32) {
+	e.align(4)
+	var buff [4]byte
+	binary.LittleEndian.PutUint32(buff[:], v)
+	e.data = append(e.data, buff[:]...)
+}
+
+func (e *encoder) putBool(v bool) {
+	if v {
+		e.putUint32(1)
+	} else {
+		e.putUint32(0)
+	}
+}
+
+func (e *encoder) putString(s string) {
+	e.putUint32(uint32(len(s)))
+	e.data = append(e.data, s...)
+	e.data = append(e.data, 0)
+}
+
+func (e *encoder) putSignature(s string) {
+	e.data = append(e.data, byte(len(s)))
+	e.data = append(e.data, s...)
+	e.data = append(e.data, 0)
+}
+
+// beginArray - start an ARRAY value: the length is backfilled by endArray.
+// 'elementBoundary' - alignment of the element type (8 for structs\dict entries)
+// Returns the position of the length value
+func (e *encoder) beginArray(elementBoundary int) int {
+	e.align(4)
+	lengthPos := len(e.data)
+	e.putUint32(0)
+	e.align(elementBoundary)
+	return lengthPos
+}
+
+func (e *encoder) endArray(lengthPos int) {
+	// array length = content size (without the length value and the alignment padding)
+	contentStart := lengthPos + 4
+	for contentStart%8 != 0 && contentStart < len(e.data) {
+		contentStart++
+	}
+	binary.LittleEndian.PutUint32(e.data[lengthPos:], uint32(len(e.data)-contentStart))
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func newDecoder(data []byte) *decoder {
+	return &decoder{data: data}
+}
+
+func (d *decoder) isEOF() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *decoder) align(boundary int) error {
+	for d.pos%boundary != 0 {
+		if d.pos >= len(d.data) {
+			return io.ErrUnexpectedEOF
+		}
+		d.pos++
+	}
+	return nil
+}
+
+func (d *decoder) getByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) getUint32() (uint32, error) {
+	if err := d.align(4); err != nil {
+		return 0, err
+	}
+	if d.pos+4 > len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	v := binary.LittleEndian.Uint32(d.data[d.pos:])
+	d.pos += 4
+	return v, nil
+}
+
+func (d *decoder) getBool() (bool, error) {
+	v, err := d.getUint32()
+	return v != 0, err
+}
+
+func (d *decoder) getString() (string, error) {
+	length, err := d.getUint32()
+	if err != nil {
+		return "", err
+	}
+	if d.pos+int(length)+1 > len(d.data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := string(d.data[d.pos : d.pos+int(length)])
+	d.pos += int(length) + 1 // + terminating nul
+	return s, nil
+}
+
+func (d *decoder) getSignature() (string, error) {
+	length, err := d.getByte()
+	if err != nil {
+		return "", err
+	}
+	if d.pos+int(length)+1 > len(d.data) {
+		return "", io.ErrUnexpectedEOF
+	}
+	s := string(d.data[d.pos : d.pos+int(length)])
+	d.pos += int(length) + 1
+	return s, nil
+}
+
+// getVariant - read a VARIANT value (subset: the types used in message headers)
+func (d *decoder) getVariant() (interface{}, error) {
+	signature, err := d.getSignature()
+	if err != nil {
+		return nil, err
+	}
+	switch signature {
+	case "s", "o":
+		return d.getString()
+	case "g":
+		return d.getSignature()
+	case "u":
+		return d.getUint32()
+	case "b":
+		return d.getBool()
+	case "y":
+		return d.getByte()
+	default:
+		return nil, fmt.Errorf("unsupported variant type: '%s'", signature)
+	}
+}
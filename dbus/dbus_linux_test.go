@@ -0,0 +1,269 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dbus
+
+// Round-trip tests of the wire format marshaling: everything written by the
+// encoder must read back unchanged through the decoder. The alignment and the
+// array-length backfill logic is exercised on purpose at different start
+// offsets - that is where a silent corruption would hide.
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeScalars(t *testing.T) {
+	e := newEncoder()
+	e.putByte(7) // misalign the following values on purpose
+	e.putUint32(0x01020304)
+	e.putBool(true)
+	e.putString("hello, world")
+	e.putSignature("(sa{sv})sa{ss}us")
+	e.putBool(false)
+	e.putString("") // empty string still has a length and a terminating nul
+
+	// the uint32 after a single byte must be preceded by 3 zero padding bytes
+	if bytes.Equal(e.data[1:4], []byte{0, 0, 0}) == false {
+		t.Errorf("non-zero alignment padding: %v", e.data[:8])
+	}
+
+	d := newDecoder(e.data)
+	if v, err := d.getByte(); err != nil || v != 7 {
+		t.Errorf("byte round-trip failed: %v %v", v, err)
+	}
+	if v, err := d.getUint32(); err != nil || v != 0x01020304 {
+		t.Errorf("uint32 round-trip failed: %v %v", v, err)
+	}
+	if v, err := d.getBool(); err != nil || v != true {
+		t.Errorf("bool round-trip failed: %v %v", v, err)
+	}
+	if v, err := d.getString(); err != nil || v != "hello, world" {
+		t.Errorf("string round-trip failed: '%s' %v", v, err)
+	}
+	if v, err := d.getSignature(); err != nil || v != "(sa{sv})sa{ss}us" {
+		t.Errorf("signature round-trip failed: '%s' %v", v, err)
+	}
+	if v, err := d.getBool(); err != nil || v != false {
+		t.Errorf("bool round-trip failed: %v %v", v, err)
+	}
+	if v, err := d.getString(); err != nil || v != "" {
+		t.Errorf("empty string round-trip failed: '%s' %v", v, err)
+	}
+	if d.isEOF() == false {
+		t.Errorf("not all encoded data consumed (%d of %d bytes)", d.pos, len(d.data))
+	}
+}
+
+func TestAlignmentAtEveryOffset(t *testing.T) {
+	// a uint32 must read back correctly regardless of the start offset
+	for offset := 0; offset < 8; offset++ {
+		e := newEncoder()
+		for i := 0; i < offset; i++ {
+			e.putByte(0xff)
+		}
+		e.putUint32(0xcafe1234)
+
+		d := newDecoder(e.data)
+		for i := 0; i < offset; i++ {
+			if _, err := d.getByte(); err != nil {
+				t.Fatalf("offset %d: %v", offset, err)
+			}
+		}
+		if v, err := d.getUint32(); err != nil || v != 0xcafe1234 {
+			t.Errorf("offset %d: uint32 round-trip failed: %x %v", offset, v, err)
+		}
+	}
+}
+
+func TestArrayLengthBackfill(t *testing.T) {
+	// the polkit subject marshaling pattern: STRUCT ("system-bus-name", a{sv})
+	// followed by more values - the array length must cover exactly the dict content
+	e := newEncoder()
+	e.align(8)
+	e.putString("system-bus-name")
+	lengthPos := e.beginArray(8)
+	e.putString("name")
+	e.putSignature("s")
+	e.putString(":1.42")
+	e.endArray(lengthPos)
+	e.putString("net.ivpn.daemon.manage")
+
+	d := newDecoder(e.data)
+	if err := d.align(8); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.getString(); err != nil || v != "system-bus-name" {
+		t.Fatalf("struct member round-trip failed: '%s' %v", v, err)
+	}
+	arrayLen, err := d.getUint32()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.align(8); err != nil { // dict entries are 8-byte aligned
+		t.Fatal(err)
+	}
+	contentStart := d.pos
+	if v, err := d.getString(); err != nil || v != "name" {
+		t.Fatalf("dict key round-trip failed: '%s' %v", v, err)
+	}
+	if v, err := d.getVariant(); err != nil || v != ":1.42" {
+		t.Fatalf("dict value round-trip failed: '%v' %v", v, err)
+	}
+	if consumed := d.pos - contentStart; consumed != int(arrayLen) {
+		t.Errorf("backfilled array length is %d, content is %d bytes", arrayLen, consumed)
+	}
+	if v, err := d.getString(); err != nil || v != "net.ivpn.daemon.manage" {
+		t.Errorf("value after the array corrupted: '%s' %v", v, err)
+	}
+}
+
+func TestEmptyArrayLengthBackfill(t *testing.T) {
+	// empty a{ss} in the middle of other values (as in the polkit 'details' argument)
+	e := newEncoder()
+	e.putString("before")
+	lengthPos := e.beginArray(8)
+	e.endArray(lengthPos)
+	e.putUint32(42)
+
+	d := newDecoder(e.data)
+	if v, err := d.getString(); err != nil || v != "before" {
+		t.Fatalf("string round-trip failed: '%s' %v", v, err)
+	}
+	if arrayLen, err := d.getUint32(); err != nil || arrayLen != 0 {
+		t.Errorf("empty array length is %d (%v), expected 0", arrayLen, err)
+	}
+	if err := d.align(8); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := d.getUint32(); err != nil || v != 42 {
+		t.Errorf("value after the empty array corrupted: %v %v", v, err)
+	}
+}
+
+func TestMessageRoundTrip(t *testing.T) {
+	// a complete method call + reply exchange over an in-memory connection:
+	// sendMessage\sendReply on one side must parse back by readMessage on the other
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+
+	client := &busConnection{conn: clientSide, pending: make(map[uint32]chan *busMessage)}
+	server := &busConnection{conn: serverSide, pending: make(map[uint32]chan *busMessage)}
+
+	body := newEncoder()
+	body.putString("net.ivpn.Daemon")
+	body.putUint32(0x4)
+
+	sendErrChan := make(chan error, 1)
+	go func() {
+		sendErrChan <- client.sendMessage(_msgTypeMethodCall, 7,
+			[]headerField{
+				{_fieldPath, "o", "/org/freedesktop/DBus"},
+				{_fieldDestination, "s", "org.freedesktop.DBus"},
+				{_fieldInterface, "s", "org.freedesktop.DBus"},
+				{_fieldMember, "s", "RequestName"},
+				{_fieldSignature, "g", "su"}},
+			body.data)
+	}()
+
+	request, err := server.readMessage()
+	if err != nil {
+		t.Fatalf("failed to read the sent message: %v", err)
+	}
+	if err := <-sendErrChan; err != nil {
+		t.Fatalf("failed to send message: %v", err)
+	}
+
+	if request.msgType != _msgTypeMethodCall || request.serial != 7 {
+		t.Errorf("message type\\serial corrupted: %d %d", request.msgType, request.serial)
+	}
+	if v := request.stringField(_fieldPath); v != "/org/freedesktop/DBus" {
+		t.Errorf("path header field corrupted: '%s'", v)
+	}
+	if v := request.stringField(_fieldMember); v != "RequestName" {
+		t.Errorf("member header field corrupted: '%s'", v)
+	}
+	if v := request.stringField(_fieldSignature); v != "su" {
+		t.Errorf("signature header field corrupted: '%s'", v)
+	}
+	if bytes.Equal(request.body, body.data) == false {
+		t.Errorf("message body corrupted: %v != %v", request.body, body.data)
+	}
+	bodyDecoder := newDecoder(request.body)
+	if v, err := bodyDecoder.getString(); err != nil || v != "net.ivpn.Daemon" {
+		t.Errorf("body string corrupted: '%s' %v", v, err)
+	}
+
+	// the reply: the reply_serial is an UINT32 header field (variant type 'u')
+	request.fields[_fieldSender] = ":1.42"
+	replyBody := newEncoder()
+	replyBody.putUint32(1)
+	go server.sendReply(request, "u", replyBody.data)
+
+	reply, err := client.readMessage()
+	if err != nil {
+		t.Fatalf("failed to read the reply: %v", err)
+	}
+	if reply.msgType != _msgTypeMethodReturn {
+		t.Errorf("unexpected reply message type: %d", reply.msgType)
+	}
+	if v, ok := reply.fields[_fieldReplySerial].(uint32); ok == false || v != 7 {
+		t.Errorf("reply_serial header field corrupted: %v", reply.fields[_fieldReplySerial])
+	}
+	if v, err := newDecoder(reply.body).getUint32(); err != nil || v != 1 {
+		t.Errorf("reply body corrupted: %v %v", v, err)
+	}
+}
+
+func TestReadMessageLimits(t *testing.T) {
+	// a message declaring an oversized body must be rejected (not allocated)
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+
+	header := make([]byte, 16)
+	header[0] = 'l'
+	header[1] = _msgTypeMethodCall
+	header[3] = 1
+	binary.LittleEndian.PutUint32(header[4:8], 1024*1024*32) // body length
+	binary.LittleEndian.PutUint32(header[8:12], 1)           // serial
+	go clientSide.Write(header)
+
+	server := &busConnection{conn: serverSide}
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := server.readMessage()
+		errChan <- err
+	}()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Error("oversized message accepted")
+		}
+	case <-time.After(time.Second * 5):
+		t.Error("oversized message not rejected in time")
+	}
+}
@@ -0,0 +1,113 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package dbus
+
+// The system bus is default-deny: without a bus policy the bus daemon refuses
+// to hand out the 'net.ivpn.Daemon' name, and without a registered polkit action
+// every CheckAuthorization call fails. Both configuration files are shipped here
+// and written by the daemon on startup (and by the installer hooks - see the
+// 'installer' package). The bus daemon and polkitd monitor their configuration
+// directories, so no reload is required after installation.
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// system bus policy: root can own the daemon bus name,
+	// any local user can call its methods (polkit authorizes the mutating ones)
+	_busPolicyFilePath = "/etc/dbus-1/system.d/net.ivpn.Daemon.conf"
+	_busPolicyFileData = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <policy user="root">
+    <allow own="net.ivpn.Daemon"/>
+  </policy>
+  <policy context="default">
+    <allow send_destination="net.ivpn.Daemon"/>
+  </policy>
+</busconfig>
+`
+
+	// polkit action definition: registers the 'net.ivpn.daemon.manage' action.
+	// 'allow_active' is 'yes' because the daemon asks polkit without the
+	// 'AllowUserInteraction' flag (it can not wait for an interactive dialog)
+	_polkitPolicyFilePath = "/usr/share/polkit-1/actions/net.ivpn.daemon.policy"
+	_polkitPolicyFileData = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE policyconfig PUBLIC "-//freedesktop//DTD PolicyKit Policy Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/PolicyKit/1/policyconfig.dtd">
+<policyconfig>
+  <vendor>IVPN</vendor>
+  <vendor_url>https://www.ivpn.net</vendor_url>
+  <action id="net.ivpn.daemon.manage">
+    <description>Manage the IVPN daemon</description>
+    <message>Authentication is required to control the VPN connection and the firewall</message>
+    <defaults>
+      <allow_any>auth_admin</allow_any>
+      <allow_inactive>auth_admin</allow_inactive>
+      <allow_active>yes</allow_active>
+    </defaults>
+  </action>
+</policyconfig>
+`
+)
+
+// InstallPolicyFiles - write the system bus policy and the polkit action
+// definition. Idempotent: an up-to-date file is left untouched
+func InstallPolicyFiles() error {
+	files := []struct {
+		path string
+		data string
+	}{
+		{_busPolicyFilePath, _busPolicyFileData},
+		{_polkitPolicyFilePath, _polkitPolicyFileData}}
+
+	for _, file := range files {
+		if oldData, err := ioutil.ReadFile(file.path); err == nil && bytes.Equal(oldData, []byte(file.data)) {
+			continue // already installed
+		}
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return fmt.Errorf("failed to create '%s': %w", filepath.Dir(file.path), err)
+		}
+		if err := ioutil.WriteFile(file.path, []byte(file.data), 0644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+// RemovePolicyFiles - delete the files installed by InstallPolicyFiles()
+// (in use by the uninstaller; a not installed file is not an error)
+func RemovePolicyFiles() error {
+	var retErr error
+	for _, path := range []string{_busPolicyFilePath, _polkitPolicyFilePath} {
+		if err := os.Remove(path); err != nil && os.IsNotExist(err) == false {
+			retErr = fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+	}
+	return retErr
+}
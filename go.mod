@@ -3,6 +3,7 @@ module github.com/ivpn/desktop-app-daemon
 go 1.13
 
 require (
+	github.com/Microsoft/go-winio v0.4.14
 	github.com/fsnotify/fsnotify v1.4.9
 	golang.org/x/net v0.0.0-20200226051749-491c5fce7268
 	golang.org/x/sys v0.0.0-20200327173247-9dae0f8f5775
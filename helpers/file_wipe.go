@@ -0,0 +1,51 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package helpers
+
+import "os"
+
+// WipeFile overwrites the file content with zeros and deletes the file
+// (for files containing sensitive data, e.g. credentials;
+// a best-effort measure: journaling\copy-on-write filesystems can keep the old blocks)
+func WipeFile(filename string) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // nothing to wipe
+		}
+		return err
+	}
+	if info.IsDir() {
+		return nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_WRONLY, 0)
+	if err == nil {
+		zeros := make([]byte, info.Size())
+		file.Write(zeros)
+		file.Sync()
+		file.Close()
+	}
+
+	return os.Remove(filename)
+}
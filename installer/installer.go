@@ -0,0 +1,126 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package installer exposes the operations the platform installers\uninstallers
+// call into the daemon for (through the one-shot maintenance commands):
+// removing the persistent firewall configuration, deleting the session on
+// uninstall, wiping the settings file and unregistering the boot service.
+// The goal: uninstall must leave no kill-switch rules blocking the network
+// and no credentials on disk.
+package installer
+
+import (
+	"fmt"
+
+	"github.com/ivpn/desktop-app-daemon/api"
+	"github.com/ivpn/desktop-app-daemon/helpers"
+	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/service/firewall"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("instl")
+}
+
+// CleanupFirewall - remove the kill-switch rules and the persistent firewall
+// configuration (leftovers able to block connectivity after uninstall)
+func CleanupFirewall() error {
+	if err := firewall.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize firewall: %w", err)
+	}
+	if err := firewall.SetPersistant(false); err != nil {
+		return fmt.Errorf("failed to disable firewall persistence: %w", err)
+	}
+	if err := firewall.SetEnabled(false); err != nil {
+		return fmt.Errorf("failed to disable firewall: %w", err)
+	}
+	return nil
+}
+
+// DeleteSession - logout: delete the session on the API server (best effort)
+// and erase the stored credentials
+func DeleteSession() error {
+	var prefs preferences.Preferences
+	if err := prefs.LoadPreferences(); err != nil {
+		return nil // no settings file - no session to delete
+	}
+
+	session := prefs.Session
+	if session.IsLoggedIn() == false {
+		return nil
+	}
+
+	// best effort: the API can be unreachable during uninstall;
+	// the session will expire on the server anyway
+	if apiObj, err := api.CreateAPI(); err == nil {
+		if err := apiObj.SessionDelete(session.Session); err != nil {
+			log.Warning("Failed to delete session on API server: ", err)
+		}
+	}
+
+	// erase credentials from the settings file
+	prefs.SetSession("", "", "", "", "", "", "")
+	return nil
+}
+
+// WipeSettings - securely delete the settings file and the generated
+// configuration files which can contain credentials
+func WipeSettings() error {
+	var retErr error
+	for _, file := range []string{
+		platform.SettingsFile(),
+		platform.WGConfigFilePath(),
+		platform.OpenvpnProxyAuthFile(),
+		platform.OpenvpnConfigFile()} {
+
+		if len(file) == 0 {
+			continue
+		}
+		if err := helpers.WipeFile(file); err != nil {
+			log.Warning(fmt.Sprintf("Failed to wipe '%s': ", file), err)
+			retErr = err
+		}
+	}
+	return retErr
+}
+
+// UnregisterBootService - unregister the daemon from the OS service manager
+// (systemd\launchd\Windows services)
+func UnregisterBootService() error {
+	return implUnregisterBootService()
+}
+
+// InstallDBusPolicies - install the D-Bus system bus policy and the polkit
+// action definition required by the D-Bus integration of the daemon
+// (no-op on platforms without D-Bus)
+func InstallDBusPolicies() error {
+	return implInstallDBusPolicies()
+}
+
+// RemoveDBusPolicies - remove the files installed by InstallDBusPolicies()
+func RemoveDBusPolicies() error {
+	return implRemoveDBusPolicies()
+}
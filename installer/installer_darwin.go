@@ -0,0 +1,59 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package installer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// launchd configuration of the daemon
+const _launchdPlistLabel = "net.ivpn.daemon"
+const _launchdPlistPath = "/Library/LaunchDaemons/" + _launchdPlistLabel + ".plist"
+
+// implUnregisterBootService - unload and remove the daemon launchd configuration
+func implUnregisterBootService() error {
+	if _, err := os.Stat(_launchdPlistPath); err != nil {
+		if os.IsNotExist(err) {
+			return nil // not registered
+		}
+		return err
+	}
+
+	if err := shell.Exec(log, "launchctl", "unload", _launchdPlistPath); err != nil {
+		return fmt.Errorf("failed to unload launchd configuration: %w", err)
+	}
+	return os.Remove(_launchdPlistPath)
+}
+
+// implInstallDBusPolicies - not applicable on macOS (no system D-Bus)
+func implInstallDBusPolicies() error {
+	return nil
+}
+
+// implRemoveDBusPolicies - not applicable on macOS (no system D-Bus)
+func implRemoveDBusPolicies() error {
+	return nil
+}
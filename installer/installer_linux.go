@@ -0,0 +1,49 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package installer
+
+import (
+	"github.com/ivpn/desktop-app-daemon/dbus"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// name of the daemon systemd unit
+const _systemdUnitName = "ivpn-service"
+
+// implUnregisterBootService - stop and disable the daemon systemd unit
+func implUnregisterBootService() error {
+	// stop is best effort: the unit may not be running
+	shell.Exec(log, "systemctl", "stop", _systemdUnitName)
+	return shell.Exec(log, "systemctl", "disable", _systemdUnitName)
+}
+
+// implInstallDBusPolicies - install the system bus policy and the polkit action
+// definition (without them the D-Bus integration of the daemon is not functional)
+func implInstallDBusPolicies() error {
+	return dbus.InstallPolicyFiles()
+}
+
+// implRemoveDBusPolicies - remove the D-Bus\polkit configuration files
+func implRemoveDBusPolicies() error {
+	return dbus.RemovePolicyFiles()
+}
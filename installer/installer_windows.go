@@ -0,0 +1,47 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package installer
+
+import (
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// name of the daemon Windows service
+const _windowsServiceName = "IVPN Client"
+
+// implUnregisterBootService - stop and delete the daemon Windows service
+func implUnregisterBootService() error {
+	// stop is best effort: the service may not be running
+	shell.Exec(log, "sc", "stop", _windowsServiceName)
+	return shell.Exec(log, "sc", "delete", _windowsServiceName)
+}
+
+// implInstallDBusPolicies - not applicable on Windows (no system D-Bus)
+func implInstallDBusPolicies() error {
+	return nil
+}
+
+// implRemoveDBusPolicies - not applicable on Windows (no system D-Bus)
+func implRemoveDBusPolicies() error {
+	return nil
+}
@@ -26,9 +26,11 @@ import (
 	"fmt"
 	"math/rand"
 	"os"
+	"os/signal"
 	"runtime"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/ivpn/desktop-app-daemon/api"
@@ -37,8 +39,10 @@ import (
 	"github.com/ivpn/desktop-app-daemon/protocol"
 	"github.com/ivpn/desktop-app-daemon/service"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/portforward"
 	"github.com/ivpn/desktop-app-daemon/service/preferences"
 	"github.com/ivpn/desktop-app-daemon/service/wgkeys"
+	"github.com/ivpn/desktop-app-daemon/sysevents"
 	"github.com/ivpn/desktop-app-daemon/version"
 )
 
@@ -87,6 +91,10 @@ func Launch() {
 		var prefs preferences.Preferences
 		if err := prefs.LoadPreferences(); err == nil {
 			logger.Enable(prefs.IsLogging)
+			// system log sink (journald\syslog\eventlog; if configured)
+			if err := logger.SetSystemLogTarget(prefs.SystemLogTarget); err != nil {
+				logger.Warning(err)
+			}
 		}
 	}
 
@@ -131,8 +139,9 @@ func Launch() {
 		openedPort := <-startedOnPortChan
 
 		// for Windows and macOS-debug we need to save port info into a file
+		// only privileged user must be able to read the file (it contains the connection secret)
 		if isNeedToSavePortInFile() == true {
-			file, err := os.Create(platform.ServicePortFile())
+			file, err := os.OpenFile(platform.ServicePortFile(), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
 			if err != nil {
 				logger.Panic(err.Error())
 			}
@@ -154,12 +163,33 @@ func Launch() {
 		logger.Panic(err.Error())
 	}
 
+	// graceful shutdown on SIGTERM\SIGINT (e.g. 'systemctl stop')
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		log.Info(fmt.Sprintf("Received signal: %v. Stopping...", sig))
+		Stop()
+	}()
+
 	// run service
 	launchService(secret, startedOnPortChan)
 }
 
-// Stop the service
+// shutdownWatchdogTimeout - maximum duration of the graceful shutdown;
+// when exceeded (some shutdown step hangs) - the daemon exit is forced
+const shutdownWatchdogTimeout = time.Second * 20
+
+// Stop the service (graceful shutdown: disconnect VPN, restore DNS, flush preferences,
+// notify and close client connections)
 func Stop() {
+	// watchdog: force the daemon to exit when some shutdown step hangs
+	go func() {
+		time.Sleep(shutdownWatchdogTimeout)
+		log.Error(fmt.Sprintf("Graceful shutdown took more than %v. Forcing exit...", shutdownWatchdogTimeout))
+		os.Exit(1)
+	}()
+
 	p := activeProtocol
 	if p != nil {
 		p.Stop()
@@ -183,9 +213,15 @@ func launchService(secret uint64, startedOnPort chan<- int) {
 	// network change detector
 	netDetector := netchange.Create()
 
+	// system events monitor (sleep\wake, network configuration changes)
+	sysEventsMonitor := sysevents.Create()
+
 	// WireGuard keys manager
 	wgKeysMgr := wgkeys.CreateKeysManager(apiObj, platform.WgToolBinaryPath())
 
+	// port-forwarding manager
+	portFwdMgr := portforward.CreateManager(apiObj)
+
 	// communication protocol
 	protocol, err := protocol.CreateProtocol()
 	if err != nil {
@@ -196,7 +232,7 @@ func launchService(secret uint64, startedOnPort chan<- int) {
 	activeProtocol = protocol
 
 	// initialize service
-	serv, err := service.CreateService(protocol, apiObj, updater, netDetector, wgKeysMgr)
+	serv, err := service.CreateService(protocol, apiObj, updater, netDetector, wgKeysMgr, portFwdMgr, sysEventsMonitor)
 	if err != nil {
 		log.Panic("Failed to initialize service:", err)
 	}
@@ -30,17 +30,29 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ivpn/desktop-app-daemon/service/platform"
 )
 
+// log levels ordered by importance (see SetLogLevel)
+const (
+	levelDebug int32 = iota
+	levelInfo
+	levelWarning
+	levelError
+)
+
 var isCanPrintToConsole bool
 var isLoggingEnabled bool
 var filePath string
 var writeMutex sync.Mutex
 var globalLogFile *os.File
 
+// minimum importance level of logged messages (default - log everything)
+var minLogLevel = levelDebug
+
 var log *Logger
 
 func init() {
@@ -98,6 +110,43 @@ func IsEnabled() bool {
 	return isLoggingEnabled
 }
 
+// SetLogLevel - runtime change of the minimum importance level of logged messages.
+// Acceptable levels (everything below the defined level is skipped):
+// 'debug' (default; everything is logged), 'info', 'warning', 'error'
+func SetLogLevel(level string) error {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		atomic.StoreInt32(&minLogLevel, levelDebug)
+	case "info":
+		atomic.StoreInt32(&minLogLevel, levelInfo)
+	case "warning":
+		atomic.StoreInt32(&minLogLevel, levelWarning)
+	case "error":
+		atomic.StoreInt32(&minLogLevel, levelError)
+	default:
+		return fmt.Errorf("unexpected log level: '%s' (acceptable values: 'debug', 'info', 'warning', 'error')", level)
+	}
+	return nil
+}
+
+// GetLogLevel returns the current minimum importance level of logged messages
+func GetLogLevel() string {
+	switch atomic.LoadInt32(&minLogLevel) {
+	case levelInfo:
+		return "info"
+	case levelWarning:
+		return "warning"
+	case levelError:
+		return "error"
+	}
+	return "debug"
+}
+
+// isLevelEnabled - check whether messages of the given level have to be logged
+func isLevelEnabled(level int32) bool {
+	return atomic.LoadInt32(&minLogLevel) <= level
+}
+
 // CanPrintToConsole define if logger can print to console
 //func CanPrintToConsole(isCanPrint bool) {
 //	isCanPrintToConsole = isCanPrint
@@ -242,21 +291,35 @@ func (l *Logger) Panic(v ...interface{}) {
 func (l *Logger) Enable(enable bool) { l.isDisabled = !enable }
 
 func _info(name string, v ...interface{}) {
+	if isLevelEnabled(levelInfo) == false {
+		return
+	}
 	mes, timeStr, _, _ := getLogPrefixes(fmt.Sprint(v...))
 	write(timeStr, name, mes)
+	writeSystemLog(sinkSeverityInfo, name, mes)
 }
 
 func _debug(name string, v ...interface{}) {
+	if isLevelEnabled(levelDebug) == false {
+		return
+	}
 	mes, timeStr, runtimeInfo, _ := getLogPrefixes(fmt.Sprint(v...))
 	write(timeStr, name, "DEBUG", runtimeInfo, mes)
 }
 
 func _warning(name string, v ...interface{}) {
+	if isLevelEnabled(levelWarning) == false {
+		return
+	}
 	mes, timeStr, runtimeInfo, _ := getLogPrefixes(fmt.Sprint(v...))
 	write(timeStr, name, "WARNING", runtimeInfo, mes)
+	writeSystemLog(sinkSeverityWarning, name, mes)
 }
 
 func _trace(name string, v ...interface{}) {
+	if isLevelEnabled(levelDebug) == false {
+		return
+	}
 	mes, timeStr, runtimeInfo, methodInfo := getLogPrefixes(fmt.Sprint(v...))
 	write(timeStr, name, "TRACE", runtimeInfo+methodInfo, mes)
 }
@@ -264,11 +327,13 @@ func _trace(name string, v ...interface{}) {
 func _error(name string, v ...interface{}) {
 	mes, timeStr, runtimeInfo, methodInfo := getLogPrefixes(fmt.Sprint(v...))
 	write(timeStr, name, "ERROR", runtimeInfo+methodInfo, mes)
+	writeSystemLog(sinkSeverityError, name, mes)
 }
 
 func _errorTrace(name string, err error) {
 	mes, timeStr, runtimeInfo, methodInfo := getLogPrefixes(getErrorDetails(err))
 	write(timeStr, name, "ERROR", runtimeInfo+methodInfo, mes)
+	writeSystemLog(sinkSeverityError, name, mes)
 }
 
 func _panic(name string, v ...interface{}) {
@@ -276,6 +341,7 @@ func _panic(name string, v ...interface{}) {
 
 	//fmt.Println(timeStr, "PANIC", runtimeInfo+methodInfo, mes)
 	write(timeStr, name, "PANIC", runtimeInfo+methodInfo, mes)
+	writeSystemLog(sinkSeverityError, name, mes)
 
 	panic(runtimeInfo + methodInfo + ": " + mes)
 }
@@ -321,9 +387,14 @@ func write(fields ...interface{}) {
 	writeMutex.Lock()
 	defer writeMutex.Unlock()
 
+	line := fmt.Sprintln(fields...)
+
+	// live log streaming (if there are subscribers)
+	notifyStream(strings.TrimRight(line, "\n"))
+
 	if isCanPrintToConsole {
 		// printing into console
-		fmt.Println(fields...)
+		fmt.Print(line)
 	}
 
 	if isLoggingEnabled {
@@ -333,7 +404,7 @@ func write(fields ...interface{}) {
 
 		if globalLogFile != nil {
 			// writting into log-file
-			globalLogFile.WriteString(fmt.Sprintln(fields...))
+			globalLogFile.WriteString(line)
 		}
 	}
 }
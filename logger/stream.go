@@ -0,0 +1,65 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package logger
+
+import (
+	"sync"
+)
+
+// Live log streaming: subscribers receive every log line as it is written
+// ('tail -f' style; e.g. the protocol streams the lines to clients which
+// requested it - so the UI can show real-time log entries).
+// The stream is independent from the log-file: it works also when
+// writing to the file is disabled
+
+var streamMutex sync.Mutex
+var streamSubscribers = make(map[chan<- string]struct{})
+
+// SubscribeLogStream - subscribe a channel to receive every log line.
+// The subscriber must consume the channel: when the channel is full
+// the line is dropped for this subscriber (logging never blocks)
+func SubscribeLogStream(subscriber chan<- string) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	streamSubscribers[subscriber] = struct{}{}
+}
+
+// UnsubscribeLogStream - unsubscribe a channel (the channel is not closed)
+func UnsubscribeLogStream(subscriber chan<- string) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+	delete(streamSubscribers, subscriber)
+}
+
+// notifyStream - forward a log line to all stream subscribers (non-blocking)
+func notifyStream(line string) {
+	streamMutex.Lock()
+	defer streamMutex.Unlock()
+
+	for subscriber := range streamSubscribers {
+		select {
+		case subscriber <- line:
+		default: // subscriber is not consuming fast enough - drop the line
+		}
+	}
+}
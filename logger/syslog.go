@@ -0,0 +1,114 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package logger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// System log sinks: in addition to the flat log-file the daemon messages can be
+// duplicated into the system logging facility (so administrators can aggregate
+// daemon events with standard tooling). Available sinks are platform-specific:
+// 'syslog'\'journald' on Linux, 'eventlog' on Windows, 'syslog' on macOS
+// (see 'implCreateSystemLogSink' implementations).
+// Only info\warning\error messages are duplicated (no debug\trace noise)
+// and only while logging is enabled.
+
+// identifier of the daemon messages in the system log
+const _systemLogTag = "ivpn-service"
+
+// severity of a message forwarded into a system log sink
+type sinkSeverity int
+
+const (
+	sinkSeverityInfo sinkSeverity = iota
+	sinkSeverityWarning
+	sinkSeverityError
+)
+
+// systemLogSink - destination of the duplicated log messages
+// (platform-specific implementations)
+type systemLogSink interface {
+	write(severity sinkSeverity, message string) error
+	close()
+}
+
+var systemSinkMutex sync.Mutex
+var systemSink systemLogSink
+var systemSinkTarget string
+
+// SetSystemLogTarget - enable\disable duplication of the daemon log messages
+// into a system log ('' - disabled; for the acceptable target names
+// see the platform-specific 'implCreateSystemLogSink' implementation)
+func SetSystemLogTarget(target string) error {
+	target = strings.TrimSpace(target)
+
+	systemSinkMutex.Lock()
+	defer systemSinkMutex.Unlock()
+
+	if target == systemSinkTarget {
+		return nil
+	}
+
+	if systemSink != nil {
+		systemSink.close()
+		systemSink = nil
+	}
+	systemSinkTarget = ""
+
+	if len(target) == 0 {
+		return nil
+	}
+
+	sink, err := implCreateSystemLogSink(target)
+	if err != nil {
+		return fmt.Errorf("failed to initialize system log sink '%s': %w", target, err)
+	}
+
+	systemSink = sink
+	systemSinkTarget = target
+	return nil
+}
+
+// writeSystemLog - forward a log message into the configured system log sink
+// (no-op when no sink is configured or logging is disabled; write errors are ignored:
+// there is no better place to report them)
+func writeSystemLog(severity sinkSeverity, name string, message string) {
+	if isLoggingEnabled == false {
+		return
+	}
+
+	systemSinkMutex.Lock()
+	defer systemSinkMutex.Unlock()
+
+	if systemSink == nil {
+		return
+	}
+
+	if len(name) > 0 {
+		message = name + " " + message
+	}
+	systemSink.write(severity, message)
+}
@@ -0,0 +1,38 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package logger
+
+import (
+	"fmt"
+)
+
+// implCreateSystemLogSink - acceptable targets:
+// 'syslog' - the system log daemon ('/var/run/syslog'; the messages end up
+// in the unified logging system and are visible in Console.app\'log show')
+func implCreateSystemLogSink(target string) (systemLogSink, error) {
+	switch target {
+	case "syslog":
+		return newSyslogSink("/var/run/syslog")
+	}
+	return nil, fmt.Errorf("unexpected system log target (acceptable values: 'syslog')")
+}
@@ -0,0 +1,76 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package logger
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// implCreateSystemLogSink - acceptable targets:
+// 'syslog' - the local syslog daemon ('/dev/log');
+// 'journald' - the systemd journal (native protocol; structured fields)
+func implCreateSystemLogSink(target string) (systemLogSink, error) {
+	switch target {
+	case "syslog":
+		return newSyslogSink("/dev/log")
+	case "journald":
+		return newJournaldSink()
+	}
+	return nil, fmt.Errorf("unexpected system log target (acceptable values: 'syslog', 'journald')")
+}
+
+// journaldSink - sink writing to the systemd journal
+// (native datagram protocol: 'FIELD=value' lines; no external dependencies involved)
+type journaldSink struct {
+	conn net.Conn
+}
+
+func newJournaldSink() (*journaldSink, error) {
+	conn, err := net.Dial("unixgram", "/run/systemd/journal/socket")
+	if err != nil {
+		return nil, err
+	}
+	return &journaldSink{conn: conn}, nil
+}
+
+func (s *journaldSink) write(severity sinkSeverity, message string) error {
+	priority := 6 // informational
+	switch severity {
+	case sinkSeverityWarning:
+		priority = 4
+	case sinkSeverityError:
+		priority = 3
+	}
+
+	// a multi-line 'MESSAGE' requires the binary field encoding - avoiding it
+	message = strings.Replace(strings.TrimRight(message, "\n"), "\n", " | ", -1)
+
+	_, err := fmt.Fprintf(s.conn, "PRIORITY=%d\nSYSLOG_IDENTIFIER=%s\nMESSAGE=%s\n", priority, _systemLogTag, message)
+	return err
+}
+
+func (s *journaldSink) close() {
+	s.conn.Close()
+}
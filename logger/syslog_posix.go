@@ -0,0 +1,68 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// +build !windows
+
+package logger
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// syslogSink - sink writing to the local syslog daemon socket
+// (RFC 3164 format; facility 'daemon')
+type syslogSink struct {
+	conn net.Conn
+}
+
+// newSyslogSink - connect to the syslog datagram socket
+// (e.g. '/dev/log' on Linux, '/var/run/syslog' on macOS)
+func newSyslogSink(socketPath string) (*syslogSink, error) {
+	conn, err := net.Dial("unixgram", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{conn: conn}, nil
+}
+
+func (s *syslogSink) write(severity sinkSeverity, message string) error {
+	// RFC 3164 severities (facility 'daemon' (3): priority = 3*8 + severity)
+	priority := 3*8 + 6 // informational
+	switch severity {
+	case sinkSeverityWarning:
+		priority = 3*8 + 4
+	case sinkSeverityError:
+		priority = 3*8 + 3
+	}
+
+	// multi-line messages are not acceptable in a syslog datagram
+	message = strings.Replace(strings.TrimRight(message, "\n"), "\n", " | ", -1)
+
+	_, err := fmt.Fprintf(s.conn, "<%d>%s: %s", priority, _systemLogTag, message)
+	return err
+}
+
+func (s *syslogSink) close() {
+	s.conn.Close()
+}
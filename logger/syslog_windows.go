@@ -0,0 +1,73 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package logger
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// name of the event source in the Windows Event Log
+const _eventLogSourceName = "IVPN Service"
+
+// implCreateSystemLogSink - acceptable targets:
+// 'eventlog' - the Windows Event Log (the 'Application' log; source 'IVPN Service')
+func implCreateSystemLogSink(target string) (systemLogSink, error) {
+	switch target {
+	case "eventlog":
+		return newEventLogSink()
+	}
+	return nil, fmt.Errorf("unexpected system log target (acceptable values: 'eventlog')")
+}
+
+// eventLogSink - sink writing to the Windows Event Log
+type eventLogSink struct {
+	elog *eventlog.Log
+}
+
+func newEventLogSink() (*eventLogSink, error) {
+	// register the event source (the error is ignored: normally it means
+	// the source is already registered by a previous run)
+	eventlog.InstallAsEventCreate(_eventLogSourceName, eventlog.Info|eventlog.Warning|eventlog.Error)
+
+	elog, err := eventlog.Open(_eventLogSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return &eventLogSink{elog: elog}, nil
+}
+
+func (s *eventLogSink) write(severity sinkSeverity, message string) error {
+	switch severity {
+	case sinkSeverityWarning:
+		return s.elog.Warning(1, message)
+	case sinkSeverityError:
+		return s.elog.Error(1, message)
+	}
+	return s.elog.Info(1, message)
+}
+
+func (s *eventLogSink) close() {
+	s.elog.Close()
+}
@@ -23,5 +23,11 @@
 package main
 
 func main() {
+	// one-shot maintenance operations (for installers and support scripts);
+	// when processed - the process exits without starting the service loop
+	if processMaintenanceCommands() {
+		return
+	}
+
 	Launch()
 }
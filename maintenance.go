@@ -0,0 +1,189 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/installer"
+	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/service/dns"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/version"
+)
+
+// processMaintenanceCommands - check the command line for a one-shot maintenance
+// subcommand and execute it without starting the service loop.
+// In use by installers\uninstallers and support scripts which need a single
+// deterministic action (e.g. remove leftover firewall rules after uninstall).
+// Returns 'true' when a maintenance command was processed (the process must exit)
+func processMaintenanceCommands() bool {
+	var cmd string
+	for _, arg := range os.Args[1:] {
+		switch strings.ToLower(arg) {
+		case "--cleanup-firewall", "--restore-dns", "--print-environment", "--migrate-settings", "--install-dbus-policies", "--uninstall":
+			cmd = strings.ToLower(arg)
+		}
+	}
+	if len(cmd) == 0 {
+		return false
+	}
+
+	// the maintenance operations log to the console only
+	logger.Enable(true)
+
+	if _, errors := platform.Init(); len(errors) > 0 {
+		for _, e := range errors {
+			fmt.Fprintln(os.Stderr, "Error:", e)
+		}
+		os.Exit(1)
+	}
+
+	var err error
+	switch cmd {
+	case "--cleanup-firewall":
+		err = maintenanceCleanupFirewall()
+	case "--restore-dns":
+		err = maintenanceRestoreDNS()
+	case "--print-environment":
+		err = maintenancePrintEnvironment()
+	case "--migrate-settings":
+		err = maintenanceMigrateSettings()
+	case "--install-dbus-policies":
+		err = maintenanceInstallDBusPolicies()
+	case "--uninstall":
+		err = maintenanceUninstall()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+	return true
+}
+
+// maintenanceCleanupFirewall - remove the kill-switch rules and the persistent
+// firewall configuration (leftovers able to block connectivity after uninstall)
+func maintenanceCleanupFirewall() error {
+	if err := installer.CleanupFirewall(); err != nil {
+		return err
+	}
+	fmt.Println("Firewall configuration removed")
+	return nil
+}
+
+// maintenanceUninstall - the full uninstall sequence:
+// logout, firewall cleanup, boot service unregistration, D-Bus policy removal
+// and settings wipe.
+// Best effort: a failed step is reported but does not stop the remaining steps
+// (uninstall must never leave the kill-switch blocking the network
+// because e.g. the API was unreachable)
+func maintenanceUninstall() error {
+	var retErr error
+	if err := installer.DeleteSession(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to delete session:", err)
+		retErr = err
+	}
+	if err := installer.CleanupFirewall(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to cleanup firewall:", err)
+		retErr = err
+	}
+	if err := installer.UnregisterBootService(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to unregister boot service:", err)
+		retErr = err
+	}
+	if err := installer.RemoveDBusPolicies(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to remove D-Bus policy files:", err)
+		retErr = err
+	}
+	if err := installer.WipeSettings(); err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to wipe settings:", err)
+		retErr = err
+	}
+	if retErr == nil {
+		fmt.Println("Uninstall cleanup finished")
+	}
+	return retErr
+}
+
+// maintenanceInstallDBusPolicies - install the D-Bus bus policy and the polkit
+// action definition (the D-Bus integration of the daemon is not functional
+// without them; the daemon installs them on startup too - this command is for
+// installers which want the files in place before the first daemon start)
+func maintenanceInstallDBusPolicies() error {
+	if err := installer.InstallDBusPolicies(); err != nil {
+		return err
+	}
+	fmt.Println("D-Bus policy files installed")
+	return nil
+}
+
+// maintenanceRestoreDNS - force restoring the system DNS configuration
+// to the OS defaults (e.g. resolv.conf left pointing to the dead tunnel resolver)
+func maintenanceRestoreDNS() error {
+	if err := dns.Initialize(); err != nil {
+		return fmt.Errorf("failed to initialize DNS module: %w", err)
+	}
+	if err := dns.Repair(); err != nil {
+		return fmt.Errorf("failed to restore DNS configuration: %w", err)
+	}
+	fmt.Println("System DNS configuration restored")
+	return nil
+}
+
+// maintenancePrintEnvironment - print the daemon version and the effective
+// platform paths (for support and diagnostics scripts)
+func maintenancePrintEnvironment() error {
+	fmt.Println("version          :", version.GetFullVersion())
+	fmt.Println("os/arch          :", runtime.GOOS+"/"+runtime.GOARCH)
+	fmt.Println("settings file    :", platform.SettingsFile())
+	fmt.Println("servers file     :", platform.ServersFile())
+	fmt.Println("log file         :", platform.LogFile())
+	fmt.Println("openvpn log file :", platform.OpenvpnLogFile())
+	fmt.Println("service port file:", platform.ServicePortFile())
+	fmt.Println("openvpn binary   :", platform.OpenVpnBinaryPath())
+	fmt.Println("obfs4proxy binary:", platform.Obfs4proxyBinaryPath())
+	fmt.Println("wireguard binary :", platform.WgBinaryPath())
+	fmt.Println("wg tool binary   :", platform.WgToolBinaryPath())
+	fmt.Println("wg config file   :", platform.WGConfigFilePath())
+	return nil
+}
+
+// maintenanceMigrateSettings - load the settings file (performing the conversion
+// from an old format, if necessary) and save it back in the current format
+func maintenanceMigrateSettings() error {
+	var prefs preferences.Preferences
+	if err := prefs.LoadPreferences(); err != nil {
+		return fmt.Errorf("failed to load settings: %w", err)
+	}
+	if err := prefs.SavePreferences(); err != nil {
+		return fmt.Errorf("failed to save settings: %w", err)
+	}
+	fmt.Println("Settings migrated ('" + platform.SettingsFile() + "')")
+	return nil
+}
@@ -0,0 +1,141 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package metrics - optional daemon observability endpoint.
+// Counters and gauges are updated from any subsystem via the package-level functions
+// (cheap no-ops in terms of allocations; safe for concurrent use).
+// When enabled - the values are exposed in the Prometheus\OpenMetrics text format
+// on a localhost-only HTTP endpoint ('/metrics').
+// No external dependencies are involved: the text format is generated directly.
+package metrics
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("metric")
+}
+
+var (
+	mutex    sync.Mutex
+	counters = make(map[string]float64)
+	gauges   = make(map[string]float64)
+	server   *http.Server
+)
+
+// IncCounter - increment a counter metric by 1
+func IncCounter(name string) {
+	AddCounter(name, 1)
+}
+
+// AddCounter - increment a counter metric by 'delta'
+func AddCounter(name string, delta float64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	counters[name] += delta
+}
+
+// SetGauge - set the current value of a gauge metric
+func SetGauge(name string, value float64) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	gauges[name] = value
+}
+
+// Start - start the metrics HTTP endpoint on 127.0.0.1:<port>
+// (only local connections are accepted)
+func Start(port int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if server != nil {
+		return nil // already started
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/metrics", handleMetricsRequest)
+
+	srv := &http.Server{Addr: addr, Handler: serveMux}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start metrics endpoint: %w", err)
+	}
+	server = srv
+
+	go func() {
+		log.Info("Metrics endpoint started: http://", addr, "/metrics")
+		if err := srv.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Error("Metrics endpoint error: ", err)
+		}
+		log.Info("Metrics endpoint stopped")
+	}()
+
+	return nil
+}
+
+// Stop - stop the metrics HTTP endpoint (do nothing when not started)
+func Stop() {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if server == nil {
+		return
+	}
+	server.Close()
+	server = nil
+}
+
+// handleMetricsRequest - write all known metrics in the Prometheus\OpenMetrics text format
+func handleMetricsRequest(w http.ResponseWriter, r *http.Request) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetrics(w, "counter", counters)
+	writeMetrics(w, "gauge", gauges)
+}
+
+func writeMetrics(w http.ResponseWriter, metricsType string, values map[string]float64) {
+	// stable output order
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, metricsType)
+		fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(values[name], 'f', -1, 64))
+	}
+}
@@ -42,6 +42,12 @@ func DefaultGatewayIP() (defGatewayIP net.IP, err error) {
 	return doDefaultGatewayIP()
 }
 
+// GetPathMTU - discover path MTU to the destination
+func GetPathMTU(destination net.IP) (int, error) {
+	// method should be implemented in platform-specific file
+	return doGetPathMTU(destination)
+}
+
 // InterfaceByIPAddr - Get network interface object by it's local IP address
 func InterfaceByIPAddr(localAddr net.IP) (*net.Interface, error) {
 	ifaces, err := net.Interfaces()
@@ -118,12 +124,12 @@ func GetAllLocalV6Addresses() ([]net.IPNet, error) {
 	return getAllLocalAddresses(nil, true)
 }
 
-/*
 // GetInterfaceV4Addresses - returns IPv4 addresses of the local interface
 func GetInterfaceV4Addresses(inf net.Interface) ([]net.IPNet, error) {
 	return getAllLocalAddresses([]net.Interface{inf}, false)
 }
 
+/*
 // GetInterfaceV6Addresses - returns IPv6 addresses of the local interfaces
 func GetInterfaceV6Addresses(inf net.Interface) ([]net.IPNet, error) {
 	return getAllLocalAddresses([]net.Interface{inf}, true)
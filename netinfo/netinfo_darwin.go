@@ -27,6 +27,7 @@ import (
 	"net"
 	"os/exec"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -84,3 +85,41 @@ func doGetDefaultGateway() (defGatewayIP net.IP, interfaceName string, err error
 
 	return defGatewayIP, interfaceName, nil
 }
+
+// doGetPathMTU - path MTU to the destination
+// (MTU of the route to the destination; parsed from the 'route get' output)
+func doGetPathMTU(destination net.IP) (int, error) {
+	// Expected output of "route -n get <ip>" command (last lines):
+	//  recvpipe  sendpipe  ssthresh  rtt,msec    rttvar  hopcount      mtu     expire
+	//         0         0         0         0         0         0      1500         0
+	cmd := exec.Command("/sbin/route", "-n", "get", destination.String())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get route info: %w", err)
+	}
+
+	lines := strings.Split(string(out), "\n")
+	for i, line := range lines {
+		headers := strings.Fields(line)
+		mtuColumn := -1
+		for col, header := range headers {
+			if header == "mtu" {
+				mtuColumn = col
+				break
+			}
+		}
+		if mtuColumn < 0 || i+1 >= len(lines) {
+			continue
+		}
+
+		values := strings.Fields(lines[i+1])
+		if mtuColumn >= len(values) {
+			continue
+		}
+		if mtu, err := strconv.Atoi(values[mtuColumn]); err == nil && mtu > 0 {
+			return mtu, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unable to determine path MTU")
+}
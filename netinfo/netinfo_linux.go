@@ -28,6 +28,8 @@ import (
 	"regexp"
 	"strconv"
 
+	"golang.org/x/sys/unix"
+
 	"github.com/ivpn/desktop-app-daemon/shell"
 )
 
@@ -78,3 +80,47 @@ func doDefaultGatewayIP() (defGatewayIP net.IP, err error) {
 
 	return defGatewayIP, retErr
 }
+
+// doGetPathMTU - path MTU to the destination:
+// a DF-flagged UDP socket is connected to the destination, an oversized probe is sent
+// and the path-MTU value cached by the kernel is read back (IP_MTU socket option)
+func doGetPathMTU(destination net.IP) (int, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: destination, Port: 33434})
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize path MTU probe: %w", err)
+	}
+	defer conn.Close()
+
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize path MTU probe: %w", err)
+	}
+
+	var sockErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_DO)
+	}); err != nil {
+		return 0, err
+	}
+	if sockErr != nil {
+		return 0, fmt.Errorf("failed to enable path MTU discovery: %w", sockErr)
+	}
+
+	// oversized probe: the kernel rejects it locally (EMSGSIZE) when it exceeds the path MTU
+	conn.Write(make([]byte, 2000))
+
+	mtu := 0
+	if err := rawConn.Control(func(fd uintptr) {
+		mtu, sockErr = unix.GetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_MTU)
+	}); err != nil {
+		return 0, err
+	}
+	if sockErr != nil {
+		return 0, fmt.Errorf("failed to read path MTU: %w", sockErr)
+	}
+	if mtu <= 0 {
+		return 0, fmt.Errorf("unable to determine path MTU")
+	}
+
+	return mtu, nil
+}
@@ -52,3 +52,9 @@ func doDefaultGatewayIP() (defGatewayIP net.IP, err error) {
 
 	return nil, fmt.Errorf("failed to determine default route")
 }
+
+// doGetPathMTU - path MTU to the destination
+// (not implemented for Windows: the WireGuard service manages the interface MTU itself)
+func doGetPathMTU(destination net.IP) (int, error) {
+	return 0, fmt.Errorf("path MTU detection not implemented for Windows")
+}
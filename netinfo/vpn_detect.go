@@ -0,0 +1,78 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package netinfo
+
+import (
+	"net"
+	"strings"
+)
+
+// typical interface name prefixes of VPN tunnels (all supported platforms)
+var vpnInterfaceNamePrefixes = []string{"tun", "tap", "utun", "wg", "ppp", "ipsec", "tailscale", "zt"}
+
+// ActiveVpnInterfaces - names of active (up) VPN tunnel interfaces,
+// excluding the interfaces listed in 'excludeNames'.
+// In use to detect coexistence with another VPN (e.g. a corporate VPN):
+// simultaneously active VPNs compete for the default route.
+// Detection is a heuristic: typical tunnel interface names + the point-to-point interface flag.
+func ActiveVpnInterfaces(excludeNames []string) ([]string, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, inf := range interfaces {
+		if inf.Flags&net.FlagUp == 0 || inf.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		isExcluded := false
+		for _, excluded := range excludeNames {
+			if inf.Name == excluded {
+				isExcluded = true
+				break
+			}
+		}
+		if isExcluded {
+			continue
+		}
+
+		isVpn := inf.Flags&net.FlagPointToPoint != 0
+		if isVpn == false {
+			name := strings.ToLower(inf.Name)
+			for _, prefix := range vpnInterfaceNamePrefixes {
+				if strings.HasPrefix(name, prefix) {
+					isVpn = true
+					break
+				}
+			}
+		}
+
+		if isVpn {
+			ret = append(ret, inf.Name)
+		}
+	}
+
+	return ret, nil
+}
@@ -25,9 +25,13 @@ package obfsproxy
 import (
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
+	"os"
 	"os/exec"
+	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -47,19 +51,45 @@ const (
 	constMaxConnectionRetries = 7
 )
 
+// ObfsProxyVersion - obfuscation protocol version
+type ObfsProxyVersion int
+
+// Supported obfuscation protocols
+const (
+	// OBFS3 - 'obfs3' obfuscation protocol
+	OBFS3 ObfsProxyVersion = 3
+	// OBFS4 - 'obfs4' obfuscation protocol (resistant to active probing; requires bridge certificate)
+	OBFS4 ObfsProxyVersion = 4
+)
+
 // Obfsproxy structure. Contains info about obfsproxy binary
 type Obfsproxy struct {
 	binaryPath string
+	version    ObfsProxyVersion
 	proc       *startedCmd
 }
 
 // CreateObfsproxy creates new obfsproxy object
-func CreateObfsproxy(theBinaryPath string) (obj *Obfsproxy) {
-	return &Obfsproxy{binaryPath: theBinaryPath}
+func CreateObfsproxy(theBinaryPath string, version ObfsProxyVersion) (obj *Obfsproxy) {
+	return &Obfsproxy{binaryPath: theBinaryPath, version: version}
+}
+
+// Obfs4AuthArgs - convert obfs4 bridge certificate into SOCKS5 username\password
+// obfs4proxy expects 'cert=...;iat-mode=N' concatenated from both fields
+// (the password can not be empty - '\x00' terminator is in use when everything fits into the username)
+func Obfs4AuthArgs(bridgeCert string) (username string, password string) {
+	args := fmt.Sprintf("cert=%s;iat-mode=0", bridgeCert)
+	if len(args) <= 255 {
+		return args, "\x00"
+	}
+	return args[:255], args[255:]
 }
 
 // Start - asynchronously start obfsproxy
 func (p *Obfsproxy) Start() (port int, err error) {
+	if p.version == OBFS4 {
+		return p.startPTManaged("obfs4")
+	}
 
 	localPort := constDefLocalPort
 	log.Info(fmt.Sprintf("Starting obfsproxy on local port %d", localPort))
@@ -121,6 +151,9 @@ func (p *Obfsproxy) Stop() {
 	}
 
 	log.Info("Stopping obfsproxy...")
+	if prc.stdin != nil {
+		prc.stdin.Close() // obfs4proxy gracefully exits when its stdin is closed
+	}
 	if err := shell.Kill(prc.command); err != nil {
 		log.Error(err)
 	}
@@ -135,10 +168,103 @@ func (e *obfsStartErrorPortInUse) Error() string {
 
 type startedCmd struct {
 	command   *exec.Cmd
+	stdin     io.WriteCloser // defined only for obfs4proxy (process exits when stdin is closed)
 	stopped   <-chan struct{}
 	exitError error
 }
 
+// startPTManaged - start obfs4proxy in the Tor pluggable-transport 'managed' mode:
+// the daemon defines the configuration by environment variables and discovers
+// the local SOCKS port from the process stdout ('CMETHOD ...' line)
+func (p *Obfsproxy) startPTManaged(transport string) (port int, err error) {
+	log.Info("Starting obfs4proxy (transport: " + transport + ")")
+	defer func() {
+		if err != nil || port <= 0 {
+			log.Error("Error starting obfs4proxy")
+			p.Stop()
+		}
+	}()
+
+	cmd := exec.Command(p.binaryPath)
+	cmd.Env = append(os.Environ(),
+		"TOR_PT_MANAGED_TRANSPORT_VER=1",
+		"TOR_PT_CLIENT_TRANSPORTS="+transport,
+		"TOR_PT_STATE_LOCATION="+path.Join(os.TempDir(), "ivpn-obfs4proxy"),
+		"TOR_PT_EXIT_ON_STDIN_CLOSE=1")
+
+	// the process exits when its stdin is closed - keep the pipe open for the process lifetime
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return 0, fmt.Errorf("failed to initialize obfs4proxy stdin: %w", err)
+	}
+
+	localPort := 0
+	startError := ""
+	outputParseFunc := func(text string, isError bool) {
+		if isError {
+			log.Error("[ERR] ", text)
+			return
+		}
+		log.Debug("[OUT] ", text)
+
+		// expected output example:
+		// 	VERSION 1
+		// 	CMETHOD obfs4 socks5 127.0.0.1:54885
+		// 	CMETHODS DONE
+		cols := strings.Fields(text)
+		if len(cols) >= 4 && cols[0] == "CMETHOD" && cols[1] == transport {
+			if _, portStr, e := net.SplitHostPort(cols[3]); e == nil {
+				if prt, e := strconv.Atoi(portStr); e == nil {
+					localPort = prt
+				}
+			}
+		} else if len(cols) >= 1 && (cols[0] == "CMETHOD-ERROR" || cols[0] == "ENV-ERROR") {
+			startError = text
+		}
+	}
+
+	if err := shell.StartConsoleReaders(cmd, outputParseFunc); err != nil {
+		log.Error("Failed to init obfs4proxy command: ", err.Error())
+		return 0, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		log.Error("Failed to start obfs4proxy: ", err.Error())
+		return 0, err
+	}
+
+	stoppedChan := make(chan struct{}, 1)
+	var procStoppedError error
+	go func() {
+		procStoppedError = cmd.Wait()
+		stdin.Close()
+		log.Info("Obfsproxy stopped")
+		stoppedChan <- struct{}{}
+		close(stoppedChan)
+	}()
+
+	// waiting for the SOCKS port info (or error) in the process output
+	started := time.Now()
+	for localPort == 0 && len(startError) == 0 && shell.IsRunning(cmd) {
+		time.Sleep(time.Millisecond * 10)
+		if time.Since(started) > time.Second*10 { // timeout limit to start obfs4proxy process = 10 seconds
+			return 0, errors.New("obfs4proxy start timeout")
+		}
+	}
+
+	if len(startError) > 0 {
+		return 0, fmt.Errorf("failed to start obfs4proxy: %s", startError)
+	}
+	if localPort == 0 {
+		return 0, errors.New("obfs4proxy not started")
+	}
+
+	p.proc = &startedCmd{command: cmd, stdin: stdin, stopped: stoppedChan, exitError: procStoppedError}
+
+	log.Info(fmt.Sprintf("Started on port %d", localPort))
+	return localPort, nil
+}
+
 func (p *Obfsproxy) start(localPort int) (command *startedCmd, err error) {
 	// obfsproxy command with arguments
 	cmd := exec.Command(p.binaryPath, "obfs3", "socks", fmt.Sprintf("127.0.0.1:%d", localPort))
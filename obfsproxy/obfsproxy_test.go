@@ -32,7 +32,7 @@ import (
 )
 
 func TestStart(t *testing.T) {
-	obfsp := obfsproxy.CreateObfsproxy(platform.ObfsproxyStartScript())
+	obfsp := obfsproxy.CreateObfsproxy(platform.ObfsproxyStartScript(), obfsproxy.OBFS3)
 
 	port, err := obfsp.Start()
 	if err != nil {
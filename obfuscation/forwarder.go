@@ -0,0 +1,107 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package obfuscation
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// forwarder - common base of the tunnel-style obfuscation transports:
+// a transparent TCP endpoint on 127.0.0.1; the traffic of every accepted connection
+// is forwarded to the obfuscation endpoint on the VPN server through
+// an obfuscated stream created by 'dial'
+type forwarder struct {
+	name           string
+	remoteEndpoint string
+	dial           func() (net.Conn, error)
+
+	listener        net.Listener
+	stopped         chan struct{}
+	isStopRequested bool
+}
+
+// Start - start the local listener; returns the listening TCP port on 127.0.0.1
+func (f *forwarder) Start() (port int, err error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to start %s transport listener: %w", f.name, err)
+	}
+	f.listener = listener
+	f.stopped = make(chan struct{})
+
+	go func() {
+		defer close(f.stopped)
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if f.isStopRequested == false {
+					log.Error(fmt.Sprintf("%s transport listener stopped: %v", f.name, err))
+				}
+				return
+			}
+			go f.forward(conn)
+		}
+	}()
+
+	port = listener.Addr().(*net.TCPAddr).Port
+	log.Info(fmt.Sprintf("%s transport started on port %d (remote endpoint: %s)", f.name, port, f.remoteEndpoint))
+	return port, nil
+}
+
+// Wait - wait for the transport listener stop
+func (f *forwarder) Wait() error {
+	if f.stopped == nil {
+		return nil
+	}
+	<-f.stopped
+	return nil
+}
+
+// Stop - stop the transport
+func (f *forwarder) Stop() {
+	f.isStopRequested = true
+	if f.listener != nil {
+		f.listener.Close()
+	}
+}
+
+func (f *forwarder) forward(local net.Conn) {
+	remote, err := f.dial()
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to connect to %s obfuscation endpoint '%s': %v", f.name, f.remoteEndpoint, err))
+		local.Close()
+		return
+	}
+
+	go func() {
+		io.Copy(remote, local)
+		remote.Close()
+		local.Close()
+	}()
+
+	io.Copy(local, remote)
+	local.Close()
+	remote.Close()
+}
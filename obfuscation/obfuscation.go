@@ -0,0 +1,78 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package obfuscation
+
+import (
+	"fmt"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/obfsproxy"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("obfus")
+}
+
+// Type - obfuscation transport type
+type Type string
+
+// Supported obfuscation transports
+const (
+	// Obfs4 - 'obfs4' pluggable transport (external obfs4proxy binary; SOCKS5 proxy model)
+	Obfs4 Type = "obfs4"
+	// TLSTunnel - VPN traffic wrapped into an ordinary TLS stream (stunnel-style)
+	TLSTunnel Type = "tlstunnel"
+	// WebSocket - VPN traffic wrapped into binary WebSocket frames
+	WebSocket Type = "websocket"
+)
+
+// ObfuscationTransport - local obfuscation endpoint of a VPN connection.
+// Start() launches the transport and returns the TCP port on 127.0.0.1 which
+// the VPN client has to use instead of the real server endpoint:
+// a transparent forwarder for 'tlstunnel'\'websocket', a SOCKS5 proxy for 'obfs4'
+type ObfuscationTransport interface {
+	// Start - asynchronously start the transport; returns the listening TCP port on 127.0.0.1
+	Start() (localPort int, err error)
+	// Wait - wait for the transport process\listener stop
+	Wait() error
+	// Stop - stop the transport
+	Stop()
+}
+
+// CreateTransport - create obfuscation transport object of the requested type
+// 'remoteEndpoint' - obfuscation endpoint on the VPN server ('host:port');
+// not applicable for 'obfs4' (the destination is defined over its SOCKS5 interface)
+func CreateTransport(transportType Type, remoteEndpoint string) (ObfuscationTransport, error) {
+	switch transportType {
+	case Obfs4:
+		return obfsproxy.CreateObfsproxy(platform.Obfs4proxyBinaryPath(), obfsproxy.OBFS4), nil
+	case TLSTunnel:
+		return newTLSTunnel(remoteEndpoint), nil
+	case WebSocket:
+		return newWSTunnel(remoteEndpoint), nil
+	}
+	return nil, fmt.Errorf("unsupported obfuscation transport: '%s'", transportType)
+}
@@ -0,0 +1,53 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package obfuscation
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+// tlsTunnel - 'tlstunnel' obfuscation transport:
+// VPN traffic is wrapped into an ordinary TLS stream to the server (stunnel-style),
+// so for a traffic inspector the connection looks like a regular HTTPS session
+type tlsTunnel struct {
+	forwarder
+}
+
+func newTLSTunnel(remoteEndpoint string) *tlsTunnel {
+	t := &tlsTunnel{forwarder{name: "TLS-tunnel", remoteEndpoint: remoteEndpoint}}
+	t.dial = t.dialTLS
+	return t
+}
+
+func (t *tlsTunnel) dialTLS() (net.Conn, error) {
+	// The outer TLS stream is only an obfuscation wrapper:
+	// the VPN server is authenticated by OpenVPN itself (CA certificate + tls-auth)
+	// inside the tunnel, therefore the wrapper certificate is not verified here
+	return tls.DialWithDialer(
+		&net.Dialer{Timeout: time.Second * 15},
+		"tcp",
+		t.remoteEndpoint,
+		&tls.Config{InsecureSkipVerify: true})
+}
@@ -0,0 +1,179 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package obfuscation
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// udpTLSWrapper - obfuscation transport for UDP-based VPN protocols (WireGuard):
+// a local UDP endpoint on 127.0.0.1; datagrams are encapsulated into a single
+// TLS\TCP stream to the obfuscation endpoint on the server,
+// so for a traffic inspector the connection looks like a regular HTTPS session.
+// Datagram framing inside the stream: 2 bytes (big-endian) payload length + payload
+type udpTLSWrapper struct {
+	remoteEndpoint string
+
+	conn            *net.UDPConn
+	stream          net.Conn
+	stopped         chan struct{}
+	isStopRequested bool
+}
+
+// NewUDPTLSWrapper - create UDP-to-TLS\TCP obfuscation wrapper
+// ('remoteEndpoint' - obfuscation endpoint on the VPN server 'host:port')
+// Note: Start() returns a local UDP port (not TCP as for the tunnel-style transports)
+func NewUDPTLSWrapper(remoteEndpoint string) ObfuscationTransport {
+	return &udpTLSWrapper{remoteEndpoint: remoteEndpoint}
+}
+
+// Start - start the local UDP listener; returns the listening UDP port on 127.0.0.1
+func (w *udpTLSWrapper) Start() (port int, err error) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start UDP wrapper listener: %w", err)
+	}
+	w.conn = conn
+	w.stopped = make(chan struct{})
+
+	go w.clientReadLoop()
+
+	port = conn.LocalAddr().(*net.UDPAddr).Port
+	log.Info(fmt.Sprintf("UDP wrapper started on port %d (remote endpoint: %s)", port, w.remoteEndpoint))
+	return port, nil
+}
+
+// Wait - wait for the wrapper listener stop
+func (w *udpTLSWrapper) Wait() error {
+	if w.stopped == nil {
+		return nil
+	}
+	<-w.stopped
+	return nil
+}
+
+// Stop - stop the wrapper
+func (w *udpTLSWrapper) Stop() {
+	w.isStopRequested = true
+	if w.conn != nil {
+		w.conn.Close()
+	}
+	w.closeStream()
+}
+
+// clientReadLoop - forward datagrams of the local VPN client into the TLS stream
+func (w *udpTLSWrapper) clientReadLoop() {
+	defer close(w.stopped)
+
+	buff := make([]byte, 65535)
+	frame := make([]byte, 65535+2)
+	for {
+		size, clientAddr, err := w.conn.ReadFromUDP(buff)
+		if err != nil {
+			if w.isStopRequested == false {
+				log.Error(fmt.Sprintf("UDP wrapper listener stopped: %v", err))
+			}
+			return
+		}
+
+		stream, err := w.getStream(clientAddr)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to connect to obfuscation endpoint '%s': %v", w.remoteEndpoint, err))
+			continue
+		}
+
+		binary.BigEndian.PutUint16(frame[0:2], uint16(size))
+		copy(frame[2:], buff[:size])
+		if _, err := stream.Write(frame[:size+2]); err != nil {
+			log.Error(fmt.Sprintf("failed to forward datagram to obfuscation endpoint: %v", err))
+			w.closeStream() // the stream is re-established on the next datagram
+		}
+	}
+}
+
+// getStream - TLS stream to the obfuscation endpoint (established on demand)
+func (w *udpTLSWrapper) getStream(clientAddr *net.UDPAddr) (net.Conn, error) {
+	if w.stream != nil {
+		return w.stream, nil
+	}
+
+	// The outer TLS stream is only an obfuscation wrapper:
+	// all the traffic inside is encrypted and authenticated by WireGuard itself,
+	// therefore the wrapper certificate is not verified here
+	stream, err := tls.DialWithDialer(
+		&net.Dialer{Timeout: time.Second * 15},
+		"tcp",
+		w.remoteEndpoint,
+		&tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+
+	w.stream = stream
+	go w.streamReadLoop(stream, clientAddr)
+	return stream, nil
+}
+
+func (w *udpTLSWrapper) closeStream() {
+	stream := w.stream
+	w.stream = nil
+	if stream != nil {
+		stream.Close()
+	}
+}
+
+// streamReadLoop - forward datagrams received from the TLS stream back to the local VPN client
+func (w *udpTLSWrapper) streamReadLoop(stream net.Conn, clientAddr *net.UDPAddr) {
+	header := make([]byte, 2)
+	buff := make([]byte, 65535)
+	for {
+		if _, err := io.ReadFull(stream, header); err != nil {
+			if w.isStopRequested == false {
+				log.Error(fmt.Sprintf("obfuscation stream closed: %v", err))
+			}
+			w.closeStream()
+			return
+		}
+
+		size := int(binary.BigEndian.Uint16(header))
+		if _, err := io.ReadFull(stream, buff[:size]); err != nil {
+			if w.isStopRequested == false {
+				log.Error(fmt.Sprintf("obfuscation stream closed: %v", err))
+			}
+			w.closeStream()
+			return
+		}
+
+		if _, err := w.conn.WriteToUDP(buff[:size], clientAddr); err != nil {
+			if w.isStopRequested == false {
+				log.Error(fmt.Sprintf("failed to forward datagram to the VPN client: %v", err))
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package obfuscation
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/websocket"
+)
+
+// wsTunnel - 'websocket' obfuscation transport:
+// VPN traffic is wrapped into binary WebSocket frames,
+// so for a traffic inspector the connection looks like an ordinary WebSocket session
+// (useful when only HTTP(S)\WebSocket traffic is allowed by a middlebox)
+type wsTunnel struct {
+	forwarder
+}
+
+func newWSTunnel(remoteEndpoint string) *wsTunnel {
+	t := &wsTunnel{forwarder{name: "WebSocket", remoteEndpoint: remoteEndpoint}}
+	t.dial = t.dialWS
+	return t
+}
+
+func (t *wsTunnel) dialWS() (net.Conn, error) {
+	config, err := websocket.NewConfig(
+		fmt.Sprintf("ws://%s/", t.remoteEndpoint),
+		fmt.Sprintf("http://%s/", t.remoteEndpoint))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := websocket.DialConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.PayloadType = websocket.BinaryFrame
+	return conn, nil
+}
@@ -0,0 +1,88 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ivpn/desktop-app-daemon/version"
+)
+
+// Implementation of the 'dbus.Requests' interface
+// (daemon operations exposed on the system D-Bus; Linux only)
+
+type dbusStatus struct {
+	DaemonVersion     string
+	VpnState          string
+	Connected         bool
+	KillSwitchEnabled bool
+}
+
+// Status - current daemon state (JSON)
+func (p *Protocol) Status() string {
+	status := dbusStatus{
+		DaemonVersion: version.Version(),
+		VpnState:      p._lastVPNState.State.String(),
+		Connected:     p._service.Connected()}
+
+	if isEnabled, _, _, _, err := p._service.KillSwitchState(); err == nil {
+		status.KillSwitchEnabled = isEnabled
+	}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// ConnectVPN - establish VPN connection using parameters of the last 'Connect' request
+func (p *Protocol) ConnectVPN() error {
+	prefs := p._service.Preferences()
+	if len(prefs.Session.Session) == 0 {
+		return fmt.Errorf("not logged in")
+	}
+	if len(prefs.LastConnectionParams) == 0 {
+		return fmt.Errorf("no saved connection parameters (at least one connection has to be established by the IVPN app)")
+	}
+
+	go p.processConnectionRequest([]byte(prefs.LastConnectionParams))
+	return nil
+}
+
+// DisconnectVPN - disconnect VPN
+func (p *Protocol) DisconnectVPN() error {
+	return p._service.Disconnect()
+}
+
+// KillSwitchEnabled - current kill-switch (firewall) state
+func (p *Protocol) KillSwitchEnabled() (bool, error) {
+	isEnabled, _, _, _, err := p._service.KillSwitchState()
+	return isEnabled, err
+}
+
+// SetKillSwitchEnabled - enable\disable kill-switch (firewall)
+func (p *Protocol) SetKillSwitchEnabled(isEnabled bool) error {
+	return p._service.SetKillSwitchState(isEnabled)
+}
@@ -0,0 +1,159 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package decoder implements strict parsing of the incoming protocol messages.
+// The daemon is running with root privileges and the protocol input is untrusted
+// (any local process can connect to the daemon port) - therefore the decoding
+// is defensive: message size is limited and only known request types are accepted.
+// The package has no side effects and is suitable for fuzzing (see 'fuzz.go')
+package decoder
+
+import (
+	"bufio"
+	"errors"
+
+	"github.com/ivpn/desktop-app-daemon/protocol/types"
+)
+
+// MaxMessageSize - maximum acceptable length of a single protocol message
+// (a message longer than the limit is treated as a protocol violation: a normal
+// request is far below the limit; the largest legitimate messages are imported
+// OpenVPN\WireGuard configurations)
+const MaxMessageSize = 128 * 1024 // 128 KB
+
+// ErrMessageTooLong - the message exceeds MaxMessageSize
+// (the connection have to be closed: the rest of the message was not read)
+var ErrMessageTooLong = errors.New("protocol message too long")
+
+// ErrUnknownCommand - the command name of the message is not a known request type
+var ErrUnknownCommand = errors.New("unknown command")
+
+// validRequests - all request types which the daemon accepts
+// (have to be in sync with the requests processing switch of the protocol package)
+var validRequests = map[string]struct{}{
+	"Hello":                              {},
+	"GetVPNState":                        {},
+	"GetStateSnapshot":                   {},
+	"GetServers":                         {},
+	"GetServerPorts":                     {},
+	"SetPreferredPort":                   {},
+	"PortForwardAdd":                     {},
+	"PortForwardRemove":                  {},
+	"PortForwardList":                    {},
+	"PingServers":                        {},
+	"GetConnectionHistory":               {},
+	"GetUsageStats":                      {},
+	"GetGeoLookup":                       {},
+	"KillSwitchGetStatus":                {},
+	"KillSwitchGetBlockedDNS":            {},
+	"KillSwitchSetUserExceptions":        {},
+	"KillSwitchSetLanAllowedAddresses":   {},
+	"KillSwitchSetEnabled":               {},
+	"NetworkRulesGet":                    {},
+	"NetworkRulesSet":                    {},
+	"SplitTunnelGetStatus":               {},
+	"SplitTunnelSetEnabled":              {},
+	"SplitTunnelSetRoutes":               {},
+	"SplitTunnelAddApp":                  {},
+	"SplitTunnelRemoveApp":               {},
+	"KillSwitchSetAllowLANMulticast":     {},
+	"KillSwitchSetAllowLAN":              {},
+	"KillSwitchSetAllowServiceDiscovery": {},
+	"KillSwitchSetSoftMode":              {},
+	"KillSwitchSetIsPersistent":          {},
+	"KillSwitchGetIsPestistent":          {},
+	"SetPreference":                      {},
+	"SetAntiTracker":                     {},
+	"DnsProxySet":                        {},
+	"DnsProxyGetStatus":                  {},
+	"GenerateDiagnostics":                {},
+	"SetLogLevel":                        {},
+	"SetLogStreaming":                    {},
+	"SetAlternateDns":                    {},
+	"RunLeakTest":                        {},
+	"GetNetworkState":                    {},
+	"RepairDNS":                          {},
+	"RepairNetworkAdapters":              {},
+	"PauseConnection":                    {},
+	"ResumeConnection":                   {},
+	"SessionNew":                         {},
+	"Login":                              {},
+	"SessionDelete":                      {},
+	"Logout":                             {},
+	"AccountStatus":                      {},
+	"SessionStatus":                      {},
+	"DeviceList":                         {},
+	"DeviceDelete":                       {},
+	"WireGuardImportCustomProfile":       {},
+	"WireGuardRemoveCustomProfile":       {},
+	"WireGuardGetCustomProfiles":         {},
+	"OpenVPNImportCustomProfile":         {},
+	"OpenVPNRemoveCustomProfile":         {},
+	"OpenVPNGetCustomProfiles":           {},
+	"WireGuardGenerateNewKeys":           {},
+	"WireGuardSetKeysRotationInterval":   {},
+	"Disconnect":                         {},
+	"CancelConnect":                      {},
+	"Connect":                            {}}
+
+// ReadMessage - read one protocol message (ending in newline) from the reader.
+// Returns ErrMessageTooLong when the message exceeds MaxMessageSize;
+// in this case the rest of the oversized message stays unread -
+// the caller have to close the connection
+func ReadMessage(reader *bufio.Reader) (string, error) {
+	var message []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		message = append(message, chunk...)
+
+		if len(message) > MaxMessageSize {
+			return "", ErrMessageTooLong
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue // delimiter not found yet - keep reading
+		}
+		return string(message), err
+	}
+}
+
+// DecodeCommand - parse base fields of a protocol message and validate it:
+// the message have to fit into MaxMessageSize, be a correct JSON object
+// and carry a known request type in the 'Command' field.
+// On ErrUnknownCommand the parsed CommandBase is still returned
+// (e.g. to send an error response with the correct request index)
+func DecodeCommand(messageData []byte) (types.CommandBase, error) {
+	if len(messageData) > MaxMessageSize {
+		return types.CommandBase{}, ErrMessageTooLong
+	}
+
+	cmd, err := types.GetCommandBase(messageData)
+	if err != nil {
+		return cmd, err
+	}
+
+	if _, isKnown := validRequests[cmd.Command]; isKnown == false {
+		return cmd, ErrUnknownCommand
+	}
+
+	return cmd, nil
+}
@@ -0,0 +1,101 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package decoder_test
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/ivpn/desktop-app-daemon/protocol/decoder"
+)
+
+func TestDecodeCommand(t *testing.T) {
+	// valid request
+	cmd, err := decoder.DecodeCommand([]byte(`{"Command":"Hello","Idx":1,"Secret":123}`))
+	if err != nil {
+		t.Errorf("valid 'Hello' request rejected: %v", err)
+	}
+	if cmd.Command != "Hello" || cmd.Idx != 1 {
+		t.Errorf("unexpected parse result of a valid request: %+v", cmd)
+	}
+
+	// command aliases have to be accepted too
+	if _, err := decoder.DecodeCommand([]byte(`{"Command":"Login"}`)); err != nil {
+		t.Errorf("valid 'Login' request rejected: %v", err)
+	}
+
+	// malformed JSON
+	if _, err := decoder.DecodeCommand([]byte(`{"Command":`)); err == nil {
+		t.Error("malformed JSON accepted")
+	}
+
+	// empty command name
+	if _, err := decoder.DecodeCommand([]byte(`{"Idx":1}`)); err == nil {
+		t.Error("message without command name accepted")
+	}
+
+	// unknown command
+	cmd, err = decoder.DecodeCommand([]byte(`{"Command":"NoSuchRequest","Idx":7}`))
+	if err != decoder.ErrUnknownCommand {
+		t.Errorf("unexpected error for an unknown command: %v", err)
+	}
+	if cmd.Idx != 7 {
+		t.Error("CommandBase of an unknown command not returned (required for the error response)")
+	}
+
+	// oversized message
+	oversized := append([]byte(`{"Command":"Hello","Version":"`), make([]byte, decoder.MaxMessageSize)...)
+	oversized = append(oversized, []byte(`"}`)...)
+	if _, err := decoder.DecodeCommand(oversized); err != decoder.ErrMessageTooLong {
+		t.Errorf("unexpected error for an oversized message: %v", err)
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	// two messages in a stream
+	reader := bufio.NewReader(strings.NewReader("{\"Command\":\"Hello\"}\n{\"Command\":\"Disconnect\"}\n"))
+	message, err := decoder.ReadMessage(reader)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	if message != "{\"Command\":\"Hello\"}\n" {
+		t.Errorf("unexpected message: '%s'", message)
+	}
+	if message, err = decoder.ReadMessage(reader); err != nil || message != "{\"Command\":\"Disconnect\"}\n" {
+		t.Errorf("unexpected second message: '%s' (%v)", message, err)
+	}
+
+	// message longer than the internal buffer of the reader (but below the limit)
+	longValue := strings.Repeat("x", 1024*8)
+	reader = bufio.NewReaderSize(strings.NewReader("{\"Version\":\""+longValue+"\"}\n"), 16)
+	if message, err = decoder.ReadMessage(reader); err != nil || !strings.Contains(message, longValue) {
+		t.Errorf("failed to read message longer than the reader buffer: %v", err)
+	}
+
+	// oversized message
+	reader = bufio.NewReader(strings.NewReader(strings.Repeat("x", decoder.MaxMessageSize+1) + "\n"))
+	if _, err = decoder.ReadMessage(reader); err != decoder.ErrMessageTooLong {
+		t.Errorf("unexpected error for an oversized message: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package protocol_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	apitypes "github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/protocol"
+	"github.com/ivpn/desktop-app-daemon/protocol/types"
+	"github.com/ivpn/desktop-app-daemon/service"
+	"github.com/ivpn/desktop-app-daemon/service/mocks"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+)
+
+// Integration test harness: drives the full protocol
+// (Hello -> Login -> WG keys generation -> Connect -> Disconnect)
+// against the real service object with mocked API and VPN backends.
+// No network access, privileged rights or installed VPN binaries are required:
+// the mock VPN process reports the CONNECTED state without creating a tunnel
+// (see 'service/mocks' package)
+
+const _testTimeout = time.Second * 30
+
+// startTestDaemon - initialize the service with mocked dependencies and start the protocol.
+// Returns the established (not yet authenticated) client connection
+func startTestDaemon(t *testing.T, secret uint64, launcher *mocks.VpnLauncher) (*protocol.Protocol, net.Conn) {
+	apiClient := &mocks.APIClient{
+		SessionToken: "session-token-integration-test",
+		VpnUsername:  "vpn_user",
+		VpnPassword:  "vpn_pass",
+		WgLocalIP:    "10.64.0.2"}
+
+	wgKeysMgr := &mocks.WgKeysManager{
+		PublicKey:  "wg-public-key-integration-test=",
+		PrivateKey: "wg-private-key-integration-test=",
+		LocalIP:    "10.64.0.2"}
+
+	proto, err := protocol.CreateProtocol()
+	if err != nil {
+		t.Fatalf("failed to initialize protocol: %v", err)
+	}
+
+	serv, err := service.CreateService(proto,
+		apiClient,
+		mocks.CreateServersUpdater(apitypes.ServersInfoResponse{}),
+		&mocks.NetChangeDetector{},
+		wgKeysMgr,
+		&mocks.PortForwardManager{},
+		&mocks.SystemEventsMonitor{})
+	if err != nil {
+		t.Fatalf("failed to initialize service: %v", err)
+	}
+
+	// substitute the production VPN process factory by the mock
+	serv.SetVpnLauncher(launcher)
+
+	startedOnPortChan := make(chan int, 1)
+	go proto.Start(secret, startedOnPortChan, serv)
+
+	var port int
+	select {
+	case port = <-startedOnPortChan:
+	case <-time.After(_testTimeout):
+		t.Fatal("protocol start timeout")
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		t.Fatalf("failed to connect to the daemon: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(_testTimeout))
+
+	return proto, conn
+}
+
+// waitForResponse - read responses\notifications until the expected one is received
+// (an error response fails the test immediately)
+func waitForResponse(t *testing.T, reader *bufio.Reader, command string) []byte {
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("failed to receive response (waiting for '%s'): %v", command, err)
+		}
+
+		cmd, err := types.GetCommandBase(line)
+		if err != nil {
+			continue
+		}
+		if cmd.Command == command {
+			return line
+		}
+		if cmd.Command == "ErrorResp" {
+			t.Fatalf("error response received (waiting for '%s'): %s", command, string(line))
+		}
+	}
+}
+
+func TestServiceOrchestration(t *testing.T) {
+	vpnProcess := &mocks.VpnProcess{
+		VpnType:  vpn.WireGuard,
+		ClientIP: net.IPv4(127, 0, 0, 1), // have to be an address of an existing local interface
+		ServerIP: net.IPv4(198, 51, 100, 1)}
+
+	secret := rand.Uint64()
+	proto, conn := startTestDaemon(t, secret, &mocks.VpnLauncher{Process: vpnProcess})
+	defer func() {
+		conn.Close()
+		proto.Stop()
+	}()
+
+	reader := bufio.NewReader(conn)
+
+	// connection authentication
+	if err := types.Send(conn, &types.Hello{Secret: secret, Version: "integration-test"}, 1); err != nil {
+		t.Fatalf("failed to send 'Hello' request: %v", err)
+	}
+	waitForResponse(t, reader, "HelloResp")
+
+	// login (the mock API always succeeds)
+	if err := types.Send(conn, &types.SessionNew{AccountID: "i-integration-test"}, 2); err != nil {
+		t.Fatalf("failed to send 'SessionNew' request: %v", err)
+	}
+	sessionRaw := waitForResponse(t, reader, "SessionNewResp")
+	var sessionResp types.SessionNewResp
+	if err := json.Unmarshal(sessionRaw, &sessionResp); err != nil {
+		t.Fatalf("failed to parse 'SessionNewResp': %v", err)
+	}
+	if sessionResp.Session.Session != "session-token-integration-test" {
+		t.Fatalf("unexpected session token: '%s'", sessionResp.Session.Session)
+	}
+
+	// WireGuard keys generation (the mock keys manager 'generates' the pre-configured key pair)
+	if err := types.Send(conn, &types.WireGuardGenerateNewKeys{}, 3); err != nil {
+		t.Fatalf("failed to send 'WireGuardGenerateNewKeys' request: %v", err)
+	}
+	waitForResponse(t, reader, "EmptyResp")
+
+	// connect (the mock VPN process reports the CONNECTED state; no tunnel is created)
+	var connectReq types.Connect
+	connectReq.VpnType = vpn.WireGuard
+	connectReq.WireGuardParameters.Port.Port = 2049
+	connectReq.WireGuardParameters.EntryVpnServer.Hosts = []types.WGHost{{
+		Host:      "198.51.100.1",
+		PublicKey: "server-public-key=",
+		LocalIP:   "10.64.0.1"}}
+	if err := types.Send(conn, &connectReq, 4); err != nil {
+		t.Fatalf("failed to send 'Connect' request: %v", err)
+	}
+	connectedRaw := waitForResponse(t, reader, "ConnectedResp")
+	var connectedResp types.ConnectedResp
+	if err := json.Unmarshal(connectedRaw, &connectedResp); err != nil {
+		t.Fatalf("failed to parse 'ConnectedResp': %v", err)
+	}
+	if connectedResp.ClientIP != "127.0.0.1" {
+		t.Fatalf("unexpected client IP of the established connection: '%s'", connectedResp.ClientIP)
+	}
+
+	// disconnect
+	if err := types.Send(conn, &types.Disconnect{}, 5); err != nil {
+		t.Fatalf("failed to send 'Disconnect' request: %v", err)
+	}
+	waitForResponse(t, reader, "DisconnectedResp")
+}
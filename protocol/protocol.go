@@ -33,10 +33,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	apitypes "github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/dbus"
 	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/protocol/decoder"
 	"github.com/ivpn/desktop-app-daemon/protocol/types"
 	"github.com/ivpn/desktop-app-daemon/service/dns"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
@@ -63,6 +66,12 @@ type Service interface {
 	// - err: error
 	OnControlConnectionClosed() (isServiceMustBeClosed bool, err error)
 
+	// PrepareToStop - graceful shutdown of the service (daemon is going to exit):
+	// stop background activities, cleanly disconnect VPN, restore DNS configuration
+	// and flush preferences. The firewall stays enabled only when the persistent
+	// configuration is requested
+	PrepareToStop()
+
 	// GetDisabledFunctions returns info about functions which are disabled
 	// Some functionality can be not accessible
 	// It can happen, for example, if some external binaries not installed
@@ -70,7 +79,18 @@ type Service interface {
 	GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error)
 
 	ServersList() (*apitypes.ServersInfoResponse, error)
-	PingServers(retryCount int, timeoutMs int) (map[string]int, error)
+	ServersSignatureVerified() bool
+	ServerPorts() (openvpnPorts []apitypes.PortInfo, wireguardPorts []apitypes.PortInfo)
+	SetPreferredPort(vpnType vpn.Type, port int, isTCP bool) error
+
+	PortForwardAdd() (int, error)
+	PortForwardRemove(port int) error
+	PortForwardList() []int
+	PingServers(retryCount int, timeoutMs int, intervalMs int, maxConcurrent int, hosts []string) (map[string]int, error)
+	PingResults() map[string]int
+	GetGeoLookup() (*apitypes.GeoLookupResponse, error)
+	ConnectionHistory() []preferences.ConnectionHistoryEntry
+	UsageStats() []preferences.UsageStatsBucket
 	ServersUpdateNotifierChannel() chan struct{}
 
 	KillSwitchState() (isEnabled, isPersistant, isAllowLAN, isAllowLanMulticast bool, err error)
@@ -78,30 +98,69 @@ type Service interface {
 	SetKillSwitchIsPersistent(isPersistant bool) error
 	SetKillSwitchAllowLANMulticast(isAllowLanMulticast bool) error
 	SetKillSwitchAllowLAN(isAllowLan bool) error
+	SetKillSwitchAllowServiceDiscovery(isAllow bool) error
+	SetKillSwitchSoftMode(isEnabled bool) error
+	KillSwitchSoftModeTriggered() bool
+	SetKillSwitchLanAllowedAddresses(addresses []string) error
+	SetKillSwitchUserExceptions(exceptions []string) error
+	KillSwitchBlockedDNSCount() (uint64, error)
 
 	Preferences() preferences.Preferences
 	SetPreference(key string, val string) error
+	SetNetworkRules(isEnabled bool, rules []preferences.NetworkRule) error
+	SetLastConnectionParams(params string) error
+
+	SplitTunnelSet(isEnabled bool, isInverse bool, apps []string) error
+	SplitTunnelAddApp(appBinaryPath string) error
+	SplitTunnelRemoveApp(appBinaryPath string) error
+	SplitTunnelStatus() (isEnabled bool, isInverse bool, apps []string, runningApps []string)
+	SplitTunnelSetRoutes(bypassCIDRs []string, vpnCIDRs []string) error
 
 	SetManualDNS(dns net.IP) error
+	SetManualDNSServers(servers []net.IP) error
 	ResetManualDNS() error
+	RepairDNS() error
+	RepairNetworkAdapters() (repairedItems []string, err error)
+
+	SetDnsProxyConfig(enabled bool, encryption string, resolver string) error
+	DnsProxyConfig() (enabled bool, encryption string, resolver string)
+
+	SetAntiTracker(enabled bool, hardcore bool) error
+
+	RunLeakTest() (externalIP string, isDNSLeak bool, isIPv6Leak bool, err error)
+
+	GetNetworkState() (
+		defaultGatewayIP string,
+		defaultInterfaceName string,
+		tunnelInterfaceName string,
+		tunnelLocalIP string,
+		dnsServers []string,
+		configuredDNS string,
+		isDNSMatch bool,
+		otherVpnInterfaces []string)
 
 	ConnectOpenVPN(connectionParams openvpn.ConnectionParams, manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error
 	ConnectWireGuard(connectionParams wireguard.ConnectionParams, manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error
 	Disconnect() error
+	CancelConnect() error
 	Connected() bool
 
-	Pause() error
+	Pause(durationSec int64) error
 	Resume() error
 
 	SetRawCredentials(AccountID, session, vpnUser, vpnPass, wgPublicKey, wgPrivateKey, wgLocalIP string, wgKeyGenerated int64) error
 
-	SessionNew(accountID string, forceLogin bool) (
+	SessionNew(accountID string, forceLogin bool, captchaID string, captcha string, confirmation2FA string) (
 		apiCode int,
 		apiErrorMsg string,
 		accountInfo preferences.AccountStatus,
+		respCaptchaID string,
+		respCaptchaImage string,
 		err error)
 
-	SessionDelete() error
+	SessionDelete() (wipedItems []string, err error)
+	DeviceList() ([]apitypes.DeviceInfo, error)
+	DeviceDelete(deviceID string) error
 	RequestSessionStatus() (
 		apiCode int,
 		apiErrorMsg string,
@@ -111,22 +170,121 @@ type Service interface {
 
 	WireGuardGenerateKeys(updateIfNecessary bool) error
 	WireGuardSetKeysRotationInterval(interval int64)
+
+	ImportWGCustomProfile(name string, config string) error
+	RemoveWGCustomProfile(name string) error
+	WGCustomProfiles() []preferences.WGCustomProfile
+	ImportOpenVPNCustomProfile(name string, config string) (rejectedDirectives []string, retErr error)
+	RemoveOpenVPNCustomProfile(name string) error
+	OpenVPNCustomProfiles() []preferences.OpenVPNCustomProfile
+	ConnectWireGuardCustomProfile(profileName string, manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error
 }
 
 // CreateProtocol - Create new protocol object
 func CreateProtocol() (*Protocol, error) {
-	return &Protocol{_connections: make(map[net.Conn]struct{})}, nil
+	return &Protocol{
+		_connections:        make(map[net.Conn]*clientConnection),
+		_connectRateLimiter: newRateLimiter(_maxConnectRequestsPerWindow, _connectRequestsWindow)}, nil
 }
 
+// clientConnection - state of an authenticated client connection.
+// All writes to the socket go through a per-connection send queue processed by a
+// dedicated writer routine: responses to requests and broadcast notifications
+// never write to the socket concurrently.
+type clientConnection struct {
+	conn net.Conn
+
+	// IsReadOnly - client connected in read-only (monitoring) mode:
+	// only status\information requests are allowed for such connection
+	IsReadOnly bool
+
+	// IsStateEventsSubscriber - client requested to receive state-change notifications
+	// wrapped into 'StateEventResp' objects (with sequence number and timestamp)
+	IsStateEventsSubscriber bool
+
+	// ClientVersion - version of the client application (reported in 'Hello' request)
+	ClientVersion string
+
+	// pendingRequests - count of requests which are currently in processing (for diagnostics)
+	pendingRequests int32
+
+	// requestsLimiter - per-connection limit of requests
+	requestsLimiter *rateLimiter
+
+	// live log streaming state of the connection (see 'SetLogStreaming' request):
+	// 'logStreamStopChan' is closed to stop the streaming routine (nil - streaming not active)
+	logStreamMutex    sync.Mutex
+	logStreamStopChan chan struct{}
+
+	sendChan chan sendQueueItem
+	stopChan chan struct{}
+	doneChan chan struct{} // closed when the writer routine finished
+	stopOnce sync.Once
+}
+
+// sendQueueItem - one queued response\notification
+type sendQueueItem struct {
+	cmd interface{}
+	idx int
+}
+
+// enqueue - queue a message to be sent by the writer routine of this connection
+func (c *clientConnection) enqueue(cmd interface{}, idx int) error {
+	select {
+	case c.sendChan <- sendQueueItem{cmd: cmd, idx: idx}:
+		return nil
+	case <-c.stopChan:
+		return fmt.Errorf("message not sent (connection closed)")
+	}
+}
+
+// markStopped - stop the writer routine and wait until queued messages are flushed
+func (c *clientConnection) markStopped() {
+	c.stopOnce.Do(func() { close(c.stopChan) })
+	select {
+	case <-c.doneChan:
+	case <-time.After(time.Second):
+	}
+}
+
+// commands which are allowed for read-only (monitoring) clients
+// all the rest commands require control access level
+var readOnlyCommands = map[string]struct{}{
+	"Hello":                      {},
+	"GetVPNState":                {},
+	"GetStateSnapshot":           {},
+	"GetServers":                 {},
+	"GetServerPorts":             {},
+	"PortForwardList":            {},
+	"PingServers":                {},
+	"GetGeoLookup":               {},
+	"GetConnectionHistory":       {},
+	"GetUsageStats":              {},
+	"WireGuardGetCustomProfiles": {},
+	"OpenVPNGetCustomProfiles":   {},
+	"KillSwitchGetStatus":        {},
+	"KillSwitchGetBlockedDNS":    {},
+	"KillSwitchGetIsPestistent":  {},
+	"NetworkRulesGet":            {},
+	"DnsProxyGetStatus":          {},
+	"RunLeakTest":                {},
+	"GetNetworkState":            {},
+	"SplitTunnelGetStatus":       {},
+	"GenerateDiagnostics":        {},
+	"SetLogStreaming":            {},
+	"AccountStatus":              {},
+	"SessionStatus":              {},
+	"DeviceList":                 {}}
+
 // Protocol - TCP interface to communicate with IVPN application
 type Protocol struct {
 	_secret uint64
 
-	// connections listener
-	_connListener *net.TCPListener
+	// connections listeners (TCP + OS-specific transports, e.g. named pipe on Windows)
+	_connListeners []net.Listener
 
 	_connectionsMutex sync.RWMutex
-	_connections      map[net.Conn]struct{}
+	_connections      map[net.Conn]*clientConnection
 
 	_service Service
 
@@ -137,19 +295,46 @@ type Protocol struct {
 	_connectRequests        int
 	_connectRequestLastTime time.Time
 
+	// rate limiting (abuse protection)
+	_connectRateLimiter *rateLimiter
+	_activeConnections  int32
+
+	// normalized parameters of the active (or establishing) VPN connection
+	// (empty - no active connection)
+	_activeConnParamsMutex sync.Mutex
+	_activeConnParams      string
+
 	// keep info about last VPN state
 	_lastVPNState vpn.StateInfo
+
+	// 'true' when the kill-switch was temporarily enabled for a seamless
+	// connection switch and has to be restored (see processConnectionRequestSeamless)
+	_fwRestoreMutex     sync.Mutex
+	_isFwRestorePending bool
+
+	// description of the daemon-side server selection of the last connection request,
+	// e.g. 'fastest: de.gw.ivpn.net' (empty - the server was defined by the client)
+	_serverSelectionInfo string
+
+	// sequence number of state events (for subscribed clients)
+	_eventSeqCounter int64
 }
 
 // Stop - stop communication
 func (p *Protocol) Stop() {
 	log.Info("Stopping")
 
-	listener := p._connListener
-	if listener != nil {
-		// do not accept new incoming connections
+	// do not accept new incoming connections
+	for _, listener := range p._connListeners {
 		listener.Close()
 	}
+	dbus.Stop()
+
+	// graceful service shutdown:
+	// stop background activities, disconnect VPN, restore DNS, flush preferences
+	if p._service != nil {
+		p._service.PrepareToStop()
+	}
 
 	// Notifying clients "service is going to stop" (client application (UI) will close)
 	// Closing and erasing all clients connections
@@ -186,7 +371,36 @@ func (p *Protocol) Start(secret uint64, startedOnPort chan<- int, service Servic
 	}
 
 	// save listener to a protocol field (to be able to stop it)
-	p._connListener = listener
+	p._connListeners = append(p._connListeners, listener)
+
+	// starting OS-specific additional transports (if defined)
+	// e.g. Windows uses named pipe (with ACL limiting access to privileged\interactive users)
+	// TCP listener kept for old clients during a transition period
+	if extraListeners, err := implStartAdditionalTransports(); err != nil {
+		log.Error("Failed to start additional transport: ", err)
+	} else {
+		p._connListeners = append(p._connListeners, extraListeners...)
+	}
+
+	// optional WebSocket bridge (for browser-based clients; enabled by preferences)
+	// same JSON protocol and authentication as the TCP transport
+	if prefs := p._service.Preferences(); prefs.IsWebSocketBridge {
+		wsPort := prefs.WebSocketBridgePort
+		if wsPort <= 0 {
+			wsPort = _defaultWebSocketPort
+		}
+		if wsListener, err := startWebSocketListener(wsPort); err != nil {
+			log.Error("Failed to start WebSocket bridge: ", err)
+		} else {
+			p._connListeners = append(p._connListeners, wsListener)
+		}
+	}
+
+	// register daemon operations on the system D-Bus (Linux only; no-op on other platforms)
+	// not fatal: the daemon stays fully functional over the TCP protocol without D-Bus
+	if err := dbus.Start(p); err != nil {
+		log.Error("Failed to register on D-Bus: ", err)
+	}
 
 	// get port opened by listener
 	openedPortStr := strings.Split(listener.Addr().String(), ":")[1]
@@ -197,20 +411,36 @@ func (p *Protocol) Start(secret uint64, startedOnPort chan<- int, service Servic
 	startedOnPort <- openedPort
 
 	log.Info(fmt.Sprintf("IVPN service started: %d [...%s]", openedPort, fmt.Sprintf("%016x", secret)[12:]))
+
+	// establish VPN connection on launch (if enabled in preferences)
+	go p.autoConnectOnLaunch()
 	defer func() {
-		listener.Close()
+		for _, l := range p._connListeners {
+			l.Close()
+		}
 		log.Info("IVPN service stopped")
 	}()
 
-	// infinite loop of processing IVPN client connection
-	for {
-		conn, err := listener.Accept()
-		if err != nil {
-			log.Error("Server: failed to accept incoming connection:", err)
-			return fmt.Errorf("(server) failed to accept incoming connection: %w", err)
-		}
-		go p.processClient(conn)
+	// infinite loop of processing IVPN client connections
+	// (each transport is processing connections in a separate routine)
+	acceptErrChan := make(chan error, len(p._connListeners))
+	for _, l := range p._connListeners {
+		go func(listener net.Listener) {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					acceptErrChan <- err
+					return
+				}
+				go p.processClient(conn)
+			}
+		}(l)
 	}
+
+	// wait until at least one listener stopped
+	err = <-acceptErrChan
+	log.Error("Server: failed to accept incoming connection:", err)
+	return fmt.Errorf("(server) failed to accept incoming connection: %w", err)
 }
 
 func (p *Protocol) processClient(conn net.Conn) {
@@ -222,6 +452,16 @@ func (p *Protocol) processClient(conn net.Conn) {
 	// In case of wrong secret - the daemon drops connection
 	isAuthenticated := false
 
+	// limit of simultaneous client connections
+	if atomic.AddInt32(&p._activeConnections, 1) > _maxClientConnections {
+		atomic.AddInt32(&p._activeConnections, -1)
+		log.Warning(fmt.Sprintf("Connection from %s rejected: too many client connections", conn.RemoteAddr()))
+		p.writeResponse(conn, &types.RequestThrottledResp{ErrorMessage: "too many client connections"}, 0)
+		conn.Close()
+		return
+	}
+	defer atomic.AddInt32(&p._activeConnections, -1)
+
 	log.Info("Client connected: ", conn.RemoteAddr())
 
 	stopChannel := make(chan struct{}, 1)
@@ -273,7 +513,9 @@ func (p *Protocol) processClient(conn net.Conn) {
 			case <-p._service.ServersUpdateNotifierChannel():
 				// servers update notifier
 				serv, _ := p._service.ServersList()
-				p.sendResponse(conn, &types.ServerListResp{VpnServers: *serv}, 0)
+				p.sendResponse(conn, &types.ServersUpdatedResp{
+					VpnServers:          *serv,
+					IsSignatureVerified: p._service.ServersSignatureVerified()}, 0)
 			case <-stopChannel:
 				return // stop loop
 			}
@@ -284,7 +526,8 @@ func (p *Protocol) processClient(conn net.Conn) {
 	// run loop forever (or until ctrl-c)
 	for {
 		// will listen for message to process ending in newline (\n)
-		message, err := reader.ReadString('\n')
+		// (the message length is limited: see 'decoder.MaxMessageSize')
+		message, err := decoder.ReadMessage(reader)
 		if err != nil {
 			if err != io.EOF {
 				log.Error("Error receiving data from client: ", err)
@@ -296,7 +539,7 @@ func (p *Protocol) processClient(conn net.Conn) {
 		if isAuthenticated == false {
 			messageData := []byte(message)
 
-			cmd, err := types.GetCommandBase(messageData)
+			cmd, err := decoder.DecodeCommand(messageData)
 			if err != nil {
 				log.Error(fmt.Sprintf("%sFailed to parse initialization request:", p.connLogID(conn)), err)
 				return
@@ -313,14 +556,17 @@ func (p *Protocol) processClient(conn net.Conn) {
 				return
 			}
 			if hello.Secret != p._secret {
-				p.sendErrorResponse(conn, cmd, fmt.Errorf("secret verification error"))
+				p.sendErrorResponseCode(conn, cmd, fmt.Errorf("secret verification error"), types.ErrorCodeNotAuthenticated)
 				return
 			}
 
 			// AUTHENTICATED
 			keepAlone = hello.KeepDaemonAlone
 			isAuthenticated = true
-			p.clientConnected(conn)
+			p.clientConnected(conn, &clientConnection{
+				IsReadOnly:              hello.ReadOnlyMode,
+				IsStateEventsSubscriber: hello.SubscribeStateEvents,
+				ClientVersion:           hello.Version})
 			go startChangesNotifier()
 		}
 
@@ -343,14 +589,51 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 
 	messageData := []byte(message)
 
-	reqCmd, err := types.GetCommandBase(messageData)
+	reqCmd, err := decoder.DecodeCommand(messageData)
 	if err != nil {
+		if errors.Is(err, decoder.ErrUnknownCommand) {
+			log.Warning("!!! Unsupported request type !!! ", reqCmd.Command)
+			p.sendErrorResponse(conn, reqCmd, fmt.Errorf("unsupported request: '%s'", reqCmd.Command))
+			return
+		}
 		log.Error(fmt.Sprintf("%sFailed to parse request:", p.connLogID(conn)), err)
 		return
 	}
 
 	log.Info("[<--] ", p.connLogID(conn), reqCmd.Command)
 
+	if ci := p.clientConnectionInfo(conn); ci != nil {
+		atomic.AddInt32(&ci.pendingRequests, 1)
+		defer atomic.AddInt32(&ci.pendingRequests, -1)
+
+		// per-connection requests limit
+		if isAllowed, retryAfterSec := ci.requestsLimiter.tryTake(); isAllowed == false {
+			log.Warning(fmt.Sprintf("%sRequest '%s' throttled (too many requests)", p.connLogID(conn), reqCmd.Command))
+			p.sendResponse(conn, &types.RequestThrottledResp{
+				ErrorMessage:  "too many requests",
+				RetryAfterSec: retryAfterSec}, reqCmd.Idx)
+			return
+		}
+	}
+
+	// Only authenticated clients are allowed to send requests
+	// ('Hello' request with correct secret is the only exception: it performs authentication itself)
+	if reqCmd.Command != "Hello" && p.isClientAuthenticated(conn) == false {
+		log.Warning(fmt.Sprintf("%sRequest '%s' from not authenticated client. Closing connection.", p.connLogID(conn), reqCmd.Command))
+		p.sendErrorResponseCode(conn, reqCmd, fmt.Errorf("client is not authenticated"), types.ErrorCodeNotAuthenticated)
+		conn.Close()
+		return
+	}
+
+	// Read-only (monitoring) clients are allowed to request only status\information commands
+	if p.isClientReadOnly(conn) {
+		if _, allowed := readOnlyCommands[reqCmd.Command]; allowed == false {
+			log.Warning(fmt.Sprintf("%sRequest '%s' not allowed for read-only client", p.connLogID(conn), reqCmd.Command))
+			p.sendErrorResponseCode(conn, reqCmd, fmt.Errorf("request '%s' not allowed for read-only client", reqCmd.Command), types.ErrorCodeNotPermitted)
+			return
+		}
+	}
+
 	switch reqCmd.Command {
 	case "Hello":
 		var req types.Hello
@@ -392,13 +675,17 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			// send VPN connection  state
 			vpnState := p._lastVPNState
 			if vpnState.State == vpn.CONNECTED {
+				prefs := p._service.Preferences()
 				p.sendResponse(conn, &types.ConnectedResp{
-					TimeSecFrom1970: vpnState.Time,
-					ClientIP:        vpnState.ClientIP.String(),
-					ServerIP:        vpnState.ServerIP.String(),
-					VpnType:         vpnState.VpnType,
-					ExitServerID:    vpnState.ExitServerID,
-					ManualDNS:       dns.GetLastManualDNS()},
+					TimeSecFrom1970:       vpnState.Time,
+					ClientIP:              vpnState.ClientIP.String(),
+					ServerIP:              vpnState.ServerIP.String(),
+					VpnType:               vpnState.VpnType,
+					ExitServerID:          vpnState.ExitServerID,
+					ManualDNS:             dns.GetLastManualDNS(),
+					ServerSelection:       p._serverSelectionInfo,
+					IsAntiTracker:         prefs.IsAntiTracker,
+					IsAntiTrackerHardcore: prefs.IsAntiTrackerHardcore},
 					req.Idx)
 			}
 		}
@@ -414,13 +701,17 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		// send VPN connection  state
 		vpnState := p._lastVPNState
 		if vpnState.State == vpn.CONNECTED {
+			prefs := p._service.Preferences()
 			p.sendResponse(conn, &types.ConnectedResp{
-				TimeSecFrom1970: vpnState.Time,
-				ClientIP:        vpnState.ClientIP.String(),
-				ServerIP:        vpnState.ServerIP.String(),
-				VpnType:         vpnState.VpnType,
-				ExitServerID:    vpnState.ExitServerID,
-				ManualDNS:       dns.GetLastManualDNS()},
+				TimeSecFrom1970:       vpnState.Time,
+				ClientIP:              vpnState.ClientIP.String(),
+				ServerIP:              vpnState.ServerIP.String(),
+				VpnType:               vpnState.VpnType,
+				ExitServerID:          vpnState.ExitServerID,
+				ManualDNS:             dns.GetLastManualDNS(),
+				ServerSelection:       p._serverSelectionInfo,
+				IsAntiTracker:         prefs.IsAntiTracker,
+				IsAntiTrackerHardcore: prefs.IsAntiTrackerHardcore},
 				reqCmd.Idx)
 		} else if vpnState.State == vpn.DISCONNECTED {
 			p.sendResponse(conn, &types.DisconnectedResp{Failure: false, Reason: 0, ReasonDescription: ""}, reqCmd.Idx)
@@ -430,6 +721,10 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 
 		break
 
+	case "GetStateSnapshot":
+		p.sendResponse(conn, p.createStateSnapshotResponse(), reqCmd.Idx)
+		break
+
 	case "GetServers":
 		serv, err := p._service.ServersList()
 		if err != nil {
@@ -443,6 +738,49 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.sendResponse(conn, &types.ServerListResp{VpnServers: *serv}, reqCmd.Idx)
 		break
 
+	case "GetServerPorts":
+		ovpnPorts, wgPorts := p._service.ServerPorts()
+		p.sendResponse(conn, &types.ServerPortsResp{OpenvpnPorts: ovpnPorts, WireguardPorts: wgPorts}, reqCmd.Idx)
+		break
+
+	case "SetPreferredPort":
+		var req types.SetPreferredPort
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetPreferredPort(req.VpnType, req.Port, req.IsTCP); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		}
+		break
+
+	case "PortForwardAdd":
+		if _, err := p._service.PortForwardAdd(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.PortForwardsResp{Ports: p._service.PortForwardList()}, reqCmd.Idx)
+		break
+
+	case "PortForwardRemove":
+		var req types.PortForwardRemove
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.PortForwardRemove(req.Port); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.PortForwardsResp{Ports: p._service.PortForwardList()}, reqCmd.Idx)
+		break
+
+	case "PortForwardList":
+		p.sendResponse(conn, &types.PortForwardsResp{Ports: p._service.PortForwardList()}, reqCmd.Idx)
+		break
+
 	case "PingServers":
 		var req types.PingServers
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -450,7 +788,7 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			break
 		}
 
-		retMap, err := p._service.PingServers(req.RetryCount, req.TimeOutMs)
+		retMap, err := p._service.PingServers(req.RetryCount, req.TimeOutMs, req.IntervalMs, req.MaxConcurrent, req.Hosts)
 		if err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
@@ -464,12 +802,76 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.sendResponse(conn, &types.PingServersResp{PingResults: results}, req.Idx)
 		break
 
+	case "GetConnectionHistory":
+		prefs := p._service.Preferences()
+		p.sendResponse(conn, &types.ConnectionHistoryResp{
+			IsEnabled: prefs.IsConnectionHistory,
+			Entries:   p._service.ConnectionHistory()}, reqCmd.Idx)
+		break
+
+	case "GetUsageStats":
+		p.sendResponse(conn, &types.UsageStatsResp{Buckets: p._service.UsageStats()}, reqCmd.Idx)
+		break
+
+	case "GetGeoLookup":
+		location, err := p._service.GetGeoLookup()
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.GeoLookupResp{Location: *location}, reqCmd.Idx)
+		break
+
 	case "KillSwitchGetStatus":
 		if isEnabled, isPersistant, isAllowLAN, isAllowLanMulticast, err := p._service.KillSwitchState(); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 		} else {
-			p.sendResponse(conn, &types.KillSwitchStatusResp{IsEnabled: isEnabled, IsPersistent: isPersistant, IsAllowLAN: isAllowLAN, IsAllowMulticast: isAllowLanMulticast}, reqCmd.Idx)
+			prefs := p._service.Preferences()
+			p.sendResponse(conn, &types.KillSwitchStatusResp{IsEnabled: isEnabled, IsPersistent: isPersistant, IsAllowLAN: isAllowLAN, IsAllowMulticast: isAllowLanMulticast,
+				IsAllowServiceDiscovery: prefs.IsFwAllowServiceDiscovery,
+				IsSoftMode:              prefs.IsFwSoftMode, IsSoftModeTriggered: p._service.KillSwitchSoftModeTriggered(),
+				LanAllowedAddresses: prefs.FwLanAllowedAddresses, UserExceptions: prefs.FwUserExceptions}, reqCmd.Idx)
+		}
+		break
+
+	case "KillSwitchGetBlockedDNS":
+		if blockedPackets, err := p._service.KillSwitchBlockedDNSCount(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+		} else {
+			p.sendResponse(conn, &types.KillSwitchBlockedDNSResp{BlockedPackets: blockedPackets}, reqCmd.Idx)
+		}
+		break
+
+	case "KillSwitchSetUserExceptions":
+		var req types.KillSwitchSetUserExceptions
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchUserExceptions(req.Exceptions); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified in case of successfull change by OnKillSwitchStateChanged() handler
+		break
+
+	case "KillSwitchSetLanAllowedAddresses":
+		var req types.KillSwitchSetLanAllowedAddresses
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchLanAllowedAddresses(req.Addresses); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
 		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified in case of successfull change by OnKillSwitchStateChanged() handler
 		break
 
 	case "KillSwitchSetEnabled":
@@ -483,6 +885,9 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
 		}
+		// an explicit kill-switch change by the user cancels the pending restore
+		// of the state saved before a seamless connection switch
+		p.setFwRestorePending(false)
 
 		// send the response to the requestor
 		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
@@ -490,6 +895,94 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 
 		break
 
+	case "NetworkRulesGet":
+		prefs := p._service.Preferences()
+		p.sendResponse(conn, &types.NetworkRulesResp{IsEnabled: prefs.IsNetworkRulesEnabled, Rules: prefs.NetworkRules}, reqCmd.Idx)
+		break
+
+	case "NetworkRulesSet":
+		var req types.NetworkRulesSet
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetNetworkRules(req.IsEnabled, req.Rules); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		// send back updated rules configuration as confirmation
+		prefs := p._service.Preferences()
+		p.sendResponse(conn, &types.NetworkRulesResp{IsEnabled: prefs.IsNetworkRulesEnabled, Rules: prefs.NetworkRules}, req.Idx)
+		break
+
+	case "SplitTunnelGetStatus":
+		isEnabled, isInverse, apps, runningApps := p._service.SplitTunnelStatus()
+		p.sendResponse(conn, &types.SplitTunnelStatusResp{IsEnabled: isEnabled, IsInverse: isInverse, Apps: apps, RunningApps: runningApps}, reqCmd.Idx)
+		break
+
+	case "SplitTunnelSetEnabled":
+		var req types.SplitTunnelSetEnabled
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SplitTunnelSet(req.IsEnabled, req.IsInverse, p._service.Preferences().SplitTunnelApps); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified by OnSplitTunnelStatusChanged() handler
+		break
+
+	case "SplitTunnelSetRoutes":
+		var req types.SplitTunnelSetRoutes
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SplitTunnelSetRoutes(req.BypassCIDRs, req.VpnCIDRs); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		break
+
+	case "SplitTunnelAddApp":
+		var req types.SplitTunnelAddApp
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SplitTunnelAddApp(req.AppBinaryPath); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		break
+
+	case "SplitTunnelRemoveApp":
+		var req types.SplitTunnelRemoveApp
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SplitTunnelRemoveApp(req.AppBinaryPath); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		break
+
 	case "KillSwitchSetAllowLANMulticast":
 		var req types.KillSwitchSetAllowLANMulticast
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -518,6 +1011,38 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		// all clients will be notified in case of successfull change by OnKillSwitchStateChanged() handler
 		break
 
+	case "KillSwitchSetAllowServiceDiscovery":
+		var req types.KillSwitchSetAllowServiceDiscovery
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchAllowServiceDiscovery(req.AllowServiceDiscovery); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified in case of successfull change by OnKillSwitchStateChanged() handler
+		break
+
+	case "KillSwitchSetSoftMode":
+		var req types.KillSwitchSetSoftMode
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetKillSwitchSoftMode(req.IsSoftMode); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// all clients will be notified in case of successfull change by OnKillSwitchStateChanged() handler
+		break
+
 	case "KillSwitchSetIsPersistent":
 		var req types.KillSwitchSetIsPersistent
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -555,14 +1080,85 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		}
 		break
 
+	case "SetAntiTracker":
+		var req types.SetAntiTracker
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetAntiTracker(req.Enabled, req.Hardcore); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, req.Idx)
+		// in case of a live tunnel, clients are notified about the new DNS by OnDNSChanged() handler
+		break
+
+	case "DnsProxySet":
+		var req types.DnsProxySet
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.SetDnsProxyConfig(req.Enabled, req.Encryption, req.Resolver); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		enabled, encryption, resolver := p._service.DnsProxyConfig()
+		p.sendResponse(conn, &types.DnsProxyStatusResp{Enabled: enabled, Encryption: encryption, Resolver: resolver}, req.Idx)
+		break
+
+	case "DnsProxyGetStatus":
+		enabled, encryption, resolver := p._service.DnsProxyConfig()
+		p.sendResponse(conn, &types.DnsProxyStatusResp{Enabled: enabled, Encryption: encryption, Resolver: resolver}, reqCmd.Idx)
+		break
+
 	case "GenerateDiagnostics":
 		if log, log0, err := logger.GetLogText(1024 * 64); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 		} else {
-			p.sendResponse(conn, &types.DiagnosticsGeneratedResp{ServiceLog: log, ServiceLog0: log0}, reqCmd.Idx)
+			// environment info: the API access policy in use
+			// (explains why API requests can fail when the kill-switch is enabled)
+			prefs := p._service.Preferences()
+			envLog := fmt.Sprintf("API access policy: through the tunnel when connected; firewall exception for API servers when disconnected: %v", prefs.IsFwAllowApiServers)
+			p.sendResponse(conn, &types.DiagnosticsGeneratedResp{ServiceLog: log, ServiceLog0: log0, EnvironmentLog: envLog}, reqCmd.Idx)
 		}
 		break
 
+	case "SetLogLevel":
+		var req types.SetLogLevel
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := logger.SetLogLevel(req.Level); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		log.Info(fmt.Sprintf("Log level changed to '%s'", logger.GetLogLevel()))
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "SetLogStreaming":
+		var req types.SetLogStreaming
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if ci := p.clientConnectionInfo(conn); ci != nil {
+			if req.Enable {
+				p.startLogStreaming(ci)
+			} else {
+				p.stopLogStreaming(ci)
+			}
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
 	case "SetAlternateDns":
 		var req types.SetAlternateDns
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -574,14 +1170,21 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		if ip := net.ParseIP(req.DNS); ip == nil || ip.Equal(net.IPv4zero) || ip.Equal(net.IPv4bcast) {
 			err = p._service.ResetManualDNS()
 		} else {
-			err = p._service.SetManualDNS(ip)
-
-			if err != nil {
-				// DNS set failed. Trying to reset DNS
-				errReset := p._service.ResetManualDNS()
-				if errReset != nil {
-					log.ErrorTrace(errReset)
+			// primary + fallback DNS servers
+			// (fallbacks are applied when the primary is not reachable through the tunnel)
+			servers := []net.IP{ip}
+			for _, dnsStr := range req.DNSFallbacks {
+				fallbackIP := net.ParseIP(dnsStr)
+				if fallbackIP == nil {
+					err = fmt.Errorf("failed to parse fallback DNS server '%s'", dnsStr)
+					break
 				}
+				servers = append(servers, fallbackIP)
+			}
+
+			if err == nil {
+				// rolls back to the default DNS configuration when no server works
+				err = p._service.SetManualDNSServers(servers)
 			}
 		}
 
@@ -591,13 +1194,61 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 			p.sendResponse(conn, &types.SetAlternateDNSResp{IsSuccess: false, ChangedDNS: net.IPv4zero.String()}, req.Idx)
 		} else {
 			// send the response to the requestor
-			p.sendResponse(conn, &types.SetAlternateDNSResp{IsSuccess: true, ChangedDNS: req.DNS}, req.Idx)
+			p.sendResponse(conn, &types.SetAlternateDNSResp{IsSuccess: true, ChangedDNS: dns.GetLastManualDNS()}, req.Idx)
 			// all clients will be notified in case of successfull change by OnDNSChanged() handler
 		}
 		break
 
+	case "RunLeakTest":
+		externalIP, isDNSLeak, isIPv6Leak, err := p._service.RunLeakTest()
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.LeakTestResp{
+			ExternalIP:         externalIP,
+			IsDNSLeakDetected:  isDNSLeak,
+			IsIPv6LeakDetected: isIPv6Leak}, reqCmd.Idx)
+		break
+
+	case "GetNetworkState":
+		defaultGatewayIP, defaultInterfaceName, tunnelInterfaceName, tunnelLocalIP, dnsServers, configuredDNS, isDNSMatch, otherVpnInterfaces := p._service.GetNetworkState()
+		p.sendResponse(conn, &types.NetworkStateResp{
+			DefaultGatewayIP:     defaultGatewayIP,
+			DefaultInterfaceName: defaultInterfaceName,
+			TunnelInterfaceName:  tunnelInterfaceName,
+			TunnelLocalIP:        tunnelLocalIP,
+			DNSServers:           dnsServers,
+			ConfiguredDNS:        configuredDNS,
+			IsDNSMatch:           isDNSMatch,
+			OtherVpnInterfaces:   otherVpnInterfaces}, reqCmd.Idx)
+		break
+
+	case "RepairDNS":
+		if err := p._service.RepairDNS(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "RepairNetworkAdapters":
+		repairedItems, err := p._service.RepairNetworkAdapters()
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.NetworkAdaptersRepairedResp{RepairedItems: repairedItems}, reqCmd.Idx)
+		break
+
 	case "PauseConnection":
-		if err := p._service.Pause(); err != nil {
+		var req types.PauseConnection
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.Pause(req.Duration); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
 		}
@@ -614,7 +1265,8 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
 		break
 
-	case "SessionNew":
+	case "SessionNew", "Login":
+		// 'Login' is an alias of 'SessionNew' (the request shape is the same)
 		var req types.SessionNew
 		if err := json.Unmarshal(messageData, &req); err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
@@ -623,7 +1275,7 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 
 		var resp types.SessionNewResp
 
-		apiCode, apiErrMsg, accountInfo, err := p._service.SessionNew(req.AccountID, req.ForceLogin)
+		apiCode, apiErrMsg, accountInfo, captchaID, captchaImage, err := p._service.SessionNew(req.AccountID, req.ForceLogin, req.CaptchaID, req.Captcha, req.Confirmation2FA)
 		if err != nil {
 			if apiCode == 0 {
 				// if apiCode == 0 - it is not API error. Sending error response
@@ -631,11 +1283,15 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 				break
 			}
 			// sending API error info
+			// (the '2FA required' and 'captcha required' cases are reported this way:
+			// the client is expected to repeat the request with the code\captcha value defined)
 			resp = types.SessionNewResp{
 				APIStatus:       apiCode,
 				APIErrorMessage: apiErrMsg,
 				Session:         types.SessionResp{}, // empty session info
-				Account:         accountInfo}
+				Account:         accountInfo,
+				CaptchaID:       captchaID,
+				CaptchaImage:    captchaImage}
 		} else {
 			// Success. Sending session info
 			resp = types.SessionNewResp{
@@ -652,19 +1308,21 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.notifyClients(p.createHelloResponse())
 		break
 
-	case "SessionDelete":
-		err := p._service.SessionDelete()
+	case "SessionDelete", "Logout":
+		wipedItems, err := p._service.SessionDelete()
 		if err != nil {
 			p.sendErrorResponse(conn, reqCmd, err)
 			break
 		}
-		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		// confirm the logout and the securely wiped credential items
+		p.sendResponse(conn, &types.CredentialsWipedResp{WipedItems: wipedItems}, reqCmd.Idx)
 
 		// notify all clients about changed session status
 		p.notifyClients(p.createHelloResponse())
 		break
 
-	case "AccountStatus":
+	case "AccountStatus", "SessionStatus":
+		// 'SessionStatus' is an alias of 'AccountStatus'
 		var resp types.AccountStatusResp
 		apiCode, apiErrMsg, sessionToken, accountInfo, err := p._service.RequestSessionStatus()
 		if err != nil && apiCode == 0 {
@@ -683,6 +1341,90 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		p.sendResponse(conn, &resp, reqCmd.Idx)
 		break
 
+	case "DeviceList":
+		devices, err := p._service.DeviceList()
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.DeviceListResp{Devices: devices}, reqCmd.Idx)
+		break
+
+	case "DeviceDelete":
+		var req types.DeviceDelete
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		if err := p._service.DeviceDelete(req.DeviceID); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "WireGuardImportCustomProfile":
+		var req types.WireGuardImportCustomProfile
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.ImportWGCustomProfile(req.Name, req.Config); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "WireGuardRemoveCustomProfile":
+		var req types.WireGuardRemoveCustomProfile
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.RemoveWGCustomProfile(req.Name); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "WireGuardGetCustomProfiles":
+		p.sendResponse(conn, &types.WireGuardCustomProfilesResp{Profiles: p._service.WGCustomProfiles()}, reqCmd.Idx)
+		break
+
+	case "OpenVPNImportCustomProfile":
+		var req types.OpenVPNImportCustomProfile
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		rejected, err := p._service.ImportOpenVPNCustomProfile(req.Name, req.Config)
+		if err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.OpenVPNProfileImportResp{Name: req.Name, RejectedDirectives: rejected}, reqCmd.Idx)
+		break
+
+	case "OpenVPNRemoveCustomProfile":
+		var req types.OpenVPNRemoveCustomProfile
+		if err := json.Unmarshal(messageData, &req); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		if err := p._service.RemoveOpenVPNCustomProfile(req.Name); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
+	case "OpenVPNGetCustomProfiles":
+		p.sendResponse(conn, &types.OpenVPNCustomProfilesResp{Profiles: p._service.OpenVPNCustomProfiles()}, reqCmd.Idx)
+		break
+
 	case "WireGuardGenerateNewKeys":
 		var req types.WireGuardGenerateNewKeys
 		if err := json.Unmarshal(messageData, &req); err != nil {
@@ -720,125 +1462,217 @@ func (p *Protocol) processRequest(conn net.Conn, message string) {
 		}
 		break
 
+	case "CancelConnect":
+		p._disconnectRequested = true
+
+		if err := p._service.CancelConnect(); err != nil {
+			p.sendErrorResponse(conn, reqCmd, err)
+			break
+		}
+
+		p.sendResponse(conn, &types.EmptyResp{}, reqCmd.Idx)
+		break
+
 	case "Connect":
-		p._disconnectRequested = false
-		requestTime := p.vpnConnectReqCounterIncrease()
+		// Idempotent connect: when the requested parameters match the already
+		// active connection - just report the current state (no reconnection)
+		if vpnState := p._lastVPNState; vpnState.State == vpn.CONNECTED && p.isActiveConnectionParams(messageData) {
+			log.Info(fmt.Sprintf("%sConnection request matches the active connection. Reporting current state.", p.connLogID(conn)))
+			prefs := p._service.Preferences()
+			p.sendResponse(conn, &types.ConnectedResp{
+				TimeSecFrom1970:       vpnState.Time,
+				ClientIP:              vpnState.ClientIP.String(),
+				ServerIP:              vpnState.ServerIP.String(),
+				VpnType:               vpnState.VpnType,
+				ExitServerID:          vpnState.ExitServerID,
+				ManualDNS:             dns.GetLastManualDNS(),
+				ServerSelection:       p._serverSelectionInfo,
+				IsAntiTracker:         prefs.IsAntiTracker,
+				IsAntiTrackerHardcore: prefs.IsAntiTrackerHardcore}, reqCmd.Idx)
+			break
+		}
 
-		stateChan := make(chan vpn.StateInfo, 1)
-		isExitChan := make(chan bool, 1)
-		disconnectAuthError := false
-		var connectionError error
+		// global limit of VPN connection requests
+		if isAllowed, retryAfterSec := p._connectRateLimiter.tryTake(); isAllowed == false {
+			log.Warning(fmt.Sprintf("%sConnection request throttled (too many connection attempts)", p.connLogID(conn)))
+			p.sendResponse(conn, &types.RequestThrottledResp{
+				ErrorMessage:  "too many connection attempts",
+				RetryAfterSec: retryAfterSec}, reqCmd.Idx)
+			break
+		}
 
-		// disconnect active connection (if connected)
-		if err := p._service.Disconnect(); err != nil {
-			log.ErrorTrace(err)
+		// remember request parameters (for 'auto-connect on launch' functionality)
+		if err := p._service.SetLastConnectionParams(string(messageData)); err != nil {
+			log.Error("Failed to save last connection parameters: ", err)
 		}
 
-		p._vpnConnectMutex.Lock()
-		defer p._vpnConnectMutex.Unlock()
+		p.processConnectionRequestSeamless(messageData)
+		break
 
-		defer p.vpnConnectReqCounterDecrease()
+	default:
+		log.Warning("!!! Unsupported request type !!! ", reqCmd.Command)
+		log.Debug("Unsupported request:", message)
+		p.sendErrorResponse(conn, reqCmd, fmt.Errorf("unsupported request: '%s'", reqCmd.Command))
+	}
+}
 
-		// skip this request if new connection request available
-		if _, lastRequestTime := p.vpnConnectReqCounter(); requestTime.Equal(lastRequestTime) == false {
-			log.Info("Skipping connection request. Newest request received.")
-			return
+// processConnectionRequestSeamless - process a connection request with no traffic
+// leak window when another VPN connection is already active: the kill-switch stays
+// enabled while the old tunnel is being replaced (and is restored to the state
+// the user had before the switch)
+func (p *Protocol) processConnectionRequestSeamless(messageData []byte) {
+	if p._lastVPNState.State == vpn.CONNECTED {
+		if isEnabled, isPersistant, _, _, err := p._service.KillSwitchState(); err == nil && isEnabled == false && isPersistant == false {
+			if err := p._service.SetKillSwitchState(true); err == nil {
+				p.setFwRestorePending(true)
+				log.Info("Kill-switch temporarily enabled for the duration of the connection switch")
+			}
 		}
+	}
 
-		var waiter sync.WaitGroup
+	p.processConnectionRequest(messageData)
 
-		// do not forget to notify that process was stopped (disconnected)
-		defer func() {
+	// normally the kill-switch is restored as soon as the new tunnel reaches
+	// the CONNECTED state (processConnectionRequest blocks for the whole tunnel
+	// lifetime - waiting for it to return would keep the kill-switch changed
+	// for hours); this call covers connection attempts which failed before
+	// reaching CONNECTED
+	p.restoreKillSwitchAfterSwitch()
+}
 
-			// stop all go-routines related to this connections
-			close(isExitChan)
+// processConnectionRequest - SYNCHRONOUSLY process request on establishing new VPN connection
+// (in use by the requests dispatcher and by 'auto-connect on launch' functionality)
+func (p *Protocol) processConnectionRequest(messageData []byte) {
+	p._disconnectRequested = false
+	p.setActiveConnectionParams(messageData)
+	requestTime := p.vpnConnectReqCounterIncrease()
+
+	stateChan := make(chan vpn.StateInfo, 1)
+	isExitChan := make(chan bool, 1)
+	disconnectAuthError := false
+	disconnectErrorMarker := "" // typed fatal-error classification from the VPN process (empty - none)
+	wasConnected := false       // 'true' when CONNECTED state was reached at least once
+	var connectionError error
+
+	// disconnect active connection (if connected)
+	if err := p._service.Disconnect(); err != nil {
+		log.ErrorTrace(err)
+	}
 
-			// Do not send "Disconnected" notification if we are going to establish new connection immediately
-			if cnt, _ := p.vpnConnectReqCounter(); cnt == 1 || p._disconnectRequested {
-				p._lastVPNState = vpn.NewStateInfo(vpn.DISCONNECTED, "")
+	p._vpnConnectMutex.Lock()
+	defer p._vpnConnectMutex.Unlock()
 
-				// Sending "Disconnected" only in one place (after VPN process stopped)
-				disconnectionReason := types.Unknown
-				if disconnectAuthError == true {
-					disconnectionReason = types.AuthenticationError
-					if connectionError == nil {
-						connectionError = fmt.Errorf("authentication failure")
-					}
-				}
-				if p._disconnectRequested {
-					// notify clients that disconnection was manually requested by one of connected clients
-					// (prevent UI clients trying to reconnect)
-					disconnectionReason = types.DisconnectRequested
-				}
+	defer p.vpnConnectReqCounterDecrease()
 
-				errMsg := ""
-				if connectionError != nil {
-					errMsg = connectionError.Error()
-				}
-				p.notifyClients(&types.DisconnectedResp{Failure: connectionError != nil, Reason: disconnectionReason, ReasonDescription: errMsg})
+	// skip this request if new connection request available
+	if _, lastRequestTime := p.vpnConnectReqCounter(); requestTime.Equal(lastRequestTime) == false {
+		log.Info("Skipping connection request. Newest request received.")
+		return
+	}
+
+	var waiter sync.WaitGroup
+
+	// do not forget to notify that process was stopped (disconnected)
+	defer func() {
+
+		// stop all go-routines related to this connections
+		close(isExitChan)
+
+		// Do not send "Disconnected" notification if we are going to establish new connection immediately
+		if cnt, _ := p.vpnConnectReqCounter(); cnt == 1 || p._disconnectRequested {
+			p._lastVPNState = vpn.NewStateInfo(vpn.DISCONNECTED, "")
+			p.setActiveConnectionParams(nil)
+
+			// Sending "Disconnected" only in one place (after VPN process stopped)
+			// classify the disconnection cause (UI and reconnect policy react on it)
+			disconnectionReason := classifyDisconnectionReason(disconnectAuthError, disconnectErrorMarker, connectionError, wasConnected)
+			if disconnectAuthError == true && connectionError == nil {
+				connectionError = fmt.Errorf("authentication failure")
+			}
+			if p._disconnectRequested {
+				// notify clients that disconnection was manually requested by one of connected clients
+				// (prevent UI clients trying to reconnect)
+				disconnectionReason = types.DisconnectRequested
 			}
 
-			// wait all routines to stop
-			waiter.Wait()
-		}()
+			errMsg := ""
+			if connectionError != nil {
+				errMsg = connectionError.Error()
+			}
+			p.notifyClients(&types.DisconnectedResp{
+				Failure:           connectionError != nil,
+				Reason:            disconnectionReason,
+				ReasonDescription: errMsg,
+				RemediationHint:   remediationHint(disconnectionReason)})
+		}
 
-		// forwarding VPN state in separate routine
-		waiter.Add(1)
-		go func() {
-			log.Info("Enter VPN status checker")
-			defer func() {
-				if r := recover(); r != nil {
-					log.Error("VPN status checker panic!")
-					if err, ok := r.(error); ok {
-						log.ErrorTrace(err)
-					}
-				}
-				log.Info("Exit VPN status checker")
-				waiter.Done()
-			}()
+		// wait all routines to stop
+		waiter.Wait()
+	}()
 
-		state_forward_loop:
-			for {
-				select {
-				case state := <-stateChan:
-					p._lastVPNState = state
-
-					switch state.State {
-					case vpn.CONNECTED:
-						// Do not send "Connected" notification if we are going to establish new connection immediately
-						if cnt, _ := p.vpnConnectReqCounter(); cnt == 1 || p._disconnectRequested {
-							p.notifyClients(&types.ConnectedResp{
-								TimeSecFrom1970: state.Time,
-								ClientIP:        state.ClientIP.String(),
-								ServerIP:        state.ServerIP.String(),
-								VpnType:         state.VpnType,
-								ExitServerID:    state.ExitServerID,
-								ManualDNS:       dns.GetLastManualDNS()})
-
-						} else {
-							log.Debug("Skip sending 'Connected' notification. New connection request is awaiting ", cnt)
-						}
-					case vpn.EXITING:
-						disconnectAuthError = state.IsAuthError
-					default:
-						p.notifyClients(&types.VpnStateResp{StateVal: state.State, State: state.State.String(), StateAdditionalInfo: state.StateAdditionalInfo})
-					}
-				case <-isExitChan:
-					break state_forward_loop
+	// forwarding VPN state in separate routine
+	waiter.Add(1)
+	go func() {
+		log.Info("Enter VPN status checker")
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("VPN status checker panic!")
+				if err, ok := r.(error); ok {
+					log.ErrorTrace(err)
 				}
 			}
+			log.Info("Exit VPN status checker")
+			waiter.Done()
 		}()
 
-		// SYNCHRONOUSLY start VPN connection process (wait until it finished)
-		if connectionError = p.processConnectRequest(messageData, stateChan); connectionError != nil {
-			log.ErrorTrace(connectionError)
+	state_forward_loop:
+		for {
+			select {
+			case state := <-stateChan:
+				p._lastVPNState = state
+
+				switch state.State {
+				case vpn.CONNECTED:
+					wasConnected = true
+					// the connection switch is over: restore the kill-switch state
+					// which was temporarily changed for the switch (if pending)
+					p.restoreKillSwitchAfterSwitch()
+					// Do not send "Connected" notification if we are going to establish new connection immediately
+					if cnt, _ := p.vpnConnectReqCounter(); cnt == 1 || p._disconnectRequested {
+						prefs := p._service.Preferences()
+						p.notifyClients(&types.ConnectedResp{
+							TimeSecFrom1970:       state.Time,
+							ClientIP:              state.ClientIP.String(),
+							ServerIP:              state.ServerIP.String(),
+							VpnType:               state.VpnType,
+							ExitServerID:          state.ExitServerID,
+							ManualDNS:             dns.GetLastManualDNS(),
+							ServerSelection:       p._serverSelectionInfo,
+							IsAntiTracker:         prefs.IsAntiTracker,
+							IsAntiTrackerHardcore: prefs.IsAntiTrackerHardcore})
+
+					} else {
+						log.Debug("Skip sending 'Connected' notification. New connection request is awaiting ", cnt)
+					}
+				case vpn.EXITING:
+					disconnectAuthError = state.IsAuthError
+				default:
+					// remember typed fatal-error markers reported by the VPN process
+					// (in use for the disconnection reason classification)
+					switch state.StateAdditionalInfo {
+					case "tls-error", "tap-adapters-exhausted":
+						disconnectErrorMarker = state.StateAdditionalInfo
+					}
+					p.notifyClients(&types.VpnStateResp{StateVal: state.State, State: state.State.String(), StateAdditionalInfo: state.StateAdditionalInfo})
+				}
+			case <-isExitChan:
+				break state_forward_loop
+			}
 		}
+	}()
 
-		break
-
-	default:
-		log.Warning("!!! Unsupported request type !!! ", reqCmd.Command)
-		log.Debug("Unsupported request:", message)
-		p.sendErrorResponse(conn, reqCmd, fmt.Errorf("unsupported request: '%s'", reqCmd.Command))
+	// SYNCHRONOUSLY start VPN connection process (wait until it finished)
+	if connectionError = p.processConnectRequest(messageData, stateChan); connectionError != nil {
+		log.ErrorTrace(connectionError)
 	}
 }
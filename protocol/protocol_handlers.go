@@ -25,6 +25,7 @@ package protocol
 import (
 	"net"
 
+	apitypes "github.com/ivpn/desktop-app-daemon/api/types"
 	"github.com/ivpn/desktop-app-daemon/protocol/types"
 	"github.com/ivpn/desktop-app-daemon/service/preferences"
 	"github.com/ivpn/desktop-app-daemon/version"
@@ -45,6 +46,13 @@ func (p *Protocol) OnServiceSessionChanged() {
 	p.notifyClients(&helloResp)
 }
 
+// OnSessionExpired - handler of invalidated session (revoked\expired on the backend).
+// Notifying clients: UIs are expected to prompt the user to log in again.
+func (p *Protocol) OnSessionExpired(reason string) {
+	log.Warning("Session is not valid anymore: ", reason)
+	p.notifyClients(&types.SessionExpiredResp{Reason: reason})
+}
+
 // OnAccountStatus - handler of account status info. Notifying clients.
 func (p *Protocol) OnAccountStatus(sessionToken string, accountInfo preferences.AccountStatus) {
 	if len(sessionToken) == 0 {
@@ -56,6 +64,16 @@ func (p *Protocol) OnAccountStatus(sessionToken string, accountInfo preferences.
 		Account:      accountInfo})
 }
 
+// OnAccountSubscriptionStatus - handler of subscription expiry\downgrade conditions. Notifying clients.
+func (p *Protocol) OnAccountSubscriptionStatus(account preferences.AccountStatus, isGracePeriod bool, revokedCapabilities []string) {
+	p.notifyClients(&types.AccountSubscriptionStatusResp{
+		Active:              account.Active,
+		ActiveUntil:         account.ActiveUntil,
+		IsGracePeriod:       isGracePeriod,
+		WillAutoRebill:      account.WillAutoRebill,
+		RevokedCapabilities: revokedCapabilities})
+}
+
 // OnDNSChanged - DNS changed handler
 func (p *Protocol) OnDNSChanged(dns net.IP) {
 	// notify all clients
@@ -66,12 +84,89 @@ func (p *Protocol) OnDNSChanged(dns net.IP) {
 	}
 }
 
+// OnConnectionProgress - handler of connection progress events. Notifying clients.
+func (p *Protocol) OnConnectionProgress(stage string, description string) {
+	p.notifyClients(&types.ConnectionProgressResp{Stage: stage, Description: description})
+}
+
+// OnConnectionStats - handler of periodic connection statistics updates. Notifying clients.
+func (p *Protocol) OnConnectionStats(bytesReceived uint64, bytesSent uint64, lastHandshake int64, endpoint string) {
+	p.notifyClients(&types.ConnectionStatsResp{
+		BytesReceived:     bytesReceived,
+		BytesSent:         bytesSent,
+		LastHandshakeTime: lastHandshake,
+		Endpoint:          endpoint})
+}
+
+// OnNetworkTrustChanged - handler of applied Wi-Fi network trust rule. Notifying clients.
+func (p *Protocol) OnNetworkTrustChanged(ssid string, isTrusted bool) {
+	p.notifyClients(&types.NetworkTrustChangedResp{SSID: ssid, IsTrusted: isTrusted})
+}
+
+// OnAPIReachabilityChanged - handler of API reachability state change. Notifying clients.
+func (p *Protocol) OnAPIReachabilityChanged(isReachable bool) {
+	if isReachable == false {
+		log.Warning("API servers are not reachable")
+	}
+	p.notifyClients(&types.APIReachabilityResp{IsReachable: isReachable})
+}
+
+// OnRecoveredFromCrash - handler of the daemon start repairs performed after an unclean shutdown. Notifying clients.
+func (p *Protocol) OnRecoveredFromCrash(repairedItems []string) {
+	p.notifyClients(&types.RecoveredFromCrashResp{RepairedItems: repairedItems})
+}
+
+// OnDataCapReached - handler of a reached data-cap limit. Notifying clients.
+func (p *Protocol) OnDataCapReached(period string, limitMB int, transferredBytes uint64, isConnectionPaused bool) {
+	p.notifyClients(&types.DataCapReachedResp{
+		Period:             period,
+		LimitMB:            limitMB,
+		TransferredBytes:   transferredBytes,
+		IsConnectionPaused: isConnectionPaused})
+}
+
+// OnOtherVpnDetected - handler of 'another active VPN detected on connect' event. Notifying clients.
+func (p *Protocol) OnOtherVpnDetected(vpnInterfaces []string, policy string) {
+	p.notifyClients(&types.OtherVpnDetectedResp{
+		VpnInterfaces: vpnInterfaces,
+		Policy:        policy})
+}
+
+// OnWGLocalIPConflict - handler of a detected conflict of the WG local address with a local subnet. Notifying clients.
+func (p *Protocol) OnWGLocalIPConflict(wgLocalIP string, conflictInterface string, conflictSubnet string, isNewIPRequested bool) {
+	p.notifyClients(&types.WGLocalIPConflictResp{
+		WgLocalIP:         wgLocalIP,
+		ConflictInterface: conflictInterface,
+		ConflictSubnet:    conflictSubnet,
+		IsNewIPRequested:  isNewIPRequested})
+}
+
+// OnUpdateAvailable - handler of 'update available' event. Notifying clients.
+func (p *Protocol) OnUpdateAvailable(updateInfo apitypes.UpdateInfoResponse, stagedArtifactPath string) {
+	p.notifyClients(&types.UpdateAvailableResp{UpdateInfo: updateInfo, StagedArtifactPath: stagedArtifactPath})
+}
+
+// OnSplitTunnelStatusChanged - handler of split-tunneling status change. Notifying clients.
+func (p *Protocol) OnSplitTunnelStatusChanged(isEnabled bool, isInverse bool, apps []string, runningApps []string) {
+	p.notifyClients(&types.SplitTunnelStatusResp{IsEnabled: isEnabled, IsInverse: isInverse, Apps: apps, RunningApps: runningApps})
+}
+
+// OnKillSwitchReapplied - handler of automatic re-apply of flushed firewall rules. Notifying clients.
+func (p *Protocol) OnKillSwitchReapplied() {
+	log.Warning("Kill-switch rules were re-applied (flushed by another application?)")
+	p.notifyClients(&types.KillSwitchReappliedResp{})
+}
+
 // OnKillSwitchStateChanged - Firewall change handler
 func (p *Protocol) OnKillSwitchStateChanged() {
 	// notify all clients about KillSwitch status
 	if isEnabled, isPersistant, isAllowLAN, isAllowLanMulticast, err := p._service.KillSwitchState(); err != nil {
 		log.Error(err)
 	} else {
-		p.notifyClients(&types.KillSwitchStatusResp{IsEnabled: isEnabled, IsPersistent: isPersistant, IsAllowLAN: isAllowLAN, IsAllowMulticast: isAllowLanMulticast})
+		prefs := p._service.Preferences()
+		p.notifyClients(&types.KillSwitchStatusResp{IsEnabled: isEnabled, IsPersistent: isPersistant, IsAllowLAN: isAllowLAN, IsAllowMulticast: isAllowLanMulticast,
+			IsAllowServiceDiscovery: prefs.IsFwAllowServiceDiscovery,
+			IsSoftMode:              prefs.IsFwSoftMode, IsSoftModeTriggered: p._service.KillSwitchSoftModeTriggered(),
+			LanAllowedAddresses: prefs.FwLanAllowedAddresses, UserExceptions: prefs.FwUserExceptions})
 	}
 }
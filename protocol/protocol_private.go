@@ -28,10 +28,18 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	apitypes "github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/netinfo"
 	"github.com/ivpn/desktop-app-daemon/protocol/types"
+	"github.com/ivpn/desktop-app-daemon/service"
+	"github.com/ivpn/desktop-app-daemon/service/dns"
 	"github.com/ivpn/desktop-app-daemon/version"
 	"github.com/ivpn/desktop-app-daemon/vpn"
 	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
@@ -61,26 +69,211 @@ func (p *Protocol) connLogID(c net.Conn) string {
 
 // -------------- send message to all active connections ---------------
 func (p *Protocol) notifyClients(cmd interface{}) {
+	// prepare event object for subscribed clients (initialized lazily: only when there is a subscriber)
+	var event *types.StateEventResp
+
 	p._connectionsMutex.RLock()
 	defer p._connectionsMutex.RUnlock()
-	for conn := range p._connections {
-		p.sendResponse(conn, cmd, 0)
+	for _, ci := range p._connections {
+		if ci.IsStateEventsSubscriber {
+			if event == nil {
+				event = p.wrapStateEvent(cmd)
+			}
+			ci.enqueue(event, 0)
+		} else {
+			ci.enqueue(cmd, 0)
+		}
+	}
+}
+
+// wrapStateEvent wraps a notification object into 'StateEventResp'
+// (assigns the next sequence number and the current timestamp)
+func (p *Protocol) wrapStateEvent(cmd interface{}) *types.StateEventResp {
+	event := &types.StateEventResp{
+		Seq:             atomic.AddInt64(&p._eventSeqCounter, 1),
+		TimeSecFrom1970: time.Now().Unix(),
+		EventType:       types.GetTypeName(cmd)}
+
+	data, err := types.Serialize(cmd, 0)
+	if err != nil {
+		log.Error(fmt.Errorf("failed to serialize state event: %w", err))
+		return event
+	}
+	event.Event = string(data)
+	return event
+}
+
+// createStateSnapshotResponse returns the current daemon state
+// with the sequence number of the last emitted state event
+func (p *Protocol) createStateSnapshotResponse() *types.StateSnapshotResp {
+	resp := &types.StateSnapshotResp{
+		Seq:         atomic.LoadInt64(&p._eventSeqCounter),
+		VpnStateVal: p._lastVPNState.State,
+		VpnState:    p._lastVPNState.State.String(),
+		ManualDNS:   dns.GetLastManualDNS()}
+
+	if isEnabled, isPersistant, _, _, err := p._service.KillSwitchState(); err != nil {
+		log.Error(err)
+	} else {
+		resp.FirewallIsEnabled = isEnabled
+		resp.FirewallIsPersistent = isPersistant
+	}
+	return resp
+}
+
+// autoConnectOnLaunch - establish VPN connection on daemon start
+// using parameters of the last 'Connect' request (if enabled in preferences)
+func (p *Protocol) autoConnectOnLaunch() {
+	prefs := p._service.Preferences()
+	if prefs.IsAutoConnectOnLaunch == false {
+		return
+	}
+	if len(prefs.LastConnectionParams) == 0 {
+		log.Info("Auto-connect on launch skipped: no saved connection parameters")
+		return
 	}
+	if len(prefs.Session.Session) == 0 {
+		log.Info("Auto-connect on launch skipped: not logged in")
+		return
+	}
+
+	log.Info("Auto-connect on launch...")
+	p.processConnectionRequest([]byte(prefs.LastConnectionParams))
 }
 
 // -------------- clients connections ---------------
-func (p *Protocol) clientConnected(c net.Conn) {
+func (p *Protocol) clientConnected(c net.Conn, ci *clientConnection) {
+	ci.conn = c
+	ci.requestsLimiter = newRateLimiter(_maxRequestsPerWindow, _requestsWindow)
+	ci.sendChan = make(chan sendQueueItem, 64)
+	ci.stopChan = make(chan struct{})
+	ci.doneChan = make(chan struct{})
+
 	p._connectionsMutex.Lock()
-	defer p._connectionsMutex.Unlock()
-	p._connections[c] = struct{}{}
+	p._connections[c] = ci
+	p._connectionsMutex.Unlock()
+
+	go p.clientWriterRoutine(ci)
+}
+
+// clientWriterRoutine - the only routine which writes to the client socket:
+// sends queued messages until the connection is stopped (then flushes the rest of the queue)
+func (p *Protocol) clientWriterRoutine(ci *clientConnection) {
+	defer close(ci.doneChan)
+
+	for {
+		select {
+		case item := <-ci.sendChan:
+			if err := p.writeResponse(ci.conn, item.cmd, item.idx); err != nil {
+				log.Error(err)
+				// connection is not usable anymore - unblock the senders and stop
+				ci.stopOnce.Do(func() { close(ci.stopChan) })
+				return
+			}
+		case <-ci.stopChan:
+			// flush messages which are already queued
+			for {
+				select {
+				case item := <-ci.sendChan:
+					if err := p.writeResponse(ci.conn, item.cmd, item.idx); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// startLogStreaming - subscribe the client connection to the live stream of daemon
+// log lines (every line is sent as a 'LogLineResp' notification); no-op when the
+// connection is already streaming
+func (p *Protocol) startLogStreaming(ci *clientConnection) {
+	ci.logStreamMutex.Lock()
+	defer ci.logStreamMutex.Unlock()
+
+	if ci.logStreamStopChan != nil {
+		return // already streaming
+	}
+
+	subscriber := make(chan string, 128)
+	logger.SubscribeLogStream(subscriber)
+
+	ci.logStreamStopChan = make(chan struct{})
+	go p.logStreamRoutine(ci, subscriber, ci.logStreamStopChan)
+}
+
+// stopLogStreaming - stop the live log streaming for the client connection (if active)
+func (p *Protocol) stopLogStreaming(ci *clientConnection) {
+	ci.logStreamMutex.Lock()
+	defer ci.logStreamMutex.Unlock()
+
+	if ci.logStreamStopChan == nil {
+		return
+	}
+	close(ci.logStreamStopChan)
+	ci.logStreamStopChan = nil
+}
+
+// logStreamRoutine - forward log lines to the client until streaming is stopped
+// or the connection is closed
+func (p *Protocol) logStreamRoutine(ci *clientConnection, subscriber chan string, stopChan chan struct{}) {
+	defer logger.UnsubscribeLogStream(subscriber)
+
+	for {
+		select {
+		case line := <-subscriber:
+			if err := ci.enqueue(&types.LogLineResp{Line: line}, 0); err != nil {
+				return // connection closed
+			}
+		case <-stopChan:
+			return
+		case <-ci.stopChan:
+			return
+		}
+	}
+}
+
+// clientConnectionInfo returns the state object of an authenticated connection
+// (nil - when connection is not authenticated)
+func (p *Protocol) clientConnectionInfo(c net.Conn) *clientConnection {
+	p._connectionsMutex.RLock()
+	defer p._connectionsMutex.RUnlock()
+	return p._connections[c]
+}
+
+// isClientAuthenticated returns 'true' when connection already passed 'Hello' authentication
+// (only authenticated connections are registered in '_connections')
+func (p *Protocol) isClientAuthenticated(c net.Conn) bool {
+	p._connectionsMutex.RLock()
+	defer p._connectionsMutex.RUnlock()
+	_, ok := p._connections[c]
+	return ok
+}
+
+// isClientReadOnly returns 'true' when client connected in read-only (monitoring) mode
+func (p *Protocol) isClientReadOnly(c net.Conn) bool {
+	p._connectionsMutex.RLock()
+	defer p._connectionsMutex.RUnlock()
+	ci, ok := p._connections[c]
+	return ok && ci.IsReadOnly
 }
 
 func (p *Protocol) clientDisconnected(c net.Conn) {
 	p._connectionsMutex.Lock()
-	defer p._connectionsMutex.Unlock()
-	if _, ok := p._connections[c]; ok {
+	ci, ok := p._connections[c]
+	if ok {
 		delete(p._connections, c)
 	}
+	p._connectionsMutex.Unlock()
+
+	if ci != nil {
+		ci.markStopped()
+		if pending := atomic.LoadInt32(&ci.pendingRequests); pending > 0 {
+			log.Info(fmt.Sprintf("%sClient disconnected with %d request(s) still in processing", p.connLogID(c), pending))
+		}
+	}
 	c.Close()
 }
 
@@ -90,9 +283,13 @@ func (p *Protocol) notifyClientsDaemonExiting() {
 	func() {
 		p._connectionsMutex.RLock()
 		defer p._connectionsMutex.RUnlock()
-		for conn := range p._connections {
+		for conn, ci := range p._connections {
+			// notifying client about the graceful shutdown
+			ci.enqueue(&types.DaemonShuttingDownResp{}, 0)
 			// notifying client "service is going to stop" (client application (UI) will close)
-			p.sendResponse(conn, &types.ServiceExitingResp{}, 0)
+			ci.enqueue(&types.ServiceExitingResp{}, 0)
+			// stop the writer routine (waits until the queued messages are flushed)
+			ci.markStopped()
 			// closing current connection with a client
 			conn.Close()
 		}
@@ -101,27 +298,94 @@ func (p *Protocol) notifyClientsDaemonExiting() {
 	// erasing clients connections
 	p._connectionsMutex.Lock()
 	defer p._connectionsMutex.Unlock()
-	p._connections = make(map[net.Conn]struct{})
+	p._connections = make(map[net.Conn]*clientConnection)
 }
 
 // -------------- sending responses ---------------
 func (p *Protocol) sendErrorResponse(conn net.Conn, request types.CommandBase, err error) {
-	log.Error(fmt.Sprintf("%sError processing request '%s': %s", p.connLogID(conn), request.Command, err))
-	p.sendResponse(conn, &types.ErrorResp{ErrorMessage: err.Error()}, request.Idx)
+	p.sendErrorResponseCode(conn, request, err, errorCodeOf(err))
+}
+
+// sendErrorResponseCode - send an error response with an explicitly defined error code
+func (p *Protocol) sendErrorResponseCode(conn net.Conn, request types.CommandBase, err error, code types.ErrorCode) {
+	log.Error(fmt.Sprintf("%sError processing request '%s' [%s]: %s", p.connLogID(conn), request.Command, code, err))
+	p.sendResponse(conn, &types.ErrorResp{ErrorCode: code, ErrorMessage: err.Error()}, request.Idx)
+}
+
+// errorCodeOf classifies an error into the enumerated protocol error code
+// (ErrorCodeUnknown - when the cause can not be determined)
+func errorCodeOf(err error) types.ErrorCode {
+	if err == nil {
+		return types.ErrorCodeUnknown
+	}
+
+	var jsonSyntaxErr *json.SyntaxError
+	var jsonTypeErr *json.UnmarshalTypeError
+	if errors.As(err, &jsonSyntaxErr) || errors.As(err, &jsonTypeErr) {
+		return types.ErrorCodeParsingFailed
+	}
+
+	var notLoggedIn service.ErrorNotLoggedIn
+	if errors.As(err, &notLoggedIn) {
+		return types.ErrorCodeNotLoggedIn
+	}
+	var canceled service.ErrorConnectionCanceled
+	if errors.As(err, &canceled) {
+		return types.ErrorCodeConnectionCanceled
+	}
+	var noWgKeys service.ErrorWireGuardKeysNotGenerated
+	if errors.As(err, &noWgKeys) {
+		return types.ErrorCodeWgKeyNotFound
+	}
+
+	var apiErr apitypes.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode {
+		case apitypes.Unauthorized:
+			return types.ErrorCodeAuthFailed
+		case apitypes.CodeSessionsLimitReached:
+			return types.ErrorCodeSessionLimitReached
+		}
+		return types.ErrorCodeUnknown
+	}
+
+	// network-level API communication failure
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return types.ErrorCodeApiUnreachable
+	}
+
+	return types.ErrorCodeUnknown
 }
 
+// sendResponse - send a message to a client.
+// For authenticated connections the message is queued for the writer routine of the
+// connection; before authentication (single reading routine) it is written directly.
 func (p *Protocol) sendResponse(conn net.Conn, cmd interface{}, idx int) (retErr error) {
 	if conn == nil {
 		return fmt.Errorf("%sresponse not sent (no connection to client)", p.connLogID(conn))
 	}
 
+	if ci := p.clientConnectionInfo(conn); ci != nil {
+		return ci.enqueue(cmd, idx)
+	}
+	return p.writeResponse(conn, cmd, idx)
+}
+
+// writeResponse - serialize and write a message to the socket
+// (must not be used concurrently for the same connection)
+func (p *Protocol) writeResponse(conn net.Conn, cmd interface{}, idx int) (retErr error) {
 	if err := types.Send(conn, cmd, idx); err != nil {
 		return fmt.Errorf("%sfailed to send command: %w", p.connLogID(conn), err)
 	}
 
 	// Just for logging
 	if reqType := types.GetTypeName(cmd); len(reqType) > 0 {
-		log.Info(fmt.Sprintf("[-->] %s", p.connLogID(conn)), reqType)
+		// log-streaming notifications are not logged: logging a sent log line
+		// would produce a new line to stream (endless feedback loop)
+		if reqType != "LogLineResp" {
+			log.Info(fmt.Sprintf("[-->] %s", p.connLogID(conn)), reqType)
+		}
 	} else {
 		return fmt.Errorf("%sprotocol error: BAD DATA SENT", p.connLogID(conn))
 	}
@@ -129,6 +393,100 @@ func (p *Protocol) sendResponse(conn net.Conn, cmd interface{}, idx int) (retErr
 	return nil
 }
 
+// classifyDisconnectionReason - determine the cause of a connection drop
+// ('errorMarker' - typed fatal-error classification reported by the VPN process
+// (e.g. 'tls-error'; see openvpn 'detectFatalError'), empty - no marker;
+// 'isWasConnected' - CONNECTED state was reached at least once for this tunnel)
+func classifyDisconnectionReason(isAuthError bool, errorMarker string, connectionError error, isWasConnected bool) types.DisconnectionReason {
+	if isAuthError {
+		return types.AuthenticationError
+	}
+
+	switch errorMarker {
+	case "tls-error":
+		return types.TLSHandshakeFailed
+	case "tap-adapters-exhausted":
+		return types.TAPAdaptersExhausted
+	}
+
+	var canceled service.ErrorConnectionCanceled
+	if connectionError != nil && errors.As(connectionError, &canceled) {
+		return types.DisconnectRequested
+	}
+
+	// no default route - the local network connectivity was lost
+	if _, err := netinfo.DefaultGatewayIP(); err != nil {
+		return types.LocalNetworkLost
+	}
+
+	if connectionError != nil {
+		if isWasConnected {
+			// the established tunnel went down with an error
+			return types.VpnProcessCrashed
+		}
+		return types.Unknown // connection was not established
+	}
+
+	if isWasConnected {
+		// the established tunnel stopped without a local error or user request
+		return types.ConnectionLost
+	}
+	return types.Unknown
+}
+
+// remediationHint - human-readable recovery hint for a disconnection reason
+// (empty - no specific hint)
+func remediationHint(reason types.DisconnectionReason) string {
+	switch reason {
+	case types.AuthenticationError:
+		return "Check the account status and log in again (the session may be expired or used on too many devices)"
+	case types.TLSHandshakeFailed:
+		return "The network may block the OpenVPN protocol. Try another port/protocol or enable obfsproxy"
+	case types.TAPAdaptersExhausted:
+		return "All TAP adapters are in use. Close other VPN software or add a new TAP adapter"
+	}
+	return ""
+}
+
+// -------------- active connection parameters ---------------
+
+// normalizeConnectionParams - canonical representation of a 'Connect' request
+// for comparison: the request index is removed and the object keys are sorted
+// (map marshaling). Empty string - when the request can not be parsed.
+func normalizeConnectionParams(messageData []byte) string {
+	var params map[string]interface{}
+	if err := json.Unmarshal(messageData, &params); err != nil {
+		return ""
+	}
+	delete(params, "Idx")
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (p *Protocol) setActiveConnectionParams(messageData []byte) {
+	p._activeConnParamsMutex.Lock()
+	defer p._activeConnParamsMutex.Unlock()
+	if messageData == nil {
+		p._activeConnParams = ""
+		return
+	}
+	p._activeConnParams = normalizeConnectionParams(messageData)
+}
+
+// isActiveConnectionParams - check whether a 'Connect' request matches
+// the parameters of the active VPN connection
+func (p *Protocol) isActiveConnectionParams(messageData []byte) bool {
+	p._activeConnParamsMutex.Lock()
+	defer p._activeConnParamsMutex.Unlock()
+	if len(p._activeConnParams) == 0 {
+		return false
+	}
+	return p._activeConnParams == normalizeConnectionParams(messageData)
+}
+
 // -------------- VPN connection requests counter ---------------
 func (p *Protocol) vpnConnectReqCounter() (int, time.Time) {
 	p._connectRequestsMutex.Lock()
@@ -151,6 +509,36 @@ func (p *Protocol) vpnConnectReqCounterDecrease() {
 	p._connectRequests--
 }
 
+// -------------- kill-switch restore after a seamless connection switch ---------------
+// (see processConnectionRequestSeamless)
+
+func (p *Protocol) setFwRestorePending(pending bool) {
+	p._fwRestoreMutex.Lock()
+	defer p._fwRestoreMutex.Unlock()
+
+	p._isFwRestorePending = pending
+}
+
+// restoreKillSwitchAfterSwitch - disable the kill-switch which was temporarily
+// enabled for a seamless connection switch. No-op when no restore is pending
+// (e.g. the user explicitly changed the kill-switch state in the meantime)
+func (p *Protocol) restoreKillSwitchAfterSwitch() {
+	p._fwRestoreMutex.Lock()
+	isPending := p._isFwRestorePending
+	p._isFwRestorePending = false
+	p._fwRestoreMutex.Unlock()
+
+	if isPending == false {
+		return
+	}
+
+	if err := p._service.SetKillSwitchState(false); err != nil {
+		log.Error("Failed to restore kill-switch state after the connection switch: ", err)
+		return
+	}
+	log.Info("Kill-switch restored to the state it had before the connection switch")
+}
+
 func (p *Protocol) createHelloResponse() *types.HelloResp {
 	prefs := p._service.Preferences()
 
@@ -180,6 +568,156 @@ func (p *Protocol) createHelloResponse() *types.HelloResp {
 	return &helloResp
 }
 
+// reachability probe timeout (the probe has to be quick:
+// it is performed synchronously during connection establishing)
+const hostProbeTimeout = time.Second * 2
+
+// isHostReachable - quick reachability probe of 'host:port'
+// TCP: try to establish a connection
+// UDP: send a probe datagram and wait shortly for an ICMP 'port unreachable' response
+// (no error response - the host is assumed to be reachable)
+func isHostReachable(host string, port int, isTCP bool) bool {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	if isTCP {
+		conn, err := net.DialTimeout("tcp", addr, hostProbeTimeout)
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+
+	conn, err := net.DialTimeout("udp", addr, hostProbeTimeout)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte{0}); err != nil {
+		return false
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond * 500))
+	buff := make([]byte, 64)
+	if _, err := conn.Read(buff); err != nil {
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			return true // no ICMP error received - assume the host is reachable
+		}
+		return false // e.g. 'connection refused' (ICMP 'port unreachable')
+	}
+	return true
+}
+
+// applyServerSelection - pick a concrete server according to the selection policy
+// defined in the 'Connect' request ('fastest'\'random', with an optional country filter)
+// and replace the entry server parameters of the request by the chosen server.
+// The decision is saved for reporting in the connection state response
+func (p *Protocol) applyServerSelection(r *types.Connect) error {
+	policy := strings.ToLower(strings.TrimSpace(r.ServerSelection))
+	if len(policy) == 0 || policy == "manual" {
+		p._serverSelectionInfo = ""
+		return nil
+	}
+	if policy != "fastest" && policy != "random" {
+		return fmt.Errorf("unexpected server selection policy: '%s'", r.ServerSelection)
+	}
+
+	servers, err := p._service.ServersList()
+	if err != nil {
+		return fmt.Errorf("unable to get servers list: %w", err)
+	}
+	if servers == nil {
+		return fmt.Errorf("servers list is not available")
+	}
+
+	latency := p._service.PingResults()
+	country := strings.ToUpper(strings.TrimSpace(r.ServerSelectionCountry))
+
+	// lower latency wins; equal (or unknown) latency - lower load wins
+	const unknownLatency = 1 << 30
+	isBetter := func(pingA int, loadA int, pingB int, loadB int) bool {
+		if pingA <= 0 {
+			pingA = unknownLatency
+		}
+		if pingB <= 0 {
+			pingB = unknownLatency
+		}
+		if pingA != pingB {
+			return pingA < pingB
+		}
+		return loadA < loadB
+	}
+
+	if vpn.Type(r.VpnType) == vpn.OpenVPN {
+		candidates := make([]apitypes.OpenvpnServerInfo, 0, len(servers.OpenvpnServers))
+		for _, svr := range servers.OpenvpnServers {
+			if len(svr.IPAddresses) == 0 {
+				continue
+			}
+			if len(country) > 0 && strings.ToUpper(svr.CountryCode) != country {
+				continue
+			}
+			candidates = append(candidates, svr)
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("no servers are matching the selection policy")
+		}
+
+		chosen := candidates[rand.Intn(len(candidates))]
+		if policy == "fastest" {
+			chosen = candidates[0]
+			for _, svr := range candidates[1:] {
+				if isBetter(latency[svr.IPAddresses[0]], svr.Load, latency[chosen.IPAddresses[0]], chosen.Load) {
+					chosen = svr
+				}
+			}
+		}
+
+		r.OpenVpnParameters.EntryVpnServer.IPAddresses = chosen.IPAddresses
+		p._serverSelectionInfo = policy + ": " + chosen.Gateway
+		log.Info(fmt.Sprintf("Server selection (%s): %s", policy, chosen.Gateway))
+		return nil
+	}
+
+	if vpn.Type(r.VpnType) == vpn.WireGuard {
+		candidates := make([]apitypes.WireGuardServerInfo, 0, len(servers.WireguardServers))
+		for _, svr := range servers.WireguardServers {
+			if len(svr.Hosts) == 0 {
+				continue
+			}
+			if len(country) > 0 && strings.ToUpper(svr.CountryCode) != country {
+				continue
+			}
+			candidates = append(candidates, svr)
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("no servers are matching the selection policy")
+		}
+
+		chosen := candidates[rand.Intn(len(candidates))]
+		if policy == "fastest" {
+			chosen = candidates[0]
+			for _, svr := range candidates[1:] {
+				if isBetter(latency[svr.Hosts[0].Host], svr.Load, latency[chosen.Hosts[0].Host], chosen.Load) {
+					chosen = svr
+				}
+			}
+		}
+
+		hosts := make([]types.WGHost, 0, len(chosen.Hosts))
+		for _, host := range chosen.Hosts {
+			hosts = append(hosts, types.WGHost{Host: host.Host, PublicKey: host.PublicKey, LocalIP: host.LocalIP})
+		}
+		r.WireGuardParameters.EntryVpnServer.Hosts = hosts
+		p._serverSelectionInfo = policy + ": " + chosen.Gateway
+		log.Info(fmt.Sprintf("Server selection (%s): %s", policy, chosen.Gateway))
+		return nil
+	}
+
+	return fmt.Errorf("unexpected VPN type (%v)", r.VpnType)
+}
+
 // -------------- processing connection request ---------------
 func (p *Protocol) processConnectRequest(messageData []byte, stateChan chan<- vpn.StateInfo) (err error) {
 	defer func() {
@@ -200,6 +738,11 @@ func (p *Protocol) processConnectRequest(messageData []byte, stateChan chan<- vp
 		return fmt.Errorf("failed to unmarshal json 'Connect' request: %w", err)
 	}
 
+	// daemon-side server selection (when a selection policy is defined in the request)
+	if err := p.applyServerSelection(&r); err != nil {
+		return fmt.Errorf("failed to select server: %w", err)
+	}
+
 	retManualDNS := net.ParseIP(r.CurrentDNS)
 
 	if vpn.Type(r.VpnType) == vpn.OpenVPN {
@@ -208,6 +751,21 @@ func (p *Protocol) processConnectRequest(messageData []byte, stateChan chan<- vp
 			hosts = append(hosts, net.ParseIP(v))
 		}
 
+		// quick reachability check: when a host is down - prefer the next host of the same location
+		// (eliminates long user-visible timeouts when a single gateway is down)
+		if port := r.OpenVpnParameters.Port.Port; port > 0 && len(hosts) > 1 {
+			isTCP := r.OpenVpnParameters.Port.Protocol > 0
+			for i, host := range hosts {
+				if isHostReachable(host.String(), port, isTCP) {
+					if i > 0 {
+						hosts = append(hosts[i:], hosts[:i]...)
+					}
+					break
+				}
+				log.Info(fmt.Sprintf("Host %s:%d is not reachable. Checking the next host of the location...", host, port))
+			}
+		}
+
 		connectionParams := openvpn.CreateConnectionParams(
 			r.OpenVpnParameters.MultihopExitSrvID,
 			r.OpenVpnParameters.Port.Protocol > 0, // is TCP
@@ -219,16 +777,68 @@ func (p *Protocol) processConnectRequest(messageData []byte, stateChan chan<- vp
 			r.OpenVpnParameters.ProxyUsername,
 			r.OpenVpnParameters.ProxyPassword)
 
+		connectionParams.SetIPv6(r.IPv6)
+		connectionParams.SetObfuscation(r.OpenVpnParameters.Obfuscation, r.OpenVpnParameters.ObfuscationPort)
+
 		return p._service.ConnectOpenVPN(connectionParams, retManualDNS, r.FirewallOnDuringConnection, stateChan)
 
 	} else if vpn.Type(r.VpnType) == vpn.WireGuard {
-		hostValue := r.WireGuardParameters.EntryVpnServer.Hosts[rand.Intn(len(r.WireGuardParameters.EntryVpnServer.Hosts))]
+		// custom (non-IVPN) profile connection: all parameters are taken from the stored profile
+		if profileName := r.WireGuardParameters.CustomProfileName; len(profileName) > 0 {
+			return p._service.ConnectWireGuardCustomProfile(profileName, retManualDNS, r.FirewallOnDuringConnection, stateChan)
+		}
+
+		wgHosts := r.WireGuardParameters.EntryVpnServer.Hosts
+		hostValue := wgHosts[rand.Intn(len(wgHosts))]
+
+		// quick reachability check: when the chosen host is down - try another host of the same location
+		// (eliminates long user-visible timeouts when a single gateway is down)
+		if port := r.WireGuardParameters.Port.Port; port > 0 && len(wgHosts) > 1 {
+			for _, i := range rand.Perm(len(wgHosts)) {
+				if isHostReachable(wgHosts[i].Host, port, false) {
+					hostValue = wgHosts[i]
+					break
+				}
+				log.Info(fmt.Sprintf("Host %s:%d is not reachable. Checking the next host of the location...", wgHosts[i].Host, port))
+			}
+		}
+
+		hostPort := r.WireGuardParameters.Port.Port
+		hostPublicKey := hostValue.PublicKey
+		hostLocalIP := net.ParseIP(strings.Split(hostValue.LocalIP, "/")[0])
+		hostLocalIPv6 := net.ParseIP(strings.Split(hostValue.LocalIPv6, "/")[0])
+		multihopExitSrvID := ""
+
+		// multihop ('double VPN'): traffic enters the VPN on the entry server and exits on the exit server;
+		// connection is established to the entry server IP using the exit server's multihop port and public key
+		exitSrv := r.WireGuardParameters.MultihopExitServer
+		if len(exitSrv.Hosts) > 0 {
+			exitHost := exitSrv.Hosts[rand.Intn(len(exitSrv.Hosts))]
+			hostPort = exitHost.MultihopPort
+			hostPublicKey = exitHost.PublicKey
+			hostLocalIP = net.ParseIP(strings.Split(exitHost.LocalIP, "/")[0])
+			hostLocalIPv6 = net.ParseIP(strings.Split(exitHost.LocalIPv6, "/")[0])
+			multihopExitSrvID = exitSrv.ExitSrvID
+		}
 
 		connectionParams := wireguard.CreateConnectionParams(
-			r.WireGuardParameters.Port.Port,
+			multihopExitSrvID,
+			hostPort,
 			net.ParseIP(hostValue.Host),
-			hostValue.PublicKey,
-			net.ParseIP(strings.Split(hostValue.LocalIP, "/")[0]))
+			hostPublicKey,
+			hostLocalIP)
+
+		// IPv6 inside the tunnel (only when requested and the server supports it)
+		if r.IPv6 && hostLocalIPv6 != nil {
+			connectionParams.SetIPv6(hostLocalIPv6)
+		}
+
+		connectionParams.SetObfuscation(r.WireGuardParameters.Obfuscation, r.WireGuardParameters.ObfuscationPort)
+
+		// partial tunneling: tunnel only the networks defined in the request
+		if len(r.WireGuardParameters.AllowedIPs) > 0 {
+			connectionParams.SetCustomAllowedIPs(r.WireGuardParameters.AllowedIPs)
+		}
 
 		return p._service.ConnectWireGuard(connectionParams, retManualDNS, r.FirewallOnDuringConnection, stateChan)
 
@@ -0,0 +1,78 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package protocol
+
+// Rate limiting of the protocol listener: protects the daemon from a misbehaving
+// or malicious local process spamming commands.
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// maximum count of simultaneous client connections
+	_maxClientConnections = 32
+
+	// per-connection limit of requests
+	_maxRequestsPerWindow = 200
+	_requestsWindow       = time.Minute
+
+	// global limit of VPN connection requests
+	_maxConnectRequestsPerWindow = 10
+	_connectRequestsWindow       = time.Minute
+)
+
+// rateLimiter - fixed-window request counter
+type rateLimiter struct {
+	mutex       sync.Mutex
+	limit       int
+	window      time.Duration
+	windowStart time.Time
+	count       int
+}
+
+func newRateLimiter(limit int, window time.Duration) *rateLimiter {
+	return &rateLimiter{limit: limit, window: window}
+}
+
+// tryTake - register one request.
+// Returns false (and the seconds left until the window resets) when the limit is exceeded
+func (rl *rateLimiter) tryTake() (isAllowed bool, retryAfterSec int) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(rl.windowStart) >= rl.window {
+		rl.windowStart = now
+		rl.count = 0
+	}
+
+	if rl.count >= rl.limit {
+		retryAfter := rl.window - now.Sub(rl.windowStart)
+		return false, int(retryAfter/time.Second) + 1
+	}
+
+	rl.count++
+	return true, 0
+}
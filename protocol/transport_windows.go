@@ -0,0 +1,51 @@
+// +build windows
+
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package protocol
+
+import (
+	"fmt"
+	"net"
+
+	winio "github.com/Microsoft/go-winio"
+)
+
+// name of the named pipe to communicate with IVPN application
+const servicePipeName = `\\.\pipe\IVPNService`
+
+// Security descriptor for the service named pipe:
+// full access only for Administrators (BA), LocalSystem (SY) and interactive users (IU)
+const servicePipeSecurityDescriptor = "D:P(A;;GA;;;BA)(A;;GA;;;SY)(A;;GA;;;IU)"
+
+// implStartAdditionalTransports - start OS-specific transports
+// Windows: named pipe with ACL restricting access to administrators\interactive users
+func implStartAdditionalTransports() ([]net.Listener, error) {
+	pipeListener, err := winio.ListenPipe(servicePipeName, &winio.PipeConfig{SecurityDescriptor: servicePipeSecurityDescriptor})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start named-pipe listener: %w", err)
+	}
+
+	log.Info("Named-pipe transport started: ", servicePipeName)
+	return []net.Listener{pipeListener}, nil
+}
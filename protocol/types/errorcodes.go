@@ -0,0 +1,80 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package types
+
+// ErrorCode - enumerated cause of an 'ErrorResp'.
+// The human-readable 'ErrorMessage' stays as the detail; the code allows UI
+// clients to branch on and localize errors without parsing message text.
+// Values are part of the protocol - do not renumber.
+type ErrorCode int
+
+const (
+	// ErrorCodeUnknown - unclassified error (see 'ErrorMessage' for details)
+	ErrorCodeUnknown ErrorCode = 0
+	// ErrorCodeParsingFailed - request could not be parsed
+	ErrorCodeParsingFailed ErrorCode = 1
+	// ErrorCodeNotAuthenticated - connection did not pass 'Hello' authentication
+	ErrorCodeNotAuthenticated ErrorCode = 2
+	// ErrorCodeNotPermitted - request not allowed for this connection (e.g. read-only client)
+	ErrorCodeNotPermitted ErrorCode = 3
+	// ErrorCodeNotLoggedIn - no active account session
+	ErrorCodeNotLoggedIn ErrorCode = 4
+	// ErrorCodeAuthFailed - invalid account credentials
+	ErrorCodeAuthFailed ErrorCode = 5
+	// ErrorCodeSessionLimitReached - account session limit reached (log out from another device)
+	ErrorCodeSessionLimitReached ErrorCode = 6
+	// ErrorCodeApiUnreachable - IVPN API servers are not reachable
+	ErrorCodeApiUnreachable ErrorCode = 7
+	// ErrorCodeWgKeyNotFound - WireGuard credentials are not generated for the session
+	ErrorCodeWgKeyNotFound ErrorCode = 8
+	// ErrorCodeFirewallBackendUnavailable - the firewall backend of the OS is not available
+	ErrorCodeFirewallBackendUnavailable ErrorCode = 9
+	// ErrorCodeConnectionCanceled - connection establishment was canceled by user request
+	ErrorCodeConnectionCanceled ErrorCode = 10
+)
+
+func (c ErrorCode) String() string {
+	switch c {
+	case ErrorCodeParsingFailed:
+		return "ParsingFailed"
+	case ErrorCodeNotAuthenticated:
+		return "NotAuthenticated"
+	case ErrorCodeNotPermitted:
+		return "NotPermitted"
+	case ErrorCodeNotLoggedIn:
+		return "NotLoggedIn"
+	case ErrorCodeAuthFailed:
+		return "AuthFailed"
+	case ErrorCodeSessionLimitReached:
+		return "SessionLimitReached"
+	case ErrorCodeApiUnreachable:
+		return "ApiUnreachable"
+	case ErrorCodeWgKeyNotFound:
+		return "WgKeyNotFound"
+	case ErrorCodeFirewallBackendUnavailable:
+		return "FirewallBackendUnavailable"
+	case ErrorCodeConnectionCanceled:
+		return "ConnectionCanceled"
+	}
+	return "Unknown"
+}
@@ -22,7 +22,10 @@
 
 package types
 
-import "github.com/ivpn/desktop-app-daemon/vpn"
+import (
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+)
 
 // Hello is an initial request
 type Hello struct {
@@ -44,6 +47,16 @@ type Hello struct {
 	//	KeepDaemonAlone == true - do nothing when client disconnects from a daemon (if VPN is connected - do not disconnect)
 	KeepDaemonAlone bool
 
+	// ReadOnlyMode == true - client connects in read-only (monitoring) mode:
+	// only status\information requests are allowed for such connection
+	// (e.g. monitoring tools can safely attach without being able to disconnect the VPN)
+	ReadOnlyMode bool
+
+	// SubscribeStateEvents == true - client requests to receive state-change notifications
+	// wrapped into 'StateEventResp' objects (with sequence number and timestamp)
+	// instead of raw notification objects
+	SubscribeStateEvents bool
+
 	// Register credentials (if not logged in)
 	// Used when updating from an old client version
 	SetRawCredentials RawCredentials
@@ -71,6 +84,18 @@ type PingServers struct {
 	CommandBase
 	RetryCount int
 	TimeOutMs  int
+
+	// IntervalMs - interval between ping probes to one host (0 - use the default interval)
+	IntervalMs int
+	// MaxConcurrent - max amount of hosts pinged in parallel (0 - use the default limit)
+	MaxConcurrent int
+	// Hosts - ping only the defined hosts (empty - ping all servers)
+	Hosts []string
+}
+
+// GetGeoLookup request geo-lookup information about the current location
+type GetGeoLookup struct {
+	CommandBase
 }
 
 // KillSwitchSetAllowLANMulticast enable\disable LAN multicast acces for kill-switch
@@ -93,6 +118,22 @@ type KillSwitchSetAllowLAN struct {
 	Synchronously bool
 }
 
+// KillSwitchSetAllowServiceDiscovery enable\disable local service discovery multicast
+// (mDNS\Bonjour and SSDP) for kill-switch; independent from 'KillSwitchSetAllowLANMulticast':
+// only the specific discovery multicast groups are allowed, not all multicast
+type KillSwitchSetAllowServiceDiscovery struct {
+	CommandBase
+	AllowServiceDiscovery bool
+}
+
+// KillSwitchSetSoftMode enable\disable the 'soft' kill-switch mode:
+// traffic is blocked only when the tunnel drops unexpectedly;
+// a deliberate disconnect keeps connectivity
+type KillSwitchSetSoftMode struct {
+	CommandBase
+	IsSoftMode bool
+}
+
 // KillSwitchSetEnabled request to enable\disable kill-switch
 type KillSwitchSetEnabled struct {
 	CommandBase
@@ -104,6 +145,26 @@ type KillSwitchGetStatus struct {
 	CommandBase
 }
 
+// KillSwitchGetBlockedDNS get statistics of the DNS leak protection:
+// amount of out-of-tunnel DNS packets blocked by the firewall
+type KillSwitchGetBlockedDNS struct {
+	CommandBase
+}
+
+// KillSwitchSetLanAllowedAddresses define user-managed list of specific LAN addresses
+// (single IPs or subnets in CIDR notation) which stay reachable when the kill-switch is enabled
+type KillSwitchSetLanAllowedAddresses struct {
+	CommandBase
+	Addresses []string
+}
+
+// KillSwitchSetUserExceptions define user-managed list of 'always allowed' destinations
+// (single IPs, 'IP:port' entries or subnets in CIDR notation, not broader than /24)
+type KillSwitchSetUserExceptions struct {
+	CommandBase
+	Exceptions []string
+}
+
 // KillSwitchSetIsPersistent request to mark kill-switch persistant
 type KillSwitchSetIsPersistent struct {
 	CommandBase
@@ -117,17 +178,123 @@ type SetPreference struct {
 	Value string
 }
 
+// GetServerPorts request available server connection ports (per VPN protocol)
+type GetServerPorts struct {
+	CommandBase
+}
+
+// PortForwardAdd request to allocate a new forwarded port for the current session
+type PortForwardAdd struct {
+	CommandBase
+}
+
+// PortForwardRemove request to release a forwarded port
+type PortForwardRemove struct {
+	CommandBase
+	Port int
+}
+
+// PortForwardList request forwarded ports of the current session
+type PortForwardList struct {
+	CommandBase
+}
+
+// SetPreferredPort sets preferred server port\protocol combination for a VPN protocol.
+// The value is persisted and applied when a 'Connect' request does not define a destination port
+// (Port == 0 - erase the preference)
+type SetPreferredPort struct {
+	CommandBase
+	VpnType vpn.Type
+	Port    int
+	IsTCP   bool
+}
+
+// GenerateDiagnostics request to get daemon logs
+type GenerateDiagnostics struct {
+	CommandBase
+}
+
+// SetLogLevel - runtime change of the daemon log level
+// ('debug', 'info', 'warning', 'error'; not persisted - the default level
+// is restored on the daemon restart)
+type SetLogLevel struct {
+	CommandBase
+	Level string
+}
+
+// SetLogStreaming - subscribe\unsubscribe this connection to a live stream of
+// daemon log lines ('tail -f' style): every line is sent as a 'LogLineResp'
+// notification until streaming is disabled or the connection is closed
+type SetLogStreaming struct {
+	CommandBase
+	Enable bool
+}
+
 // SetAlternateDns request to set custom DNS
+// 'DNS' - primary DNS server (IPv4 or IPv6)
+// 'DNSFallbacks' - optional fallback servers: applied (in order) when the primary
+// server is not reachable through the tunnel
 type SetAlternateDns struct {
 	CommandBase
-	DNS string
+	DNS          string
+	DNSFallbacks []string
+}
+
+// RepairDNS - force restoring the system DNS configuration to the OS defaults
+// (for situations when DNS is left pointing to the dead tunnel resolver after a crash)
+type RepairDNS struct {
+	CommandBase
+}
+
+// RepairNetworkAdapters - repair stuck VPN network adapters
+// (Windows: reset\reinstall TAP adapters left in a broken state after a crash)
+type RepairNetworkAdapters struct {
+	CommandBase
+}
+
+// RunLeakTest - connectivity and leak self-test (available only when VPN is connected):
+// check of the external IP, DNS leak check and IPv6 leak check
+type RunLeakTest struct {
+	CommandBase
+}
+
+// GetNetworkState - request the daemon view of the current networking state
+// (default route, tunnel interface, DNS resolvers in effect ...)
+type GetNetworkState struct {
+	CommandBase
+}
+
+// SetAntiTracker - enable\disable AntiTracker (ad\tracker blocking DNS)
+// 'Hardcore' mode additionally blocks domains of Google and Facebook
+type SetAntiTracker struct {
+	CommandBase
+	Enabled  bool
+	Hardcore bool
+}
+
+// DnsProxySet - configure local DNS proxy
+// (daemon-hosted DNS over HTTPS\TLS resolver)
+type DnsProxySet struct {
+	CommandBase
+	Enabled bool
+	// Encryption - upstream connection type: 'doh' or 'dot'
+	Encryption string
+	// Resolver - upstream resolver: DoH URL (https://...) or DoT host[:port]
+	Resolver string
+}
+
+// DnsProxyGetStatus - get local DNS proxy configuration
+type DnsProxyGetStatus struct {
+	CommandBase
 }
 
 // WGHost is a WireGuard host description
 type WGHost struct {
-	Host      string
-	PublicKey string `json:"public_key"`
-	LocalIP   string `json:"local_ip"`
+	Host         string
+	PublicKey    string `json:"public_key"`
+	LocalIP      string `json:"local_ip"`
+	LocalIPv6    string `json:"local_ipv6"` // IPv6 address of the host inside the tunnel (empty when IPv6 is not supported by the server)
+	MultihopPort int    `json:"multihop_port"`
 }
 
 // Connect request to establish new VPN connection
@@ -137,14 +304,51 @@ type Connect struct {
 	CurrentDNS                 string
 	FirewallOnDuringConnection bool
 
+	// IPv6 == true - prefer IPv6 inside the tunnel (when the server supports it)
+	// When the tunnel stays IPv4-only - all IPv6 traffic is blocked while connected (IPv6 leak protection)
+	IPv6 bool
+
+	// ServerSelection - server selection policy performed by the daemon:
+	// 'fastest' - server with the lowest known latency (server load is a tiebreak);
+	// 'random' - random server
+	// (empty or 'manual' - connect to the server defined in the request parameters)
+	ServerSelection string
+	// ServerSelectionCountry - optional country code filter for the selection policy
+	ServerSelectionCountry string
+
 	WireGuardParameters struct {
+		// CustomProfileName - when defined, connect using the stored custom (non-IVPN)
+		// WireGuard profile with this name (all other WireGuard parameters are ignored)
+		CustomProfileName string
+
+		// AllowedIPs - partial tunneling: tunnel only the defined networks
+		// (empty - full default-route capture, the default '0.0.0.0/0' behavior);
+		// destinations outside of the defined networks stay reachable even with
+		// the kill-switch enabled
+		AllowedIPs []string
+
 		Port struct {
 			Port int
 		}
 
+		// Obfuscation == true - encapsulate the WireGuard UDP traffic into a TLS\TCP stream
+		// to the obfuscation endpoint on the server (for networks where plain WireGuard is blocked)
+		Obfuscation bool
+		// ObfuscationPort - obfuscation endpoint port on the server (0 - use the connection port)
+		ObfuscationPort int
+
 		EntryVpnServer struct {
 			Hosts []WGHost
 		}
+
+		// MultihopExitServer - when defined, multihop ('double VPN') connection is established:
+		// traffic enters the VPN on the entry server and exits on the exit server
+		// (connection to the entry server IP using the exit server's multihop port and public key)
+		MultihopExitServer struct {
+			// ExitSrvID (server gateway ID) in use to keep clients informed in status notifications
+			ExitSrvID string
+			Hosts     []WGHost
+		}
 	}
 
 	OpenVpnParameters struct {
@@ -159,6 +363,14 @@ type Connect struct {
 		ProxyUsername     string
 		ProxyPassword     string
 
+		// Obfuscation - obfuscation transport for this connection:
+		// empty - no obfuscation; 'obfs4', 'tlstunnel' or 'websocket'
+		// (the 'enable_obfsproxy' preference keeps working independently of this field)
+		Obfuscation string
+		// ObfuscationPort - obfuscation endpoint port on the server
+		// (0 - use the connection port; not applicable for 'obfs4')
+		ObfuscationPort int
+
 		Port struct {
 			Port     int
 			Protocol int
@@ -171,11 +383,85 @@ type Disconnect struct {
 	CommandBase
 }
 
+// CancelConnect cancel in-progress connection attempt
+// (useful when a connection attempt hangs; a clean immediate teardown
+// instead of waiting for connection timeouts)
+type CancelConnect struct {
+	CommandBase
+}
+
+// PauseConnection pause active VPN connection
+// Duration - seconds until automatic resume (0 - no automatic resume);
+// the resume timer lives in the daemon, so it survives UI restarts
+type PauseConnection struct {
+	CommandBase
+	Duration int64
+}
+
+// ResumeConnection resume paused VPN connection
+type ResumeConnection struct {
+	CommandBase
+}
+
 // GetVPNState request daemon to provive current VPN connection state
 type GetVPNState struct {
 	CommandBase
 }
 
+// GetStateSnapshot request full daemon state snapshot
+// (in use by clients subscribed to state events: to resynchronize after a missed event)
+type GetStateSnapshot struct {
+	CommandBase
+}
+
+// NetworkRulesSet - define trusted\untrusted Wi-Fi network rules
+type NetworkRulesSet struct {
+	CommandBase
+	IsEnabled bool
+	Rules     []preferences.NetworkRule
+}
+
+// NetworkRulesGet - request currently defined trusted\untrusted Wi-Fi network rules
+type NetworkRulesGet struct {
+	CommandBase
+}
+
+// SplitTunnelSetEnabled enable\disable split-tunneling
+// IsInverse == true - inverse mode: only the configured applications are routed
+// through the tunnel, everything else uses the default route
+type SplitTunnelSetEnabled struct {
+	CommandBase
+	IsEnabled bool
+	IsInverse bool
+}
+
+// SplitTunnelAddApp add application to the split-tunneling configuration
+type SplitTunnelAddApp struct {
+	CommandBase
+	// AppBinaryPath - absolute path to the application binary
+	AppBinaryPath string
+}
+
+// SplitTunnelRemoveApp remove application from the split-tunneling configuration
+type SplitTunnelRemoveApp struct {
+	CommandBase
+	AppBinaryPath string
+}
+
+// SplitTunnelSetRoutes define per-IP\subnet split-tunneling rules (CIDR notation)
+type SplitTunnelSetRoutes struct {
+	CommandBase
+	// BypassCIDRs - networks which have to bypass the tunnel
+	BypassCIDRs []string
+	// VpnCIDRs - networks which always have to use the tunnel
+	VpnCIDRs []string
+}
+
+// SplitTunnelGetStatus request current split-tunneling configuration
+type SplitTunnelGetStatus struct {
+	CommandBase
+}
+
 // SessionNew - create new session
 //
 // When force is set to true - all active sessions will be deleted prior to creating a new one if user reached session limit.
@@ -185,6 +471,25 @@ type SessionNew struct {
 	CommandBase
 	AccountID  string
 	ForceLogin bool
+
+	// CaptchaID + Captcha - the solved captcha challenge
+	// (defined only when a previous login attempt returned a captcha challenge)
+	CaptchaID string
+	Captcha   string
+	// Confirmation2FA - TOTP 2FA code
+	// (defined only when a previous login attempt was rejected with '2FA required')
+	Confirmation2FA string
+}
+
+// Login - create new session (alias of 'SessionNew': same parameters, same response)
+type Login struct {
+	CommandBase
+	AccountID  string
+	ForceLogin bool
+
+	CaptchaID       string
+	Captcha         string
+	Confirmation2FA string
 }
 
 // SessionDelete logout from current device
@@ -192,11 +497,86 @@ type SessionDelete struct {
 	CommandBase
 }
 
+// Logout - logout from current device (alias of 'SessionDelete')
+type Logout struct {
+	CommandBase
+}
+
 // AccountStatus get account status
 type AccountStatus struct {
 	CommandBase
 }
 
+// SessionStatus get status of the current session (alias of 'AccountStatus')
+type SessionStatus struct {
+	CommandBase
+}
+
+// GetConnectionHistory request the locally stored connection audit log
+// (available only when enabled by the 'connection_history' preference)
+type GetConnectionHistory struct {
+	CommandBase
+}
+
+// WireGuardImportCustomProfile import an arbitrary WireGuard configuration (wg-quick format)
+// and store it as a named custom profile
+// (to connect: 'Connect' request with 'WireGuardParameters.CustomProfileName')
+type WireGuardImportCustomProfile struct {
+	CommandBase
+	Name   string
+	Config string
+}
+
+// WireGuardRemoveCustomProfile remove a stored custom WireGuard profile
+type WireGuardRemoveCustomProfile struct {
+	CommandBase
+	Name string
+}
+
+// WireGuardGetCustomProfiles request the stored custom WireGuard profiles
+type WireGuardGetCustomProfiles struct {
+	CommandBase
+}
+
+// OpenVPNImportCustomProfile import a third-party '.ovpn' configuration:
+// the daemon sanitizes it (dangerous directives are stripped), extracts the embedded
+// certificates into separate files and stores the result as a named custom profile
+type OpenVPNImportCustomProfile struct {
+	CommandBase
+	Name   string
+	Config string
+}
+
+// OpenVPNRemoveCustomProfile remove a stored custom OpenVPN profile
+type OpenVPNRemoveCustomProfile struct {
+	CommandBase
+	Name string
+}
+
+// OpenVPNGetCustomProfiles request the stored custom OpenVPN profiles
+type OpenVPNGetCustomProfiles struct {
+	CommandBase
+}
+
+// GetUsageStats request the locally stored usage accounting buckets
+// (bytes transferred through the VPN, aggregated per day and per server)
+type GetUsageStats struct {
+	CommandBase
+}
+
+// DeviceList request devices registered to the account
+type DeviceList struct {
+	CommandBase
+}
+
+// DeviceDelete request to remove a device registered to the account
+// (logs out the session of that device; allows the user
+// to resolve the 'session limit' situation from within the app)
+type DeviceDelete struct {
+	CommandBase
+	DeviceID string
+}
+
 // WireGuardGenerateNewKeys - generate WG keys
 type WireGuardGenerateNewKeys struct {
 	CommandBase
@@ -38,9 +38,20 @@ func init() {
 // ErrorResp response of error
 type ErrorResp struct {
 	CommandBase
+	// ErrorCode - enumerated error cause (see 'ErrorCode' constants)
+	ErrorCode ErrorCode
+	// ErrorMessage - human-readable details
 	ErrorMessage string
 }
 
+// RequestThrottledResp - request rejected due to rate limiting
+// (client can retry after 'RetryAfterSec' seconds)
+type RequestThrottledResp struct {
+	CommandBase
+	ErrorMessage  string
+	RetryAfterSec int
+}
+
 // EmptyResp empty response on request
 type EmptyResp struct {
 	CommandBase
@@ -51,6 +62,12 @@ type ServiceExitingResp struct {
 	CommandBase
 }
 
+// DaemonShuttingDownResp - daemon is performing a graceful shutdown
+// (sent to all connected clients just before their connections are closed)
+type DaemonShuttingDownResp struct {
+	CommandBase
+}
+
 // DisabledFunctionality Some functionality can be not accessible
 // It can happen, for example, if some external binaries not installed
 // (e.g. obfsproxy or WireGaurd on Linux)
@@ -86,6 +103,14 @@ type SessionResp struct {
 	WgKeysRegenInerval int64 // seconds
 }
 
+// CredentialsWipedResp - confirmation of the logout ('SessionDelete' request):
+// descriptions of the credential items which were securely wiped
+// (session token, generated configuration files...)
+type CredentialsWipedResp struct {
+	CommandBase
+	WipedItems []string
+}
+
 // CreateSessionResp create new session info object to send to client
 func CreateSessionResp(s preferences.SessionStatus) SessionResp {
 	return SessionResp{
@@ -98,12 +123,19 @@ func CreateSessionResp(s preferences.SessionStatus) SessionResp {
 }
 
 // SessionNewResp - information about created session (or error info)
+// When 'APIStatus' reports a captcha challenge - 'CaptchaID' and 'CaptchaImage' are defined:
+// the client is expected to display the image and to repeat the login request
+// with the solved captcha value (same for the '2FA required' status and the TOTP code)
 type SessionNewResp struct {
 	CommandBase
 	APIStatus       int
 	APIErrorMessage string
 	Session         SessionResp
 	Account         preferences.AccountStatus
+
+	CaptchaID string
+	// CaptchaImage - base64-encoded captcha image payload to be displayed to the user
+	CaptchaImage string
 }
 
 // AccountStatusResp - information about account status (or error info)
@@ -115,6 +147,104 @@ type AccountStatusResp struct {
 	Account         preferences.AccountStatus
 }
 
+// SessionExpiredResp - notification: the stored session is not valid anymore
+// (revoked from another device, deleted on the backend ...)
+// The daemon already disconnected the VPN and erased the session;
+// UI clients are expected to prompt the user to log in again
+type SessionExpiredResp struct {
+	CommandBase
+	Reason string
+}
+
+// ConnectionHistoryResp returns the locally stored connection audit log
+// ('IsEnabled' == false - the functionality is not enabled by the user; 'Entries' is empty)
+type ConnectionHistoryResp struct {
+	CommandBase
+	IsEnabled bool
+	Entries   []preferences.ConnectionHistoryEntry
+}
+
+// WireGuardCustomProfilesResp returns the stored custom (non-IVPN) WireGuard profiles
+// (private keys are redacted: they never leave the daemon)
+type WireGuardCustomProfilesResp struct {
+	CommandBase
+	Profiles []preferences.WGCustomProfile
+}
+
+// OpenVPNProfileImportResp - result of a custom OpenVPN profile import
+// ('RejectedDirectives' - forbidden directives stripped from the configuration)
+type OpenVPNProfileImportResp struct {
+	CommandBase
+	Name               string
+	RejectedDirectives []string
+}
+
+// OpenVPNCustomProfilesResp returns the stored custom (non-IVPN) OpenVPN profiles
+type OpenVPNCustomProfilesResp struct {
+	CommandBase
+	Profiles []preferences.OpenVPNCustomProfile
+}
+
+// UsageStatsResp returns the locally stored usage accounting buckets
+// (bytes transferred through the VPN, aggregated per day and per server)
+type UsageStatsResp struct {
+	CommandBase
+	Buckets []preferences.UsageStatsBucket
+}
+
+// DataCapReachedResp - notification about a reached data-cap limit
+// ('Period' - 'day' or 'month'; see the 'datacap_*' preferences)
+type DataCapReachedResp struct {
+	CommandBase
+	Period             string
+	LimitMB            int
+	TransferredBytes   uint64
+	IsConnectionPaused bool
+}
+
+// OtherVpnDetectedResp - notification about other active VPN tunnel interface(s)
+// detected on connect ('Policy' - the applied coexistence policy:
+// 'refuse', 'takeover' or 'nested'; see the 'vpn_coexistence_policy' preference)
+type OtherVpnDetectedResp struct {
+	CommandBase
+	VpnInterfaces []string
+	Policy        string
+}
+
+// WGLocalIPConflictResp - warning notification: the API-assigned WireGuard
+// in-tunnel address belongs to an already existing local subnet (such conflicts
+// break the tunnel routing). 'IsNewIPRequested' - a new address assignment was
+// requested from the API (see the 'wireguard_renew_ip_on_conflict' preference)
+type WGLocalIPConflictResp struct {
+	CommandBase
+	WgLocalIP         string
+	ConflictInterface string
+	ConflictSubnet    string
+	IsNewIPRequested  bool
+}
+
+// DeviceListResp returns devices registered to the account
+type DeviceListResp struct {
+	CommandBase
+	Devices []types.DeviceInfo
+}
+
+// AccountSubscriptionStatusResp - notification about the subscription state
+// (pushed when the periodically refreshed account status reports that the subscription
+// is about to expire or already in the grace period, or when some capabilities
+// were revoked since the previous check - e.g. subscription downgrade)
+type AccountSubscriptionStatusResp struct {
+	CommandBase
+	Active bool
+	// ActiveUntil - Unix time of the subscription expiration
+	ActiveUntil int64
+	// IsGracePeriod - the paid period is over but the account is still marked as active
+	IsGracePeriod  bool
+	WillAutoRebill bool
+	// RevokedCapabilities - capabilities which were available before and are not anymore
+	RevokedCapabilities []string
+}
+
 // KillSwitchStatusResp returns kill-switch status
 type KillSwitchStatusResp struct {
 	CommandBase
@@ -122,6 +252,33 @@ type KillSwitchStatusResp struct {
 	IsPersistent     bool
 	IsAllowLAN       bool
 	IsAllowMulticast bool
+	// IsAllowServiceDiscovery - mDNS\Bonjour and SSDP multicast is allowed
+	// (independent from the blanket 'IsAllowMulticast' permission)
+	IsAllowServiceDiscovery bool
+	// IsSoftMode - 'soft' kill-switch mode: traffic is blocked only when the tunnel
+	// drops unexpectedly; a deliberate disconnect keeps connectivity
+	IsSoftMode bool
+	// IsSoftModeTriggered - the rules are currently applied by the 'soft' mode
+	// due to an unexpected tunnel drop
+	IsSoftModeTriggered bool
+	// LanAllowedAddresses - user-managed list of specific LAN addresses (IPs or CIDR subnets)
+	// which stay reachable when the kill-switch is enabled
+	LanAllowedAddresses []string
+	// UserExceptions - user-managed list of 'always allowed' destinations
+	UserExceptions []string
+}
+
+// KillSwitchReappliedResp notification: the kill-switch rules were detected as flushed
+// (e.g. by another firewall-management tool) and were automatically re-applied by the daemon
+type KillSwitchReappliedResp struct {
+	CommandBase
+}
+
+// KillSwitchBlockedDNSResp returns statistics of the DNS leak protection
+// (BlockedPackets == 0 when counters are not supported by the platform firewall)
+type KillSwitchBlockedDNSResp struct {
+	CommandBase
+	BlockedPackets uint64
 }
 
 // KillSwitchGetIsPestistentResp returns kill-switch persistance status
@@ -140,6 +297,13 @@ type DiagnosticsGeneratedResp struct {
 	EnvironmentLog string
 }
 
+// LogLineResp - one line of the daemon log
+// (live log streaming notification; see 'SetLogStreaming' request)
+type LogLineResp struct {
+	CommandBase
+	Line string
+}
+
 // SetAlternateDNSResp returns status of changing DNS
 type SetAlternateDNSResp struct {
 	CommandBase
@@ -147,6 +311,105 @@ type SetAlternateDNSResp struct {
 	ChangedDNS string
 }
 
+// DnsProxyStatusResp returns local DNS proxy configuration
+type DnsProxyStatusResp struct {
+	CommandBase
+	Enabled    bool
+	Encryption string
+	Resolver   string
+}
+
+// ConnectionStatsResp - periodic connection statistics update
+// (streamed to the connected clients while VPN is connected)
+type ConnectionStatsResp struct {
+	CommandBase
+	BytesReceived uint64
+	BytesSent     uint64
+	// LastHandshakeTime - unix time of the last successful handshake
+	// (0 when not applicable; in use only by WireGuard)
+	LastHandshakeTime int64
+	// Endpoint - current peer endpoint
+	Endpoint string
+}
+
+// LeakTestResp - report of the connectivity and leak self-test
+type LeakTestResp struct {
+	CommandBase
+	// ExternalIP - external IP address as seen by the IVPN API (empty when the check failed)
+	ExternalIP string
+	// IsDNSLeakDetected == true - DNS requests are able to bypass the tunnel
+	IsDNSLeakDetected bool
+	// IsIPv6LeakDetected == true - IPv6 traffic is able to bypass the tunnel
+	// (checked only when IPv6 have to be blocked for the current connection)
+	IsIPv6LeakDetected bool
+}
+
+// NetworkStateResp - the daemon view of the current networking state
+// (response to 'GetNetworkState' request; for the UI 'network health' panel and debugging)
+type NetworkStateResp struct {
+	CommandBase
+	// DefaultGatewayIP - IP address of the default gateway (empty when detection failed)
+	DefaultGatewayIP string
+	// DefaultInterfaceName - name of the network interface which owns the default route
+	DefaultInterfaceName string
+	// TunnelInterfaceName - name of the VPN tunnel interface (empty when not connected)
+	TunnelInterfaceName string
+	// TunnelLocalIP - client address inside the tunnel (empty when not connected)
+	TunnelLocalIP string
+	// DNSServers - DNS resolvers currently in effect for the system
+	// (empty when detection is not supported by the platform implementation)
+	DNSServers []string
+	// ConfiguredDNS - DNS value configured by the daemon (empty when not defined)
+	ConfiguredDNS string
+	// IsDNSMatch == false - the configured DNS is not between the resolvers in effect
+	// (DNS configuration was overwritten by another application?)
+	IsDNSMatch bool
+	// OtherVpnInterfaces - active VPN tunnel interfaces which do not belong to the daemon
+	OtherVpnInterfaces []string
+}
+
+// ConnectionProgressResp notifies about progress of establishing VPN connection
+// (pushed to clients during processing of 'Connect' request; allows UI to show
+// a meaningful progress indicator and to pinpoint where a failed connect stalled)
+type ConnectionProgressResp struct {
+	CommandBase
+	// Stage - machine-readable progress stage identifier
+	// (e.g. 'generating_configuration', 'configuring_firewall', 'configuring_dns', 'starting_process')
+	Stage string
+	// Description - human-readable details of the current stage
+	Description string
+}
+
+// NetworkRulesResp - currently defined trusted\untrusted Wi-Fi network rules
+type NetworkRulesResp struct {
+	CommandBase
+	IsEnabled bool
+	Rules     []preferences.NetworkRule
+}
+
+// NetworkTrustChangedResp - notification about applied trust rule
+// for the currently connected Wi-Fi network
+// (on 'untrusted' network UI clients are expected to initiate VPN connection)
+type NetworkTrustChangedResp struct {
+	CommandBase
+	SSID      string
+	IsTrusted bool
+}
+
+// SplitTunnelStatusResp - current split-tunneling configuration
+// (also pushed to clients as a notification when the set of running
+// split-tunneling applications changes)
+type SplitTunnelStatusResp struct {
+	CommandBase
+	IsEnabled bool
+	// IsInverse == true - only 'Apps' are routed through the tunnel
+	IsInverse bool
+	// Apps - paths to binaries of applications which are excluded from the tunnel
+	Apps []string
+	// RunningApps - subset of 'Apps' which are currently running
+	RunningApps []string
+}
+
 // ConnectedResp notifying about established connection
 type ConnectedResp struct {
 	CommandBase
@@ -156,6 +419,12 @@ type ConnectedResp struct {
 	ServerIP        string
 	ExitServerID    string
 	ManualDNS       string
+	// ServerSelection - how the server was chosen by the daemon, e.g. 'fastest: de.gw.ivpn.net'
+	// (empty - the server was defined by the client)
+	ServerSelection string
+	// current AntiTracker mode (ad\tracker blocking DNS)
+	IsAntiTracker         bool
+	IsAntiTrackerHardcore bool
 }
 
 // DisconnectionReason - disconnection reason
@@ -166,6 +435,18 @@ const (
 	Unknown             DisconnectionReason = iota
 	AuthenticationError DisconnectionReason = iota
 	DisconnectRequested DisconnectionReason = iota
+	// ConnectionLost - the tunnel was closed from the server side
+	ConnectionLost DisconnectionReason = iota
+	// LocalNetworkLost - local network connectivity lost (no default route)
+	LocalNetworkLost DisconnectionReason = iota
+	// VpnProcessCrashed - the VPN process terminated with an error
+	VpnProcessCrashed DisconnectionReason = iota
+	// TLSHandshakeFailed - TLS handshake with the server failed
+	// (e.g. the OpenVPN protocol is blocked\filtered by the network)
+	TLSHandshakeFailed DisconnectionReason = iota
+	// TAPAdaptersExhausted - no free TAP\Wintun adapter (Windows;
+	// normally - the adapters are occupied by another VPN software)
+	TAPAdaptersExhausted DisconnectionReason = iota
 )
 
 // DisconnectedResp notifying about stopped connetion
@@ -174,6 +455,34 @@ type DisconnectedResp struct {
 	Failure           bool
 	Reason            DisconnectionReason //int
 	ReasonDescription string
+	// RemediationHint - human-readable hint on how to recover from the failure
+	// (empty - no specific hint for this disconnection reason)
+	RemediationHint string
+}
+
+// StateEventResp - state-change event for subscribed clients
+// Each event carries monotonically increasing sequence number and timestamp,
+// so a client can detect a missed event and resynchronize using 'GetStateSnapshot' request
+type StateEventResp struct {
+	CommandBase
+	Seq             int64
+	TimeSecFrom1970 int64
+	// EventType - type name of the wrapped event object (e.g. 'VpnStateResp', 'KillSwitchStatusResp' ...)
+	EventType string
+	// Event - JSON-serialized event object
+	Event string
+}
+
+// StateSnapshotResp returns the current daemon state
+// 'Seq' is a sequence number of the last emitted state event
+type StateSnapshotResp struct {
+	CommandBase
+	Seq                  int64
+	VpnStateVal          vpn.State
+	VpnState             string
+	ManualDNS            string
+	FirewallIsEnabled    bool
+	FirewallIsPersistent bool
 }
 
 // VpnStateResp returns VPN connection state
@@ -191,7 +500,70 @@ type ServerListResp struct {
 	VpnServers types.ServersInfoResponse
 }
 
-//PingResultType represents information ping TTL for a host (is a part of 'PingServersResp')
+// ServersUpdatedResp - notification about an updated servers list
+// (pushed to all connected clients when the background refresh detects changes)
+type ServersUpdatedResp struct {
+	CommandBase
+	VpnServers types.ServersInfoResponse
+	// IsSignatureVerified - true when the servers list came from a signature-verified download
+	// (false - the list was loaded from the local cache file)
+	IsSignatureVerified bool
+}
+
+// ServerPortsResp returns available server connection ports (per VPN protocol)
+type ServerPortsResp struct {
+	CommandBase
+	OpenvpnPorts   []types.PortInfo
+	WireguardPorts []types.PortInfo
+}
+
+// PortForwardsResp returns forwarded ports of the current session
+type PortForwardsResp struct {
+	CommandBase
+	Ports []int
+}
+
+// APIReachabilityResp - notification about changed API servers reachability
+// (IsReachable == false - all known API hosts and alternate IPs were tried and none responded)
+type APIReachabilityResp struct {
+	CommandBase
+	IsReachable bool
+}
+
+// RecoveredFromCrashResp - notification about the repairs performed on daemon start
+// after an unclean shutdown was detected (e.g. stale tunnel interface removed, DNS configuration restored)
+type RecoveredFromCrashResp struct {
+	CommandBase
+	RepairedItems []string
+}
+
+// NetworkAdaptersRepairedResp - result of the 'RepairNetworkAdapters' request
+// (descriptions of the performed repairs; empty - nothing to repair)
+type NetworkAdaptersRepairedResp struct {
+	CommandBase
+	RepairedItems []string
+}
+
+// UpdateAvailableResp - notification about an available update of the daemon
+// or the bundled binaries.
+// The daemon only detects, downloads and signature-verifies the update;
+// the actual installation is performed by the platform installer
+type UpdateAvailableResp struct {
+	CommandBase
+	UpdateInfo types.UpdateInfoResponse
+	// path to the downloaded and signature-verified daemon artifact
+	// (empty - nothing is staged yet)
+	StagedArtifactPath string
+}
+
+// GeoLookupResp returns information about the current location
+// (as seen by the API servers; the daemon caches the result until the connection state changes)
+type GeoLookupResp struct {
+	CommandBase
+	Location types.GeoLookupResponse
+}
+
+// PingResultType represents information ping TTL for a host (is a part of 'PingServersResp')
 type PingResultType struct {
 	Host string
 	Ping int
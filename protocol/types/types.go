@@ -49,7 +49,7 @@ func Send(conn net.Conn, cmd interface{}, idx int) (retErr error) {
 		}
 	}()
 
-	bytesToSend, err := serialize(cmd, idx)
+	bytesToSend, err := Serialize(cmd, idx)
 	if err != nil {
 		return fmt.Errorf("unable to send command: %w", err)
 	}
@@ -91,7 +91,7 @@ func GetTypeName(cmd interface{}) string {
 }
 
 // Serialize initializing 'Command' field and serializing object
-func serialize(cmd interface{}, idx int) (ret []byte, err error) {
+func Serialize(cmd interface{}, idx int) (ret []byte, err error) {
 	if err := initCmdFields(cmd, idx); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,373 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package protocol
+
+// Optional WebSocket bridge for the local protocol (for browser-based dashboards
+// and Electron renderers which have no access to a raw TCP socket).
+// Each WebSocket text message carries one line of the JSON protocol - the same
+// requests, responses and authentication (the 'Hello' request with the secret)
+// as the TCP transport. Implemented as an additional net.Listener: accepted
+// WebSocket connections are wrapped into net.Conn objects and processed by the
+// regular client processing routine. No external dependencies are involved
+// (the RFC 6455 handshake and framing are implemented directly).
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 6455: GUID for the 'Sec-WebSocket-Accept' calculation
+const _webSocketKeyGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// default TCP port of the WebSocket bridge
+// (in use when the 'websocket_bridge_port' preference is not defined)
+const _defaultWebSocketPort = 16290
+
+// sanity limit for an incoming message (applied per frame and to the
+// accumulated fragmented message): the protocol messages are small JSON lines
+const _wsMaxMessageSize = 1024 * 1024
+
+// WebSocket frame opcodes (RFC 6455)
+const (
+	_wsOpcodeContinuation = 0x0
+	_wsOpcodeText         = 0x1
+	_wsOpcodeBinary       = 0x2
+	_wsOpcodeClose        = 0x8
+	_wsOpcodePing         = 0x9
+	_wsOpcodePong         = 0xA
+)
+
+// startWebSocketListener - start the WebSocket bridge on 127.0.0.1:<port>
+// (only local connections are accepted).
+// The returned listener produces net.Conn objects for every established
+// WebSocket connection (ready to be processed as a regular protocol client)
+func startWebSocketListener(port int) (net.Listener, error) {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	tcpListener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start WebSocket listener: %w", err)
+	}
+
+	wsl := &webSocketListener{
+		tcpListener: tcpListener,
+		connChan:    make(chan net.Conn),
+		closeChan:   make(chan struct{})}
+
+	serveMux := http.NewServeMux()
+	serveMux.HandleFunc("/", wsl.handleUpgradeRequest)
+	go func() {
+		httpServer := &http.Server{Handler: serveMux}
+		httpServer.Serve(tcpListener)
+	}()
+
+	log.Info("WebSocket bridge started: ws://", addr)
+	return wsl, nil
+}
+
+// webSocketListener - net.Listener producing established WebSocket connections
+type webSocketListener struct {
+	tcpListener net.Listener
+	connChan    chan net.Conn
+	closeChan   chan struct{}
+	closeOnce   sync.Once
+}
+
+func (l *webSocketListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.connChan:
+		return conn, nil
+	case <-l.closeChan:
+		return nil, fmt.Errorf("WebSocket listener closed")
+	}
+}
+
+func (l *webSocketListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closeChan) })
+	return l.tcpListener.Close()
+}
+
+func (l *webSocketListener) Addr() net.Addr {
+	return l.tcpListener.Addr()
+}
+
+// handleUpgradeRequest - RFC 6455 opening handshake
+func (l *webSocketListener) handleUpgradeRequest(w http.ResponseWriter, r *http.Request) {
+	if isWebSocketUpgradeRequest(r) == false {
+		http.Error(w, "WebSocket upgrade required", http.StatusBadRequest)
+		return
+	}
+
+	// a browser page always sends the 'Origin' header: accept local origins only,
+	// so an arbitrary web page cannot open connections to the daemon through
+	// the browser of a local user
+	if origin := r.Header.Get("Origin"); isAllowedWebSocketOrigin(origin) == false {
+		log.Warning(fmt.Sprintf("WebSocket connection rejected (forbidden origin '%s')", origin))
+		http.Error(w, "origin is not allowed", http.StatusForbidden)
+		return
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if len(key) == 0 {
+		http.Error(w, "'Sec-WebSocket-Key' header is not defined", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if ok == false {
+		http.Error(w, "connection hijacking is not supported", http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		log.Error("WebSocket handshake failed: ", err)
+		return
+	}
+
+	// Sec-WebSocket-Accept: base64(SHA-1(key + GUID))
+	hash := sha1.Sum([]byte(key + _webSocketKeyGUID))
+	accept := base64.StdEncoding.EncodeToString(hash[:])
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil || rw.Flush() != nil {
+		conn.Close()
+		return
+	}
+
+	wsConn := &webSocketConn{conn: conn, reader: rw.Reader}
+	select {
+	case l.connChan <- wsConn:
+	case <-l.closeChan:
+		conn.Close()
+	}
+}
+
+// isAllowedWebSocketOrigin - check the 'Origin' header value of an upgrade request.
+// Allowed: no header (non-browser clients), 'file://' (Electron renderers loaded
+// from the filesystem) and localhost origins (any port)
+func isAllowedWebSocketOrigin(origin string) bool {
+	if len(origin) == 0 {
+		return true
+	}
+	if strings.EqualFold(origin, "file://") {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
+
+func isWebSocketUpgradeRequest(r *http.Request) bool {
+	if headerContainsToken(r.Header.Get("Connection"), "upgrade") == false {
+		return false
+	}
+	return headerContainsToken(r.Header.Get("Upgrade"), "websocket")
+}
+
+// headerContainsToken - check whether a comma-separated header value
+// contains the expected token (case-insensitive)
+func headerContainsToken(headerValue string, token string) bool {
+	for _, val := range bytes.Split([]byte(headerValue), []byte(",")) {
+		if string(bytes.ToLower(bytes.TrimSpace(val))) == token {
+			return true
+		}
+	}
+	return false
+}
+
+// webSocketConn - net.Conn adapter over an established WebSocket connection:
+// Read returns the payload of incoming text\binary messages ('\n'-terminated,
+// as the protocol expects), Write sends each response as one text message
+type webSocketConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	// not consumed data of the already received messages
+	readBuf    bytes.Buffer
+	writeMutex sync.Mutex
+}
+
+func (c *webSocketConn) Read(b []byte) (int, error) {
+	for c.readBuf.Len() == 0 {
+		payload, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		c.readBuf.Write(payload)
+		// the protocol is line-based: ensure the message is '\n'-terminated
+		if len(payload) == 0 || payload[len(payload)-1] != '\n' {
+			c.readBuf.WriteByte('\n')
+		}
+	}
+	return c.readBuf.Read(b)
+}
+
+func (c *webSocketConn) Write(b []byte) (int, error) {
+	// one response per Write call (terminating '\n' is not necessary in a message)
+	if err := c.writeFrame(_wsOpcodeText, bytes.TrimSuffix(b, []byte("\n"))); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *webSocketConn) Close() error                       { return c.conn.Close() }
+func (c *webSocketConn) LocalAddr() net.Addr                { return c.conn.LocalAddr() }
+func (c *webSocketConn) RemoteAddr() net.Addr               { return c.conn.RemoteAddr() }
+func (c *webSocketConn) SetDeadline(t time.Time) error      { return c.conn.SetDeadline(t) }
+func (c *webSocketConn) SetReadDeadline(t time.Time) error  { return c.conn.SetReadDeadline(t) }
+func (c *webSocketConn) SetWriteDeadline(t time.Time) error { return c.conn.SetWriteDeadline(t) }
+
+// readMessage - read the next complete data message
+// (continuation frames are accumulated; ping\pong\close are processed internally)
+func (c *webSocketConn) readMessage() ([]byte, error) {
+	var message []byte
+	for {
+		finalFragment, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case _wsOpcodeText, _wsOpcodeBinary, _wsOpcodeContinuation:
+			message = append(message, payload...)
+			// the per-frame limit does not protect from an unlimited amount
+			// of small continuation frames - limit the accumulated size too
+			if len(message) > _wsMaxMessageSize {
+				return nil, fmt.Errorf("WebSocket message is too big (>%d bytes)", _wsMaxMessageSize)
+			}
+			if finalFragment {
+				return message, nil
+			}
+		case _wsOpcodePing:
+			c.writeFrame(_wsOpcodePong, payload)
+		case _wsOpcodePong:
+			// nothing to do
+		case _wsOpcodeClose:
+			c.writeFrame(_wsOpcodeClose, nil)
+			return nil, io.EOF
+		default:
+			return nil, fmt.Errorf("unexpected WebSocket opcode: 0x%X", opcode)
+		}
+	}
+}
+
+// readFrame - read and unmask one WebSocket frame (client frames must be masked)
+func (c *webSocketConn) readFrame() (finalFragment bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	finalFragment = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	isMasked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(c.reader, extended[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	// sanity limit: the protocol messages are small JSON lines
+	if length > _wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("WebSocket frame is too big (%d bytes)", length)
+	}
+
+	var maskKey [4]byte
+	if isMasked {
+		if _, err = io.ReadFull(c.reader, maskKey[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if isMasked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	return finalFragment, opcode, payload, nil
+}
+
+// writeFrame - send one (unmasked; server-to-client) WebSocket frame
+func (c *webSocketConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode) // FIN + opcode
+
+	length := len(payload)
+	switch {
+	case length < 126:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, byte(length>>8), byte(length))
+	default:
+		header = append(header, 127)
+		var extended [8]byte
+		binary.BigEndian.PutUint64(extended[:], uint64(length))
+		header = append(header, extended[:]...)
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(payload); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+)
+
+// Connection audit log: locally stored history of connect\disconnect events.
+// Privacy-sensitive, therefore strictly opt-in (see the 'connection_history' preference):
+// nothing is recorded or kept on disk until the user explicitly enables it.
+
+// _connHistoryMaxEntries - retention limit of the connection audit log
+const _connHistoryMaxEntries = 500
+
+func connectionHistoryFile() string {
+	return filepath.Join(filepath.Dir(platform.SettingsFile()), "connection-history.json")
+}
+
+// addConnectionHistoryEvent - append a record to the connection audit log
+// (does nothing when the functionality is not enabled by the user)
+func (s *Service) addConnectionHistoryEvent(event string, server string, vpnType string, reason string) {
+	if s.Preferences().IsConnectionHistory == false {
+		return
+	}
+
+	s._connHistoryMutex.Lock()
+	defer s._connHistoryMutex.Unlock()
+
+	s.loadConnectionHistoryNoLock()
+
+	s._connHistory = append(s._connHistory, preferences.ConnectionHistoryEntry{
+		TimeSecFrom1970: time.Now().Unix(),
+		Event:           event,
+		Server:          server,
+		VpnType:         vpnType,
+		Reason:          reason})
+
+	// apply retention limit
+	if len(s._connHistory) > _connHistoryMaxEntries {
+		s._connHistory = s._connHistory[len(s._connHistory)-_connHistoryMaxEntries:]
+	}
+
+	if data, err := json.Marshal(s._connHistory); err != nil {
+		log.Error("Failed to serialize connection history: ", err)
+	} else if err := ioutil.WriteFile(connectionHistoryFile(), data, 0600); err != nil {
+		log.Error("Failed to save connection history: ", err)
+	}
+}
+
+// ConnectionHistory returns the stored connection audit log
+// (nil when the functionality is not enabled by the user)
+func (s *Service) ConnectionHistory() []preferences.ConnectionHistoryEntry {
+	if s.Preferences().IsConnectionHistory == false {
+		return nil
+	}
+
+	s._connHistoryMutex.Lock()
+	defer s._connHistoryMutex.Unlock()
+
+	s.loadConnectionHistoryNoLock()
+
+	history := make([]preferences.ConnectionHistoryEntry, len(s._connHistory))
+	copy(history, s._connHistory)
+	return history
+}
+
+// clearConnectionHistory erases the audit log (in memory and on disk)
+// Called when the user disables the functionality
+func (s *Service) clearConnectionHistory() {
+	s._connHistoryMutex.Lock()
+	defer s._connHistoryMutex.Unlock()
+
+	s._connHistory = nil
+	s._isConnHistoryLoaded = true
+
+	if err := os.Remove(connectionHistoryFile()); err != nil && os.IsNotExist(err) == false {
+		log.Error("Failed to remove connection history file: ", err)
+	}
+}
+
+// loadConnectionHistoryNoLock reads the audit log from disk (once)
+// Expects '_connHistoryMutex' to be acquired by the caller
+func (s *Service) loadConnectionHistoryNoLock() {
+	if s._isConnHistoryLoaded {
+		return
+	}
+	s._isConnHistoryLoaded = true
+
+	data, err := ioutil.ReadFile(connectionHistoryFile())
+	if err != nil {
+		if os.IsNotExist(err) == false {
+			log.Warning("Failed to read connection history: ", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &s._connHistory); err != nil {
+		log.Warning("Failed to parse connection history: ", err)
+		s._connHistory = nil
+	}
+}
@@ -72,6 +72,25 @@ func DeleteManual(localInterfaceIP net.IP) error {
 	return ret
 }
 
+// IsRestored - verify that the system DNS configuration was restored
+// to the pre-connection values (used after disconnect or daemon crash)
+func IsRestored() (bool, error) {
+	return implIsRestored()
+}
+
+// Repair - force restoring the system DNS configuration to the OS defaults
+// (for situations when DNS is left pointing to the dead tunnel resolver)
+func Repair() error {
+	lastManualDNS = nil
+	return implRepair()
+}
+
+// SystemDNSServers - DNS resolvers currently in effect for the system
+// (empty list when detection is not supported by the platform implementation)
+func SystemDNSServers() ([]string, error) {
+	return implSystemDNSServers()
+}
+
 // GetLastManualDNS - returns information about current manual DNS
 func GetLastManualDNS() string {
 	// TODO: get real DNS configuration of the OS
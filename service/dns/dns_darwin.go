@@ -24,7 +24,9 @@ package dns
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"strings"
 
 	"github.com/ivpn/desktop-app-daemon/service/platform"
 	"github.com/ivpn/desktop-app-daemon/shell"
@@ -75,6 +77,22 @@ func implDeleteManual(localInterfaceIP net.IP) error {
 	return nil
 }
 
+// implIsRestored - verify that the system DNS configuration was restored
+func implIsRestored() (bool, error) {
+	// verification is not supported by the script implementation
+	// ('-delete_alternate_dns' is idempotent, so Repair() can be used at any time)
+	return true, nil
+}
+
+// implRepair - force restoring the original DNS configuration of all network services
+func implRepair() error {
+	err := shell.Exec(log, platform.DNSScript(), "-delete_alternate_dns")
+	if err != nil {
+		return fmt.Errorf("repair DNS: Failed to change DNS: %w", err)
+	}
+	return nil
+}
+
 // IsPrimaryInterfaceFound (macOS specific implementation) returns 'true' when networking is available (primary interface is available)
 // When no networking available (WiFi off ?) - returns 'false'
 // <this method in use by macOS:WireGuard implementation>
@@ -85,3 +103,23 @@ func IsPrimaryInterfaceFound() bool {
 	}
 	return true
 }
+
+// implSystemDNSServers - DNS resolvers currently in effect
+// ('nameserver' entries of /etc/resolv.conf - reflects the primary resolver configuration)
+func implSystemDNSServers() ([]string, error) {
+	data, err := ioutil.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS configuration: %w", err)
+	}
+
+	servers := make([]string, 0, 3)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if ip := net.ParseIP(fields[1]); ip != nil {
+				servers = append(servers, ip.String())
+			}
+		}
+	}
+	return servers, nil
+}
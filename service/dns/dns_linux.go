@@ -24,11 +24,16 @@ package dns
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
 )
 
 var (
@@ -40,12 +45,124 @@ var (
 	done chan struct{}
 )
 
+// marker of a resolv.conf file which was generated by the daemon
+const resolvAutogeneratedMarker = "# resolv.conf autogenerated by"
+
+// dnsManagementMode - way of applying the DNS configuration on this system
+type dnsManagementMode int
+
+const (
+	// direct rewriting of /etc/resolv.conf (fallback)
+	dnsMgmtResolvConf dnsManagementMode = iota
+	// systemd-resolved: per-link DNS over D-Bus
+	// (org.freedesktop.resolve1.Manager: SetLinkDNS + SetLinkDomains)
+	dnsMgmtSystemdResolved
+	// NetworkManager: per-device DNS configuration
+	// (NM keeps ownership of resolv.conf, so the change survives NM refreshes)
+	dnsMgmtNetworkManager
+)
+
+var dnsMgmtMode = dnsMgmtResolvConf
+
+// detectDNSManagementMode - select the DNS integration available on this system
+func detectDNSManagementMode() dnsManagementMode {
+	// systemd-resolved: per-link DNS must be used when the service is active
+	// ('resolvectl' is a D-Bus client of org.freedesktop.resolve1)
+	if _, err := exec.LookPath("resolvectl"); err == nil {
+		if err := exec.Command("systemctl", "is-active", "--quiet", "systemd-resolved").Run(); err == nil {
+			return dnsMgmtSystemdResolved
+		}
+	}
+
+	// NetworkManager (without systemd-resolved)
+	if _, err := exec.LookPath("nmcli"); err == nil {
+		if err := exec.Command("nmcli", "-t", "general", "status").Run(); err == nil {
+			return dnsMgmtNetworkManager
+		}
+	}
+
+	return dnsMgmtResolvConf
+}
+
+// getTunnelInterfaceName - find the VPN tunnel network interface
+// (OpenVPN: 'tunX'; WireGuard: 'wgivpn')
+func getTunnelInterfaceName() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+	for _, ifs := range ifaces {
+		if strings.HasPrefix(ifs.Name, "tun") || strings.HasPrefix(ifs.Name, "wg") {
+			return ifs.Name, nil
+		}
+	}
+	return "", fmt.Errorf("tunnel interface not found")
+}
+
+// resolvedSetLinkDNS - apply DNS to the tunnel link via systemd-resolved
+// The routing domain '~.' directs all name lookups to the tunnel DNS
+func resolvedSetLinkDNS(addr net.IP) error {
+	ifName, err := getTunnelInterfaceName()
+	if err != nil {
+		return fmt.Errorf("failed to set link DNS (systemd-resolved): %w", err)
+	}
+	if err := shell.Exec(log, "resolvectl", "dns", ifName, addr.String()); err != nil {
+		return fmt.Errorf("failed to set link DNS (systemd-resolved): %w", err)
+	}
+	if err := shell.Exec(log, "resolvectl", "domain", ifName, "~."); err != nil {
+		return fmt.Errorf("failed to set link domains (systemd-resolved): %w", err)
+	}
+	return nil
+}
+
+// resolvedRevertLinkDNS - remove the per-link DNS configuration
+func resolvedRevertLinkDNS() error {
+	ifName, err := getTunnelInterfaceName()
+	if err != nil {
+		return nil // tunnel already gone - per-link configuration is removed with the link
+	}
+	return shell.Exec(log, "resolvectl", "revert", ifName)
+}
+
+// nmSetDeviceDNS - apply DNS to the tunnel device via NetworkManager
+// (negative dns-priority: the tunnel DNS takes precedence over all other interfaces)
+func nmSetDeviceDNS(addr net.IP) error {
+	ifName, err := getTunnelInterfaceName()
+	if err != nil {
+		return fmt.Errorf("failed to set DNS (NetworkManager): %w", err)
+	}
+	if err := shell.Exec(log, "nmcli", "device", "modify", ifName, "ipv4.dns", addr.String(), "ipv4.dns-priority", "-1"); err != nil {
+		return fmt.Errorf("failed to set DNS (NetworkManager): %w", err)
+	}
+	return nil
+}
+
+// nmResetDeviceDNS - remove the per-device DNS configuration
+func nmResetDeviceDNS() error {
+	ifName, err := getTunnelInterfaceName()
+	if err != nil {
+		return nil // tunnel already gone - per-device configuration is removed with the device
+	}
+	return shell.Exec(log, "nmcli", "device", "modify", ifName, "ipv4.dns", "", "ipv4.dns-priority", "0")
+}
+
 func init() {
 	done = make(chan struct{})
 }
 
 // implInitialize doing initialization stuff (called on application start)
 func implInitialize() error {
+	// select the DNS integration available on this system
+	dnsMgmtMode = detectDNSManagementMode()
+	switch dnsMgmtMode {
+	case dnsMgmtSystemdResolved:
+		log.Info("DNS management: systemd-resolved")
+	case dnsMgmtNetworkManager:
+		log.Info("DNS management: NetworkManager")
+	default:
+		log.Info("DNS management: resolv.conf")
+	}
+
 	// check if backup DNS file exists
 	if _, err := os.Stat(resolvBackupFile); err != nil {
 		// nothing to restore
@@ -82,6 +199,23 @@ func implSetManual(addr net.IP, localInterfaceIP net.IP) error {
 		return implDeleteManual(nil)
 	}
 
+	// native integrations: apply DNS to the tunnel link only
+	// (other interfaces stay untouched; no resolv.conf rewriting necessary)
+	switch dnsMgmtMode {
+	case dnsMgmtSystemdResolved:
+		if err := resolvedSetLinkDNS(addr); err != nil {
+			return err
+		}
+		manualDNS = addr
+		return nil
+	case dnsMgmtNetworkManager:
+		if err := nmSetDeviceDNS(addr); err != nil {
+			return err
+		}
+		manualDNS = addr
+		return nil
+	}
+
 	createBackupIfNotExists := func() (created bool, er error) {
 		if _, err := os.Stat(resolvBackupFile); err != nil {
 			// if no backup exists - create backup of DNS configuration
@@ -105,7 +239,7 @@ func implSetManual(addr net.IP, localInterfaceIP net.IP) error {
 			return fmt.Errorf("failed to update DNS configuration (%w)", err)
 		}
 
-		if _, err := out.WriteString(fmt.Sprintln(fmt.Sprintf("# resolv.conf autogenerated by '%s'\n\nnameserver %s", os.Args[0], addr.String()))); err != nil {
+		if _, err := out.WriteString(fmt.Sprintln(fmt.Sprintf("%s '%s'\n\nnameserver %s", resolvAutogeneratedMarker, os.Args[0], addr.String()))); err != nil {
 			return fmt.Errorf("failed to change DNS configuration: %w", err)
 		}
 
@@ -189,6 +323,14 @@ func implDeleteManual(localInterfaceIP net.IP) error {
 	// stop file change monitoring
 	stopDNSChangeMonitoring()
 
+	// native integrations: remove the per-link DNS configuration
+	switch dnsMgmtMode {
+	case dnsMgmtSystemdResolved:
+		return resolvedRevertLinkDNS()
+	case dnsMgmtNetworkManager:
+		return nmResetDeviceDNS()
+	}
+
 	if _, err := os.Stat(resolvBackupFile); err != nil {
 		// nothing to restore
 		return nil
@@ -202,6 +344,64 @@ func implDeleteManual(localInterfaceIP net.IP) error {
 	return nil
 }
 
+// implIsRestored - verify that the system DNS configuration was restored:
+// the backup file must not exist and resolv.conf must not be the autogenerated one
+func implIsRestored() (bool, error) {
+	// native integrations: the per-link configuration is removed together with
+	// the tunnel link, so nothing can be left behind
+	if dnsMgmtMode != dnsMgmtResolvConf {
+		return true, nil
+	}
+
+	if _, err := os.Stat(resolvBackupFile); err == nil {
+		return false, nil // backup still exists - original configuration not restored
+	}
+
+	data, err := ioutil.ReadFile(resolvFile)
+	if err != nil {
+		return true, nil // no resolv.conf - nothing of our configuration left
+	}
+	if strings.Contains(string(data), resolvAutogeneratedMarker) {
+		return false, nil // resolv.conf is still the one generated by the daemon
+	}
+	return true, nil
+}
+
+// implRepair - force restoring the original DNS configuration
+func implRepair() error {
+	stopDNSChangeMonitoring()
+
+	// native integrations: remove the per-link DNS configuration (if the link still exists);
+	// the resolv.conf checks below also run, in case the management mode changed between runs
+	switch dnsMgmtMode {
+	case dnsMgmtSystemdResolved:
+		resolvedRevertLinkDNS()
+	case dnsMgmtNetworkManager:
+		nmResetDeviceDNS()
+	}
+
+	// restore the backup when it is available
+	if _, err := os.Stat(resolvBackupFile); err == nil {
+		if err := os.Rename(resolvBackupFile, resolvFile); err != nil {
+			return fmt.Errorf("failed to restore DNS configuration: %w", err)
+		}
+		return nil
+	}
+
+	// no backup: when resolv.conf is our autogenerated one - remove it,
+	// so the network management daemon (NetworkManager\dhclient) recreates the original
+	data, err := ioutil.ReadFile(resolvFile)
+	if err != nil {
+		return nil // no resolv.conf - nothing to repair
+	}
+	if strings.Contains(string(data), resolvAutogeneratedMarker) {
+		if err := os.Remove(resolvFile); err != nil {
+			return fmt.Errorf("failed to remove autogenerated DNS configuration: %w", err)
+		}
+	}
+	return nil
+}
+
 func stopDNSChangeMonitoring() {
 	// stop file change monitoring
 	select {
@@ -211,3 +411,23 @@ func stopDNSChangeMonitoring() {
 		break
 	}
 }
+
+// implSystemDNSServers - DNS resolvers currently in effect
+// ('nameserver' entries of /etc/resolv.conf)
+func implSystemDNSServers() ([]string, error) {
+	data, err := ioutil.ReadFile(resolvFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DNS configuration: %w", err)
+	}
+
+	servers := make([]string, 0, 3)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(strings.TrimSpace(line))
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if ip := net.ParseIP(fields[1]); ip != nil {
+				servers = append(servers, ip.String())
+			}
+		}
+	}
+	return servers, nil
+}
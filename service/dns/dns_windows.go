@@ -245,9 +245,26 @@ func implDeleteManual(localInterfaceIP net.IP) (err error) {
 	return nil
 }
 
+// implIsRestored - verify that the system DNS configuration was restored
+// (the custom DNS value was removed from all interfaces)
+func implIsRestored() (bool, error) {
+	return _lastDNS == nil, nil
+}
+
+// implRepair - force removing the custom DNS configuration from all interfaces
+func implRepair() (err error) {
+	defer catchPanic(&err)
+
+	if _lastDNS == nil {
+		return nil // nothing to repair
+	}
+	return implDeleteManual(nil)
+}
+
 // getMACAddrByIPinNetwork - get hardware addresses (MAC) of the network interfaces to which belongs an IP address (MAC of interface which is in same network as 'addr')
-// 		addr - IP address from local network (which can be accessed by interface different to VPN interface)
-//		localAddrToSkip - local IP of interface which can be excluded from output (e.g. VPN interface)
+//
+//	addr - IP address from local network (which can be accessed by interface different to VPN interface)
+//	localAddrToSkip - local IP of interface which can be excluded from output (e.g. VPN interface)
 func getMACAddrByIPinNetwork(addr net.IP, localAddrToSkip net.IP) (ret []net.HardwareAddr, err error) {
 	if addr == nil {
 		return ret, nil
@@ -283,3 +300,10 @@ func getMACAddrByIPinNetwork(addr net.IP, localAddrToSkip net.IP) (ret []net.Har
 
 	return ret, nil
 }
+
+// implSystemDNSServers - DNS resolvers currently in effect.
+// Detection of the per-interface resolver list is not implemented for Windows -
+// only the daemon-configured value is known (see GetLastManualDNS)
+func implSystemDNSServers() ([]string, error) {
+	return nil, nil
+}
@@ -0,0 +1,317 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package dnsproxy implements a local DNS proxy:
+// the daemon listens for plain DNS requests on the loopback interface and
+// forwards them to a DNS over HTTPS\TLS resolver
+// (when VPN is connected - the encrypted queries go through the tunnel)
+package dnsproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("dnsp")
+}
+
+// Supported encryption types of the upstream resolver connection
+const (
+	EncryptionDoH = "doh" // DNS over HTTPS
+	EncryptionDoT = "dot" // DNS over TLS
+)
+
+const (
+	listenAddr = "127.0.0.1:53"
+	// default port for DNS over TLS (used when 'Resolver' has no port defined)
+	dotDefaultPort = "853"
+	// maximum size of a DNS message (RFC 6891)
+	maxDNSMsgSize = 4096
+
+	queryTimeout = time.Second * 10
+)
+
+// Config - local DNS proxy configuration
+type Config struct {
+	// Encryption - upstream connection type: EncryptionDoH or EncryptionDoT
+	Encryption string
+	// Resolver - upstream resolver: DoH URL (https://...) or DoT host[:port]
+	Resolver string
+}
+
+var (
+	mutex     sync.Mutex
+	config    Config
+	isRunning bool
+
+	udpListener *net.UDPConn
+	tcpListener net.Listener
+)
+
+// Start - start local DNS proxy with the defined configuration
+// (when the proxy is already running - it is restarted)
+func Start(cfg Config) error {
+	if err := CheckConfig(cfg); err != nil {
+		return err
+	}
+
+	if err := Stop(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	udpAddr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to resolve listen address: %w", err)
+	}
+	udpConn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return fmt.Errorf("failed to start DNS proxy (UDP): %w", err)
+	}
+	tcpConn, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		udpConn.Close()
+		return fmt.Errorf("failed to start DNS proxy (TCP): %w", err)
+	}
+
+	config = cfg
+	isRunning = true
+	udpListener = udpConn
+	tcpListener = tcpConn
+
+	go serveUDP(udpConn, cfg)
+	go serveTCP(tcpConn, cfg)
+
+	log.Info(fmt.Sprintf("Started (%s; resolver: %s)", cfg.Encryption, cfg.Resolver))
+	return nil
+}
+
+// Stop - stop local DNS proxy (does nothing when the proxy is not running)
+func Stop() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !isRunning {
+		return nil
+	}
+	isRunning = false
+
+	if udpListener != nil {
+		udpListener.Close()
+		udpListener = nil
+	}
+	if tcpListener != nil {
+		tcpListener.Close()
+		tcpListener = nil
+	}
+
+	log.Info("Stopped")
+	return nil
+}
+
+// IsRunning returns true when the local DNS proxy is active
+func IsRunning() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return isRunning
+}
+
+// ListenIP - IP address the local DNS proxy is listening on
+// (the address which have to be set as a system DNS)
+func ListenIP() net.IP {
+	host, _, _ := net.SplitHostPort(listenAddr)
+	return net.ParseIP(host)
+}
+
+// CheckConfig - validate local DNS proxy configuration
+func CheckConfig(cfg Config) error {
+	if len(cfg.Resolver) == 0 {
+		return fmt.Errorf("resolver not defined")
+	}
+
+	switch cfg.Encryption {
+	case EncryptionDoH:
+		if strings.HasPrefix(cfg.Resolver, "https://") == false {
+			return fmt.Errorf("DoH resolver must be an 'https://' URL")
+		}
+	case EncryptionDoT:
+		// OK
+	default:
+		return fmt.Errorf("unsupported encryption type '%s'", cfg.Encryption)
+	}
+	return nil
+}
+
+func serveUDP(conn *net.UDPConn, cfg Config) {
+	buff := make([]byte, maxDNSMsgSize)
+	for {
+		readBytes, addr, err := conn.ReadFromUDP(buff)
+		if err != nil {
+			if IsRunning() {
+				log.Error("UDP listener error: ", err)
+			}
+			return
+		}
+
+		request := make([]byte, readBytes)
+		copy(request, buff[:readBytes])
+
+		go func(request []byte, addr *net.UDPAddr) {
+			response, err := forward(cfg, request)
+			if err != nil {
+				log.Error("Failed to forward DNS request: ", err)
+				return
+			}
+			if _, err := conn.WriteToUDP(response, addr); err != nil {
+				log.Error("Failed to send DNS response: ", err)
+			}
+		}(request, addr)
+	}
+}
+
+func serveTCP(listener net.Listener, cfg Config) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if IsRunning() {
+				log.Error("TCP listener error: ", err)
+			}
+			return
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(queryTimeout))
+
+			request, err := readDNSMsgTCP(conn)
+			if err != nil {
+				return
+			}
+			response, err := forward(cfg, request)
+			if err != nil {
+				log.Error("Failed to forward DNS request: ", err)
+				return
+			}
+			writeDNSMsgTCP(conn, response)
+		}(conn)
+	}
+}
+
+// forward - send the raw DNS request to the upstream resolver and return the raw response
+func forward(cfg Config, request []byte) ([]byte, error) {
+	switch cfg.Encryption {
+	case EncryptionDoH:
+		return forwardDoH(cfg.Resolver, request)
+	case EncryptionDoT:
+		return forwardDoT(cfg.Resolver, request)
+	}
+	return nil, fmt.Errorf("unsupported encryption type '%s'", cfg.Encryption)
+}
+
+func forwardDoH(resolverURL string, request []byte) ([]byte, error) {
+	client := &http.Client{Timeout: queryTimeout}
+
+	resp, err := client.Post(resolverURL, "application/dns-message", bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("DoH request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH resolver returned status %d", resp.StatusCode)
+	}
+
+	response, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DoH response: %w", err)
+	}
+	return response, nil
+}
+
+func forwardDoT(resolver string, request []byte) ([]byte, error) {
+	host := resolver
+	if _, _, err := net.SplitHostPort(resolver); err != nil {
+		host = net.JoinHostPort(resolver, dotDefaultPort)
+	}
+
+	dialer := &net.Dialer{Timeout: queryTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect DoT resolver: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(queryTimeout))
+
+	if err := writeDNSMsgTCP(conn, request); err != nil {
+		return nil, fmt.Errorf("failed to send DoT request: %w", err)
+	}
+
+	response, err := readDNSMsgTCP(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DoT response: %w", err)
+	}
+	return response, nil
+}
+
+// readDNSMsgTCP - read a DNS message in TCP framing (2-byte length prefix; RFC 1035)
+func readDNSMsgTCP(conn net.Conn) ([]byte, error) {
+	lenBuff := make([]byte, 2)
+	if _, err := io.ReadFull(conn, lenBuff); err != nil {
+		return nil, err
+	}
+
+	msgLen := binary.BigEndian.Uint16(lenBuff)
+	if msgLen == 0 || msgLen > maxDNSMsgSize {
+		return nil, fmt.Errorf("unexpected DNS message size: %d", msgLen)
+	}
+
+	msg := make([]byte, msgLen)
+	if _, err := io.ReadFull(conn, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeDNSMsgTCP - write a DNS message in TCP framing (2-byte length prefix; RFC 1035)
+func writeDNSMsgTCP(conn net.Conn, msg []byte) error {
+	buff := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buff, uint16(len(msg)))
+	copy(buff[2:], msg)
+	_, err := conn.Write(buff)
+	return err
+}
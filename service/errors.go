@@ -29,3 +29,19 @@ type ErrorNotLoggedIn struct {
 func (e ErrorNotLoggedIn) Error() string {
 	return "not logged in; please visit https://www.ivpn.net/ to Sign Up or Log In to get info about your Account ID"
 }
+
+// ErrorConnectionCanceled - connection establishment was canceled by user request
+type ErrorConnectionCanceled struct {
+}
+
+func (e ErrorConnectionCanceled) Error() string {
+	return "connection canceled"
+}
+
+// ErrorWireGuardKeysNotGenerated - WireGuard credentials are not generated for the active session
+type ErrorWireGuardKeysNotGenerated struct {
+}
+
+func (e ErrorWireGuardKeysNotGenerated) Error() string {
+	return "WireGuard credentials are not defined (please, regenerate WG credentials or re-login)"
+}
@@ -0,0 +1,82 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+)
+
+// FirewallBackend is the interface of a concrete firewall implementation
+// (WFP on Windows, PF on macOS, iptables\nftables on Linux).
+// The backend is selected at runtime during initialization (see implInitialize
+// of the current platform); tests can inject a mock implementation using SetBackend()
+type FirewallBackend interface {
+	// Name returns a short backend name (e.g. 'iptables', 'pf', 'wfp')
+	Name() string
+	// Enable installs the kill-switch ruleset
+	Enable() error
+	// Disable removes the kill-switch ruleset
+	Disable() error
+	// GetEnabled returns true when the kill-switch ruleset is installed
+	GetEnabled() (bool, error)
+	// SetAllowLAN allows\disallows LAN (and LAN multicast) communication
+	SetAllowLAN(allowLAN bool, allowLANMulticast bool) error
+	// AddException - allow communication with the defined hosts (IPs or CIDR subnets)
+	AddException(hostsIPs []string) error
+	// RemoveException - disallow communication with the defined hosts
+	RemoveException(hostsIPs []string) error
+	// Snapshot saves the pre-existing (user-managed) firewall configuration
+	Snapshot() error
+	// Restore re-applies the configuration saved by Snapshot()
+	Restore() error
+}
+
+// active firewall backend (initialized by implInitialize of the current platform)
+var activeBackend FirewallBackend
+
+// getBackend returns the active firewall backend.
+// When called before Initialize - the backend is selected on first use
+// (an error when the backend selection fails; the functions delegating
+// to the backend must never nil-pointer panic)
+func getBackend() (FirewallBackend, error) {
+	if activeBackend == nil {
+		if err := implInitialize(); err != nil {
+			return nil, fmt.Errorf("firewall backend is not initialized: %w", err)
+		}
+	}
+	return activeBackend, nil
+}
+
+// SetBackend - inject a firewall backend implementation
+// The backend is selected automatically on initialization;
+// this function is intended for tests (to inject a mock backend)
+func SetBackend(b FirewallBackend) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	activeBackend = b
+}
+
+// Backend returns the active firewall backend
+func Backend() FirewallBackend {
+	return activeBackend
+}
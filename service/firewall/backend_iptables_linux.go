@@ -0,0 +1,268 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// snapshot of the user's iptables configuration (saved before enabling the kill-switch)
+const iptablesSnapshotFile = "/etc/ivpn-firewall-iptables.snapshot"
+
+// iptablesBackend - Linux firewall implementation based on iptables
+// (rules are managed by the firewall shell script)
+type iptablesBackend struct{}
+
+func (b *iptablesBackend) Name() string {
+	return "iptables"
+}
+
+func (b *iptablesBackend) Enable() error {
+	return shell.Exec(nil, platform.FirewallScript(), "-enable")
+}
+
+func (b *iptablesBackend) Disable() error {
+	return shell.Exec(nil, platform.FirewallScript(), "-disable")
+}
+
+func (b *iptablesBackend) GetEnabled() (bool, error) {
+	err := shell.Exec(nil, platform.FirewallScript(), "-status")
+
+	if err != nil {
+		exitCode, err := shell.GetCmdExitCode(err)
+		if err != nil {
+			return false, fmt.Errorf("failed to get Cmd exit code: %w", err)
+		}
+		if exitCode == 1 {
+			return false, nil
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (b *iptablesBackend) SetAllowLAN(allowLAN bool, allowLANMulticast bool) error {
+	localIPs, err := getLanIPs()
+	if err != nil {
+		return fmt.Errorf("failed to get local IPs: %w", err)
+	}
+
+	if allowLAN {
+		if allowLANMulticast {
+			// allow LAN + multicast
+			return addHostsToExceptions(append(localIPs, multicastIP), true)
+		}
+
+		// disallow Multicast
+		removeHostsFromExceptions([]string{multicastIP})
+		// allow LAN
+		return addHostsToExceptions(localIPs, true)
+	}
+
+	// disallow everything (LAN + multicast)
+	return removeHostsFromExceptions(append(localIPs, multicastIP))
+}
+
+func (b *iptablesBackend) AddException(hostsIPs []string) error {
+	ipList := strings.Join(hostsIPs, ",")
+
+	if len(ipList) > 0 {
+		log.Info("-add_exceptions ", ipList)
+		return shell.Exec(nil, platform.FirewallScript(), "-add_exceptions", ipList)
+	}
+	return nil
+}
+
+func (b *iptablesBackend) RemoveException(hostsIPs []string) error {
+	ipList := strings.Join(hostsIPs, ",")
+
+	if len(ipList) > 0 {
+		log.Info("-remove_exceptions ", ipList)
+		return shell.Exec(nil, platform.FirewallScript(), "-remove_exceptions", ipList)
+	}
+	return nil
+}
+
+// dedicated chain for the DNS leak protection rules
+// (managed by the daemon directly: the firewall shell script has no DNS functionality)
+const iptablesDNSChain = "IVPN-BLOCK-DNS"
+
+// ports to block: plain DNS and DNS-over-TLS
+// (DNS-over-HTTPS uses 443 and can not be distinguished by port)
+var dnsBlockPorts = []string{"53", "853"}
+
+// SetDNSBlock - DNS leak protection: block outbound DNS requests
+// to everything except the VPN tunnel interface
+func (b *iptablesBackend) SetDNSBlock(block bool, tunInterfaceName string) error {
+	iptables, err := exec.LookPath("iptables")
+	if err != nil {
+		return fmt.Errorf("failed to locate iptables binary: %w", err)
+	}
+
+	// remove previously applied rules (ignoring errors: the chain may not exist)
+	exec.Command(iptables, "-D", "OUTPUT", "-j", iptablesDNSChain).Run()
+	exec.Command(iptables, "-F", iptablesDNSChain).Run()
+	exec.Command(iptables, "-X", iptablesDNSChain).Run()
+
+	if !block {
+		return nil
+	}
+
+	rules := [][]string{{"-N", iptablesDNSChain}}
+	if len(tunInterfaceName) > 0 {
+		rules = append(rules, []string{"-A", iptablesDNSChain, "-o", tunInterfaceName, "-j", "ACCEPT"})
+	}
+	rules = append(rules, []string{"-A", iptablesDNSChain, "-o", "lo", "-j", "ACCEPT"})
+	for _, proto := range []string{"udp", "tcp"} {
+		for _, port := range dnsBlockPorts {
+			rules = append(rules, []string{"-A", iptablesDNSChain, "-p", proto, "--dport", port, "-j", "DROP"})
+		}
+	}
+	rules = append(rules, []string{"-I", "OUTPUT", "1", "-j", iptablesDNSChain})
+
+	for _, args := range rules {
+		if err := shell.Exec(nil, iptables, args...); err != nil {
+			return fmt.Errorf("failed to apply DNS block rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetBlockedDNSCount - amount of DNS packets dropped by the DNS leak protection rules
+func (b *iptablesBackend) GetBlockedDNSCount() (uint64, error) {
+	iptables, err := exec.LookPath("iptables")
+	if err != nil {
+		return 0, fmt.Errorf("failed to locate iptables binary: %w", err)
+	}
+
+	out, err := exec.Command(iptables, "-L", iptablesDNSChain, "-v", "-x", "-n").Output()
+	if err != nil {
+		return 0, nil // chain does not exist - nothing blocked
+	}
+
+	var total uint64
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[2] != "DROP" {
+			continue
+		}
+		if cnt, err := strconv.ParseUint(fields[0], 10, 64); err == nil {
+			total += cnt
+		}
+	}
+	return total, nil
+}
+
+// dedicated chain for the inbound port-forwarding rules
+const iptablesPortsChain = "IVPN-PORT-FORWARD"
+
+// SetAllowedIncomingPorts - open inbound forwarded ports (TCP and UDP)
+// on the VPN tunnel interface; previously applied port rules are removed first
+func (b *iptablesBackend) SetAllowedIncomingPorts(tunInterfaceName string, ports []int) error {
+	iptables, err := exec.LookPath("iptables")
+	if err != nil {
+		return fmt.Errorf("failed to locate iptables binary: %w", err)
+	}
+
+	// remove previously applied rules (ignoring errors: the chain may not exist)
+	exec.Command(iptables, "-D", "INPUT", "-j", iptablesPortsChain).Run()
+	exec.Command(iptables, "-F", iptablesPortsChain).Run()
+	exec.Command(iptables, "-X", iptablesPortsChain).Run()
+
+	if len(ports) == 0 || len(tunInterfaceName) == 0 {
+		return nil
+	}
+
+	rules := [][]string{{"-N", iptablesPortsChain}}
+	for _, port := range ports {
+		for _, proto := range []string{"udp", "tcp"} {
+			rules = append(rules, []string{"-A", iptablesPortsChain, "-i", tunInterfaceName, "-p", proto, "--dport", strconv.Itoa(port), "-j", "ACCEPT"})
+		}
+	}
+	rules = append(rules, []string{"-I", "INPUT", "1", "-j", iptablesPortsChain})
+
+	for _, args := range rules {
+		if err := shell.Exec(nil, iptables, args...); err != nil {
+			return fmt.Errorf("failed to apply port-forwarding rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// Snapshot - save the user's current iptables configuration (iptables-save)
+// Does nothing when a snapshot already exists (e.g. watchdog re-apply):
+// the original pre-kill-switch configuration must not be overwritten
+func (b *iptablesBackend) Snapshot() error {
+	if _, err := os.Stat(iptablesSnapshotFile); err == nil {
+		return nil // snapshot already exists
+	}
+
+	iptablesSave, err := exec.LookPath("iptables-save")
+	if err != nil {
+		return fmt.Errorf("failed to locate iptables-save binary: %w", err)
+	}
+
+	out, err := exec.Command(iptablesSave).Output()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot iptables configuration: %w", err)
+	}
+
+	if err := ioutil.WriteFile(iptablesSnapshotFile, out, 0600); err != nil {
+		return fmt.Errorf("failed to save iptables snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore - re-apply the iptables configuration saved by Snapshot()
+// (the snapshot file is removed after a successful restore)
+func (b *iptablesBackend) Restore() error {
+	f, err := os.Open(iptablesSnapshotFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // no snapshot - nothing to restore
+		}
+		return fmt.Errorf("failed to open iptables snapshot: %w", err)
+	}
+	defer f.Close()
+
+	iptablesRestore, err := exec.LookPath("iptables-restore")
+	if err != nil {
+		return fmt.Errorf("failed to locate iptables-restore binary: %w", err)
+	}
+
+	cmd := exec.Command(iptablesRestore)
+	cmd.Stdin = f
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to restore iptables configuration: %w", err)
+	}
+
+	return os.Remove(iptablesSnapshotFile)
+}
@@ -0,0 +1,395 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// nftablesBackend - native nftables implementation of the Linux firewall
+// (selected automatically when legacy iptables is not usable: the 'iptables' binary
+// is missing or is just a shim over nf_tables)
+//
+// All rules live in a dedicated table ('inet ivpn-firewall'), therefore
+// user-managed tables\chains stay untouched
+type nftablesBackend struct{}
+
+const (
+	nftBinary    = "nft"
+	nftTable     = "inet ivpn-firewall"
+	nftExSet     = "exceptions" // set of allowed remote IPs\subnets
+	nftRulesFile = "/etc/ivpn-firewall.nft"
+)
+
+func (b *nftablesBackend) Name() string {
+	return "nftables"
+}
+
+// isNftablesRequired returns true when legacy iptables is not usable on this system:
+// the binary is missing or is the nf_tables shim
+func isNftablesRequired() bool {
+	iptables, err := exec.LookPath("iptables")
+	if err != nil {
+		// no iptables binary - nftables is the only option (when available)
+		_, err := exec.LookPath(nftBinary)
+		return err == nil
+	}
+
+	// 'iptables --version' prints e.g. 'iptables v1.8.7 (nf_tables)' for the shim
+	out, err := exec.Command(iptables, "--version").Output()
+	if err != nil {
+		return false
+	}
+	if strings.Contains(string(out), "nf_tables") {
+		_, err := exec.LookPath(nftBinary)
+		return err == nil
+	}
+	return false
+}
+
+func (b *nftablesBackend) Enable() error {
+	enabled, err := b.GetEnabled()
+	if err != nil {
+		return err
+	}
+	if enabled {
+		return nil
+	}
+
+	commands := [][]string{
+		{"add", "table", "inet", "ivpn-firewall"},
+		{"add", "set", "inet", "ivpn-firewall", nftExSet, "{ type ipv4_addr ; flags interval ; }"},
+		{"add", "chain", "inet", "ivpn-firewall", "out", "{ type filter hook output priority -10 ; policy drop ; }"},
+		{"add", "chain", "inet", "ivpn-firewall", "in", "{ type filter hook input priority -10 ; policy drop ; }"},
+		// loopback
+		{"add", "rule", "inet", "ivpn-firewall", "out", "oifname lo accept"},
+		{"add", "rule", "inet", "ivpn-firewall", "in", "iifname lo accept"},
+		// DHCP
+		{"add", "rule", "inet", "ivpn-firewall", "out", "udp sport 68 udp dport 67 accept"},
+		{"add", "rule", "inet", "ivpn-firewall", "in", "udp sport 67 udp dport 68 accept"},
+		// allowed hosts (LAN, VPN servers, user exceptions...)
+		{"add", "rule", "inet", "ivpn-firewall", "out", "ip daddr @" + nftExSet + " accept"},
+		{"add", "rule", "inet", "ivpn-firewall", "in", "ip saddr @" + nftExSet + " accept"},
+	}
+
+	for _, args := range commands {
+		if err := shell.Exec(nil, nftBinary, args...); err != nil {
+			// cleanup partially created table
+			b.Disable()
+			return fmt.Errorf("failed to apply nftables configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+func (b *nftablesBackend) Disable() error {
+	enabled, err := b.GetEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return nil
+	}
+	return shell.Exec(nil, nftBinary, "delete", "table", "inet", "ivpn-firewall")
+}
+
+func (b *nftablesBackend) GetEnabled() (bool, error) {
+	err := exec.Command(nftBinary, "list", "table", "inet", "ivpn-firewall").Run()
+	if err != nil {
+		// non-zero exit code: table does not exist
+		if _, isExitErr := err.(*exec.ExitError); isExitErr {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get nftables state: %w", err)
+	}
+	return true, nil
+}
+
+func (b *nftablesBackend) SetAllowLAN(allowLAN bool, allowLANMulticast bool) error {
+	localIPs, err := getLanIPs()
+	if err != nil {
+		return fmt.Errorf("failed to get local IPs: %w", err)
+	}
+
+	if allowLAN {
+		if allowLANMulticast {
+			return addHostsToExceptions(append(localIPs, multicastIP), true)
+		}
+
+		removeHostsFromExceptions([]string{multicastIP})
+		return addHostsToExceptions(localIPs, true)
+	}
+
+	return removeHostsFromExceptions(append(localIPs, multicastIP))
+}
+
+func (b *nftablesBackend) AddException(hostsIPs []string) error {
+	if len(hostsIPs) == 0 {
+		return nil
+	}
+	enabled, err := b.GetEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+	elements := "{ " + strings.Join(hostsIPs, ", ") + " }"
+	log.Info("add elements ", elements)
+	return shell.Exec(nil, nftBinary, "add", "element", "inet", "ivpn-firewall", nftExSet, elements)
+}
+
+func (b *nftablesBackend) RemoveException(hostsIPs []string) error {
+	if len(hostsIPs) == 0 {
+		return nil
+	}
+	enabled, err := b.GetEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+	elements := "{ " + strings.Join(hostsIPs, ", ") + " }"
+	log.Info("delete elements ", elements)
+	return shell.Exec(nil, nftBinary, "delete", "element", "inet", "ivpn-firewall", nftExSet, elements)
+}
+
+// snapshot of the user's nftables configuration (saved before enabling the kill-switch)
+const nftSnapshotFile = "/etc/ivpn-firewall-nft.snapshot"
+
+// Snapshot - save the user's current nftables ruleset
+// Does nothing when a snapshot already exists (e.g. watchdog re-apply):
+// the original pre-kill-switch configuration must not be overwritten
+func (b *nftablesBackend) Snapshot() error {
+	if _, err := os.Stat(nftSnapshotFile); err == nil {
+		return nil // snapshot already exists
+	}
+
+	out, err := exec.Command(nftBinary, "list", "ruleset").Output()
+	if err != nil {
+		return fmt.Errorf("failed to snapshot nftables ruleset: %w", err)
+	}
+
+	if err := ioutil.WriteFile(nftSnapshotFile, out, 0600); err != nil {
+		return fmt.Errorf("failed to save nftables snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore - re-apply the nftables ruleset saved by Snapshot()
+// (the snapshot file is removed after a successful restore)
+func (b *nftablesBackend) Restore() error {
+	if _, err := os.Stat(nftSnapshotFile); err != nil {
+		if os.IsNotExist(err) {
+			return nil // no snapshot - nothing to restore
+		}
+		return fmt.Errorf("failed to access nftables snapshot: %w", err)
+	}
+
+	// the independently-managed DNS-block and IPv6-block tables are not part
+	// of the snapshot - remember their state: the ruleset flush destroys them
+	isDNSBlockActive := exec.Command(nftBinary, "list", "table", "inet", nftDNSTable).Run() == nil
+	isIPv6BlockActive := exec.Command(nftBinary, "list", "table", "inet", nftIPv6Table).Run() == nil
+
+	if err := shell.Exec(nil, nftBinary, "flush", "ruleset"); err != nil {
+		return fmt.Errorf("failed to flush nftables ruleset: %w", err)
+	}
+	if err := shell.Exec(nil, nftBinary, "-f", nftSnapshotFile); err != nil {
+		return fmt.Errorf("failed to restore nftables ruleset: %w", err)
+	}
+
+	// re-apply the DNS-block\IPv6-block tables which were active before the restore
+	if isDNSBlockActive {
+		if err := b.SetDNSBlock(true, connectedTunInterfaceName); err != nil {
+			log.Error("Failed to re-apply DNS block after restoring the nftables snapshot: ", err)
+		}
+	}
+	if isIPv6BlockActive {
+		if err := b.BlockIPv6(true); err != nil {
+			log.Error("Failed to re-apply IPv6 block after restoring the nftables snapshot: ", err)
+		}
+	}
+
+	return os.Remove(nftSnapshotFile)
+}
+
+// SetPersistent - install\remove boot-time rules file (IsFwPersistant mode):
+// the current ruleset is dumped into a file which can be loaded by the init system
+// before networking starts
+func (b *nftablesBackend) SetPersistent(persistant bool) error {
+	if !persistant {
+		if err := os.Remove(nftRulesFile); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove persistent rules file: %w", err)
+		}
+		return nil
+	}
+
+	// ensure rules are in place before dumping them
+	if err := b.Enable(); err != nil {
+		return err
+	}
+
+	out, err := exec.Command(nftBinary, "list", "table", "inet", "ivpn-firewall").Output()
+	if err != nil {
+		return fmt.Errorf("failed to dump nftables ruleset: %w", err)
+	}
+
+	data := "#!/usr/sbin/nft -f\n# IVPN persistent firewall rules (autogenerated)\n" + string(out)
+	if err := ioutil.WriteFile(nftRulesFile, []byte(data), 0600); err != nil {
+		return fmt.Errorf("failed to save persistent rules file: %w", err)
+	}
+	return nil
+}
+
+// ClientConnected - allow communication trough the VPN tunnel interface
+func (b *nftablesBackend) ClientConnected(interfaceName string, clientLocalIPAddress net.IP) error {
+	commands := [][]string{
+		{"add", "rule", "inet", "ivpn-firewall", "out", "oifname " + interfaceName + " accept"},
+		{"add", "rule", "inet", "ivpn-firewall", "in", "iifname " + interfaceName + " accept"},
+	}
+	for _, args := range commands {
+		if err := shell.Exec(nil, nftBinary, args...); err != nil {
+			return fmt.Errorf("failed to apply nftables configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// ClientDisconnected - remove tunnel interface rules (recreate the base ruleset)
+func (b *nftablesBackend) ClientDisconnected() error {
+	enabled, err := b.GetEnabled()
+	if err != nil || !enabled {
+		return err
+	}
+	// the simplest way to remove the per-connection rules: recreate the ruleset
+	if err := b.Disable(); err != nil {
+		return err
+	}
+	if err := b.Enable(); err != nil {
+		return err
+	}
+	// restore all exceptions which are still allowed (e.g. LAN, user exceptions)
+	return reApplyExceptions()
+}
+
+// dedicated table for the DNS leak protection rules
+const nftDNSTable = "ivpn-firewall-dns"
+
+// dedicated table for the IPv6 leak protection rules
+const nftIPv6Table = "ivpn-firewall-ipv6"
+
+// SetDNSBlock - DNS leak protection: block outbound DNS requests
+// to everything except the VPN tunnel interface
+// Implemented as a separate table, so it works independently from the kill-switch state
+func (b *nftablesBackend) SetDNSBlock(block bool, tunInterfaceName string) error {
+	// remove previously applied rules (the table is recreated from scratch on each call)
+	if exec.Command(nftBinary, "list", "table", "inet", nftDNSTable).Run() == nil {
+		if err := shell.Exec(nil, nftBinary, "delete", "table", "inet", nftDNSTable); err != nil {
+			return fmt.Errorf("failed to remove DNS block table: %w", err)
+		}
+	}
+	if !block {
+		return nil
+	}
+
+	commands := [][]string{
+		{"add", "table", "inet", nftDNSTable},
+		{"add", "chain", "inet", nftDNSTable, "out", "{ type filter hook output priority -12 ; policy accept ; }"},
+		{"add", "rule", "inet", nftDNSTable, "out", "oifname lo accept"},
+	}
+	if len(tunInterfaceName) > 0 {
+		commands = append(commands, []string{"add", "rule", "inet", nftDNSTable, "out", "oifname " + tunInterfaceName + " accept"})
+	}
+	commands = append(commands,
+		[]string{"add", "rule", "inet", nftDNSTable, "out", "meta l4proto { tcp, udp } th dport { " + strings.Join(dnsBlockPorts, ", ") + " } counter drop"})
+
+	for _, args := range commands {
+		if err := shell.Exec(nil, nftBinary, args...); err != nil {
+			// cleanup partially created table
+			shell.Exec(nil, nftBinary, "delete", "table", "inet", nftDNSTable)
+			return fmt.Errorf("failed to apply DNS block configuration: %w", err)
+		}
+	}
+	return nil
+}
+
+// SetAllowedIncomingPorts - open inbound forwarded ports on the VPN tunnel interface
+// Nothing to do here: the base ruleset already accepts all inbound traffic
+// on the tunnel interface while the VPN client is connected (see ClientConnected)
+func (b *nftablesBackend) SetAllowedIncomingPorts(tunInterfaceName string, ports []int) error {
+	return nil
+}
+
+// GetBlockedDNSCount - amount of DNS packets dropped by the DNS leak protection rules
+func (b *nftablesBackend) GetBlockedDNSCount() (uint64, error) {
+	out, err := exec.Command(nftBinary, "list", "table", "inet", nftDNSTable).Output()
+	if err != nil {
+		return 0, nil // table does not exist - nothing blocked
+	}
+
+	// the drop rules contain counters, e.g.: '... counter packets 12 bytes 720 drop'
+	var total uint64
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		for i := 0; i < len(fields)-1; i++ {
+			if fields[i] == "packets" {
+				if cnt, err := strconv.ParseUint(fields[i+1], 10, 64); err == nil {
+					total += cnt
+				}
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// BlockIPv6 - block all IPv6 traffic (IPv6 leak protection)
+// Implemented as a separate table, so it works independently from the kill-switch state
+// (note: when the kill-switch is enabled - IPv6 is blocked anyway by the 'drop' policy
+// of the 'inet' family chains)
+func (b *nftablesBackend) BlockIPv6(block bool) error {
+	if !block {
+		err := exec.Command(nftBinary, "list", "table", "inet", nftIPv6Table).Run()
+		if err != nil {
+			return nil // table does not exist - nothing to do
+		}
+		return shell.Exec(nil, nftBinary, "delete", "table", "inet", nftIPv6Table)
+	}
+
+	commands := [][]string{
+		{"add", "table", "inet", nftIPv6Table},
+		{"add", "chain", "inet", nftIPv6Table, "out", "{ type filter hook output priority -11 ; policy accept ; }"},
+		{"add", "chain", "inet", nftIPv6Table, "in", "{ type filter hook input priority -11 ; policy accept ; }"},
+		{"add", "rule", "inet", nftIPv6Table, "out", "oifname != lo meta nfproto ipv6 drop"},
+		{"add", "rule", "inet", nftIPv6Table, "in", "iifname != lo meta nfproto ipv6 drop"},
+	}
+	for _, args := range commands {
+		if err := shell.Exec(nil, nftBinary, args...); err != nil {
+			return fmt.Errorf("failed to apply nftables configuration: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// pfBackend - macOS firewall implementation based on PF (Packet Filter)
+// (rules are managed by the firewall shell script; anchor: 'ivpn_firewall')
+type pfBackend struct{}
+
+func (b *pfBackend) Name() string {
+	return "pf"
+}
+
+func (b *pfBackend) Enable() error {
+	return shell.Exec(nil, platform.FirewallScript(), "-enable")
+}
+
+func (b *pfBackend) Disable() error {
+	return shell.Exec(nil, platform.FirewallScript(), "-disable")
+}
+
+func (b *pfBackend) GetEnabled() (bool, error) {
+	err := shell.Exec(nil, platform.FirewallScript(), "-status")
+
+	if err != nil {
+		exitCode, err := shell.GetCmdExitCode(err)
+		if err != nil {
+			return false, fmt.Errorf("failed to get Cmd exit code: %w", err)
+		}
+		if exitCode == 1 {
+			return false, nil
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+func (b *pfBackend) SetAllowLAN(allowLAN bool, allowLANMulticast bool) error {
+	localIPs, err := getLanIPs()
+	if err != nil {
+		return fmt.Errorf("failed to get local IPs: %w", err)
+	}
+
+	if allowLAN {
+		if allowLANMulticast {
+			// allow LAN + multicast
+			return addHostsToExceptions(append(localIPs, multicastIP), true)
+		}
+
+		// disallow Multicast
+		removeHostsFromExceptions([]string{multicastIP})
+		// allow LAN
+		return addHostsToExceptions(localIPs, true)
+	}
+
+	// disallow everything (LAN + multicast)
+	return removeHostsFromExceptions(append(localIPs, multicastIP))
+}
+
+func (b *pfBackend) AddException(hostsIPs []string) error {
+	ipList := strings.Join(hostsIPs, " ")
+
+	if len(ipList) > 0 {
+		log.Info("-add_exceptions ", ipList)
+		return shell.Exec(nil, platform.FirewallScript(), "-add_exceptions", ipList)
+	}
+	return nil
+}
+
+func (b *pfBackend) RemoveException(hostsIPs []string) error {
+	ipList := strings.Join(hostsIPs, " ")
+
+	if len(ipList) > 0 {
+		log.Info("-remove_exceptions ", ipList)
+		return shell.Exec(nil, platform.FirewallScript(), "-remove_exceptions", ipList)
+	}
+	return nil
+}
+
+func (b *pfBackend) Snapshot() error {
+	// nothing to do for the pf implementation:
+	// the kill-switch rules live in a dedicated anchor ('ivpn_firewall')
+	// and do not touch user-managed rules
+	return nil
+}
+
+func (b *pfBackend) Restore() error {
+	// nothing to do for the pf implementation
+	return nil
+}
@@ -0,0 +1,79 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+// wfpBackend - Windows firewall implementation based on WFP (Windows Filtering Platform)
+type wfpBackend struct{}
+
+func (b *wfpBackend) Name() string {
+	return "wfp"
+}
+
+func (b *wfpBackend) Enable() error {
+	return doEnable()
+}
+
+func (b *wfpBackend) Disable() error {
+	return doDisable()
+}
+
+func (b *wfpBackend) GetEnabled() (bool, error) {
+	pInfo, err := manager.GetProviderInfo(providerKey)
+	if err != nil {
+		return false, err
+	}
+	return pInfo.IsInstalled, nil
+}
+
+func (b *wfpBackend) SetAllowLAN(allowLAN bool, allowLANMulticast bool) error {
+	if isAllowLAN == allowLAN && isAllowLANMulticast == allowLANMulticast {
+		return nil
+	}
+
+	isAllowLAN = allowLAN
+	isAllowLANMulticast = allowLANMulticast
+
+	return reEnableIfActive()
+}
+
+func (b *wfpBackend) AddException(hostsIPs []string) error {
+	// nothing to do for the WFP implementation
+	// (exceptions are defined as 'allow' filters during doEnable())
+	return nil
+}
+
+func (b *wfpBackend) RemoveException(hostsIPs []string) error {
+	// nothing to do for the WFP implementation
+	return nil
+}
+
+func (b *wfpBackend) Snapshot() error {
+	// nothing to do for the WFP implementation:
+	// filters are installed into a dedicated sublayer and do not touch user-managed rules
+	return nil
+}
+
+func (b *wfpBackend) Restore() error {
+	// nothing to do for the WFP implementation
+	return nil
+}
@@ -40,12 +40,26 @@ var (
 	connectedClientInterfaceIP net.IP
 	mutex                      sync.Mutex
 	isClientPaused             bool
+	// DNS leak protection: block out-of-tunnel DNS requests while connected
+	isBlockDNSEnabled bool
 )
 
 // Initialize is doing initialization stuff
 // Must be called on application start
 func Initialize() error {
-	return implInitialize()
+	if err := implInitialize(); err != nil {
+		return err
+	}
+
+	// crash recovery: when a configuration snapshot is left from a previous run
+	// and the kill-switch is not active - restore the user's firewall configuration
+	if enabled, err := activeBackend.GetEnabled(); err == nil && !enabled {
+		if err := activeBackend.Restore(); err != nil {
+			log.Warning("Failed to restore firewall configuration snapshot: ", err)
+		}
+	}
+
+	return nil
 }
 
 // SetEnabled - change firewall state
@@ -53,8 +67,23 @@ func SetEnabled(enable bool) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	// an explicit state change overrules the 'soft' mode bookkeeping
+	isSoftModeTriggered = false
+
+	return setEnabled(enable)
+}
+
+func setEnabled(enable bool) error {
 	if enable {
 		log.Info("Enabling...")
+
+		// snapshot the user's pre-existing firewall configuration
+		// (to be able to restore it exactly on disable)
+		if backend, err := getBackend(); err == nil {
+			if err := backend.Snapshot(); err != nil {
+				log.Warning("Failed to snapshot firewall configuration: ", err)
+			}
+		}
 	} else {
 		log.Info("Disabling...")
 	}
@@ -65,6 +94,16 @@ func SetEnabled(enable bool) error {
 		return fmt.Errorf("failed to change firewall state : %w", err)
 	}
 
+	if !enable {
+		// restore the user's firewall configuration saved before enabling
+		if backend, err := getBackend(); err == nil {
+			if err := backend.Restore(); err != nil {
+				log.Warning("Failed to restore firewall configuration: ", err)
+			}
+		}
+	}
+
+	expectedEnabledState = enable
 	if enable {
 		// To fulfill such flow (example): FWEnable -> Connected -> FWDisable -> FWEnable
 		// Here we should notify that client is still connected
@@ -77,6 +116,71 @@ func SetEnabled(enable bool) error {
 	return nil
 }
 
+// 'soft' kill-switch mode: traffic is blocked only after an unexpected tunnel drop;
+// a deliberate disconnect keeps connectivity
+var (
+	isSoftModeEnabled bool
+	// true while the rules are applied due to an unexpected tunnel drop
+	isSoftModeTriggered bool
+)
+
+// SetSoftMode - enable\disable the 'soft' kill-switch mode.
+// When the mode is disabled while its block is active - the block is released
+func SetSoftMode(enabled bool) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Soft mode: %t", enabled))
+
+	isSoftModeEnabled = enabled
+	if enabled == false && isSoftModeTriggered {
+		isSoftModeTriggered = false
+		return setEnabled(false)
+	}
+	return nil
+}
+
+// SoftModeStatus - state of the 'soft' kill-switch mode
+// (isTriggered == true - the rules are currently applied due to an unexpected tunnel drop)
+func SoftModeStatus() (isEnabled bool, isTriggered bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return isSoftModeEnabled, isSoftModeTriggered
+}
+
+// SoftModeTrigger - apply the kill-switch rules due to an unexpected tunnel drop
+// (no-op when the 'soft' mode is not enabled or the firewall is already active)
+func SoftModeTrigger() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isSoftModeEnabled == false || isSoftModeTriggered {
+		return nil
+	}
+	if enabled, err := implGetEnabled(); err == nil && enabled {
+		return nil // already enabled (the regular kill-switch is active)
+	}
+
+	log.Info("Soft kill-switch: blocking traffic (unexpected tunnel drop)")
+	isSoftModeTriggered = true
+	return setEnabled(true)
+}
+
+// SoftModeRelease - release the 'soft' kill-switch block
+// (called on a deliberate disconnect request or when the tunnel is re-established)
+func SoftModeRelease() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isSoftModeTriggered == false {
+		return nil
+	}
+
+	log.Info("Soft kill-switch: releasing block")
+	isSoftModeTriggered = false
+	return setEnabled(false)
+}
+
 // SetPersistant - set persistant firewall state and enable it if necessary
 func SetPersistant(persistant bool) error {
 	mutex.Lock()
@@ -87,6 +191,9 @@ func SetPersistant(persistant bool) error {
 	err := implSetPersistant(persistant)
 	if err != nil {
 		log.Error(err)
+	} else if persistant {
+		// persistant firewall rules must be always in place
+		expectedEnabledState = true
 	}
 	return err
 }
@@ -129,8 +236,23 @@ func ClientConnected(clientLocalIPAddress net.IP) error {
 	err := implClientConnected(clientLocalIPAddress)
 	if err != nil {
 		log.Error(err)
+		return err
 	}
-	return err
+
+	// DNS leak protection: block out-of-tunnel DNS requests (if enabled)
+	if isBlockDNSEnabled {
+		if err := implSetDNSBlock(true); err != nil {
+			log.Error("Failed to apply DNS leak protection: ", err)
+		}
+	}
+
+	// open inbound forwarded ports on the tunnel interface (if defined)
+	if len(allowedIncomingPorts) > 0 {
+		if err := implSetAllowedIncomingPorts(allowedIncomingPorts); err != nil {
+			log.Error("Failed to open inbound forwarded ports: ", err)
+		}
+	}
+	return nil
 }
 
 // ClientDisconnected - Remove all hosts exceptions
@@ -143,6 +265,17 @@ func ClientDisconnected() error {
 	if connectedClientInterfaceIP != nil {
 		connectedClientInterfaceIP = nil
 		log.Info("Client disconnected")
+
+		// remove connection-scoped DNS block
+		if err := implSetDNSBlock(false); err != nil {
+			log.Error("Failed to remove DNS leak protection rules: ", err)
+		}
+
+		// remove connection-scoped inbound port rules
+		if err := implSetAllowedIncomingPorts(nil); err != nil {
+			log.Error("Failed to remove inbound forwarded port rules: ", err)
+		}
+
 		err := implClientDisconnected()
 		if err != nil {
 			log.Error(err)
@@ -166,6 +299,165 @@ func AddHostsToExceptions(IPs []net.IP) error {
 	return err
 }
 
+// SetApiServersExceptions - define narrow 'always allowed' exception for the IVPN API servers
+// (keeps the API reachable when the firewall is enabled but VPN is not connected;
+// while connected - daemon-originated API requests just go through the tunnel)
+func SetApiServersExceptions(IPs []net.IP) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	addresses := make([]string, 0, len(IPs))
+	for _, ip := range IPs {
+		addresses = append(addresses, ip.String())
+	}
+
+	log.Info("API servers exceptions: ", addresses)
+
+	err := implSetApiServersExceptions(addresses)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// SetLanAllowedAddresses - define user-managed list of LAN addresses (single IPs or subnets
+// in CIDR notation) which stay reachable when the firewall is enabled,
+// even when LAN access is not allowed globally (IsFwAllowLAN == false)
+func SetLanAllowedAddresses(addresses []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info("LAN allowed addresses: ", addresses)
+
+	err := implSetLanAllowedAddresses(addresses)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// SetUserExceptions - define user-managed list of 'always allowed' destinations
+// (single IPs or subnets in CIDR notation) which are injected into the kill-switch ruleset
+// Note: port granularity is not supported by the current backends - the whole host is allowed
+func SetUserExceptions(addresses []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info("User exceptions: ", addresses)
+
+	err := implSetUserExceptions(addresses)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// SetPartialTunnelNetworks - destination networks which are NOT captured by the tunnel
+// when the connection uses a partial AllowedIPs set. The kill-switch normally assumes
+// full default-route capture and blocks everything outside the tunnel; in partial-tunnel
+// mode the non-captured destinations have to stay reachable (empty list - full-tunnel mode)
+func SetPartialTunnelNetworks(addresses []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(addresses) > 0 {
+		log.Info("Partial-tunnel allowed networks: ", addresses)
+	}
+
+	err := implSetPartialTunnelNetworks(addresses)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// SetDNSLeakProtection - enable\disable DNS leak protection:
+// block outbound DNS requests (plain DNS and DoT ports) to anything except the tunnel DNS
+// The rules are applied only while the VPN client is connected
+func SetDNSLeakProtection(enabled bool) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isBlockDNSEnabled == enabled {
+		return nil
+	}
+	isBlockDNSEnabled = enabled
+
+	log.Info(fmt.Sprintf("DNS leak protection: %t", enabled))
+
+	// apply\remove rules immediately when VPN client is connected
+	if connectedClientInterfaceIP != nil && isClientPaused == false {
+		return implSetDNSBlock(enabled)
+	}
+	if enabled == false {
+		return implSetDNSBlock(false)
+	}
+	return nil
+}
+
+// GetBlockedDNSCount - amount of DNS packets blocked by the DNS leak protection
+// (0 when counters are not supported by the active backend)
+func GetBlockedDNSCount() (uint64, error) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return implGetBlockedDNSCount()
+}
+
+// inbound ports which have to stay open on the VPN tunnel interface
+// (in use by the port-forwarding functionality)
+var allowedIncomingPorts []int
+
+// SetAllowedIncomingPorts - define inbound ports which have to stay open
+// on the VPN tunnel interface (in use by the port-forwarding functionality)
+// The rules are applied only while the VPN client is connected
+// (and re-applied on each new connection)
+func SetAllowedIncomingPorts(ports []int) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	allowedIncomingPorts = ports
+
+	// apply\remove rules immediately when VPN client is connected
+	if connectedClientInterfaceIP != nil && isClientPaused == false {
+		return implSetAllowedIncomingPorts(ports)
+	}
+	return nil
+}
+
+// BlockIPv6 - block all IPv6 traffic (IPv6 leak protection)
+// In use while VPN is connected and the tunnel is IPv4-only
+// (independent from the main kill-switch state)
+func BlockIPv6(block bool) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Block IPv6: %t", block))
+
+	err := implBlockIPv6(block)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// AllowServiceDiscovery - allow\forbid local service discovery multicast
+// (mDNS\Bonjour and SSDP) while the firewall is enabled.
+// Independent from the blanket 'allowLanMulticast' permission: only the specific
+// discovery multicast groups are allowed (AirPrint\Chromecast\DLNA keep working
+// without opening all multicast)
+func AllowServiceDiscovery(allow bool) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	log.Info(fmt.Sprintf("Allow service discovery (mDNS\\SSDP): %t", allow))
+
+	err := implAllowServiceDiscovery(allow)
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
 // AllowLAN - allow/forbid LAN communication
 func AllowLAN(allowLan bool, allowLanMulticast bool) error {
 	mutex.Lock()
@@ -52,48 +52,49 @@ func init() {
 	allowedHosts = make(map[string]bool)
 }
 
-func implInitialize() error { return nil }
+func implInitialize() error {
+	// select firewall backend implementation
+	activeBackend = &pfBackend{}
+	log.Info("Firewall backend: ", activeBackend.Name())
+	return nil
+}
 
 func implGetEnabled() (bool, error) {
-	err := shell.Exec(nil, platform.FirewallScript(), "-status")
-
+	backend, err := getBackend()
 	if err != nil {
-		exitCode, err := shell.GetCmdExitCode(err)
-		if err != nil {
-			return false, fmt.Errorf("failed to get Cmd exit code: %w", err)
-		}
-		if exitCode == 1 {
-			return false, nil
-		}
-		return false, nil
+		return false, err
 	}
-	return true, nil
+	return backend.GetEnabled()
 }
 
 func implSetEnabled(isEnabled bool) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
+	}
 	if isEnabled {
-		err := shell.Exec(nil, platform.FirewallScript(), "-enable")
-		if err != nil {
-			return fmt.Errorf("failed to execute shell command: %w", err)
+		if err := backend.Enable(); err != nil {
+			return fmt.Errorf("failed to enable firewall: %w", err)
 		}
 		// To fulfill such flow (example): Connected -> FWDisable -> FWEnable
 		// Here we should restore all exceptions (all hosts which are allowed)
 		return reApplyExceptions()
 	}
-	return shell.Exec(nil, platform.FirewallScript(), "-disable")
+	return backend.Disable()
 }
 
 func implSetPersistant(persistant bool) error {
 	if persistant {
-		// The persistence is based on such facts:
-		// 	- daemon is starting as 'LaunchDaemons'
-		// 	- SetPersistant() called by service object on daemon start
-		// This means we just have to ensure that firewall enabled.
+		// Boot-time persistence: a LaunchDaemon loads the PF rules at boot,
+		// so the rules are in place even before this daemon is started
+		if err := installBootTimePersistence(); err != nil {
+			log.Error("Failed to install boot-time firewall persistence: ", err)
+		}
 
 		// Just ensure that firewall is enabled
 		return implSetEnabled(true)
 	}
-	return nil
+	return removeBootTimePersistence()
 }
 
 // ClientConnected - allow communication for local vpn/client IP address
@@ -119,25 +120,138 @@ func implClientDisconnected() error {
 }
 
 func implAllowLAN(isAllowLAN bool, isAllowLanMulticast bool) error {
-	localIPs, err := getLanIPs()
+	backend, err := getBackend()
 	if err != nil {
-		return fmt.Errorf("failed to get local IPs: %w", err)
+		return err
+	}
+	return backend.SetAllowLAN(isAllowLAN, isAllowLanMulticast)
+}
+
+// service discovery multicast groups:
+// mDNS\Bonjour - 224.0.0.251 (UDP 5353); SSDP - 239.255.255.250 (UDP 1900)
+// Note: port granularity is not supported by the exceptions mechanism,
+// but these groups carry only the corresponding discovery protocol
+var serviceDiscoveryHosts = []string{"224.0.0.251", "239.255.255.250"}
+var isAllowServiceDiscovery bool
+
+func implAllowServiceDiscovery(allow bool) error {
+	if isAllowServiceDiscovery == allow {
+		return nil
+	}
+	isAllowServiceDiscovery = allow
+
+	if allow {
+		return addHostsToExceptions(serviceDiscoveryHosts, true)
+	}
+	return removeHostsFromExceptions(serviceDiscoveryHosts)
+}
+
+// user-managed list of LAN addresses which are allowed in addition to the 'AllowLAN' functionality
+var lanAllowedAddresses []string
+
+func implSetLanAllowedAddresses(addresses []string) error {
+	// remove previously allowed addresses
+	if len(lanAllowedAddresses) > 0 {
+		if err := removeHostsFromExceptions(lanAllowedAddresses); err != nil {
+			log.Error(err)
+		}
+	}
+
+	lanAllowedAddresses = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// user-managed list of 'always allowed' destinations
+var userExceptions []string
+
+func implSetUserExceptions(addresses []string) error {
+	// remove previously defined exceptions
+	if len(userExceptions) > 0 {
+		if err := removeHostsFromExceptions(userExceptions); err != nil {
+			log.Error(err)
+		}
+	}
+
+	userExceptions = addresses
+	if len(addresses) == 0 {
+		return nil
 	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// destination networks not captured by a partial tunnel (see SetPartialTunnelNetworks)
+var partialTunnelNetworks []string
+
+func implSetPartialTunnelNetworks(addresses []string) error {
+	// remove previously defined exceptions
+	if len(partialTunnelNetworks) > 0 {
+		if err := removeHostsFromExceptions(partialTunnelNetworks); err != nil {
+			log.Error(err)
+		}
+	}
+
+	partialTunnelNetworks = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// API servers which stay reachable when the firewall is enabled but VPN is not connected
+var apiServersExceptions []string
 
-	if isAllowLAN {
-		if isAllowLanMulticast {
-			// allow LAN + multicast
-			return addHostsToExceptions(append(localIPs, multicastIP), true)
+func implSetApiServersExceptions(addresses []string) error {
+	// remove previously defined exceptions
+	if len(apiServersExceptions) > 0 {
+		if err := removeHostsFromExceptions(apiServersExceptions); err != nil {
+			log.Error(err)
 		}
+	}
+
+	apiServersExceptions = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// true when all IPv6 traffic is blocked (IPv6 leak protection for IPv4-only tunnels)
+var isIPv6Blocked bool
 
-		// disallow Multicast
-		removeHostsFromExceptions([]string{multicastIP})
-		// allow LAN
-		return addHostsToExceptions(localIPs, true)
+func implBlockIPv6(block bool) error {
+	if isIPv6Blocked == block {
+		return nil
+	}
+	isIPv6Blocked = block
+
+	if block {
+		return shell.Exec(nil, platform.FirewallScript(), "-block_ipv6")
+	}
+	return shell.Exec(nil, platform.FirewallScript(), "-unblock_ipv6")
+}
+
+// implSetDNSBlock - block outbound DNS requests (plain DNS and DNS-over-TLS ports)
+// to everything except the VPN tunnel interface (DNS leak protection)
+func implSetDNSBlock(block bool) error {
+	if block {
+		return shell.Exec(nil, platform.FirewallScript(), "-block_dns")
 	}
+	return shell.Exec(nil, platform.FirewallScript(), "-unblock_dns")
+}
+
+func implGetBlockedDNSCount() (uint64, error) {
+	// blocked-packets counters are not supported by the PF script implementation
+	return 0, nil
+}
 
-	// disallow everything (LAN + multicast)
-	return removeHostsFromExceptions(append(localIPs, multicastIP))
+// implSetAllowedIncomingPorts - open inbound forwarded ports on the VPN tunnel interface
+// Nothing to do here: the PF rules already accept all traffic
+// on the tunnel interface while the VPN client is connected
+func implSetAllowedIncomingPorts(ports []int) error {
+	return nil
 }
 
 // AddHostsToExceptions - allow comminication with this hosts
@@ -160,26 +274,20 @@ func implSetManualDNS(addr net.IP) error {
 
 //---------------------------------------------------------------------
 
-func applyAddHostsToExceptions(hostsIPs []string) error { //
-	var ipList string
-	ipList = strings.Join(hostsIPs, " ")
-
-	if len(ipList) > 0 {
-		log.Info("-add_exceptions ", ipList)
-		return shell.Exec(nil, platform.FirewallScript(), "-add_exceptions", ipList)
+func applyAddHostsToExceptions(hostsIPs []string) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
 	}
-	return nil
+	return backend.AddException(hostsIPs)
 }
 
 func applyRemoveHostsFromExceptions(hostsIPs []string) error {
-	var ipList string
-	ipList = strings.Join(hostsIPs, " ")
-
-	if len(ipList) > 0 {
-		log.Info("-remove_exceptions ", ipList)
-		return shell.Exec(nil, platform.FirewallScript(), "-remove_exceptions", ipList)
+	backend, err := getBackend()
+	if err != nil {
+		return err
 	}
-	return nil
+	return backend.RemoveException(hostsIPs)
 }
 
 func reApplyExceptions() error {
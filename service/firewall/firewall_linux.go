@@ -25,7 +25,6 @@ package firewall
 import (
 	"fmt"
 	"net"
-	"strings"
 
 	"github.com/ivpn/desktop-app-daemon/netinfo"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
@@ -46,48 +45,72 @@ func init() {
 	allowedHosts = make(map[string]bool)
 }
 
-func implInitialize() error { return nil }
+func implInitialize() error {
+	// select firewall backend implementation:
+	// native nftables when legacy iptables is not usable on this system
+	// (modern distros are deprecating legacy iptables)
+	if isNftablesRequired() {
+		activeBackend = &nftablesBackend{}
+	} else {
+		activeBackend = &iptablesBackend{}
+	}
+	log.Info("Firewall backend: ", activeBackend.Name())
+	return nil
+}
 
 func implGetEnabled() (bool, error) {
-	err := shell.Exec(nil, platform.FirewallScript(), "-status")
-
+	backend, err := getBackend()
 	if err != nil {
-		exitCode, err := shell.GetCmdExitCode(err)
-		if err != nil {
-			return false, fmt.Errorf("failed to get Cmd exit code: %w", err)
-		}
-		if exitCode == 1 {
-			return false, nil
-		}
-		return false, nil
+		return false, err
 	}
-	return true, nil
+	return backend.GetEnabled()
 }
 
 func implSetEnabled(isEnabled bool) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
+	}
 	if isEnabled {
-		err := shell.Exec(nil, platform.FirewallScript(), "-enable")
-		if err != nil {
-			return fmt.Errorf("failed to execute shell command: %w", err)
+		if err := backend.Enable(); err != nil {
+			return fmt.Errorf("failed to enable firewall: %w", err)
 		}
 		// To fulfill such flow (example): Connected -> FWDisable -> FWEnable
 		// Here we should restore all exceptions (all hosts which are allowed)
 		return reApplyExceptions()
 	}
-	return shell.Exec(nil, platform.FirewallScript(), "-disable")
+	return backend.Disable()
 }
 
 func implSetPersistant(persistant bool) error {
-	// nothing todo here
-	return nil
+	// boot-time rules file (implemented only by the nftables backend)
+	if b, ok := activeBackend.(*nftablesBackend); ok {
+		if err := b.SetPersistent(persistant); err != nil {
+			return err
+		}
+	}
+
+	// systemd unit which loads the rules before networking starts
+	if persistant {
+		return installBootTimePersistence()
+	}
+	return removeBootTimePersistence()
 }
 
+// name of the VPN tunnel interface (defined only while VPN client is connected)
+var connectedTunInterfaceName string
+
 // ClientConnected - allow communication for local vpn/client IP address
 func implClientConnected(clientLocalIPAddress net.IP) error {
 	inf, err := netinfo.InterfaceByIPAddr(clientLocalIPAddress)
 	if err != nil {
 		return fmt.Errorf("failed to get local interface by IP: %w", err)
 	}
+	connectedTunInterfaceName = inf.Name
+
+	if b, ok := activeBackend.(*nftablesBackend); ok {
+		return b.ClientConnected(inf.Name, clientLocalIPAddress)
+	}
 
 	scriptArgs := fmt.Sprintf("-connected %s %s", inf.Name, clientLocalIPAddress)
 	return shell.Exec(nil, platform.FirewallScript(), scriptArgs)
@@ -95,35 +118,174 @@ func implClientConnected(clientLocalIPAddress net.IP) error {
 
 // ClientDisconnected - Disable communication for local vpn/client IP address
 func implClientDisconnected() error {
+	connectedTunInterfaceName = ""
+
 	// remove all exceptions related to current connection (all non-persistant exceptions)
 	err := removeAllHostsFromExceptions()
 	if err != nil {
 		log.Error(err)
 	}
 
+	if b, ok := activeBackend.(*nftablesBackend); ok {
+		return b.ClientDisconnected()
+	}
+
 	return shell.Exec(nil, platform.FirewallScript(), "-disconnected")
 }
 
 func implAllowLAN(isAllowLAN bool, isAllowLanMulticast bool) error {
-	localIPs, err := getLanIPs()
+	backend, err := getBackend()
 	if err != nil {
-		return fmt.Errorf("failed to get local IPs: %w", err)
+		return err
+	}
+	return backend.SetAllowLAN(isAllowLAN, isAllowLanMulticast)
+}
+
+// service discovery multicast groups:
+// mDNS\Bonjour - 224.0.0.251 (UDP 5353); SSDP - 239.255.255.250 (UDP 1900)
+// Note: port granularity is not supported by the exceptions mechanism,
+// but these groups carry only the corresponding discovery protocol
+var serviceDiscoveryHosts = []string{"224.0.0.251", "239.255.255.250"}
+var isAllowServiceDiscovery bool
+
+func implAllowServiceDiscovery(allow bool) error {
+	if isAllowServiceDiscovery == allow {
+		return nil
 	}
+	isAllowServiceDiscovery = allow
+
+	if allow {
+		return addHostsToExceptions(serviceDiscoveryHosts, true)
+	}
+	return removeHostsFromExceptions(serviceDiscoveryHosts)
+}
 
-	if isAllowLAN {
-		if isAllowLanMulticast {
-			// allow LAN + multicast
-			return addHostsToExceptions(append(localIPs, multicastIP), true)
+// user-managed list of LAN addresses which are allowed in addition to the 'AllowLAN' functionality
+var lanAllowedAddresses []string
+
+func implSetLanAllowedAddresses(addresses []string) error {
+	// remove previously allowed addresses
+	if len(lanAllowedAddresses) > 0 {
+		if err := removeHostsFromExceptions(lanAllowedAddresses); err != nil {
+			log.Error(err)
 		}
+	}
 
-		// disallow Multicast
-		removeHostsFromExceptions([]string{multicastIP})
-		// allow LAN
-		return addHostsToExceptions(localIPs, true)
+	lanAllowedAddresses = addresses
+	if len(addresses) == 0 {
+		return nil
 	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// user-managed list of 'always allowed' destinations
+var userExceptions []string
 
-	// disallow everything (LAN + multicast)
-	return removeHostsFromExceptions(append(localIPs, multicastIP))
+func implSetUserExceptions(addresses []string) error {
+	// remove previously defined exceptions
+	if len(userExceptions) > 0 {
+		if err := removeHostsFromExceptions(userExceptions); err != nil {
+			log.Error(err)
+		}
+	}
+
+	userExceptions = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// destination networks not captured by a partial tunnel (see SetPartialTunnelNetworks)
+var partialTunnelNetworks []string
+
+func implSetPartialTunnelNetworks(addresses []string) error {
+	// remove previously defined exceptions
+	if len(partialTunnelNetworks) > 0 {
+		if err := removeHostsFromExceptions(partialTunnelNetworks); err != nil {
+			log.Error(err)
+		}
+	}
+
+	partialTunnelNetworks = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// API servers which stay reachable when the firewall is enabled but VPN is not connected
+var apiServersExceptions []string
+
+func implSetApiServersExceptions(addresses []string) error {
+	// remove previously defined exceptions
+	if len(apiServersExceptions) > 0 {
+		if err := removeHostsFromExceptions(apiServersExceptions); err != nil {
+			log.Error(err)
+		}
+	}
+
+	apiServersExceptions = addresses
+	if len(addresses) == 0 {
+		return nil
+	}
+	return addHostsToExceptions(addresses, true)
+}
+
+// true when all IPv6 traffic is blocked (IPv6 leak protection for IPv4-only tunnels)
+var isIPv6Blocked bool
+
+func implBlockIPv6(block bool) error {
+	if isIPv6Blocked == block {
+		return nil
+	}
+	isIPv6Blocked = block
+
+	if b, ok := activeBackend.(*nftablesBackend); ok {
+		return b.BlockIPv6(block)
+	}
+
+	if block {
+		return shell.Exec(nil, platform.FirewallScript(), "-block_ipv6")
+	}
+	return shell.Exec(nil, platform.FirewallScript(), "-unblock_ipv6")
+}
+
+// dnsBlocker - DNS leak protection functionality of a firewall backend
+type dnsBlocker interface {
+	SetDNSBlock(block bool, tunInterfaceName string) error
+	GetBlockedDNSCount() (uint64, error)
+}
+
+// implSetDNSBlock - block outbound DNS requests (plain DNS and DNS-over-TLS ports)
+// to everything except the VPN tunnel interface
+func implSetDNSBlock(block bool) error {
+	if b, ok := activeBackend.(dnsBlocker); ok {
+		return b.SetDNSBlock(block, connectedTunInterfaceName)
+	}
+	return nil
+}
+
+func implGetBlockedDNSCount() (uint64, error) {
+	if b, ok := activeBackend.(dnsBlocker); ok {
+		return b.GetBlockedDNSCount()
+	}
+	return 0, nil
+}
+
+// incomingPortsController - optional backend functionality:
+// open inbound ports on the VPN tunnel interface (port-forwarding)
+type incomingPortsController interface {
+	SetAllowedIncomingPorts(tunInterfaceName string, ports []int) error
+}
+
+// implSetAllowedIncomingPorts - open inbound forwarded ports on the VPN tunnel interface
+// (previously applied port rules are removed first)
+func implSetAllowedIncomingPorts(ports []int) error {
+	if b, ok := activeBackend.(incomingPortsController); ok {
+		return b.SetAllowedIncomingPorts(connectedTunInterfaceName, ports)
+	}
+	return nil
 }
 
 // AddHostsToExceptions - allow comminication with this hosts
@@ -146,26 +308,20 @@ func implSetManualDNS(addr net.IP) error {
 
 //---------------------------------------------------------------------
 
-func applyAddHostsToExceptions(hostsIPs []string) error { //
-	var ipList string
-	ipList = strings.Join(hostsIPs, ",")
-
-	if len(ipList) > 0 {
-		log.Info("-add_exceptions ", ipList)
-		return shell.Exec(nil, platform.FirewallScript(), "-add_exceptions", ipList)
+func applyAddHostsToExceptions(hostsIPs []string) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
 	}
-	return nil
+	return backend.AddException(hostsIPs)
 }
 
 func applyRemoveHostsFromExceptions(hostsIPs []string) error {
-	var ipList string
-	ipList = strings.Join(hostsIPs, ",")
-
-	if len(ipList) > 0 {
-		log.Info("-remove_exceptions ", ipList)
-		return shell.Exec(nil, platform.FirewallScript(), "-remove_exceptions", ipList)
+	backend, err := getBackend()
+	if err != nil {
+		return err
 	}
-	return nil
+	return backend.RemoveException(hostsIPs)
 }
 
 func reApplyExceptions() error {
@@ -38,6 +38,15 @@ var (
 	providerKey = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x01}}
 	sublayerKey = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x02}}
 
+	// separate provider\sublayer for the connection-scoped IPv6 block (IPv6 leak protection):
+	// it works independently from the main kill-switch provider
+	providerKeyIPv6 = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x03}}
+	sublayerKeyIPv6 = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x04}}
+
+	// separate provider\sublayer for the connection-scoped DNS block (DNS leak protection)
+	providerKeyDNS = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x05}}
+	sublayerKeyDNS = syscall.GUID{Data1: 0xfed0afd4, Data2: 0x98d4, Data3: 0x4233, Data4: [8]byte{0xa4, 0xf3, 0x8b, 0x7c, 0x02, 0x44, 0x50, 0x06}}
+
 	v4Layers = []syscall.GUID{winlib.FwpmLayerAleAuthConnectV4, winlib.FwpmLayerAleAuthRecvAcceptV4}
 	v6Layers = []syscall.GUID{winlib.FwpmLayerAleAuthConnectV6, winlib.FwpmLayerAleAuthRecvAcceptV6}
 
@@ -54,6 +63,14 @@ const (
 	providerDName = "IVPN Kill Switch"
 	sublayerDName = "IVPN Kill Switch Sub-Layer"
 	filterDName   = "IVPN Kill Switch filter"
+
+	providerDNameIPv6 = "IVPN Block IPv6"
+	sublayerDNameIPv6 = "IVPN Block IPv6 Sub-Layer"
+	filterDNameIPv6   = "IVPN Block IPv6 filter"
+
+	providerDNameDNS = "IVPN Block DNS"
+	sublayerDNameDNS = "IVPN Block DNS Sub-Layer"
+	filterDNameDNS   = "IVPN Block DNS filter"
 )
 
 // implInitialize doing initialization stuff (called on application start)
@@ -70,18 +87,31 @@ func implInitialize() error {
 	// save initial persistant state into package-variable
 	isPersistant = pInfo.IsPersistent
 
+	// select firewall backend implementation
+	activeBackend = &wfpBackend{}
+	log.Info("Firewall backend: ", activeBackend.Name())
+
 	return nil
 }
 
 func implGetEnabled() (bool, error) {
-	pInfo, err := manager.GetProviderInfo(providerKey)
+	backend, err := getBackend()
+	if err != nil {
+		return false, err
+	}
+	enabled, err := backend.GetEnabled()
 	if err != nil {
 		return false, fmt.Errorf("failed to get provider info: %w", err)
 	}
-	return pInfo.IsInstalled, nil
+	return enabled, nil
 }
 
 func implSetEnabled(isEnabled bool) (retErr error) {
+	backend, err := getBackend()
+	if err != nil {
+		return err
+	}
+
 	// start transaction
 	if err := manager.TransactionStart(); err != nil {
 		return fmt.Errorf("failed to start transaction: %w", err)
@@ -103,9 +133,9 @@ func implSetEnabled(isEnabled bool) (retErr error) {
 	}()
 
 	if isEnabled {
-		return doEnable()
+		return backend.Enable()
 	}
-	return doDisable()
+	return backend.Disable()
 }
 
 func implSetPersistant(persistant bool) (retErr error) {
@@ -175,16 +205,256 @@ func implAddHostsToExceptions(IPs []net.IP) error {
 	return nil
 }
 
-// AllowLAN - allow/forbid LAN communication
-func implAllowLAN(allowLan bool, allowLanMulticast bool) error {
+func implBlockIPv6(block bool) (retErr error) {
+	// start / commit transaction
+	if err := manager.TransactionStart(); err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if retErr == nil {
+			manager.TransactionCommit()
+		} else {
+			// abort transaction if there was an error
+			manager.TransactionAbort()
+		}
+	}()
 
-	if isAllowLAN == allowLan && isAllowLANMulticast == allowLanMulticast {
-		return nil
+	if block {
+		return doBlockIPv6()
+	}
+	return doUnblockIPv6()
+}
+
+func doBlockIPv6() error {
+	pinfo, err := manager.GetProviderInfo(providerKeyIPv6)
+	if err != nil {
+		return fmt.Errorf("failed to get provider info: %w", err)
+	}
+	if pinfo.IsInstalled {
+		return nil // already blocked
+	}
+
+	provider := winlib.CreateProvider(providerKeyIPv6, providerDNameIPv6, "", false)
+	sublayer := winlib.CreateSubLayer(sublayerKeyIPv6, providerKeyIPv6, sublayerDNameIPv6, "", 2301, false)
+
+	if err = manager.AddProvider(provider); err != nil {
+		return fmt.Errorf("failed to add provider : %w", err)
+	}
+	installed, err := manager.IsSubLayerInstalled(sublayerKeyIPv6)
+	if err != nil {
+		return fmt.Errorf("failed to check sublayer is installed: %w", err)
+	}
+	if !installed {
+		if err = manager.AddSubLayer(sublayer); err != nil {
+			return fmt.Errorf("failed to add sublayer: %w", err)
+		}
+	}
+
+	for _, layer := range v6Layers {
+		_, err := manager.AddFilter(winlib.NewFilterBlockAll(providerKeyIPv6, layer, sublayerKeyIPv6, filterDNameIPv6, "", true, false))
+		if err != nil {
+			return fmt.Errorf("failed to add filter 'block all IPv6': %w", err)
+		}
+	}
+	return nil
+}
+
+func doUnblockIPv6() error {
+	pinfo, err := manager.GetProviderInfo(providerKeyIPv6)
+	if err != nil {
+		return fmt.Errorf("failed to get provider info: %w", err)
+	}
+	if !pinfo.IsInstalled {
+		return nil // not blocked
+	}
+
+	for _, l := range v6Layers {
+		if err := manager.DeleteFilterByProviderKey(providerKeyIPv6, l); err != nil {
+			return fmt.Errorf("failed to delete filter : %w", err)
+		}
+	}
+
+	installed, err := manager.IsSubLayerInstalled(sublayerKeyIPv6)
+	if err != nil {
+		return fmt.Errorf("failed to check is sublayer installed : %w", err)
+	}
+	if installed {
+		if err := manager.DeleteSubLayer(sublayerKeyIPv6); err != nil {
+			return fmt.Errorf("failed to delete sublayer : %w", err)
+		}
+	}
+
+	if err := manager.DeleteProvider(providerKeyIPv6); err != nil {
+		return fmt.Errorf("failed to delete provider : %w", err)
+	}
+	return nil
+}
+
+// implSetDNSBlock - block outbound DNS requests to everything except the custom DNS
+// (DNS leak protection; works independently from the main kill-switch provider)
+func implSetDNSBlock(block bool) (retErr error) {
+	// start / commit transaction
+	if err := manager.TransactionStart(); err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() {
+		if retErr == nil {
+			manager.TransactionCommit()
+		} else {
+			// abort transaction if there was an error
+			manager.TransactionAbort()
+		}
+	}()
+
+	if block {
+		return doBlockDNS()
+	}
+	return doUnblockDNS()
+}
+
+func implGetBlockedDNSCount() (uint64, error) {
+	// per-filter packet statistics are not exposed by WFP
+	return 0, nil
+}
+
+// implSetAllowedIncomingPorts - open inbound forwarded ports on the VPN tunnel interface
+// Nothing to do here: the WFP rules already permit traffic
+// on the tunnel interface while the VPN client is connected
+func implSetAllowedIncomingPorts(ports []int) error {
+	return nil
+}
+
+func doBlockDNS() error {
+	pinfo, err := manager.GetProviderInfo(providerKeyDNS)
+	if err != nil {
+		return fmt.Errorf("failed to get provider info: %w", err)
+	}
+	if pinfo.IsInstalled {
+		return nil // already blocked
+	}
+
+	provider := winlib.CreateProvider(providerKeyDNS, providerDNameDNS, "", false)
+	sublayer := winlib.CreateSubLayer(sublayerKeyDNS, providerKeyDNS, sublayerDNameDNS, "", 2302, false)
+
+	if err = manager.AddProvider(provider); err != nil {
+		return fmt.Errorf("failed to add provider : %w", err)
+	}
+	installed, err := manager.IsSubLayerInstalled(sublayerKeyDNS)
+	if err != nil {
+		return fmt.Errorf("failed to check sublayer is installed: %w", err)
+	}
+	if !installed {
+		if err = manager.AddSubLayer(sublayer); err != nil {
+			return fmt.Errorf("failed to add sublayer: %w", err)
+		}
+	}
+
+	for _, layer := range v4Layers {
+		_, err := manager.AddFilter(winlib.NewFilterBlockDNS(providerKeyDNS, layer, sublayerKeyDNS, filterDNameDNS, "", customDNS, false))
+		if err != nil {
+			return fmt.Errorf("failed to add filter 'block dns': %w", err)
+		}
+	}
+	return nil
+}
+
+func doUnblockDNS() error {
+	pinfo, err := manager.GetProviderInfo(providerKeyDNS)
+	if err != nil {
+		return fmt.Errorf("failed to get provider info: %w", err)
+	}
+	if !pinfo.IsInstalled {
+		return nil // not blocked
 	}
 
-	isAllowLAN = allowLan
-	isAllowLANMulticast = allowLanMulticast
+	for _, l := range v4Layers {
+		if err := manager.DeleteFilterByProviderKey(providerKeyDNS, l); err != nil {
+			return fmt.Errorf("failed to delete filter : %w", err)
+		}
+	}
 
+	installed, err := manager.IsSubLayerInstalled(sublayerKeyDNS)
+	if err != nil {
+		return fmt.Errorf("failed to check is sublayer installed : %w", err)
+	}
+	if installed {
+		if err := manager.DeleteSubLayer(sublayerKeyDNS); err != nil {
+			return fmt.Errorf("failed to delete sublayer : %w", err)
+		}
+	}
+
+	if err := manager.DeleteProvider(providerKeyDNS); err != nil {
+		return fmt.Errorf("failed to delete provider : %w", err)
+	}
+	return nil
+}
+
+// user-managed list of LAN addresses which are allowed in addition to the 'AllowLAN' functionality
+var lanAllowedAddresses []net.IPNet
+
+// user-managed list of 'always allowed' destinations
+var userExceptions []net.IPNet
+
+// destination networks not captured by a partial tunnel (see SetPartialTunnelNetworks)
+var partialTunnelNetworks []net.IPNet
+
+// API servers which stay reachable when the firewall is enabled but VPN is not connected
+var apiServersExceptions []net.IPNet
+
+func implSetLanAllowedAddresses(addresses []string) error {
+	nets, err := parseAddressesList(addresses)
+	if err != nil {
+		return err
+	}
+	lanAllowedAddresses = nets
+	return reEnableIfActive()
+}
+
+func implSetUserExceptions(addresses []string) error {
+	nets, err := parseAddressesList(addresses)
+	if err != nil {
+		return err
+	}
+	userExceptions = nets
+	return reEnableIfActive()
+}
+
+func implSetPartialTunnelNetworks(addresses []string) error {
+	nets, err := parseAddressesList(addresses)
+	if err != nil {
+		return err
+	}
+	partialTunnelNetworks = nets
+	return reEnableIfActive()
+}
+
+func implSetApiServersExceptions(addresses []string) error {
+	nets, err := parseAddressesList(addresses)
+	if err != nil {
+		return err
+	}
+	apiServersExceptions = nets
+	return reEnableIfActive()
+}
+
+// parseAddressesList - parse list of single IPs or subnets in CIDR notation
+func parseAddressesList(addresses []string) ([]net.IPNet, error) {
+	nets := make([]net.IPNet, 0, len(addresses))
+	for _, addr := range addresses {
+		if ip := net.ParseIP(addr); ip != nil {
+			nets = append(nets, net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse address '%s': %w", addr, err)
+		}
+		nets = append(nets, *ipNet)
+	}
+	return nets, nil
+}
+
+func reEnableIfActive() error {
 	enabled, err := implGetEnabled()
 	if err != nil {
 		return fmt.Errorf("failed to get info if firewall is on: %w", err)
@@ -196,6 +466,26 @@ func implAllowLAN(allowLan bool, allowLanMulticast bool) error {
 	return reEnable()
 }
 
+// AllowLAN - allow/forbid LAN communication
+func implAllowLAN(allowLan bool, allowLanMulticast bool) error {
+	backend, err := getBackend()
+	if err != nil {
+		return err
+	}
+	return backend.SetAllowLAN(allowLan, allowLanMulticast)
+}
+
+// true when local service discovery multicast (mDNS\SSDP) is allowed
+var isAllowServiceDiscovery bool
+
+func implAllowServiceDiscovery(allow bool) error {
+	if isAllowServiceDiscovery == allow {
+		return nil
+	}
+	isAllowServiceDiscovery = allow
+	return reEnableIfActive()
+}
+
 // SetManualDNS - configure firewall to allow DNS which is out of VPN tunnel
 // Applicable to Windows implementation (to allow custom DNS from local network)
 func implSetManualDNS(addr net.IP) error {
@@ -293,6 +583,16 @@ func doEnable() (retErr error) {
 			return fmt.Errorf("failed to add filter 'block all IPv6': %w", err)
 		}
 
+		if isPersistant {
+			// boot-time filter: enforced from early boot (before BFE started),
+			// so there is no leak window between OS boot and daemon start
+			bootTimeFilter := winlib.NewFilterBlockAll(providerKey, layer, sublayerKey, filterDName, "", true, false)
+			bootTimeFilter.Flags |= winlib.FwpmFilterFlagBoottime
+			if _, err := manager.AddFilter(bootTimeFilter); err != nil {
+				return fmt.Errorf("failed to add boot-time filter 'block all IPv6': %w", err)
+			}
+		}
+
 		if isAllowLAN {
 			for _, ip := range addressesV6 {
 				prefixLen, _ := ip.Mask.Size()
@@ -312,6 +612,15 @@ func doEnable() (retErr error) {
 			return fmt.Errorf("failed to add filter 'block all': %w", err)
 		}
 
+		if isPersistant {
+			// boot-time filter: enforced from early boot (before BFE started)
+			bootTimeFilter := winlib.NewFilterBlockAll(providerKey, layer, sublayerKey, filterDName, "", false, false)
+			bootTimeFilter.Flags |= winlib.FwpmFilterFlagBoottime
+			if _, err := manager.AddFilter(bootTimeFilter); err != nil {
+				return fmt.Errorf("failed to add boot-time filter 'block all': %w", err)
+			}
+		}
+
 		// block DNS
 		_, err = manager.AddFilter(winlib.NewFilterBlockDNS(providerKey, layer, sublayerKey, sublayerDName, "", customDNS, isPersistant))
 		if err != nil {
@@ -372,6 +681,58 @@ func doEnable() (retErr error) {
 			}
 		}
 
+		if isAllowServiceDiscovery {
+			// service discovery passthrough - narrow per-protocol rules,
+			// independent from the blanket multicast permission:
+			// mDNS\Bonjour - 224.0.0.251 UDP 5353; SSDP - 239.255.255.250 UDP 1900
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIPPort(providerKey, layer, sublayerKey, filterDName, "",
+				net.IPv4(224, 0, 0, 251), net.IPv4(255, 255, 255, 255), 5353, isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow mDNS': %w", err)
+			}
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIPPort(providerKey, layer, sublayerKey, filterDName, "",
+				net.IPv4(239, 255, 255, 250), net.IPv4(255, 255, 255, 255), 1900, isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow SSDP': %w", err)
+			}
+		}
+
+		// user-managed LAN access control list
+		for _, ipNet := range lanAllowedAddresses {
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIP(providerKey, layer, sublayerKey, filterDName, "",
+				ipNet.IP, net.IP(ipNet.Mask), isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow LAN address': %w", err)
+			}
+		}
+
+		// user-managed 'always allowed' destinations
+		for _, ipNet := range userExceptions {
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIP(providerKey, layer, sublayerKey, filterDName, "",
+				ipNet.IP, net.IP(ipNet.Mask), isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow user exception': %w", err)
+			}
+		}
+
+		// destinations outside of a partial tunnel (see SetPartialTunnelNetworks)
+		for _, ipNet := range partialTunnelNetworks {
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIP(providerKey, layer, sublayerKey, filterDName, "",
+				ipNet.IP, net.IP(ipNet.Mask), isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow partial-tunnel destination': %w", err)
+			}
+		}
+
+		// IVPN API servers (applied when the API access policy allows it)
+		for _, ipNet := range apiServersExceptions {
+			_, err = manager.AddFilter(winlib.NewFilterAllowRemoteIP(providerKey, layer, sublayerKey, filterDName, "",
+				ipNet.IP, net.IP(ipNet.Mask), isPersistant))
+			if err != nil {
+				return fmt.Errorf("failed to add filter 'allow API server': %w", err)
+			}
+		}
+
 		/*
 			for ipStrKey := range allowedHosts {
 				ip := net.ParseIP(ipStrKey)
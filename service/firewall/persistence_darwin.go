@@ -0,0 +1,85 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// Boot-time firewall persistence (IsFwPersistant mode):
+// a LaunchDaemon loads the PF kill-switch rules at boot (RunAtLoad),
+// so there is no leak window between OS boot and daemon start
+
+const launchdPlistLabel = "net.ivpn.firewall"
+const launchdPlistPath = "/Library/LaunchDaemons/" + launchdPlistLabel + ".plist"
+
+// installBootTimePersistence - install the LaunchDaemon which loads
+// the PF kill-switch rules at boot
+func installBootTimePersistence() error {
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdPlistLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>` + platform.FirewallScript() + `</string>
+		<string>-enable</string>
+	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<false/>
+</dict>
+</plist>
+`
+	if err := ioutil.WriteFile(launchdPlistPath, []byte(plist), 0644); err != nil {
+		return fmt.Errorf("failed to save LaunchDaemon plist: %w", err)
+	}
+
+	if err := shell.Exec(nil, "launchctl", "load", "-w", launchdPlistPath); err != nil {
+		log.Warning("Failed to load LaunchDaemon: ", err)
+	}
+	return nil
+}
+
+// removeBootTimePersistence - unload and remove the boot-time LaunchDaemon
+func removeBootTimePersistence() error {
+	if _, err := os.Stat(launchdPlistPath); os.IsNotExist(err) {
+		return nil // not installed
+	}
+
+	if err := shell.Exec(nil, "launchctl", "unload", "-w", launchdPlistPath); err != nil {
+		log.Warning("Failed to unload LaunchDaemon: ", err)
+	}
+	if err := os.Remove(launchdPlistPath); err != nil {
+		return fmt.Errorf("failed to remove LaunchDaemon plist: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// Boot-time firewall persistence (IsFwPersistant mode):
+// a systemd unit installs the blocking rules before networking starts,
+// so there is no leak window between OS boot and daemon start
+
+const systemdUnitName = "ivpn-firewall.service"
+const systemdUnitPath = "/etc/systemd/system/" + systemdUnitName
+
+// installBootTimePersistence - install and enable the systemd unit which loads
+// the kill-switch rules before networking starts
+func installBootTimePersistence() error {
+	// command which re-applies the rules at boot
+	bootCmd := platform.FirewallScript() + " -enable"
+	if _, ok := activeBackend.(*nftablesBackend); ok {
+		nftPath, err := exec.LookPath(nftBinary)
+		if err != nil {
+			return fmt.Errorf("failed to locate nft binary: %w", err)
+		}
+		bootCmd = nftPath + " -f " + nftRulesFile
+	}
+
+	unit := `[Unit]
+Description=IVPN boot-time firewall (kill-switch persistence)
+DefaultDependencies=no
+Before=network-pre.target
+Wants=network-pre.target
+
+[Service]
+Type=oneshot
+ExecStart=` + bootCmd + `
+RemainAfterExit=yes
+
+[Install]
+WantedBy=multi-user.target
+`
+	if err := ioutil.WriteFile(systemdUnitPath, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to save systemd unit: %w", err)
+	}
+
+	if err := shell.Exec(nil, "systemctl", "daemon-reload"); err != nil {
+		log.Warning("Failed to reload systemd configuration: ", err)
+	}
+	if err := shell.Exec(nil, "systemctl", "enable", systemdUnitName); err != nil {
+		return fmt.Errorf("failed to enable systemd unit: %w", err)
+	}
+	return nil
+}
+
+// removeBootTimePersistence - disable and remove the boot-time systemd unit
+func removeBootTimePersistence() error {
+	if _, err := os.Stat(systemdUnitPath); os.IsNotExist(err) {
+		return nil // not installed
+	}
+
+	if err := shell.Exec(nil, "systemctl", "disable", systemdUnitName); err != nil {
+		log.Warning("Failed to disable systemd unit: ", err)
+	}
+	if err := os.Remove(systemdUnitPath); err != nil {
+		return fmt.Errorf("failed to remove systemd unit: %w", err)
+	}
+	if err := shell.Exec(nil, "systemctl", "daemon-reload"); err != nil {
+		log.Warning("Failed to reload systemd configuration: ", err)
+	}
+	return nil
+}
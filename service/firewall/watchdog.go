@@ -0,0 +1,95 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package firewall
+
+import (
+	"time"
+)
+
+// interval of verifying that the enabled firewall configuration is still in place
+const watchdogCheckInterval = time.Second * 30
+
+// expected state of the firewall (as it was requested last time)
+// External tools (e.g. ufw, firewalld, GPO) can silently flush our rules;
+// the watchdog detects such situation and re-applies the configuration
+var expectedEnabledState bool
+
+// StartWatchdog - start goroutine which periodically verifies that the firewall rules
+// are still present (when the firewall is expected to be enabled) and re-applies them if not.
+// 'onReapplied' is called after each successful re-apply (e.g. to notify clients)
+func StartWatchdog(onReapplied func()) {
+	go func() {
+		log.Info("Firewall watchdog started")
+		for {
+			time.Sleep(watchdogCheckInterval)
+			if isReapplied := watchdogCheck(); isReapplied && onReapplied != nil {
+				onReapplied()
+			}
+		}
+	}()
+}
+
+// RecheckAndReapply - immediately verify that the firewall configuration is still in place
+// and re-apply it if not (e.g. after the system resumed from sleep).
+// Returns true if the configuration was re-applied
+func RecheckAndReapply() bool {
+	return watchdogCheck()
+}
+
+// watchdogCheck - single check iteration
+// Returns true if the firewall configuration was re-applied
+func watchdogCheck() bool {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if expectedEnabledState == false {
+		return false
+	}
+
+	isEnabled, err := implGetEnabled()
+	if err != nil {
+		log.Error("Watchdog failed to get firewall state: ", err)
+		return false
+	}
+	if isEnabled {
+		return false
+	}
+
+	log.Warning("Firewall rules are not present anymore (flushed by another application?). Re-applying...")
+
+	if err := implSetEnabled(true); err != nil {
+		log.Error("Watchdog failed to re-enable firewall: ", err)
+		return false
+	}
+
+	// restore exception for the connected VPN client interface (if any)
+	clientAddr := connectedClientInterfaceIP
+	if clientAddr != nil && isClientPaused == false {
+		if err := implClientConnected(clientAddr); err != nil {
+			log.Error("Watchdog failed to restore client connection rule: ", err)
+		}
+	}
+
+	log.Info("Firewall configuration re-applied")
+	return true
+}
@@ -110,6 +110,33 @@ func NewFilterAllowRemoteIP(
 	return f
 }
 
+// NewFilterAllowRemoteIPPort creates a filter to allow remote IP + remote port combination
+// (narrower than NewFilterAllowRemoteIP: only the defined destination port is allowed)
+func NewFilterAllowRemoteIPPort(
+	keyProvider syscall.GUID,
+	keyLayer syscall.GUID,
+	keySublayer syscall.GUID,
+	dispName string,
+	dispDescription string,
+	ip net.IP,
+	mask net.IP,
+	port uint16,
+	isPersistent bool) Filter {
+
+	f := NewFilter(keyProvider, keyLayer, keySublayer, dispName, dispDescription)
+	f.Weight = weightAllowRemoteIP
+	f.Action = FwpActionPermit
+
+	f.Flags = FwpmFilterFlagClearActionRight
+	if isPersistent {
+		f.Flags = f.Flags | FwpmFilterFlagPersistent
+	}
+
+	f.AddCondition(&ConditionIPRemoteAddressV4{Match: FwpMatchEqual, IP: ip, Mask: mask})
+	f.AddCondition(&ConditionIPRemotePort{Match: FwpMatchEqual, Port: port})
+	return f
+}
+
 // NewFilterAllowRemoteIPV6 creates a filter to allow remote IP v6
 func NewFilterAllowRemoteIPV6(
 	keyProvider syscall.GUID,
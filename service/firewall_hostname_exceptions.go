@@ -0,0 +1,158 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/service/firewall"
+)
+
+// Hostname-based kill-switch exceptions: an exception can be defined as a hostname;
+// the daemon resolves it, installs IP rules and re-resolves periodically
+// to keep the rules current (services behind CDNs rotate their IP addresses).
+// Note: the standard resolver does not expose record TTL - a fixed re-resolution
+// interval is in use instead.
+const _hostnameExceptionsResolveInterval = time.Minute * 5
+
+// isValidExceptionHostname - syntactic validation of a hostname exception entry
+// (anything parseable as an IP\CIDR is handled before this check; see parseUserException)
+func isValidExceptionHostname(hostname string) bool {
+	if len(hostname) == 0 || len(hostname) > 253 {
+		return false
+	}
+
+	isAlphaFound := false
+	for _, label := range strings.Split(hostname, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, c := range label {
+			switch {
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+				isAlphaFound = true
+			case c >= '0' && c <= '9', c == '-':
+			default:
+				return false
+			}
+		}
+	}
+
+	// a pure-numeric name is not a hostname (and not a valid IP - already rejected)
+	return isAlphaFound
+}
+
+// resolveUserExceptions - expand the kill-switch exceptions list into firewall host entries:
+// IP\subnet entries are passed through; hostname entries are resolved (IPv4 only)
+// A hostname which cannot be resolved at the moment is skipped with a warning
+// (the periodic re-resolution will pick it up later)
+func resolveUserExceptions(exceptions []string) (hosts []string, isHostnamesDefined bool) {
+	hosts = make([]string, 0, len(exceptions))
+	for _, entry := range exceptions {
+		host, isHostname, err := parseUserException(entry)
+		if err != nil {
+			// not expected here: entries are validated when the list is defined
+			log.Warning("Skipping bad kill-switch exception entry: ", err)
+			continue
+		}
+
+		if isHostname == false {
+			hosts = append(hosts, host)
+			continue
+		}
+
+		isHostnamesDefined = true
+		ips, err := net.LookupIP(host)
+		if err != nil {
+			log.Warning("Unable to resolve kill-switch exception '" + host + "' (will be retried): " + err.Error())
+			continue
+		}
+		for _, ip := range ips {
+			if ip4 := ip.To4(); ip4 != nil {
+				hosts = append(hosts, ip4.String())
+			}
+		}
+	}
+	return hosts, isHostnamesDefined
+}
+
+// applyUserExceptionsToFirewall - resolve the exceptions list and inject it into the firewall.
+// When hostname entries are present - the background re-resolver is started (if not started yet)
+func (s *Service) applyUserExceptionsToFirewall(exceptions []string) error {
+	hosts, isHostnamesDefined := resolveUserExceptions(exceptions)
+
+	if err := firewall.SetUserExceptions(hosts); err != nil {
+		return err
+	}
+
+	s._hostnameExceptionsMutex.Lock()
+	defer s._hostnameExceptionsMutex.Unlock()
+
+	s._appliedUserExceptionsSnapshot = strings.Join(hosts, ",")
+	if isHostnamesDefined && s._isHostnameExceptionsResolverStarted == false {
+		s._isHostnameExceptionsResolverStarted = true
+		go s.hostnameExceptionsResolver()
+	}
+	return nil
+}
+
+// hostnameExceptionsResolver - background re-resolution of hostname exceptions:
+// the firewall rules are re-applied when the resolved addresses change.
+// The goroutine stops itself when hostname entries are not defined anymore
+func (s *Service) hostnameExceptionsResolver() {
+	log.Info("Hostname exceptions re-resolver started")
+	defer log.Info("Hostname exceptions re-resolver stopped")
+
+	for {
+		time.Sleep(_hostnameExceptionsResolveInterval)
+
+		hosts, isHostnamesDefined := resolveUserExceptions(s.Preferences().FwUserExceptions)
+
+		if isHostnamesDefined == false {
+			s._hostnameExceptionsMutex.Lock()
+			s._isHostnameExceptionsResolverStarted = false
+			s._hostnameExceptionsMutex.Unlock()
+			return
+		}
+
+		snapshot := strings.Join(hosts, ",")
+		s._hostnameExceptionsMutex.Lock()
+		isChanged := snapshot != s._appliedUserExceptionsSnapshot
+		if isChanged {
+			s._appliedUserExceptionsSnapshot = snapshot
+		}
+		s._hostnameExceptionsMutex.Unlock()
+
+		if isChanged {
+			log.Info("Resolved addresses of hostname exceptions changed. Re-applying firewall rules...")
+			if err := firewall.SetUserExceptions(hosts); err != nil {
+				log.Error("Failed to re-apply kill-switch exceptions: ", err)
+			}
+		}
+	}
+}
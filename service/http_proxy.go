@@ -0,0 +1,47 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"github.com/ivpn/desktop-app-daemon/service/httpproxy"
+)
+
+// Local HTTP\HTTPS proxy with PAC file generation: browsers pointed at the PAC file
+// ('http://127.0.0.1:<port>/proxy.pac') get VPN-or-direct routing per domain - even
+// on platforms where split tunneling is not implemented. The proxied traffic is
+// bound to the tunnel address, therefore it always goes through the VPN.
+// See the 'http_proxy', 'http_proxy_port' and 'http_proxy_domains' preferences.
+
+// applyHTTPProxyPreference - start\stop the local HTTP proxy according to preferences
+func (s *Service) applyHTTPProxyPreference() error {
+	prefs := s.Preferences()
+
+	if prefs.IsHTTPProxy == false {
+		return httpproxy.Stop()
+	}
+
+	return httpproxy.Start(httpproxy.Config{
+		Port:         prefs.HTTPProxyPort,
+		SourceIPFunc: s.tunnelSourceIP,
+		PacDomains:   prefs.HTTPProxyPacDomains})
+}
@@ -0,0 +1,253 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package httpproxy implements a local HTTP\HTTPS proxy bound to the loopback
+// interface: outgoing connections are bound to the tunnel source address, therefore
+// the proxied traffic always goes through the VPN. The proxy also serves a PAC file
+// ('http://127.0.0.1:<port>/proxy.pac') which describes VPN-or-direct routing per
+// domain - useful for browsers on platforms where split tunneling is not implemented.
+package httpproxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("httpp")
+}
+
+const (
+	// DefaultPort - listen port used when the configuration does not define one
+	DefaultPort = 8118
+
+	dialTimeout    = time.Second * 10
+	requestTimeout = time.Second * 15
+)
+
+// Config - local HTTP proxy configuration
+type Config struct {
+	// Port - listen port on the loopback interface (0 - DefaultPort)
+	Port int
+	// SourceIPFunc - source address for the outgoing connections
+	// (binds the proxied traffic to the tunnel);
+	// called for every proxied connection; an error fails the connection
+	SourceIPFunc func() (net.IP, error)
+	// PacDomains - domains which have to be routed through the proxy (and therefore
+	// through the VPN) according to the generated PAC file; all other domains are
+	// declared as 'DIRECT' (empty - all domains are routed through the proxy)
+	PacDomains []string
+}
+
+var (
+	mutex     sync.Mutex
+	isRunning bool
+	listener  net.Listener
+)
+
+// Start - start local HTTP proxy with the defined configuration
+// (when the proxy is already running - it is restarted)
+func Start(cfg Config) error {
+	if cfg.SourceIPFunc == nil {
+		return fmt.Errorf("source address function not defined")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+
+	if err := Stop(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	l, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to start HTTP proxy: %w", err)
+	}
+
+	isRunning = true
+	listener = l
+
+	go serve(l, cfg)
+
+	log.Info(fmt.Sprintf("Started (127.0.0.1:%d; PAC: http://127.0.0.1:%d/proxy.pac)", cfg.Port, cfg.Port))
+	return nil
+}
+
+// Stop - stop local HTTP proxy (does nothing when the proxy is not running)
+func Stop() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isRunning == false {
+		return nil
+	}
+	isRunning = false
+
+	if listener != nil {
+		listener.Close()
+		listener = nil
+	}
+
+	log.Info("Stopped")
+	return nil
+}
+
+// GeneratePAC - PAC file content describing VPN-or-direct routing per domain
+func GeneratePAC(port int, pacDomains []string) string {
+	proxy := fmt.Sprintf("PROXY 127.0.0.1:%d", port)
+
+	if len(pacDomains) == 0 {
+		return fmt.Sprintf("function FindProxyForURL(url, host) {\n\treturn \"%s\";\n}\n", proxy)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("function FindProxyForURL(url, host) {\n")
+	for _, domain := range pacDomains {
+		domain = strings.TrimSpace(domain)
+		if len(domain) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\tif (dnsDomainIs(host, \"%s\") || host == \"%s\") return \"%s\";\n", "."+strings.TrimPrefix(domain, "."), strings.TrimPrefix(domain, "."), proxy))
+	}
+	sb.WriteString("\treturn \"DIRECT\";\n}\n")
+	return sb.String()
+}
+
+func serve(l net.Listener, cfg Config) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return // listener closed (proxy stopped)
+		}
+
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("PANIC: ", r)
+				}
+				conn.Close()
+			}()
+
+			if err := processConnection(conn, cfg); err != nil {
+				log.Warning("Connection error: ", err)
+			}
+		}()
+	}
+}
+
+// processConnection - process one client connection:
+// 'CONNECT' requests are tunneled, absolute-URI requests are forwarded,
+// 'GET /proxy.pac' returns the generated PAC file
+func processConnection(conn net.Conn, cfg Config) error {
+	conn.SetDeadline(time.Now().Add(requestTimeout))
+
+	reader := bufio.NewReader(conn)
+	request, err := http.ReadRequest(reader)
+	if err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+
+	// PAC file request (direct request to the proxy itself)
+	if request.Method != http.MethodConnect && request.URL.IsAbs() == false {
+		if request.URL.Path == "/proxy.pac" {
+			pac := GeneratePAC(cfg.Port, cfg.PacDomains)
+			response := "HTTP/1.1 200 OK\r\nContent-Type: application/x-ns-proxy-autoconfig\r\nContent-Length: " +
+				strconv.Itoa(len(pac)) + "\r\nConnection: close\r\n\r\n" + pac
+			_, err := conn.Write([]byte(response))
+			return err
+		}
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nConnection: close\r\n\r\n"))
+		return nil
+	}
+
+	// destination of the proxied connection
+	destHost := request.Host
+	if strings.Contains(destHost, ":") == false {
+		if request.Method == http.MethodConnect {
+			destHost += ":443"
+		} else {
+			destHost += ":80"
+		}
+	}
+
+	// source address binds the proxied traffic to the tunnel
+	sourceIP, err := cfg.SourceIPFunc()
+	if err != nil {
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nConnection: close\r\n\r\n"))
+		return fmt.Errorf("no source address for proxied connection: %w", err)
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	if sourceIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+
+	destConn, err := dialer.Dial("tcp", destHost)
+	if err != nil {
+		conn.Write([]byte("HTTP/1.1 502 Bad Gateway\r\nConnection: close\r\n\r\n"))
+		return fmt.Errorf("failed to connect to '%s': %w", destHost, err)
+	}
+	defer destConn.Close()
+
+	if request.Method == http.MethodConnect {
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+			return err
+		}
+	} else {
+		// forward the already parsed request to the destination
+		if err := request.Write(destConn); err != nil {
+			return fmt.Errorf("failed to forward request: %w", err)
+		}
+	}
+
+	// forwarding data in both directions
+	conn.SetDeadline(time.Time{})
+	done := make(chan struct{}, 2)
+	go func() {
+		// the reader can keep buffered bytes received after the initial request
+		io.Copy(destConn, reader)
+		destConn.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, destConn)
+		conn.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	return nil
+}
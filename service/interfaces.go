@@ -27,13 +27,57 @@ import (
 	"time"
 
 	"github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/service/portforward"
 	"github.com/ivpn/desktop-app-daemon/service/preferences"
 	"github.com/ivpn/desktop-app-daemon/service/wgkeys"
+	"github.com/ivpn/desktop-app-daemon/sysevents"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
 )
 
+// IAPIClient - client of the IVPN API (the subset of requests which the service uses).
+// The production implementation is 'api.API'; tests substitute it with a mock
+// (see 'service/mocks' package)
+type IAPIClient interface {
+	SetReachabilityNotifier(fn func(isReachable bool))
+	GeoLookup() (*types.GeoLookupResponse, error)
+	SessionNew(accountID string, wgPublicKey string, forceLogin bool, captchaID string, captcha string, confirmation2FA string, deviceName string) (
+		*types.SessionNewResponse,
+		*types.SessionNewErrorLimitResponse,
+		*types.SessionNewErrorCaptchaResponse,
+		*types.APIErrorResponse,
+		error)
+	SessionStatus(session string) (
+		*types.ServiceStatusAPIResp,
+		*types.APIErrorResponse,
+		error)
+	SessionDelete(session string) error
+	DeviceList(session string) ([]types.DeviceInfo, error)
+	DeviceDelete(session string, deviceID string) error
+	DownloadUpdateInfo() (*types.UpdateInfoResponse, error)
+	DownloadUpdateArtifact(urlPath string, signatureBase64 string) ([]byte, error)
+}
+
+// IVpnLauncher - factory of VPN process objects.
+// The production implementation launches the real OpenVPN\WireGuard binaries;
+// tests substitute it with a mock (see 'service/mocks' package)
+type IVpnLauncher interface {
+	// GetDisabledFunctions returns info about functions which are disabled
+	// (e.g. the corresponding binary is not installed)
+	GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error)
+	// NewOpenVPN - create OpenVPN process object
+	// ('isObfs4' - the obfs4 obfuscation have to be used; 'obfs4Cert' - bridge certificate of the destination server)
+	NewOpenVPN(connectionParams openvpn.ConnectionParams, isObfsproxy bool, isObfs4 bool, obfs4Cert string, extraParameters string) (vpn.Process, error)
+	// NewWireGuard - create WireGuard process object
+	NewWireGuard(connectionParams wireguard.ConnectionParams) (vpn.Process, error)
+}
+
 // IServersUpdater - interface for updating server info mechanism
 type IServersUpdater interface {
 	GetServers() (*types.ServersInfoResponse, error)
+	// IsSignatureVerified - true when the current servers list came from a signature-verified download
+	IsSignatureVerified() bool
 	// UpdateNotifierChannel returns channel which is notifying when servers was updated
 	UpdateNotifierChannel() chan struct{}
 }
@@ -45,6 +89,12 @@ type INetChangeDetector interface {
 	DelayBeforeNotify() time.Duration
 }
 
+// ISystemEventsMonitor - object is monitoring system events (sleep\wake, network configuration changes)
+type ISystemEventsMonitor interface {
+	Start(notifyChan chan<- sysevents.Event) error
+	Stop()
+}
+
 // IWgKeysManager - WireGuard keys manager
 type IWgKeysManager interface {
 	Init(receiver wgkeys.IWgKeysChangeReceiver) error
@@ -54,10 +104,31 @@ type IWgKeysManager interface {
 	UpdateKeysIfNecessary() error
 }
 
+// IPortForwardManager - port-forwarding manager
+type IPortForwardManager interface {
+	Init(receiver portforward.IPortForwardReceiver) error
+	Add() (int, error)
+	Remove(port int) error
+	List() []int
+}
+
 // IServiceEventsReceiver is the receiver for service events (normally, it is protocol object)
 type IServiceEventsReceiver interface {
 	OnServiceSessionChanged()
+	OnSessionExpired(reason string)
 	OnAccountStatus(sessionToken string, account preferences.AccountStatus)
+	OnAccountSubscriptionStatus(account preferences.AccountStatus, isGracePeriod bool, revokedCapabilities []string)
 	OnDNSChanged(dns net.IP)
 	OnKillSwitchStateChanged()
+	OnKillSwitchReapplied()
+	OnConnectionProgress(stage string, description string)
+	OnConnectionStats(bytesReceived uint64, bytesSent uint64, lastHandshake int64, endpoint string)
+	OnNetworkTrustChanged(ssid string, isTrusted bool)
+	OnAPIReachabilityChanged(isReachable bool)
+	OnRecoveredFromCrash(repairedItems []string)
+	OnDataCapReached(period string, limitMB int, transferredBytes uint64, isConnectionPaused bool)
+	OnOtherVpnDetected(vpnInterfaces []string, policy string)
+	OnWGLocalIPConflict(wgLocalIP string, conflictInterface string, conflictSubnet string, isNewIPRequested bool)
+	OnUpdateAvailable(updateInfo types.UpdateInfoResponse, stagedArtifactPath string)
+	OnSplitTunnelStatusChanged(isEnabled bool, isInverse bool, apps []string, runningApps []string)
 }
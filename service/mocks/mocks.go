@@ -0,0 +1,337 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package mocks - in-repo mock implementations of the service dependencies
+// (API client, VPN process factory, servers updater ...).
+// In use by the integration test harness which drives the full protocol
+// without network access or privileged rights
+// (see 'protocol/integration_test.go')
+package mocks
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	apitypes "github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/service/portforward"
+	"github.com/ivpn/desktop-app-daemon/service/wgkeys"
+	"github.com/ivpn/desktop-app-daemon/sysevents"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
+)
+
+// APIClient - mock of the IVPN API client (service.IAPIClient):
+// 'SessionNew' always succeeds and returns the pre-configured session info
+type APIClient struct {
+	// SessionToken - session token returned on successful login
+	SessionToken string
+	// VpnUsername\VpnPassword - OpenVPN credentials returned on successful login
+	VpnUsername string
+	VpnPassword string
+	// WgLocalIP - WireGuard client address returned on successful login
+	WgLocalIP string
+	// GeoLocation - response of 'GeoLookup'
+	GeoLocation apitypes.GeoLookupResponse
+}
+
+// SetReachabilityNotifier - does nothing (the mock is always reachable)
+func (a *APIClient) SetReachabilityNotifier(fn func(isReachable bool)) {}
+
+// GeoLookup returns the pre-configured location info
+func (a *APIClient) GeoLookup() (*apitypes.GeoLookupResponse, error) {
+	location := a.GeoLocation
+	return &location, nil
+}
+
+// SessionNew always succeeds and returns the pre-configured session info
+func (a *APIClient) SessionNew(accountID string, wgPublicKey string, forceLogin bool, captchaID string, captcha string, confirmation2FA string, deviceName string) (
+	*apitypes.SessionNewResponse,
+	*apitypes.SessionNewErrorLimitResponse,
+	*apitypes.SessionNewErrorCaptchaResponse,
+	*apitypes.APIErrorResponse,
+	error) {
+
+	successResp := &apitypes.SessionNewResponse{
+		Token:       a.SessionToken,
+		VpnUsername: a.VpnUsername,
+		VpnPassword: a.VpnPassword}
+	successResp.Status = apitypes.CodeSuccess
+	successResp.ServiceStatus = apitypes.ServiceStatusAPIResp{Active: true}
+	successResp.WireGuard.Status = apitypes.CodeSuccess
+	successResp.WireGuard.IPAddress = a.WgLocalIP
+
+	apiErr := &apitypes.APIErrorResponse{}
+	apiErr.Status = apitypes.CodeSuccess
+
+	return successResp, nil, nil, apiErr, nil
+}
+
+// SessionStatus reports the session as active
+func (a *APIClient) SessionStatus(session string) (
+	*apitypes.ServiceStatusAPIResp,
+	*apitypes.APIErrorResponse,
+	error) {
+	apiErr := &apitypes.APIErrorResponse{}
+	apiErr.Status = apitypes.CodeSuccess
+	return &apitypes.ServiceStatusAPIResp{Active: true}, apiErr, nil
+}
+
+// SessionDelete always succeeds
+func (a *APIClient) SessionDelete(session string) error { return nil }
+
+// DeviceList returns an empty device list
+func (a *APIClient) DeviceList(session string) ([]apitypes.DeviceInfo, error) { return nil, nil }
+
+// DeviceDelete always succeeds
+func (a *APIClient) DeviceDelete(session string, deviceID string) error { return nil }
+
+// DownloadUpdateInfo - updates are not supported by the mock
+func (a *APIClient) DownloadUpdateInfo() (*apitypes.UpdateInfoResponse, error) {
+	return nil, fmt.Errorf("not supported by the mock API client")
+}
+
+// DownloadUpdateArtifact - updates are not supported by the mock
+func (a *APIClient) DownloadUpdateArtifact(urlPath string, signatureBase64 string) ([]byte, error) {
+	return nil, fmt.Errorf("not supported by the mock API client")
+}
+
+// ServersUpdater - mock of the servers list updater (service.IServersUpdater):
+// always returns the pre-configured servers list (no download)
+type ServersUpdater struct {
+	Servers      apitypes.ServersInfoResponse
+	notifierChan chan struct{}
+}
+
+// CreateServersUpdater - mock servers updater constructor
+func CreateServersUpdater(servers apitypes.ServersInfoResponse) *ServersUpdater {
+	return &ServersUpdater{Servers: servers, notifierChan: make(chan struct{})}
+}
+
+// GetServers returns the pre-configured servers list
+func (u *ServersUpdater) GetServers() (*apitypes.ServersInfoResponse, error) {
+	servers := u.Servers
+	return &servers, nil
+}
+
+// IsSignatureVerified - the mock servers list is always treated as verified
+func (u *ServersUpdater) IsSignatureVerified() bool { return true }
+
+// UpdateNotifierChannel returns channel which is notifying when servers was updated
+// (the mock never notifies)
+func (u *ServersUpdater) UpdateNotifierChannel() chan struct{} { return u.notifierChan }
+
+// NetChangeDetector - mock of the routing change detector (service.INetChangeDetector): does nothing
+type NetChangeDetector struct{}
+
+// Start - does nothing
+func (d *NetChangeDetector) Start(routingChangeChan chan<- struct{}, currentDefaultInterface *net.Interface) {
+}
+
+// Stop - does nothing
+func (d *NetChangeDetector) Stop() {}
+
+// DelayBeforeNotify - no delay
+func (d *NetChangeDetector) DelayBeforeNotify() time.Duration { return 0 }
+
+// SystemEventsMonitor - mock of the system events monitor (service.ISystemEventsMonitor): does nothing
+type SystemEventsMonitor struct{}
+
+// Start - does nothing
+func (m *SystemEventsMonitor) Start(notifyChan chan<- sysevents.Event) error { return nil }
+
+// Stop - does nothing
+func (m *SystemEventsMonitor) Stop() {}
+
+// PortForwardManager - mock of the port-forwarding manager (service.IPortForwardManager)
+type PortForwardManager struct{}
+
+// Init - does nothing
+func (m *PortForwardManager) Init(receiver portforward.IPortForwardReceiver) error { return nil }
+
+// Add - port-forwarding is not supported by the mock
+func (m *PortForwardManager) Add() (int, error) {
+	return 0, fmt.Errorf("not supported by the mock port-forward manager")
+}
+
+// Remove - does nothing
+func (m *PortForwardManager) Remove(port int) error { return nil }
+
+// List returns an empty ports list
+func (m *PortForwardManager) List() []int { return nil }
+
+// WgKeysManager - mock of the WireGuard keys manager (service.IWgKeysManager):
+// 'generates' the pre-configured key pair (no 'wg' tool required)
+type WgKeysManager struct {
+	PublicKey  string
+	PrivateKey string
+	LocalIP    string
+
+	receiver wgkeys.IWgKeysChangeReceiver
+}
+
+// Init - save the keys change receiver
+func (m *WgKeysManager) Init(receiver wgkeys.IWgKeysChangeReceiver) error {
+	m.receiver = receiver
+	return nil
+}
+
+// StartKeysRotation - does nothing (no background rotation)
+func (m *WgKeysManager) StartKeysRotation() error { return nil }
+
+// StopKeysRotation - does nothing
+func (m *WgKeysManager) StopKeysRotation() {}
+
+// GenerateKeys - save the pre-configured key pair
+func (m *WgKeysManager) GenerateKeys() error {
+	if m.receiver == nil {
+		return fmt.Errorf("keys manager not initialized")
+	}
+	m.receiver.WireGuardSaveNewKeys(m.PublicKey, m.PrivateKey, m.LocalIP)
+	return nil
+}
+
+// UpdateKeysIfNecessary - generate the keys only when not defined yet
+func (m *WgKeysManager) UpdateKeysIfNecessary() error {
+	if m.receiver == nil {
+		return nil
+	}
+	if _, wgPublicKey, _, _, _, _ := m.receiver.WireGuardGetKeys(); len(wgPublicKey) > 0 {
+		return nil
+	}
+	return m.GenerateKeys()
+}
+
+// VpnLauncher - mock of the VPN process factory (service.IVpnLauncher):
+// every connection request gets the same mock VPN process; all functionality is reported as accessible
+type VpnLauncher struct {
+	// Process - the VPN process object returned for every connection request
+	Process *VpnProcess
+}
+
+// GetDisabledFunctions - all functionality is accessible for the mock
+func (l *VpnLauncher) GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error) { return nil, nil, nil }
+
+// NewOpenVPN returns the mock VPN process
+func (l *VpnLauncher) NewOpenVPN(connectionParams openvpn.ConnectionParams, isObfsproxy bool, isObfs4 bool, obfs4Cert string, extraParameters string) (vpn.Process, error) {
+	return l.Process, nil
+}
+
+// NewWireGuard returns the mock VPN process
+func (l *VpnLauncher) NewWireGuard(connectionParams wireguard.ConnectionParams) (vpn.Process, error) {
+	return l.Process, nil
+}
+
+// VpnProcess - mock of a VPN process (vpn.Process):
+// 'Connect' immediately reports the CONNECTED state and blocks until 'Disconnect'
+// (no process is started, no tunnel interface is created)
+type VpnProcess struct {
+	VpnType vpn.Type
+	// ClientIP - the in-tunnel client address reported in the CONNECTED state
+	// (have to be an address of an existing local interface - e.g. 127.0.0.1)
+	ClientIP net.IP
+	ServerIP net.IP
+
+	mutex          sync.Mutex
+	disconnectChan chan struct{}
+	isPaused       bool
+}
+
+// Type returns VPN type
+func (p *VpnProcess) Type() vpn.Type { return p.VpnType }
+
+// Init - does nothing
+func (p *VpnProcess) Init() error { return nil }
+
+// Connect reports the CONNECTED state and blocks until 'Disconnect' request
+func (p *VpnProcess) Connect(stateChan chan<- vpn.StateInfo) error {
+	p.mutex.Lock()
+	p.disconnectChan = make(chan struct{})
+	disconnectChan := p.disconnectChan
+	p.mutex.Unlock()
+
+	stateChan <- vpn.NewStateInfo(vpn.CONNECTING, "")
+	stateChan <- vpn.NewStateInfoConnected(p.ClientIP, p.ServerIP)
+
+	<-disconnectChan
+
+	stateChan <- vpn.NewStateInfo(vpn.EXITING, "")
+	return nil
+}
+
+// Disconnect - stop the blocked 'Connect'
+func (p *VpnProcess) Disconnect() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.disconnectChan != nil {
+		select {
+		case <-p.disconnectChan: // already closed
+		default:
+			close(p.disconnectChan)
+		}
+	}
+	return nil
+}
+
+// Pause - remember the paused state
+func (p *VpnProcess) Pause() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.isPaused = true
+	return nil
+}
+
+// Resume - reset the paused state
+func (p *VpnProcess) Resume() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.isPaused = false
+	return nil
+}
+
+// IsPaused returns the paused state
+func (p *VpnProcess) IsPaused() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.isPaused
+}
+
+// SetManualDNS - does nothing
+func (p *VpnProcess) SetManualDNS(addr net.IP) error { return nil }
+
+// ResetManualDNS - does nothing
+func (p *VpnProcess) ResetManualDNS() error { return nil }
+
+// GetStats reports a 'just handshaked' connection (keeps the dead-peer detection satisfied)
+func (p *VpnProcess) GetStats() (vpn.Statistics, error) {
+	return vpn.Statistics{LastHandshake: time.Now().Unix()}, nil
+}
+
+// DestinationIPs - the mock process has no real destination
+// (empty list: no firewall exceptions are requested)
+func (p *VpnProcess) DestinationIPs() []net.IP { return nil }
+
+// OnRoutingChanged - roaming is always 'successful' for the mock
+func (p *VpnProcess) OnRoutingChanged() error { return nil }
@@ -0,0 +1,40 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+)
+
+// RepairNetworkAdapters - enumerate and repair stuck VPN network adapters
+// (Windows: TAP\Wintun adapters left in a broken state are the most common
+// support issue - e.g. 'all TAP adapters in use' after an OpenVPN crash).
+// Invoked manually via the 'RepairNetworkAdapters' protocol request and
+// automatically when OpenVPN reports no free adapter.
+// Returns descriptions of the performed repairs (empty - nothing to repair)
+func (s *Service) RepairNetworkAdapters() (repairedItems []string, err error) {
+	if s.Connected() {
+		return nil, fmt.Errorf("unable to repair network adapters: VPN is connected")
+	}
+	return implRepairNetworkAdapters()
+}
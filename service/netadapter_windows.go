@@ -0,0 +1,165 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows/registry"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// GUID of the 'Network adapters' device class
+// ('...Control\Class\{GUID}\NNNN' - driver info of each installed adapter;
+// '...Control\Network\{GUID}\<NetCfgInstanceId>\Connection' - the connection name)
+const _netAdaptersClassKeyPath = `SYSTEM\CurrentControlSet\Control\Class\{4D36E972-E325-11CE-BFC1-08002BE10318}`
+const _netAdaptersConnectionKeyPath = `SYSTEM\CurrentControlSet\Control\Network\{4D36E972-E325-11CE-BFC1-08002BE10318}`
+
+// component IDs of the VPN tunnel drivers
+var _tunnelDriverComponentIDs = map[string]struct{}{
+	"tap0901": {}, // TAP-Windows
+	"wintun":  {}} // Wintun (WireGuard)
+
+// tunnelAdapterInfo - info about an installed VPN tunnel adapter
+type tunnelAdapterInfo struct {
+	// driver component ID (e.g. 'tap0901')
+	componentID string
+	// name of the network connection (e.g. 'Local Area Connection 2')
+	connectionName string
+}
+
+// implRepairNetworkAdapters - repair stuck TAP\Wintun adapters:
+// each found adapter is reset (disable + enable releases a stuck
+// 'media status connected' state left by a crashed VPN process);
+// when no TAP adapter is installed at all - the TAP driver reinstallation
+// is attempted (requires 'tapinstall.exe' shipped with the OpenVPN binaries)
+func implRepairNetworkAdapters() (repairedItems []string, err error) {
+	repairedItems = make([]string, 0, 2)
+
+	adapters, err := enumTunnelAdapters()
+	if err != nil {
+		return nil, fmt.Errorf("failed to enumerate network adapters: %w", err)
+	}
+
+	isTapAdapterFound := false
+	for _, adapter := range adapters {
+		if adapter.componentID == "tap0901" {
+			isTapAdapterFound = true
+		}
+
+		if len(adapter.connectionName) == 0 {
+			continue // adapter is not bound to a network connection - nothing to reset
+		}
+		log.Info(fmt.Sprintf("Resetting network adapter '%s' (%s)...", adapter.connectionName, adapter.componentID))
+		if err := resetTunnelAdapter(adapter.connectionName); err != nil {
+			log.Error(fmt.Sprintf("Failed to reset network adapter '%s': ", adapter.connectionName), err)
+			continue
+		}
+		repairedItems = append(repairedItems, fmt.Sprintf("adapter '%s' (%s) reset", adapter.connectionName, adapter.componentID))
+	}
+
+	// no TAP adapter installed - try to reinstall the TAP driver
+	if isTapAdapterFound == false {
+		log.Info("No TAP adapter found. Trying to reinstall the TAP driver...")
+		if err := reinstallTapDriver(); err != nil {
+			log.Error("Failed to reinstall the TAP driver: ", err)
+		} else {
+			repairedItems = append(repairedItems, "TAP driver reinstalled")
+		}
+	}
+
+	return repairedItems, nil
+}
+
+// enumTunnelAdapters - enumerate installed VPN tunnel adapters (TAP\Wintun)
+// using the 'Network adapters' device class registry keys
+func enumTunnelAdapters() ([]tunnelAdapterInfo, error) {
+	classKey, err := registry.OpenKey(registry.LOCAL_MACHINE, _netAdaptersClassKeyPath, registry.READ)
+	if err != nil {
+		return nil, err
+	}
+	defer classKey.Close()
+
+	subKeys, err := classKey.ReadSubKeyNames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	adapters := make([]tunnelAdapterInfo, 0, 2)
+	for _, subKey := range subKeys {
+		driverKey, err := registry.OpenKey(registry.LOCAL_MACHINE, _netAdaptersClassKeyPath+`\`+subKey, registry.READ)
+		if err != nil {
+			continue // e.g. the 'Properties' sub-key is not readable
+		}
+		componentID, _, _ := driverKey.GetStringValue("ComponentId")
+		netCfgInstanceID, _, _ := driverKey.GetStringValue("NetCfgInstanceId")
+		driverKey.Close()
+
+		if _, isTunnelDriver := _tunnelDriverComponentIDs[componentID]; isTunnelDriver == false {
+			continue
+		}
+
+		adapterInfo := tunnelAdapterInfo{componentID: componentID}
+		if len(netCfgInstanceID) > 0 {
+			if connKey, err := registry.OpenKey(registry.LOCAL_MACHINE, _netAdaptersConnectionKeyPath+`\`+netCfgInstanceID+`\Connection`, registry.READ); err == nil {
+				adapterInfo.connectionName, _, _ = connKey.GetStringValue("Name")
+				connKey.Close()
+			}
+		}
+		adapters = append(adapters, adapterInfo)
+	}
+
+	return adapters, nil
+}
+
+// resetTunnelAdapter - disable + enable the network connection of an adapter
+func resetTunnelAdapter(connectionName string) error {
+	if err := shell.Exec(log, "netsh", "interface", "set", "interface", "name="+connectionName, "admin=disabled"); err != nil {
+		return err
+	}
+	return shell.Exec(log, "netsh", "interface", "set", "interface", "name="+connectionName, "admin=enabled")
+}
+
+// reinstallTapDriver - remove the (possibly corrupted) TAP adapters and install a new one.
+// 'tapinstall.exe' and the driver files are expected next to the OpenVPN binary
+func reinstallTapDriver() error {
+	openVpnDir := filepath.Dir(platform.OpenVpnBinaryPath())
+
+	tapInstallPath := filepath.Join(openVpnDir, "tapinstall.exe")
+	if _, err := os.Stat(tapInstallPath); err != nil {
+		return fmt.Errorf("'tapinstall.exe' not found ('%s')", tapInstallPath)
+	}
+	driverInfPath := filepath.Join(openVpnDir, "driver", "OemVista.inf")
+	if _, err := os.Stat(driverInfPath); err != nil {
+		return fmt.Errorf("TAP driver not found ('%s')", driverInfPath)
+	}
+
+	// remove the broken adapters first (the error is ignored: there may be nothing to remove)
+	shell.Exec(log, tapInstallPath, "remove", "tap0901")
+
+	return shell.Exec(log, tapInstallPath, "install", driverInfPath, "tap0901")
+}
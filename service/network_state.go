@@ -0,0 +1,111 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/service/dns"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+)
+
+// GetNetworkState - the daemon view of the current networking state:
+// default route, tunnel interface, DNS resolvers in effect and whether they match
+// the daemon-configured DNS (for the UI 'network health' panel and debugging).
+// Partial failures are not fatal: the corresponding fields are just left empty.
+func (s *Service) GetNetworkState() (
+	defaultGatewayIP string,
+	defaultInterfaceName string,
+	tunnelInterfaceName string,
+	tunnelLocalIP string,
+	dnsServers []string,
+	configuredDNS string,
+	isDNSMatch bool,
+	otherVpnInterfaces []string) {
+
+	// default route
+	if gwIP, err := netinfo.DefaultGatewayIP(); err != nil {
+		log.Warning("Failed to detect default gateway: ", err)
+	} else {
+		defaultGatewayIP = gwIP.String()
+
+		// interface which owns the network of the default gateway
+		if addresses, err := netinfo.GetAllLocalV4Addresses(); err != nil {
+			log.Warning("Failed to enumerate local addresses: ", err)
+		} else {
+			for _, addr := range addresses {
+				if addr.Contains(gwIP) == false {
+					continue
+				}
+				if inf, err := netinfo.InterfaceByIPAddr(addr.IP); err == nil {
+					defaultInterfaceName = inf.Name
+				}
+				break
+			}
+		}
+	}
+
+	// tunnel info (only when connected)
+	if localIP := s._tunnelLocalIP; localIP != nil {
+		tunnelLocalIP = localIP.String()
+		if inf, err := netinfo.InterfaceByIPAddr(localIP); err != nil {
+			log.Warning("Failed to detect tunnel interface: ", err)
+		} else {
+			tunnelInterfaceName = inf.Name
+		}
+	}
+
+	// DNS resolvers in effect vs. the daemon-configured value
+	dnsServers, err := dns.SystemDNSServers()
+	if err != nil {
+		log.Warning("Failed to detect system DNS configuration: ", err)
+	}
+	configuredDNS = dns.GetLastManualDNS()
+
+	isDNSMatch = true
+	if len(configuredDNS) > 0 {
+		isDNSMatch = false
+		for _, server := range dnsServers {
+			if server == configuredDNS {
+				isDNSMatch = true
+				break
+			}
+		}
+	}
+
+	// other active VPN tunnels (interfaces which do not belong to us)
+	wgConfigPath := platform.WGConfigFilePath()
+	ownInterfaces := []string{strings.TrimSuffix(filepath.Base(wgConfigPath), filepath.Ext(wgConfigPath))}
+	if len(tunnelInterfaceName) > 0 {
+		ownInterfaces = append(ownInterfaces, tunnelInterfaceName)
+	}
+	if vpnInterfaces, err := netinfo.ActiveVpnInterfaces(ownInterfaces); err != nil {
+		log.Warning("Failed to detect other active VPN interfaces: ", err)
+	} else {
+		otherVpnInterfaces = vpnInterfaces
+	}
+
+	return defaultGatewayIP, defaultInterfaceName, tunnelInterfaceName, tunnelLocalIP, dnsServers, configuredDNS, isDNSMatch, otherVpnInterfaces
+}
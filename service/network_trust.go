@@ -0,0 +1,96 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/wifi"
+)
+
+// interval of checking the currently connected Wi-Fi network
+const networkTrustCheckInterval = time.Second * 15
+
+// startNetworkTrustMonitor - start background monitoring of the current Wi-Fi network.
+// When the network changes and a trust rule is defined for it - the rule is applied:
+//	'untrusted' network - enable firewall (connected clients are notified to establish VPN connection)
+//	'trusted' network - disable firewall (only if it is not in persistent state)
+func (s *Service) startNetworkTrustMonitor() {
+	go func() {
+		for {
+			ssid := wifi.GetCurrentSSID()
+			if ssid != s._lastKnownSSID {
+				s._lastKnownSSID = ssid
+				s.applyNetworkRule(ssid)
+			}
+
+			time.Sleep(networkTrustCheckInterval)
+		}
+	}()
+}
+
+// SetNetworkRules - save trusted\untrusted Wi-Fi network rules
+// and immediately re-apply the rule for the currently connected network
+func (s *Service) SetNetworkRules(isEnabled bool, rules []preferences.NetworkRule) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsNetworkRulesEnabled = isEnabled
+	prefs.NetworkRules = rules
+	s.setPreferences(prefs)
+
+	s.applyNetworkRule(wifi.GetCurrentSSID())
+	return nil
+}
+
+// applyNetworkRule - apply trust rule (if defined) for a network
+func (s *Service) applyNetworkRule(ssid string) {
+	prefs := s.Preferences()
+	if prefs.IsNetworkRulesEnabled == false || len(ssid) == 0 {
+		return
+	}
+
+	for _, rule := range prefs.NetworkRules {
+		if rule.SSID != ssid {
+			continue
+		}
+
+		if rule.IsTrusted {
+			// trusted network: disable firewall (do not touch the persistent configuration)
+			if prefs.IsFwPersistant == false {
+				if err := s.SetKillSwitchState(false); err != nil {
+					log.Error("Failed to disable firewall (trusted network rule): ", err)
+				}
+			}
+		} else {
+			// untrusted network: enable firewall
+			// (VPN connection is initiated by clients on 'NetworkTrustChangedResp' notification)
+			if err := s.SetKillSwitchState(true); err != nil {
+				log.Error("Failed to enable firewall (untrusted network rule): ", err)
+			}
+		}
+
+		log.Info("Network trust rule applied: ssid=", ssid, " trusted=", rule.IsTrusted)
+		s._evtReceiver.OnNetworkTrustChanged(ssid, rule.IsTrusted)
+		return
+	}
+}
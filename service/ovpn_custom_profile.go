@@ -0,0 +1,194 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
+)
+
+// Custom (non-IVPN) OpenVPN profiles: a third-party '.ovpn' configuration can be
+// imported through the protocol. During import the configuration is sanitized
+// (directives which are able to execute external commands are stripped) and the
+// embedded certificates\keys are extracted into separate files with restrictive
+// permissions under the settings directory.
+
+// inline blocks which are extracted into separate files during import
+var _ovpnInlineBlocks = []string{"ca", "cert", "key", "tls-auth", "tls-crypt", "dh", "crl-verify", "pkcs12", "secret"}
+
+// ovpnCustomProfilesDir - base directory of the imported OpenVPN profiles
+func ovpnCustomProfilesDir() string {
+	return filepath.Join(filepath.Dir(platform.SettingsFile()), "openvpn-custom-profiles")
+}
+
+// isValidProfileName - the profile name is used as a directory name:
+// only letters, digits, '-', '_' and '.' are accepted (protection against path traversal)
+func isValidProfileName(name string) bool {
+	if len(name) == 0 || len(name) > 64 {
+		return false
+	}
+	for _, c := range name {
+		isOK := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.'
+		if isOK == false {
+			return false
+		}
+	}
+	return name != "." && name != ".."
+}
+
+// ImportOpenVPNCustomProfile - sanitize a third-party '.ovpn' configuration and store it
+// as a custom profile (a profile with the same name is replaced)
+// Returns the list of stripped (forbidden) directives
+func (s *Service) ImportOpenVPNCustomProfile(name string, config string) (rejectedDirectives []string, retErr error) {
+	name = strings.TrimSpace(name)
+	if isValidProfileName(name) == false {
+		return nil, fmt.Errorf("bad profile name (only letters, digits, '-', '_' and '.' are acceptable)")
+	}
+
+	profileDir := filepath.Join(ovpnCustomProfilesDir(), name)
+	if err := os.MkdirAll(profileDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %w", err)
+	}
+
+	// extract embedded certificates\keys into separate files (0600)
+	config, err := extractOvpnInlineBlocks(config, profileDir)
+	if err != nil {
+		os.RemoveAll(profileDir)
+		return nil, err
+	}
+
+	// strip dangerous directives (same rules as for the user-defined extra parameters)
+	sanitized, rejected := openvpn.FilterExtraParameters(config)
+	if len(rejected) > 0 {
+		log.Warning("Forbidden directives stripped from the imported OpenVPN profile: ", strings.Join(rejected, ", "))
+	}
+
+	configFilePath := filepath.Join(profileDir, "config.ovpn")
+	if err := ioutil.WriteFile(configFilePath, []byte(sanitized), 0600); err != nil {
+		os.RemoveAll(profileDir)
+		return nil, fmt.Errorf("failed to save profile configuration: %w", err)
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	profiles := make([]preferences.OpenVPNCustomProfile, 0, len(prefs.OpenVpnCustomProfiles)+1)
+	for _, p := range prefs.OpenVpnCustomProfiles {
+		if p.Name != name {
+			profiles = append(profiles, p)
+		}
+	}
+	prefs.OpenVpnCustomProfiles = append(profiles, preferences.OpenVPNCustomProfile{
+		Name:           name,
+		ConfigFilePath: configFilePath})
+	s.setPreferences(prefs)
+
+	log.Info(fmt.Sprintf("Custom OpenVPN profile imported: '%s'", name))
+	return rejected, nil
+}
+
+// RemoveOpenVPNCustomProfile - remove a stored custom OpenVPN profile
+// (including the sanitized configuration and the extracted certificates)
+func (s *Service) RemoveOpenVPNCustomProfile(name string) error {
+	prefs := s._preferences.GetSnapshot()
+	profiles := make([]preferences.OpenVPNCustomProfile, 0, len(prefs.OpenVpnCustomProfiles))
+	found := false
+	for _, p := range prefs.OpenVpnCustomProfiles {
+		if p.Name == name {
+			found = true
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	if found == false {
+		return fmt.Errorf("custom OpenVPN profile '%s' not found", name)
+	}
+	prefs.OpenVpnCustomProfiles = profiles
+	s.setPreferences(prefs)
+
+	if isValidProfileName(name) {
+		if err := os.RemoveAll(filepath.Join(ovpnCustomProfilesDir(), name)); err != nil {
+			log.Error("Failed to remove profile directory: ", err)
+		}
+	}
+	return nil
+}
+
+// OpenVPNCustomProfiles returns the stored custom OpenVPN profiles
+func (s *Service) OpenVPNCustomProfiles() []preferences.OpenVPNCustomProfile {
+	stored := s.Preferences().OpenVpnCustomProfiles
+	profiles := make([]preferences.OpenVPNCustomProfile, len(stored))
+	copy(profiles, stored)
+	return profiles
+}
+
+// extractOvpnInlineBlocks - extract the embedded '<ca>'\'<cert>'\... blocks of an OpenVPN
+// configuration into separate files (0600) and replace them with file-reference directives
+func extractOvpnInlineBlocks(config string, profileDir string) (string, error) {
+	var sb strings.Builder
+
+	lines := strings.Split(config, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		blockName := ""
+		for _, block := range _ovpnInlineBlocks {
+			if line == "<"+block+">" {
+				blockName = block
+				break
+			}
+		}
+		if len(blockName) == 0 {
+			sb.WriteString(lines[i] + "\n")
+			continue
+		}
+
+		// collect the block content (until the closing tag)
+		var content strings.Builder
+		closed := false
+		for i++; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i]) == "</"+blockName+">" {
+				closed = true
+				break
+			}
+			content.WriteString(lines[i] + "\n")
+		}
+		if closed == false {
+			return "", fmt.Errorf("embedded '<%s>' block is not closed", blockName)
+		}
+
+		blockFilePath := filepath.Join(profileDir, blockName+".pem")
+		if err := ioutil.WriteFile(blockFilePath, []byte(content.String()), 0600); err != nil {
+			return "", fmt.Errorf("failed to save embedded '<%s>' block: %w", blockName, err)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s \"%s\"\n", blockName, blockFilePath))
+	}
+
+	return sb.String(), nil
+}
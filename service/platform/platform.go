@@ -50,8 +50,9 @@ var (
 	openvpnProxyAuthFile  string
 	openvpnUserParamsFile string
 
-	obfsproxyStartScript string
-	obfsproxyHostPort    int
+	obfsproxyStartScript  string
+	obfs4proxyBinaryPath  string
+	obfsproxyHostPort     int
 
 	wgBinaryPath     string
 	wgToolBinaryPath string
@@ -283,6 +284,11 @@ func ObfsproxyStartScript() string {
 	return obfsproxyStartScript
 }
 
+// Obfs4proxyBinaryPath path to obfs4proxy binary
+func Obfs4proxyBinaryPath() string {
+	return obfs4proxyBinaryPath
+}
+
 // ObfsproxyHostPort is an port of obfsproxy host
 func ObfsproxyHostPort() int {
 	return obfsproxyHostPort
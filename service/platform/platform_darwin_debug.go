@@ -65,6 +65,7 @@ func doOsInitForBuild() (warnings []string, errors []error) {
 	openvpnDownScript = path.Join(installDir, "References/macOS/etc/dns.sh -down")
 
 	obfsproxyStartScript = path.Join(installDir, "References/macOS/obfsproxy/obfsproxy.sh")
+	obfs4proxyBinaryPath = path.Join(installDir, "References/macOS/obfsproxy/obfs4proxy")
 
 	wgBinaryPath = path.Join(installDir, "References/macOS/_deps/wg_inst/wireguard-go")
 	wgToolBinaryPath = path.Join(installDir, "References/macOS/_deps/wg_inst/wg")
@@ -54,6 +54,7 @@ func doOsInitForBuild() (warnings []string, errors []error) {
 	openvpnDownScript = "/Applications/IVPN.app/Contents/Resources/etc/dns.sh -down"
 
 	obfsproxyStartScript = "/Applications/IVPN.app/Contents/Resources/obfsproxy/obfsproxy.sh"
+	obfs4proxyBinaryPath = "/Applications/IVPN.app/Contents/Resources/obfsproxy/obfs4proxy"
 
 	wgBinaryPath = "/Applications/IVPN.app/Contents/MacOS/WireGuard/wireguard-go"
 	wgToolBinaryPath = "/Applications/IVPN.app/Contents/MacOS/WireGuard/wg"
@@ -45,6 +45,7 @@ func doInitConstants() {
 func doOsInit() (warnings []string, errors []error) {
 	openVpnBinaryPath = path.Join("/usr/sbin", "openvpn")
 	obfsproxyStartScript = "/usr/bin/obfsproxy"
+	obfs4proxyBinaryPath = "/usr/bin/obfs4proxy"
 	wgBinaryPath = path.Join("/usr/bin", "wg-quick")
 	wgToolBinaryPath = path.Join("/usr/bin", "wg")
 
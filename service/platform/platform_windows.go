@@ -79,6 +79,7 @@ func doOsInit() (warnings []string, errors []error) {
 	openvpnDownScript = ""
 
 	obfsproxyStartScript = path.Join(_installDir, "OpenVPN", "obfsproxy", "obfsproxy.exe")
+	obfs4proxyBinaryPath = path.Join(_installDir, "OpenVPN", "obfsproxy", "obfs4proxy.exe")
 
 	_wgArchDir := "x86_64"
 	if _, err := os.Stat(path.Join(_installDir, "WireGuard", _wgArchDir, "wireguard.exe")); err != nil {
@@ -0,0 +1,202 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package portforward
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/api"
+	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/service/firewall"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("prtfwd")
+}
+
+// interval of forwarded ports renewal (to keep allocations alive on the API side)
+const _keepAliveInterval = time.Minute * 5
+
+// IPortForwardReceiver - access to the daemon state required by the port-forwarding manager
+type IPortForwardReceiver interface {
+	Connected() bool
+	SessionToken() string
+}
+
+// CreateManager create port-forwarding manager
+func CreateManager(apiObj *api.API) *Manager {
+	return &Manager{
+		stopKeepAlive: make(chan struct{}),
+		api:           apiObj}
+}
+
+// Manager - port-forwarding manager:
+// keeps information about forwarded ports allocated for the current session
+// and periodically renews the allocations while VPN is connected
+type Manager struct {
+	mutex              sync.Mutex
+	service            IPortForwardReceiver
+	api                *api.API
+	ports              map[int]struct{}
+	isKeepAliveStarted bool
+	stopKeepAlive      chan struct{}
+}
+
+// Init - initialize master service
+func (m *Manager) Init(receiver IPortForwardReceiver) error {
+	if receiver == nil || m.service != nil {
+		return fmt.Errorf("failed to initialize port-forwarding manager")
+	}
+	m.service = receiver
+	return nil
+}
+
+// Add - allocate a new forwarded port for the current session.
+// The port allocation is kept alive while VPN is connected;
+// the corresponding inbound firewall rule on the tunnel interface is opened
+func (m *Manager) Add() (int, error) {
+	if m.service == nil {
+		return 0, fmt.Errorf("port-forwarding manager not initialized")
+	}
+
+	session := m.service.SessionToken()
+	if len(session) == 0 {
+		return 0, fmt.Errorf("unable to add port forwarding (account not logged in)")
+	}
+
+	port, err := m.api.PortForwardAdd(session, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add port forwarding: %w", err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.ports == nil {
+		m.ports = make(map[int]struct{})
+	}
+	m.ports[port] = struct{}{}
+	log.Info(fmt.Sprintf("Port forwarding added: %d", port))
+
+	m.applyFirewallRules()
+	m.startKeepAlive()
+
+	return port, nil
+}
+
+// Remove - release a forwarded port
+func (m *Manager) Remove(port int) error {
+	if m.service == nil {
+		return fmt.Errorf("port-forwarding manager not initialized")
+	}
+
+	m.mutex.Lock()
+	if _, ok := m.ports[port]; ok == false {
+		m.mutex.Unlock()
+		return fmt.Errorf("port %d is not forwarded", port)
+	}
+	delete(m.ports, port)
+	m.applyFirewallRules()
+	m.mutex.Unlock()
+
+	session := m.service.SessionToken()
+	if err := m.api.PortForwardDelete(session, port); err != nil {
+		return fmt.Errorf("failed to remove port forwarding: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("Port forwarding removed: %d", port))
+	return nil
+}
+
+// List - forwarded ports of the current session (sorted)
+func (m *Manager) List() []int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ports := make([]int, 0, len(m.ports))
+	for port := range m.ports {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// applyFirewallRules - open inbound firewall rules on the tunnel interface
+// for all forwarded ports (must be called under mutex protection)
+func (m *Manager) applyFirewallRules() {
+	ports := make([]int, 0, len(m.ports))
+	for port := range m.ports {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+
+	if err := firewall.SetAllowedIncomingPorts(ports); err != nil {
+		log.Warning("Failed to apply firewall rules for forwarded ports: ", err)
+	}
+}
+
+// startKeepAlive - start the allocations renewal loop (must be called under mutex protection)
+func (m *Manager) startKeepAlive() {
+	if m.isKeepAliveStarted {
+		return
+	}
+	m.isKeepAliveStarted = true
+
+	go func() {
+		log.Info("Port forwarding keep-alive started")
+		defer log.Info("Port forwarding keep-alive stopped")
+
+		for {
+			select {
+			case <-time.After(_keepAliveInterval):
+				m.renewAllocations()
+			case <-m.stopKeepAlive:
+				return
+			}
+		}
+	}()
+}
+
+// renewAllocations - renew all forwarded port allocations
+// (skipped when VPN is not connected: API calls would be blocked by the firewall anyway)
+func (m *Manager) renewAllocations() {
+	if m.service.Connected() == false {
+		return
+	}
+
+	session := m.service.SessionToken()
+	if len(session) == 0 {
+		return
+	}
+
+	for _, port := range m.List() {
+		if _, err := m.api.PortForwardAdd(session, port); err != nil {
+			log.Warning(fmt.Sprintf("Failed to renew port forwarding %d: %s", port, err))
+		}
+	}
+}
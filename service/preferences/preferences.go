@@ -46,15 +46,181 @@ const (
 
 // Preferences - IVPN service preferences
 type Preferences struct {
-	IsLogging                bool
-	IsFwPersistant           bool
-	IsFwAllowLAN             bool
-	IsFwAllowLANMulticast    bool
+	IsLogging bool
+	// SystemLogTarget - duplicate daemon log messages into the system log
+	// ('' - disabled; 'syslog'\'journald' on Linux, 'eventlog' on Windows, 'syslog' on macOS)
+	SystemLogTarget       string
+	IsFwPersistant        bool
+	IsFwAllowLAN          bool
+	IsFwAllowLANMulticast bool
+	// IsFwAllowServiceDiscovery - allow mDNS\Bonjour and SSDP multicast while the firewall
+	// is enabled (AirPrint\Chromecast\DLNA discovery), independent from 'IsFwAllowLANMulticast':
+	// only the specific discovery multicast groups are allowed, not all multicast
+	IsFwAllowServiceDiscovery bool
+	// IsFwSoftMode - 'soft' kill-switch mode: traffic is blocked only when the tunnel
+	// drops unexpectedly; a deliberate disconnect keeps connectivity
+	IsFwSoftMode             bool
 	IsStopOnClientDisconnect bool
 	IsObfsproxy              bool
+	// ObfsproxyVersion - obfuscation protocol to use when obfsproxy is enabled:
+	// 3 (default) - obfs3; 4 - obfs4 (requires obfs4 bridge support on the server)
+	ObfsproxyVersion int
 
-	// last known account status
-	//Account AccountStatus
+	// OpenVpnExtraParameters - user-defined extra directives for OpenVPN configuration (advanced users)
+	// Validated by the daemon before use: directives able to execute external commands are forbidden
+	OpenVpnExtraParameters string
+
+	// FwLanAllowedAddresses - user-managed list of specific LAN addresses (IPs or CIDR subnets)
+	// which stay reachable when the firewall is enabled (in addition to 'IsFwAllowLAN')
+	FwLanAllowedAddresses []string
+	// FwUserExceptions - user-managed list of 'always allowed' destinations
+	// (single IPs, 'IP:port' entries or CIDR subnets not broader than /24)
+	FwUserExceptions []string
+	// IsFwBlockDNS - DNS leak protection: block out-of-tunnel DNS requests while connected
+	IsFwBlockDNS bool
+	// IsFwAllowApiServers - API access policy: keep the IVPN API servers reachable
+	// when the firewall is enabled but VPN is not connected
+	// (narrow exception only for the known API IP addresses;
+	// while connected - daemon-originated API requests just go through the tunnel)
+	IsFwAllowApiServers bool
+
+	// IsWebSocketBridge - expose the local JSON protocol also over a localhost-only
+	// WebSocket endpoint (for browser-based dashboards and Electron renderers);
+	// same requests\responses and authentication as the TCP transport.
+	// The change takes effect after the daemon restart
+	IsWebSocketBridge bool
+	// WebSocketBridgePort - TCP port of the WebSocket bridge (0 - use the default port)
+	WebSocketBridgePort int
+
+	// IsMetrics - expose daemon observability metrics (connection state, traffic counters,
+	// API error rates...) in the Prometheus\OpenMetrics text format
+	// on a localhost-only HTTP endpoint
+	IsMetrics bool
+	// MetricsPort - TCP port of the metrics endpoint (0 - use the default port)
+	MetricsPort int
+
+	// Local DNS proxy: daemon-hosted DNS over HTTPS\TLS resolver
+	// (when enabled - system DNS points to the local proxy while connected
+	// and queries are forwarded to 'DnsProxyResolver' through the tunnel)
+	IsDnsProxy         bool
+	DnsProxyEncryption string // 'doh' or 'dot'
+	DnsProxyResolver   string // DoH URL or DoT host[:port]
+
+	// AntiTracker: use ad\tracker blocking DNS servers while connected
+	// ('hardcore' mode additionally blocks domains of Google and Facebook)
+	IsAntiTracker         bool
+	IsAntiTrackerHardcore bool
+
+	// IsConnectionFallback - when an OpenVPN connection attempt fails - automatically retry
+	// through alternate ports\protocols (UDP alternates, TCP 443, obfsproxy) before reporting failure
+	IsConnectionFallback bool
+
+	// Preferred server connection port\protocol combination ('TCP:443', 'UDP:2049' ...)
+	// Applied when a 'Connect' request does not define a destination port -
+	// the daemon (not each UI separately) owns the port decision
+	// (empty - no preference defined)
+	PreferredPortOpenVpn   string
+	PreferredPortWireGuard string
+
+	// WireGuardMTU - MTU of the WireGuard tunnel interface
+	// (0 - automatic: path MTU to the server is probed after connecting
+	// and the WireGuard encapsulation overhead subtracted)
+	WireGuardMTU int
+
+	// WireGuardKeepAlive - peer persistent-keepalive interval in seconds
+	// (0 - use the default interval; useful for strict NATs which drop UDP mappings quickly)
+	WireGuardKeepAlive int
+	// WireGuardListenPort - fixed local listen port (0 - use a random free port;
+	// a predictable source port is useful for router firewall rules)
+	WireGuardListenPort int
+
+	// WireGuardAllowedIPs - partial tunneling: tunnel only the defined networks
+	// (empty - full default-route capture; a 'Connect' request value has a priority)
+	WireGuardAllowedIPs []string
+
+	// IsWGIPConflictRenew - when the API-assigned WireGuard in-tunnel address
+	// conflicts with an existing local subnet - automatically request a new
+	// address assignment from the API before connecting
+	// (a warning is reported to clients in any case)
+	IsWGIPConflictRenew bool
+
+	// WireGuardHandshakeTimeout - dead-peer detection for WireGuard connections (seconds):
+	// when there is no successful handshake for this period - the connection is re-established
+	// (0 - detection disabled)
+	WireGuardHandshakeTimeout int
+
+	// IsAutoReconnect - automatically reconnect on unexpected tunnel disconnection
+	IsAutoReconnect bool
+	// ReconnectMaxRetries - give up automatic reconnection after this amount
+	// of consecutive failed attempts (0 - no limit)
+	ReconnectMaxRetries int
+
+	// VpnCoexistencePolicy - behavior when another active VPN tunnel is detected on connect:
+	// "refuse" - do not connect; "takeover" (default) - take over the default route;
+	// "nested" - connect through the other tunnel (tunnel-within-tunnel)
+	VpnCoexistencePolicy string
+
+	// Trusted\untrusted Wi-Fi networks functionality
+	IsNetworkRulesEnabled bool
+	NetworkRules          []NetworkRule
+
+	// Split-tunneling functionality
+	// (SplitTunnelApps - paths to binaries of applications which are excluded from the tunnel)
+	IsSplitTunnel bool
+	// IsSplitTunnelInverse == true - only 'SplitTunnelApps' are routed through the tunnel,
+	// everything else uses the default route
+	IsSplitTunnelInverse bool
+	SplitTunnelApps      []string
+	// Per-IP\subnet split-tunneling rules (CIDR notation), applied during VPN route setup
+	SplitTunnelBypassCIDRs []string // networks which have to bypass the tunnel
+	SplitTunnelVpnCIDRs    []string // networks which always have to use the tunnel
+
+	// IsAlwaysOnVPN - re-establish the tunnel whenever it is down
+	// (ignoring reconnection limits; the firewall blocks traffic during the gaps);
+	// only an explicit disconnect request stops the connection
+	IsAlwaysOnVPN bool
+
+	// IsAutoConnectOnLaunch - automatically establish VPN connection on daemon start
+	// (using parameters of the last connection request)
+	IsAutoConnectOnLaunch bool
+	// LastConnectionParams - last received 'Connect' request (raw JSON)
+	LastConnectionParams string
+
+	// WireGuardCustomProfiles - imported custom (non-IVPN) WireGuard connection profiles
+	// (see wg_custom_profile.go)
+	WireGuardCustomProfiles []WGCustomProfile
+	// OpenVpnCustomProfiles - imported custom (non-IVPN) OpenVPN connection profiles
+	// (see ovpn_custom_profile.go)
+	OpenVpnCustomProfiles []OpenVPNCustomProfile
+
+	// IsConnectionHistory - enable the locally stored connection audit log
+	// (privacy-sensitive, therefore strictly opt-in; disabled by default)
+	IsConnectionHistory bool
+
+	// Local SOCKS5 proxy: applications configured to use the proxy get an explicit
+	// routing of their traffic regardless of the split-tunneling configuration
+	// (SocksProxyMode: 'vpn' - always through the tunnel; 'bypass' - always outside of it)
+	IsSocksProxy   bool
+	SocksProxyPort int    // 0 - default port (1080)
+	SocksProxyMode string // 'vpn' (default) or 'bypass'
+
+	// Local HTTP\HTTPS proxy bound to the tunnel + PAC file generation
+	// (HTTPProxyPacDomains - domains routed through the VPN according to the PAC file;
+	// empty - all domains)
+	IsHTTPProxy         bool
+	HTTPProxyPort       int // 0 - default port (8118)
+	HTTPProxyPacDomains []string
+
+	// Data-cap alerts for metered connections (e.g. LTE\hotspot):
+	// clients are notified when the amount of data transferred through the VPN
+	// during the current day\month reaches the limit (0 - limit disabled)
+	DataCapDailyLimitMB   int
+	DataCapMonthlyLimitMB int
+	// IsDataCapPauseOnLimit - also pause the VPN connection when a data-cap limit is reached
+	IsDataCapPauseOnLimit bool
+
+	// last known account status (refreshed by the periodic session check)
+	Account AccountStatus
 	Session SessionStatus
 }
 
@@ -68,6 +234,18 @@ func (p *Preferences) SetSession(accountID string,
 	wgLocalIP string) {
 
 	p.setSession(accountID, session, vpnUser, vpnPass, wgPublicKey, wgPrivateKey, wgLocalIP)
+
+	// erase the last known account status on logout
+	if len(session) == 0 {
+		p.Account = AccountStatus{}
+	}
+
+	p.SavePreferences()
+}
+
+// UpdateAccountStatus save the last known account status
+func (p *Preferences) UpdateAccountStatus(account AccountStatus) {
+	p.Account = account
 	p.SavePreferences()
 }
 
@@ -89,6 +267,14 @@ func (p *Preferences) SavePreferences() error {
 
 // LoadPreferences loads preferences
 func (p *Preferences) LoadPreferences() error {
+	// default values for the parameters which can be missing in preferences file
+	// (missing JSON fields are keeping these values untouched)
+	p.IsAutoReconnect = true
+	p.IsFwBlockDNS = true
+	p.IsFwAllowApiServers = true
+	p.IsConnectionFallback = true
+	p.WireGuardHandshakeTimeout = 180
+
 	data, err := ioutil.ReadFile(platform.SettingsFile())
 
 	if err != nil {
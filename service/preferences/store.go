@@ -0,0 +1,106 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Store - concurrent-safe access to the service preferences.
+// The preferences are mutated from multiple goroutines (protocol requests,
+// WG keys rotation, session checker...), therefore the raw Preferences object
+// must not be shared: readers obtain a copy ('GetSnapshot') and all mutations
+// go through the 'Modify' callback which is executed under the lock.
+//
+// Note: the snapshot is a shallow copy - slice fields must be replaced,
+// never modified in-place (the rule all existing call sites already follow)
+type Store struct {
+	mutex sync.RWMutex
+	prefs Preferences
+}
+
+// GetSnapshot returns a copy of the current preferences
+func (s *Store) GetSnapshot() Preferences {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.prefs
+}
+
+// Modify applies the 'modifier' callback to the preferences under the lock.
+// When the callback changed anything - the preferences are saved to disk
+func (s *Store) Modify(modifier func(p *Preferences)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	old := s.prefs
+	modifier(&s.prefs)
+
+	if reflect.DeepEqual(old, s.prefs) == false {
+		if err := s.prefs.SavePreferences(); err != nil {
+			log.Error("Failed to save preferences: ", err)
+		}
+	}
+}
+
+// LoadPreferences reads the preferences from disk
+func (s *Store) LoadPreferences() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.prefs.LoadPreferences()
+}
+
+// SavePreferences writes the preferences to disk
+func (s *Store) SavePreferences() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.prefs.SavePreferences()
+}
+
+// SetSession save account credentials
+func (s *Store) SetSession(accountID string,
+	session string,
+	vpnUser string,
+	vpnPass string,
+	wgPublicKey string,
+	wgPrivateKey string,
+	wgLocalIP string) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prefs.SetSession(accountID, session, vpnUser, vpnPass, wgPublicKey, wgPrivateKey, wgLocalIP)
+}
+
+// UpdateAccountStatus save the last known account status
+func (s *Store) UpdateAccountStatus(account AccountStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prefs.UpdateAccountStatus(account)
+}
+
+// UpdateWgCredentials save wireguard credentials
+func (s *Store) UpdateWgCredentials(wgPublicKey string, wgPrivateKey string, wgLocalIP string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prefs.UpdateWgCredentials(wgPublicKey, wgPrivateKey, wgLocalIP)
+}
@@ -0,0 +1,40 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package preferences
+
+// WGCustomProfile - imported custom (non-IVPN) WireGuard connection profile
+// (allows to use the daemon as a general WireGuard client for self-hosted servers)
+type WGCustomProfile struct {
+	Name string
+
+	// [Interface] section of the imported configuration
+	PrivateKey string // redacted in protocol responses
+	Address    string // client address inside the tunnel (IP or CIDR notation)
+	DNS        string // DNS server to use while connected (empty - DNS of the peer gateway)
+
+	// [Peer] section of the imported configuration
+	PublicKey           string
+	Endpoint            string // 'host:port' (the host can be a domain name)
+	AllowedIPs          []string
+	PersistentKeepalive int // seconds (0 - not defined)
+}
@@ -0,0 +1,83 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/service/dns"
+	"github.com/ivpn/desktop-app-daemon/service/firewall"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+)
+
+// recoverFromUncleanShutdown - detect and clean leftovers of a previous unclean daemon shutdown
+// (e.g. daemon crash during an active VPN connection):
+// - stale WireGuard tunnel interface;
+// - enabled firewall (when the persistent firewall configuration is not used);
+// - modified DNS configuration.
+// Must be called on daemon start before accepting client commands.
+// Clients are notified about the performed repairs (if any).
+func (s *Service) recoverFromUncleanShutdown() {
+	repairedItems := make([]string, 0, 3)
+
+	// stale WireGuard tunnel interface
+	// (the interface survives the daemon process; a leftover interface keeps routes pointing to a dead tunnel)
+	wgConfigPath := platform.WGConfigFilePath()
+	wgInterfaceName := strings.TrimSuffix(filepath.Base(wgConfigPath), filepath.Ext(wgConfigPath))
+	if iface, _ := net.InterfaceByName(wgInterfaceName); iface != nil {
+		log.Warning(fmt.Sprintf("Stale WireGuard interface detected on daemon start: '%s' (leftover of an unclean shutdown). Removing it...", wgInterfaceName))
+		if err := implRemoveStaleWireGuardInterface(wgInterfaceName); err != nil {
+			log.Error("Failed to remove stale WireGuard interface: ", err)
+		} else {
+			repairedItems = append(repairedItems, "stale WireGuard tunnel interface removed")
+		}
+	}
+
+	// firewall left enabled (when user did not request the persistent firewall configuration)
+	if isEnabled, err := firewall.GetEnabled(); err == nil && isEnabled && !s.Preferences().IsFwPersistant {
+		log.Warning("Firewall is enabled on daemon start but the persistent configuration is not used (leftover of an unclean shutdown). Disabling it...")
+		if err := firewall.SetEnabled(false); err != nil {
+			log.Error("Failed to disable firewall: ", err)
+		} else {
+			repairedItems = append(repairedItems, "firewall disabled")
+		}
+	}
+
+	// DNS configuration left modified (manual DNS was not restored on shutdown)
+	if isRestored, err := dns.IsRestored(); err == nil && !isRestored {
+		log.Warning("DNS configuration is modified on daemon start (leftover of an unclean shutdown). Restoring it...")
+		if err := dns.Repair(); err != nil {
+			log.Error("Failed to restore DNS configuration: ", err)
+		} else {
+			repairedItems = append(repairedItems, "DNS configuration restored")
+		}
+	}
+
+	if len(repairedItems) > 0 {
+		log.Info("Recovered from an unclean shutdown: ", strings.Join(repairedItems, "; "))
+		s._evtReceiver.OnRecoveredFromCrash(repairedItems)
+	}
+}
@@ -23,6 +23,7 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -40,6 +41,9 @@ type serversUpdater struct {
 	servers           *types.ServersInfoResponse
 	api               *api.API
 	updatedNotifyChan chan struct{}
+	// true when the current servers list came from a signature-verified download
+	// (false - the list was loaded from the local cache file)
+	isSignatureVerified bool
 }
 
 // CreateServersUpdater - constructor for serversUpdater object
@@ -108,9 +112,21 @@ func (s *serversUpdater) updateServers() (*types.ServersInfoResponse, error) {
 	if err != nil {
 		return servers, fmt.Errorf("failed to download servers list: %w", err)
 	}
+
+	// skip the update when nothing changed since the previous version
+	// (no cache re-write, no client notifications)
+	if s.servers != nil {
+		oldData, errOld := json.Marshal(s.servers)
+		newData, errNew := json.Marshal(servers)
+		if errOld == nil && errNew == nil && bytes.Equal(oldData, newData) {
+			return s.servers, nil
+		}
+	}
+
 	log.Info(fmt.Sprintf("Updated servers info (%d OpenVPN; %d WireGuard)\n", len(servers.OpenvpnServers), len(servers.WireguardServers)))
 
 	s.servers = servers
+	s.isSignatureVerified = true // signature is verified during download (see api.DownloadServersList)
 	if err := writeServersToCache(servers); err != nil {
 		log.Error("failed to save servers cache file: ", err)
 	}
@@ -130,6 +146,12 @@ func (s *serversUpdater) UpdateNotifierChannel() chan struct{} {
 	return s.updatedNotifyChan
 }
 
+// IsSignatureVerified - true when the current servers list came from a signature-verified
+// download (false - the list was loaded from the local cache file and was not re-verified)
+func (s *serversUpdater) IsSignatureVerified() bool {
+	return s.isSignatureVerified
+}
+
 func readServersFromCache() (svrs *types.ServersInfoResponse, apiIPs []string, e error) {
 
 	serversFile := platform.ServersFile()
@@ -24,7 +24,7 @@ package service
 
 import (
 	"bufio"
-	"errors"
+	"context"
 	"fmt"
 	"net"
 	"os"
@@ -33,17 +33,22 @@ import (
 	"sync"
 	"time"
 
-	"github.com/ivpn/desktop-app-daemon/api"
 	"github.com/ivpn/desktop-app-daemon/api/types"
 	"github.com/ivpn/desktop-app-daemon/helpers"
 	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/metrics"
 	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/obfsproxy"
+	"github.com/ivpn/desktop-app-daemon/obfuscation"
 	"github.com/ivpn/desktop-app-daemon/ping"
 	"github.com/ivpn/desktop-app-daemon/service/dns"
+	"github.com/ivpn/desktop-app-daemon/service/dnsproxy"
 	"github.com/ivpn/desktop-app-daemon/service/firewall"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
 	"github.com/ivpn/desktop-app-daemon/service/platform/filerights"
 	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/service/splittun"
+	"github.com/ivpn/desktop-app-daemon/sysevents"
 	"github.com/ivpn/desktop-app-daemon/vpn"
 	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
 	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
@@ -68,38 +73,102 @@ const (
 const (
 	// SessionCheckInterval - the interval for periodical check session status
 	SessionCheckInterval time.Duration = time.Hour * 6
+
+	// defaultPingMaxConcurrent - default limit of hosts pinged in parallel (see PingServers)
+	defaultPingMaxConcurrent = 16
+
+	// accountExpiryWarningPeriod - notify clients when the subscription
+	// expires in less than this period (and auto-rebill is off)
+	accountExpiryWarningPeriod = time.Hour * 24 * 3
+
+	// defaultMetricsPort - default TCP port of the observability metrics endpoint
+	// (localhost-only; started when the 'metrics' preference is enabled)
+	defaultMetricsPort = 16289
 )
 
 // Service - IVPN service
 type Service struct {
 	_evtReceiver       IServiceEventsReceiver
-	_api               *api.API
+	_api               IAPIClient
 	_serversUpdater    IServersUpdater
 	_netChangeDetector INetChangeDetector
 	_wgKeysMgr         IWgKeysManager
+	_portFwdMgr        IPortForwardManager
+	_sysEventsMonitor  ISystemEventsMonitor
 	_vpn               vpn.Process
-	_preferences       preferences.Preferences
-	_connectMutex      sync.Mutex
+	// VPN process factory (nil - the production implementation; see vpn_launcher.go)
+	_vpnLauncher  IVpnLauncher
+	_preferences  preferences.Store
+	_connectMutex sync.Mutex
 
 	// manual DNS value (if not defined - nil)
 	_manualDNS net.IP
 
+	// client address inside the tunnel (nil - not connected);
+	// in use by the local SOCKS5 proxy to bind proxied connections to the tunnel
+	_tunnelLocalIP net.IP
+
+	// hostname-based kill-switch exceptions: background re-resolution state
+	// (see firewall_hostname_exceptions.go)
+	_hostnameExceptionsMutex             sync.Mutex
+	_isHostnameExceptionsResolverStarted bool
+	_appliedUserExceptionsSnapshot       string
+
 	// Required VPN state which service is going to reach (disconnect->keep connection->connect)
 	// When KeepConnection - reconnects immediately after disconnection
 	_requiredVpnState RequiredState
 
+	// 'true' when the last VPN process exit was caused by an authentication failure
+	// (credential errors are not recoverable by retrying - automatic reconnection is canceled)
+	_lastVpnAuthError bool
+
 	// Note: Disconnect() function will wait until VPN fully disconnects
 	_done chan struct{}
 
 	_isServersPingInProgress bool
 
+	// results of the last successful servers pinging (host -> ping time in milliseconds)
+	_pingResults      map[string]int
+	_pingResultsMutex sync.Mutex
+
+	// connection audit log (opt-in functionality; see connection_history.go)
+	_connHistory         []preferences.ConnectionHistoryEntry
+	_isConnHistoryLoaded bool
+	_connHistoryMutex    sync.Mutex
+
+	// usage accounting: per-day\per-server traffic buckets (see usage_stats.go)
+	_usageStats         []preferences.UsageStatsBucket
+	_isUsageStatsLoaded bool
+	_usageStatsMutex    sync.Mutex
+	// day\month for which the data-cap alert was already sent (see checkDataCapLimits)
+	_dataCapNotifiedDay   string
+	_dataCapNotifiedMonth string
+
+	// cached result of the last geo-lookup request (nil - not requested yet or invalidated)
+	// The cache is invalidated on each connection state change (the external IP changes)
+	_geoLookupCache *types.GeoLookupResponse
+	_geoLookupMutex sync.Mutex
+
 	// nil - when session checker stopped
 	// to stop -> write to channel (it is synchronous channel)
 	_sessionCheckerStopChn chan struct{}
+
+	// daemon version which the clients were already notified about
+	// (empty - no update notifications were sent yet; see updater.go)
+	_lastNotifiedUpdateVersion string
+
+	// SSID of the last known Wi-Fi network (trusted\untrusted networks functionality)
+	_lastKnownSSID string
+
+	// when defined - connection is paused and automatic resume is scheduled
+	_resumeTimer *time.Timer
+
+	// true when all IPv6 traffic have to be blocked while connected (tunnel is IPv4-only)
+	_isBlockIPv6Required bool
 }
 
 // CreateService - service constructor
-func CreateService(evtReceiver IServiceEventsReceiver, api *api.API, updater IServersUpdater, netChDetector INetChangeDetector, wgKeysMgr IWgKeysManager) (*Service, error) {
+func CreateService(evtReceiver IServiceEventsReceiver, api IAPIClient, updater IServersUpdater, netChDetector INetChangeDetector, wgKeysMgr IWgKeysManager, portFwdMgr IPortForwardManager, sysEventsMonitor ISystemEventsMonitor) (*Service, error) {
 	if updater == nil {
 		return &Service{}, fmt.Errorf("ServersUpdater is not defined")
 	}
@@ -109,7 +178,9 @@ func CreateService(evtReceiver IServiceEventsReceiver, api *api.API, updater ISe
 		_api:               api,
 		_serversUpdater:    updater,
 		_netChangeDetector: netChDetector,
-		_wgKeysMgr:         wgKeysMgr}
+		_wgKeysMgr:         wgKeysMgr,
+		_portFwdMgr:        portFwdMgr,
+		_sysEventsMonitor:  sysEventsMonitor}
 
 	if err := serv.init(); err != nil {
 		return nil, fmt.Errorf("service initialization error : %w", err)
@@ -125,32 +196,103 @@ func (s *Service) init() error {
 		log.Warning("Saving default values for preferences")
 		s._preferences.SavePreferences()
 	}
+	prefs := s._preferences.GetSnapshot()
 
 	if err := dns.Initialize(); err != nil {
 		log.Error(fmt.Sprintf("failed to initialize DNS : %s", err))
 	}
 
+	// notify clients about API reachability changes
+	s._api.SetReachabilityNotifier(func(isReachable bool) {
+		s._evtReceiver.OnAPIReachabilityChanged(isReachable)
+	})
+
 	if err := firewall.Initialize(); err != nil {
 		return fmt.Errorf("service initialization error : %w", err)
 	}
 
+	// detect and clean leftovers of a previous unclean daemon shutdown (if any)
+	s.recoverFromUncleanShutdown()
+
 	// Logging mus be already initialized (by launcher). Do nothing here.
 	// Init logger (if not initialized before)
-	//logger.Enable(s._preferences.IsLogging)
+	//logger.Enable(prefs.IsLogging)
 
 	// Init firewall
-	if err := firewall.AllowLAN(s._preferences.IsFwAllowLAN, s._preferences.IsFwAllowLANMulticast); err != nil {
+	if err := firewall.AllowLAN(prefs.IsFwAllowLAN, prefs.IsFwAllowLANMulticast); err != nil {
 		log.Error("Failed to initialize firewall with AllowLAN preference value: ", err)
 	}
 
-	if s._preferences.IsFwPersistant {
+	if prefs.IsFwSoftMode {
+		if err := firewall.SetSoftMode(true); err != nil {
+			log.Error("Failed to initialize firewall with soft-mode preference value: ", err)
+		}
+	}
+
+	if prefs.IsFwAllowServiceDiscovery {
+		if err := firewall.AllowServiceDiscovery(true); err != nil {
+			log.Error("Failed to initialize firewall with service discovery preference value: ", err)
+		}
+	}
+
+	if len(prefs.FwLanAllowedAddresses) > 0 {
+		if err := firewall.SetLanAllowedAddresses(prefs.FwLanAllowedAddresses); err != nil {
+			log.Error("Failed to apply allowed LAN addresses: ", err)
+		}
+	}
+
+	if len(prefs.FwUserExceptions) > 0 {
+		if err := s.SetKillSwitchUserExceptions(prefs.FwUserExceptions); err != nil {
+			log.Error("Failed to apply firewall user exceptions: ", err)
+		}
+	}
+
+	if err := firewall.SetDNSLeakProtection(prefs.IsFwBlockDNS); err != nil {
+		log.Error("Failed to initialize firewall with DNS leak protection preference value: ", err)
+	}
+
+	// apply the API access policy (firewall exception for the known API server addresses)
+	// (in background: obtaining servers info can require a download when the local cache is not available)
+	go s.applyApiServersExceptions(prefs.IsFwAllowApiServers)
+
+	// start local SOCKS5 proxy (when enabled by preferences)
+	if prefs.IsSocksProxy {
+		if err := s.applySocksProxyPreference(); err != nil {
+			log.Error("Failed to start SOCKS5 proxy: ", err)
+		}
+	}
+
+	// start local HTTP proxy (when enabled by preferences)
+	if prefs.IsHTTPProxy {
+		if err := s.applyHTTPProxyPreference(); err != nil {
+			log.Error("Failed to start HTTP proxy: ", err)
+		}
+	}
+
+	if prefs.IsFwPersistant {
 		log.Info("Enabling firewal (persistant configuration)")
 		if err := firewall.SetPersistant(true); err != nil {
 			log.Error("Failed to enable firewall: ", err)
 		}
 	}
 
+	// Start firewall watchdog: re-applies the kill-switch rules if they were
+	// flushed by another application (e.g. ufw, firewalld) and notifies clients about it
+	firewall.StartWatchdog(func() {
+		s._evtReceiver.OnKillSwitchReapplied()
+	})
+
+	// Start system events monitor: sleep\wake and network configuration changes
+	// (do not wait until traffic fails; proactively refresh tunnel\keys\session\firewall)
+	s.startSystemEventsProcessor()
+
 	// start WireGuard keys rotation
+	if s._portFwdMgr != nil {
+		if err := s._portFwdMgr.Init(s); err != nil {
+			log.Error("Failed to initialize port-forwarding manager:", err)
+		}
+	}
+
 	if err := s._wgKeysMgr.Init(s); err != nil {
 		log.Error("Failed to initialize WG keys rotation:", err)
 	} else {
@@ -164,9 +306,138 @@ func (s *Service) init() error {
 	// Start session status checker
 	s.startSessionChecker()
 
+	// Start monitoring of the current Wi-Fi network (trusted\untrusted networks functionality)
+	s.startNetworkTrustMonitor()
+
+	// Start periodic check for available updates of the daemon and the bundled binaries
+	s.startUpdateChecker()
+
+	// Start the observability metrics endpoint (if enabled by preferences)
+	if prefs.IsMetrics {
+		s.applyMetricsPreference(true, prefs.MetricsPort)
+	}
+
+	// Initialize split-tunneling and restore saved configuration
+	if err := splittun.Initialize(); err != nil {
+		log.Error("Failed to initialize split-tunneling: ", err)
+	} else {
+		if prefs.IsSplitTunnel {
+			if err := splittun.ApplyConfig(true, prefs.IsSplitTunnelInverse, prefs.SplitTunnelApps); err != nil {
+				log.Error("Failed to restore split-tunneling configuration: ", err)
+			}
+		}
+		s.startSplitTunnelMonitor()
+	}
+
 	return nil
 }
 
+// startSystemEventsProcessor - start receiving system events (sleep\wake, network changes)
+// and processing them (in a separate goroutine)
+func (s *Service) startSystemEventsProcessor() {
+	if s._sysEventsMonitor == nil {
+		return
+	}
+
+	eventsChan := make(chan sysevents.Event, 1)
+	if err := s._sysEventsMonitor.Start(eventsChan); err != nil {
+		log.Error("Failed to start system events monitor: ", err)
+		return
+	}
+
+	go func() {
+		for evt := range eventsChan {
+			switch evt {
+			case sysevents.Wake:
+				s.onSystemWake()
+			case sysevents.NetworkChange:
+				s.onSystemNetworkChange()
+			}
+		}
+	}()
+}
+
+// onSystemWake - catch-up actions after the system resumed from sleep\hibernation
+func (s *Service) onSystemWake() {
+	log.Info("Processing system wake event...")
+
+	// firewall rules could be flushed while the system was sleeping
+	if isReapplied := firewall.RecheckAndReapply(); isReapplied {
+		s._evtReceiver.OnKillSwitchReapplied()
+	}
+
+	// the scheduled WG keys rotation could be missed during sleep
+	if err := s._wgKeysMgr.UpdateKeysIfNecessary(); err != nil {
+		log.Error("Failed to check WG keys rotation after wake: ", err)
+	}
+
+	// session could be revoked\expired while the system was sleeping
+	go s.RequestSessionStatus()
+
+	// the external IP (and location) is probably not the same anymore
+	s.invalidateGeoLookupCache()
+
+	// tunnel handshakes\endpoints can be stale after a long sleep;
+	// when connection have to be kept - refresh it
+	if s._requiredVpnState == KeepConnection && s.Connected() {
+		log.Info("Reconnecting after system wake...")
+		go s.reconnect()
+	}
+}
+
+// onSystemNetworkChange - catch-up actions after the network configuration change
+// (routing changes of an active connection are processed separately by INetChangeDetector)
+func (s *Service) onSystemNetworkChange() {
+	// the external IP could change - the cached geo-lookup info is not actual anymore
+	s.invalidateGeoLookupCache()
+
+	// verify that the firewall rules survived the network reconfiguration
+	if isReapplied := firewall.RecheckAndReapply(); isReapplied {
+		s._evtReceiver.OnKillSwitchReapplied()
+	}
+}
+
+// PrepareToStop - graceful shutdown of the service (daemon is going to exit):
+// stop background activities, cleanly disconnect VPN, restore DNS configuration
+// and flush preferences. The firewall stays enabled only when the persistent
+// configuration is requested
+func (s *Service) PrepareToStop() {
+	log.Info("Service is going to stop. Preparing...")
+
+	// stop background activities
+	s._wgKeysMgr.StopKeysRotation()
+	go s.stopSessionChecker()
+	if s._sysEventsMonitor != nil {
+		s._sysEventsMonitor.Stop()
+	}
+
+	// cleanly disconnect VPN (if connected)
+	// Disconnect() waits until the VPN is fully disconnected
+	if err := s.Disconnect(); err != nil {
+		log.Error("Failed to disconnect VPN: ", err)
+	}
+
+	// disable the kill-switch when the persistent configuration is not requested
+	if s.Preferences().IsFwPersistant == false {
+		if err := firewall.SetEnabled(false); err != nil {
+			log.Error("Failed to disable firewall: ", err)
+		}
+	}
+
+	// ensure the system DNS configuration is restored
+	if restored, err := dns.IsRestored(); err == nil && restored == false {
+		log.Warning("System DNS configuration was not restored. Repairing...")
+		if err := dns.Repair(); err != nil {
+			log.Error("Failed to repair DNS configuration: ", err)
+		}
+	}
+
+	// flush preferences
+	s._preferences.SavePreferences()
+
+	log.Info("Service stopped")
+}
+
 // OnControlConnectionClosed - Perform reqired operations when protocol (controll channel with UI application) was closed
 // (for example, we must disable firewall (if it not persistant))
 // Must be called by protocol object
@@ -174,9 +445,10 @@ func (s *Service) init() error {
 // - isServiceMustBeClosed: true informing that service have to be closed ("Stop IVPN Agent when application is not running" feature)
 // - err: error
 func (s *Service) OnControlConnectionClosed() (isServiceMustBeClosed bool, err error) {
-	isServiceMustBeClosed = s._preferences.IsStopOnClientDisconnect
+	prefs := s.Preferences()
+	isServiceMustBeClosed = prefs.IsStopOnClientDisconnect
 	// disable firewall if it not persistant
-	if !s._preferences.IsFwPersistant {
+	if !prefs.IsFwPersistant {
 		log.Info("Control connection was closed. Disabling firewall.")
 		err = firewall.SetEnabled(false)
 	}
@@ -188,48 +460,177 @@ func (s *Service) ServersList() (*types.ServersInfoResponse, error) {
 	return s._serversUpdater.GetServers()
 }
 
-// ServersUpdateNotifierChannel returns channel which is notifying when servers was updated
-func (s *Service) ServersUpdateNotifierChannel() chan struct{} {
-	return s._serversUpdater.UpdateNotifierChannel()
+// ServerPorts returns available server connection ports (per VPN protocol).
+// Ports are taken from the servers metadata; when the metadata does not define them -
+// the default ports are returned
+func (s *Service) ServerPorts() (openvpnPorts []types.PortInfo, wireguardPorts []types.PortInfo) {
+	if servers, err := s.ServersList(); err == nil && servers != nil {
+		openvpnPorts = servers.Config.Ports.OpenVPN
+		wireguardPorts = servers.Config.Ports.WireGuard
+	}
+
+	if len(openvpnPorts) == 0 {
+		openvpnPorts = []types.PortInfo{
+			{Type: "UDP", Port: 2049},
+			{Type: "UDP", Port: 2050},
+			{Type: "UDP", Port: 53},
+			{Type: "TCP", Port: 443},
+			{Type: "TCP", Port: 1443},
+			{Type: "TCP", Port: 80}}
+	}
+	if len(wireguardPorts) == 0 {
+		wireguardPorts = []types.PortInfo{
+			{Type: "UDP", Port: 2049},
+			{Type: "UDP", Port: 2050},
+			{Type: "UDP", Port: 53},
+			{Type: "UDP", Port: 30587}}
+	}
+
+	return openvpnPorts, wireguardPorts
 }
 
-// GetDisabledFunctions returns info about functions which are disabled
-// Some functionality can be not accessible
-// It can happen, for example, if some external binaries not installed
-// (e.g. obfsproxy or WireGuard on Linux)
-func (s *Service) GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error) {
-	if err := filerights.CheckFileAccessRightsExecutable(platform.OpenVpnBinaryPath()); err != nil {
-		ovpnErr = fmt.Errorf("OpenVPN binary: %w", err)
+// SetPreferredPort save preferred server port\protocol combination for a VPN protocol.
+// The combination is validated against the available server ports (see 'ServerPorts')
+// and applied when a 'Connect' request does not define a destination port
+// (port == 0 - erase the preference)
+func (s *Service) SetPreferredPort(vpnType vpn.Type, port int, isTCP bool) error {
+	prefs := s._preferences.GetSnapshot()
+
+	portType := "UDP"
+	if isTCP {
+		portType = "TCP"
 	}
 
-	if err := filerights.CheckFileAccessRightsExecutable(platform.ObfsproxyStartScript()); err != nil {
-		obfspErr = fmt.Errorf("obfsproxy binary: %w", err)
+	switch vpnType {
+	case vpn.OpenVPN:
+		prefs.PreferredPortOpenVpn = ""
+	case vpn.WireGuard:
+		prefs.PreferredPortWireGuard = ""
+	default:
+		return fmt.Errorf("unexpected VPN type: %v", vpnType)
 	}
 
-	if err := filerights.CheckFileAccessRightsExecutable(platform.WgBinaryPath()); err != nil {
-		wgErr = fmt.Errorf("WireGuard binary: %w", err)
-	} else {
-		if err := filerights.CheckFileAccessRightsExecutable(platform.WgToolBinaryPath()); err != nil {
-			wgErr = fmt.Errorf("WireGuard tools binary: %w", err)
+	if port > 0 {
+		ovpnPorts, wgPorts := s.ServerPorts()
+		availablePorts := ovpnPorts
+		if vpnType == vpn.WireGuard {
+			availablePorts = wgPorts
+		}
+
+		isKnownPort := false
+		for _, p := range availablePorts {
+			if p.Port == port && strings.EqualFold(p.Type, portType) {
+				isKnownPort = true
+				break
+			}
 		}
+		if isKnownPort == false {
+			return fmt.Errorf("port %s:%d is not applicable for the VPN protocol", portType, port)
+		}
+
+		if vpnType == vpn.OpenVPN {
+			prefs.PreferredPortOpenVpn = fmt.Sprintf("%s:%d", portType, port)
+		} else {
+			prefs.PreferredPortWireGuard = fmt.Sprintf("%s:%d", portType, port)
+		}
+	}
+
+	s.setPreferences(prefs)
+	return nil
+}
+
+// parsePreferredPort parses preferred port value stored in preferences ('PROTOCOL:PORT')
+// (0 is returned when the value is empty or malformed)
+func parsePreferredPort(val string) (port int, isTCP bool) {
+	cols := strings.Split(val, ":")
+	if len(cols) != 2 {
+		return 0, false
 	}
+	port, err := strconv.Atoi(cols[1])
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, false
+	}
+	return port, strings.EqualFold(cols[0], "TCP")
+}
+
+// ServersUpdateNotifierChannel returns channel which is notifying when servers was updated
+func (s *Service) ServersUpdateNotifierChannel() chan struct{} {
+	return s._serversUpdater.UpdateNotifierChannel()
+}
+
+// ServersSignatureVerified - true when the current servers list came from a signature-verified
+// download (false - the list was loaded from the local cache file)
+func (s *Service) ServersSignatureVerified() bool {
+	return s._serversUpdater.IsSignatureVerified()
+}
+
+// SessionToken - current session token (in use by the port-forwarding manager)
+func (s *Service) SessionToken() string {
+	return s.Preferences().Session.Session
+}
 
-	if errors.Is(ovpnErr, os.ErrNotExist) {
-		ovpnErr = fmt.Errorf("%w. Please install OpenVPN", ovpnErr)
+// PortForwardAdd - allocate a new forwarded port for the current session
+func (s *Service) PortForwardAdd() (int, error) {
+	if s._portFwdMgr == nil {
+		return 0, fmt.Errorf("port-forwarding manager is not initialized")
+	}
+	if s.isAccountCapabilityAvailable("port-forwarding") == false {
+		return 0, fmt.Errorf("port-forwarding is not available for the current subscription plan")
 	}
-	if errors.Is(obfspErr, os.ErrNotExist) {
-		obfspErr = fmt.Errorf("%w. Please install obfsproxy binary", obfspErr)
+	return s._portFwdMgr.Add()
+}
+
+// PortForwardRemove - release a forwarded port
+func (s *Service) PortForwardRemove(port int) error {
+	if s._portFwdMgr == nil {
+		return fmt.Errorf("port-forwarding manager is not initialized")
 	}
-	if errors.Is(wgErr, os.ErrNotExist) {
-		wgErr = fmt.Errorf("%w. Please install WireGuard", wgErr)
+	return s._portFwdMgr.Remove(port)
+}
+
+// PortForwardList - forwarded ports of the current session
+func (s *Service) PortForwardList() []int {
+	if s._portFwdMgr == nil {
+		return nil
 	}
+	return s._portFwdMgr.List()
+}
 
-	return wgErr, ovpnErr, obfspErr
+// GetDisabledFunctions returns info about functions which are disabled
+// Some functionality can be not accessible
+// It can happen, for example, if some external binaries not installed
+// (e.g. obfsproxy or WireGuard on Linux)
+func (s *Service) GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error) {
+	return s.vpnLauncher().GetDisabledFunctions()
 }
 
 // ConnectOpenVPN start OpenVPN connection
+// openVpnFallbackSteps - automatic transport\port fallback ladder for OpenVPN:
+// applied (in order) when a connection attempt fails
+// (helps to connect from behind restrictive networks without manually cycling ports)
+var openVpnFallbackSteps = []struct {
+	port      int
+	tcp       bool
+	obfsproxy bool
+}{
+	{port: 2049, tcp: false},
+	{port: 53, tcp: false},
+	{port: 443, tcp: true},
+	{port: 443, tcp: true, obfsproxy: true}}
+
 func (s *Service) ConnectOpenVPN(connectionParams openvpn.ConnectionParams, manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error {
 
+	// when true - obfsproxy is forced by the connection fallback (the last fallback step)
+	isObfsproxyFallback := false
+
+	// apply the coexistence policy when another VPN is active
+	// (the 'nested' policy is not implemented for OpenVPN - the takeover behavior is used)
+	if nestedVpnInterface, err := s.checkVpnCoexistence(); err != nil {
+		return err
+	} else if len(nestedVpnInterface) > 0 {
+		log.Warning("The 'nested' VPN coexistence policy is not supported for OpenVPN connections. Taking over the default route.")
+	}
+
 	createVpnObjfunc := func() (vpn.Process, error) {
 		prefs := s.Preferences()
 
@@ -238,12 +639,20 @@ func (s *Service) ConnectOpenVPN(connectionParams openvpn.ConnectionParams, manu
 		if ovpnErr != nil {
 			return nil, ovpnErr
 		}
-		if prefs.IsObfsproxy == true && obfspErr != nil {
+		if (prefs.IsObfsproxy == true || isObfsproxyFallback) && obfspErr != nil {
 			return nil, obfspErr
 		}
 
 		connectionParams.SetCredentials(prefs.Session.OpenVPNUser, prefs.Session.OpenVPNPass)
 
+		// apply per-IP\subnet split-tunneling rules (if defined)
+		connectionParams.SetCustomRoutes(prefs.SplitTunnelBypassCIDRs, prefs.SplitTunnelVpnCIDRs)
+
+		// preferred port\protocol combination (applied only when the request does not define a port)
+		if port, isTCP := parsePreferredPort(prefs.PreferredPortOpenVpn); port > 0 {
+			connectionParams.SetPreferredPort(port, isTCP)
+		}
+
 		openVpnExtraParameters := ""
 		// read user-defined extra parameters for OpenVPN configuration (if exists)
 		extraParamsFile := platform.OpenvpnUserParamsFile()
@@ -293,22 +702,84 @@ func (s *Service) ConnectOpenVPN(connectionParams openvpn.ConnectionParams, manu
 			}
 		}
 
+		// user-defined extra directives from daemon preferences (advanced users)
+		if len(prefs.OpenVpnExtraParameters) > 0 {
+			openVpnExtraParameters = openVpnExtraParameters + "\n" + prefs.OpenVpnExtraParameters
+		}
+
+		// safety validation (defense in depth: parameters were already validated when defined)
+		// e.g. 'script-security' or 'up'\'down' overrides are never written into the config file
+		filteredParams, rejected := openvpn.FilterExtraParameters(openVpnExtraParameters)
+		if len(rejected) > 0 {
+			log.Warning("NOTE! Forbidden user-defined OpenVPN directives ignored: ", strings.Join(rejected, ", "))
+		}
+		openVpnExtraParameters = filteredParams
+
+		// obfs4: bridge certificate of the destination server (from the servers list)
+		isObfs4 := ((prefs.IsObfsproxy || isObfsproxyFallback) && prefs.ObfsproxyVersion == 4) ||
+			connectionParams.ObfuscationType() == obfuscation.Obfs4
+		obfs4Cert := ""
+		if isObfs4 {
+			obfs4Cert = s.findOpenVpnObfs4Cert(connectionParams.HostIPs())
+		}
+
 		// creating OpenVPN object
-		vpnObj, err := openvpn.NewOpenVpnObject(
-			platform.OpenVpnBinaryPath(),
-			platform.OpenvpnConfigFile(),
-			platform.OpenvpnLogFile(),
-			prefs.IsObfsproxy,
-			openVpnExtraParameters,
-			connectionParams)
+		return s.vpnLauncher().NewOpenVPN(connectionParams, prefs.IsObfsproxy || isObfsproxyFallback, isObfs4, obfs4Cert, openVpnExtraParameters)
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to create new openVPN object: %w", err)
+	// IPv6 leak protection: when the tunnel is IPv4-only - block all IPv6 traffic
+	s.setBlockIPv6Required(connectionParams.IsIPv6() == false)
+
+	err := s.keepConnection(createVpnObjfunc, manualDNS, firewallDuringConnection, stateChan)
+	if err == nil {
+		return nil
+	}
+
+	// automatic transport\port fallback (can be disabled by preferences)
+	if s.Preferences().IsConnectionFallback == false {
+		return err
+	}
+	if _, ok := err.(ErrorConnectionCanceled); ok {
+		return err
+	}
+	if _, ok := err.(ErrorNotLoggedIn); ok {
+		return err
+	}
+
+	log.Info("Connection attempt failed. Trying automatic transport\\port fallback...")
+	for _, step := range openVpnFallbackSteps {
+		if step.obfsproxy {
+			if s.Preferences().IsObfsproxy {
+				continue // obfsproxy is already in use
+			}
+			if _, _, obfspErr := s.GetDisabledFunctions(); obfspErr != nil {
+				continue // obfsproxy functionality not accessible
+			}
+		}
+
+		proto := "UDP"
+		if step.tcp {
+			proto = "TCP"
+		}
+		description := fmt.Sprintf("Retrying over %s port %d", proto, step.port)
+		if step.obfsproxy {
+			description = fmt.Sprintf("Retrying over obfsproxy (%s port %d)", proto, step.port)
+		}
+		log.Info(description)
+		s.notifyConnectionProgress("connection_fallback", description)
+
+		connectionParams.SetPort(step.port, step.tcp)
+		isObfsproxyFallback = step.obfsproxy
+
+		if err = s.keepConnection(createVpnObjfunc, manualDNS, firewallDuringConnection, stateChan); err == nil {
+			return nil
+		}
+		if _, ok := err.(ErrorConnectionCanceled); ok {
+			return err
 		}
-		return vpnObj, nil
 	}
 
-	return s.keepConnection(createVpnObjfunc, manualDNS, firewallDuringConnection, stateChan)
+	return fmt.Errorf("unable to connect (automatic transport\\port fallback steps failed too): %w", err)
 }
 
 // ConnectWireGuard start WireGuard connection
@@ -331,37 +802,113 @@ func (s *Service) ConnectWireGuard(connectionParams wireguard.ConnectionParams,
 		// TODO: notify UI
 	}
 
+	// apply the coexistence policy when another VPN is active
+	nestedVpnInterface, err := s.checkVpnCoexistence()
+	if err != nil {
+		return err
+	}
+	if len(nestedVpnInterface) > 0 {
+		connectionParams.SetEndpointRouteInterface(nestedVpnInterface)
+	}
+
 	createVpnObjfunc := func() (vpn.Process, error) {
 		session := s.Preferences().Session
 
 		if session.IsWGCredentialsOk() == false {
-			return nil, fmt.Errorf("WireGuard credentials are not defined (please, regenerate WG credentials or re-login)")
+			return nil, ErrorWireGuardKeysNotGenerated{}
 		}
 
 		localip := net.ParseIP(session.WGLocalIP)
 		if localip == nil {
 			return nil, fmt.Errorf("error updating WG connection preferences (failed parsing local IP for WG connection)")
 		}
+
+		// check the assigned in-tunnel address for conflicts with existing local subnets
+		// (may request a new address assignment from the API - see 'wg_ip_conflict.go')
+		localip = s.resolveWGLocalIPConflict(localip)
+		// re-read the session: the credentials could be renewed during the conflict resolution
+		session = s.Preferences().Session
+
 		connectionParams.SetCredentials(session.WGPrivateKey, localip)
 
-		vpnObj, err := wireguard.NewWireGuardObject(
-			platform.WgBinaryPath(),
-			platform.WgToolBinaryPath(),
-			platform.WGConfigFilePath(),
-			connectionParams)
+		// apply per-IP\subnet split-tunneling rules (if defined)
+		connectionParams.SetCustomRoutes(s.Preferences().SplitTunnelBypassCIDRs)
 
-		if err != nil {
-			return nil, fmt.Errorf("failed to create new WireGuard object: %w", err)
+		// partial tunneling: user-defined AllowedIPs (a request value has a priority)
+		if len(connectionParams.CustomAllowedIPs()) == 0 && len(s.Preferences().WireGuardAllowedIPs) > 0 {
+			connectionParams.SetCustomAllowedIPs(s.Preferences().WireGuardAllowedIPs)
+		}
+		// firewall: keep destinations outside of a partial tunnel reachable
+		if err := firewall.SetPartialTunnelNetworks(wireguard.NotCapturedNetworks(connectionParams.CustomAllowedIPs())); err != nil {
+			log.Error("Failed to apply partial-tunnel networks to firewall: ", err)
 		}
-		return vpnObj, nil
+
+		// tunnel MTU (0 - automatic path MTU probing)
+		connectionParams.SetMTU(s.Preferences().WireGuardMTU)
+		// persistent-keepalive interval and fixed local listen port (when defined)
+		connectionParams.SetKeepAlive(s.Preferences().WireGuardKeepAlive)
+		connectionParams.SetListenPort(s.Preferences().WireGuardListenPort)
+
+		// preferred connection port (applied only when the request does not define a port)
+		if port, _ := parsePreferredPort(s.Preferences().PreferredPortWireGuard); port > 0 {
+			connectionParams.SetPreferredPort(port)
+		}
+
+		// creating WireGuard object
+		return s.vpnLauncher().NewWireGuard(connectionParams)
 	}
 
+	// IPv6 leak protection: when the tunnel is IPv4-only - block all IPv6 traffic
+	s.setBlockIPv6Required(connectionParams.IsIPv6() == false)
+
 	return s.keepConnection(createVpnObjfunc, manualDNS, firewallDuringConnection, stateChan)
 }
 
+// findOpenVpnObfs4Cert - obfs4 bridge certificate of the server we are connecting to
+// (looked up in the servers list by the destination IP addresses)
+func (s *Service) findOpenVpnObfs4Cert(hostIPs []net.IP) string {
+	servers, err := s._serversUpdater.GetServers()
+	if err != nil {
+		log.Error("Failed to get servers list (obfs4 certificate lookup): ", err)
+		return ""
+	}
+
+	for _, svr := range servers.OpenvpnServers {
+		for _, ipStr := range svr.IPAddresses {
+			svrIP := net.ParseIP(ipStr)
+			if svrIP == nil {
+				continue
+			}
+			for _, hostIP := range hostIPs {
+				if svrIP.Equal(hostIP) {
+					return svr.Obfs4Cert
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+// setBlockIPv6Required - save and apply requirement to block all IPv6 traffic
+// (IPv6 leak protection for IPv4-only tunnels; temporary disabled while connection is paused)
+func (s *Service) setBlockIPv6Required(required bool) {
+	s._isBlockIPv6Required = required
+	if err := firewall.BlockIPv6(required); err != nil {
+		log.Error("Failed to change IPv6 block state: ", err)
+	}
+}
+
 func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error {
+	return s.keepConnectionEx(createVpnObj, manualDNS, firewallDuringConnection, stateChan, true)
+}
+
+// keepConnectionEx - same as keepConnection;
+// isSessionRequired == false - do not require an IVPN session
+// (custom (non-IVPN) profile connections use their own credentials)
+func (s *Service) keepConnectionEx(createVpnObj func() (vpn.Process, error), manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo, isSessionRequired bool) error {
 	prefs := s.Preferences()
-	if prefs.Session.IsLoggedIn() == false {
+	if isSessionRequired && prefs.Session.IsLoggedIn() == false {
 		return ErrorNotLoggedIn{}
 	}
 
@@ -373,9 +920,12 @@ func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manua
 
 	// no delay before first reconnection
 	delayBeforeReconnect := 0 * time.Second
+	// amount of consecutive failed reconnection attempts
+	consecutiveFailures := 0
 
 	for {
 		// create new VPN object
+		s.notifyConnectionProgress("generating_configuration", "Generating VPN configuration")
 		vpnObj, err := createVpnObj()
 		if err != nil {
 			return fmt.Errorf("failed to create VPN object: %w", err)
@@ -384,6 +934,7 @@ func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manua
 		lastConnectionTryTime := time.Now()
 
 		// start connection
+		s._lastVpnAuthError = false
 		err = s.connect(vpnObj, s._manualDNS, firewallDuringConnection, stateChan)
 		if err != nil {
 			log.Error(fmt.Sprintf("Connection error: %s", err))
@@ -396,14 +947,56 @@ func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manua
 
 		// retry, if reconnection requested
 		if s._requiredVpnState == KeepConnection {
-			// notifying clients about reconnection
-			stateChan <- vpn.NewStateInfo(vpn.RECONNECTING, "Reconnecting due to disconnection")
+			isAlwaysOnVPN := s.Preferences().IsAlwaysOnVPN
+
+			// 'always-on VPN': ensure no traffic leaks outside the tunnel while it is down
+			if isAlwaysOnVPN {
+				if err := s.SetKillSwitchState(true); err != nil {
+					log.Error("Failed to enable firewall (always-on VPN): ", err)
+				}
+			}
+
+			// 'soft' kill-switch mode: block traffic after the unexpected tunnel drop
+			// (released when the tunnel is re-established or on a deliberate disconnect)
+			if err := firewall.SoftModeTrigger(); err != nil {
+				log.Error("Failed to apply soft kill-switch block: ", err)
+			}
+
+			// credential errors are not recoverable by retrying with the same credentials:
+			// the session have to be re-authenticated (or the keys re-generated) first
+			if s._lastVpnAuthError {
+				log.Info("Automatic reconnection canceled: authentication failure")
+				break
+			}
+
+			// automatic reconnection can be disabled by preferences
+			// ('always-on VPN' overrules it: the tunnel have to be kept alive
+			// until an explicit disconnect request from an authorized client)
+			if isAlwaysOnVPN == false && s.Preferences().IsAutoReconnect == false {
+				log.Info("Automatic reconnection disabled by preferences")
+				break
+			}
 
 			// no delay before reconnection (if last connection was long time ago)
 			if time.Now().After(lastConnectionTryTime.Add(time.Second * 30)) {
 				delayBeforeReconnect = 0
+				consecutiveFailures = 0
+			}
+			consecutiveFailures++
+
+			// give up when the limit of consecutive failed attempts is reached
+			// (not applicable for 'always-on VPN': it never gives up)
+			maxRetries := s.Preferences().ReconnectMaxRetries
+			if isAlwaysOnVPN == false && maxRetries > 0 && consecutiveFailures > maxRetries {
+				log.Info(fmt.Sprintf("Automatic reconnection stopped (%d consecutive attempts failed)", maxRetries))
+				break
 			}
 
+			// notifying clients about reconnection
+			stateChan <- vpn.NewStateInfo(vpn.RECONNECTING, fmt.Sprintf("Reconnecting due to disconnection (attempt %d)", consecutiveFailures))
+
+			metrics.IncCounter("ivpn_reconnects_total")
+
 			if delayBeforeReconnect > 0 {
 				log.Info(fmt.Sprintf("Reconnecting (pause %s)...", delayBeforeReconnect))
 				// do delay before next reconnection
@@ -416,8 +1009,15 @@ func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manua
 			}
 
 			if s._requiredVpnState == KeepConnection {
-				// consecutive reconnections has delay 5 seconds
-				delayBeforeReconnect = time.Second * 5
+				// consecutive reconnections: exponential backoff 5 sec ... 5 min
+				if delayBeforeReconnect <= 0 {
+					delayBeforeReconnect = time.Second * 5
+				} else {
+					delayBeforeReconnect *= 2
+					if delayBeforeReconnect > time.Minute*5 {
+						delayBeforeReconnect = time.Minute * 5
+					}
+				}
 				continue
 			}
 		}
@@ -426,6 +1026,13 @@ func (s *Service) keepConnection(createVpnObj func() (vpn.Process, error), manua
 		break
 	}
 
+	if s._requiredVpnState == Disconnect {
+		// deliberate disconnect: the 'soft' kill-switch block (if active) is not applicable
+		if err := firewall.SoftModeRelease(); err != nil {
+			log.Error("Failed to release soft kill-switch block: ", err)
+		}
+	}
+
 	return nil
 }
 
@@ -457,10 +1064,19 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		}
 	}()
 
+	// user can cancel connection process at any moment
+	if s.isConnectionCancelRequested() {
+		return ErrorConnectionCanceled{}
+	}
+
 	log.Info("Connecting...")
 	// save vpn object
 	s._vpn = vpnProc
 
+	// connection audit log data (safe to read after the connect routines are stopped)
+	var historyConnectedServer string
+	var historyDisconnectReason string
+
 	internalStateChan := make(chan vpn.StateInfo, 1)
 	stopChannel := make(chan bool, 1)
 
@@ -477,6 +1093,9 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		// Ensure that routing-change detector is stopped (we do not need it when VPN disconnected)
 		s._netChangeDetector.Stop()
 
+		// external IP is changed - the cached geo-lookup info is not actual anymore
+		s.invalidateGeoLookupCache()
+
 		// notify firewall that client is disconnected
 		err := firewall.ClientDisconnected()
 		if err != nil {
@@ -500,10 +1119,35 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 			log.Error("(stopping) error resetting manual DNS: ", err)
 		}
 
+		// stop local DNS proxy (does nothing when the proxy was not started)
+		if err := dnsproxy.Stop(); err != nil {
+			log.Error("(stopping) error stopping local DNS proxy: ", err)
+		}
+
+		// verify that the system DNS configuration was restored to the pre-connection values;
+		// repair it when it is still pointing to the dead tunnel resolver
+		if restored, err := dns.IsRestored(); err == nil && restored == false {
+			log.Warning("System DNS configuration was not restored after disconnect. Repairing...")
+			if err := dns.Repair(); err != nil {
+				log.Error("(stopping) failed to repair DNS configuration: ", err)
+			}
+		}
+
 		connectRoutinesWaiter.Wait()
 
+		// connection audit log: the connection is stopped
+		if len(historyConnectedServer) > 0 {
+			s.addConnectionHistoryEvent("disconnect", historyConnectedServer, vpnProc.Type().String(), historyDisconnectReason)
+		}
+
 		// Forget VPN object
 		s._vpn = nil
+		s._tunnelLocalIP = nil
+
+		// reset partial-tunnel firewall exceptions (if any)
+		firewall.SetPartialTunnelNetworks(nil)
+
+		metrics.SetGauge("ivpn_connection_state", 0)
 
 		log.Info("VPN process stopped")
 	}()
@@ -520,6 +1164,7 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		}()
 
 		var state vpn.StateInfo
+		isAdapterRepairTried := false
 		for isRuning := true; isRuning; {
 			select {
 			case state = <-internalStateChan:
@@ -534,10 +1179,35 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 
 				log.Info(fmt.Sprintf("State: %v", state))
 
-				// internally process VPN state change
-				switch state.State {
+				// connection audit log: remember details of an unexpected exit
+				if state.State == vpn.EXITING && len(state.StateAdditionalInfo) > 0 {
+					historyDisconnectReason = state.StateAdditionalInfo
+				}
 
-				case vpn.RECONNECTING:
+				// remember authentication failures (reconnection with the same credentials is pointless)
+				if state.State == vpn.EXITING && state.IsAuthError {
+					s._lastVpnAuthError = true
+				}
+
+				// OpenVPN found no free TAP adapter - try to repair the adapters
+				// before the next reconnection attempt (Windows only; once per connection)
+				if state.StateAdditionalInfo == "tap-adapters-exhausted" && isAdapterRepairTried == false {
+					isAdapterRepairTried = true
+					go func() {
+						log.Info("No free TAP adapter. Trying to repair network adapters...")
+						repairedItems, err := implRepairNetworkAdapters()
+						if err != nil {
+							log.Error("Failed to repair network adapters: ", err)
+							return
+						}
+						log.Info("Network adapters repair finished: ", strings.Join(repairedItems, "; "))
+					}()
+				}
+
+				// internally process VPN state change
+				switch state.State {
+
+				case vpn.RECONNECTING:
 					// Disable routing-change detector when reconnecting
 					s._netChangeDetector.Stop()
 
@@ -558,6 +1228,23 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 
 					// Inform firewall about client local IP
 					firewall.ClientConnected(state.ClientIP)
+
+					// tunnel is (re-)established: release the 'soft' kill-switch block (if active)
+					if err := firewall.SoftModeRelease(); err != nil {
+						log.Error("Failed to release soft kill-switch block: ", err)
+					}
+
+					// in use by the local SOCKS5 proxy ('vpn' mode)
+					s._tunnelLocalIP = state.ClientIP
+
+					// external IP is changed - the cached geo-lookup info is not actual anymore
+					s.invalidateGeoLookupCache()
+
+					// connection audit log: connection established
+					historyConnectedServer = state.ServerIP.String()
+					s.addConnectionHistoryEvent("connect", historyConnectedServer, vpnProc.Type().String(), "")
+
+					metrics.SetGauge("ivpn_connection_state", 1)
 				default:
 				}
 
@@ -581,7 +1268,13 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 			case <-routingChangeChan: // routing changed
 				if s._vpn.IsPaused() {
 					log.Info("Route change ignored due to Paused state.")
+				} else if err := s._vpn.OnRoutingChanged(); err == nil {
+					// the tunnel roamed to the new network - no reconnection required
+					log.Info("Route change detected. Peer endpoint re-applied (tunnel roamed).")
+					s._evtReceiver.OnConnectionProgress("roaming", "Network changed: the VPN tunnel roamed to the new network")
 				} else {
+					log.Info(fmt.Sprintf("Route change detected. Roaming not possible (%s). Reconnecting...", err))
+
 					// Disconnect (client will request then reconnection, because of unexpected disconnection)
 					// reconnect in separate routine (do not block current thread)
 					go func() {
@@ -591,7 +1284,6 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 							}
 						}()
 
-						log.Info("Route change detected. Reconnecting...")
 						s.reconnect()
 					}()
 
@@ -603,12 +1295,37 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		}
 	}()
 
+	// polling connection statistics (streamed to the connected clients)
+	connectRoutinesWaiter.Add(1)
+	go func() {
+		log.Info("Connection statistics poller started")
+		defer func() {
+			log.Info("Connection statistics poller stopped")
+			connectRoutinesWaiter.Done()
+		}()
+
+		s.statsPoller(vpnProc, stopChannel)
+	}()
+
+	// WireGuard dead-peer detection (handshake monitor)
+	connectRoutinesWaiter.Add(1)
+	go func() {
+		defer connectRoutinesWaiter.Done()
+		s.wgHandshakeMonitor(vpnProc, stopChannel)
+	}()
+
 	log.Info("Initializing...")
+	s.notifyConnectionProgress("initializing", "Initializing VPN process")
 	if err := vpnProc.Init(); err != nil {
 		return fmt.Errorf("failed to initialize VPN object: %w", err)
 	}
 
+	if s.isConnectionCancelRequested() {
+		return ErrorConnectionCanceled{}
+	}
+
 	log.Info("Initializing firewall")
+	s.notifyConnectionProgress("configuring_firewall", "Configuring firewall")
 	if firewallDuringConnection == true {
 		// in case to enable FW for this connection parameter:
 		// - check initial FW state
@@ -634,7 +1351,28 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		return err
 	}
 
+	if s.isConnectionCancelRequested() {
+		return ErrorConnectionCanceled{}
+	}
+
 	log.Info("Initializing DNS")
+	s.notifyConnectionProgress("configuring_dns", "Configuring DNS")
+
+	// Local DNS proxy: when enabled - system DNS requests are served by the daemon
+	// (queries are forwarded to the configured DoH\DoT resolver through the tunnel)
+	if s.Preferences().IsDnsProxy {
+		if err := s.startDNSProxy(); err != nil {
+			log.Error("Failed to start local DNS proxy: ", err)
+		} else {
+			manualDNS = dnsproxy.ListenIP()
+		}
+	}
+
+	// AntiTracker: the blocking DNS servers have priority over any other DNS configuration
+	if s.Preferences().IsAntiTracker {
+		manualDNS = s.antiTrackerDNSValue()
+	}
+
 	// set manual DNS
 	if manualDNS == nil || manualDNS.Equal(net.IPv4zero) || manualDNS.Equal(net.IPv4bcast) {
 		err = s.ResetManualDNS()
@@ -647,7 +1385,12 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 		return err
 	}
 
+	if s.isConnectionCancelRequested() {
+		return ErrorConnectionCanceled{}
+	}
+
 	log.Info("Starting VPN process")
+	s.notifyConnectionProgress("starting_process", "Starting VPN process")
 	// connect: start VPN process and wait until it finishes
 	err = vpnProc.Connect(internalStateChan)
 	if err != nil {
@@ -659,6 +1402,11 @@ func (s *Service) connect(vpnProc vpn.Process, manualDNS net.IP, firewallDuringC
 	return nil
 }
 
+// notifyConnectionProgress informs connected clients about progress of establishing VPN connection
+func (s *Service) notifyConnectionProgress(stage string, description string) {
+	s._evtReceiver.OnConnectionProgress(stage, description)
+}
+
 func (s *Service) reconnect() {
 	// Just call disconnect
 	// The reconnection will be performed automatically in method 'keepConnection(...)'
@@ -672,6 +1420,20 @@ func (s *Service) Disconnect() error {
 	return s.disconnect()
 }
 
+// CancelConnect cancels an in-progress connection attempt
+// The connection process aborts on the nearest cancellation checkpoint
+// (if VPN process is already started - it will be stopped)
+func (s *Service) CancelConnect() error {
+	log.Info("Canceling connection attempt...")
+	s._requiredVpnState = Disconnect
+	return s.disconnect()
+}
+
+// isConnectionCancelRequested returns 'true' when user requested to cancel\stop connection process
+func (s *Service) isConnectionCancelRequested() bool {
+	return s._requiredVpnState == Disconnect
+}
+
 func (s *Service) disconnect() error {
 	vpn := s._vpn
 	if vpn == nil {
@@ -698,6 +1460,12 @@ func (s *Service) disconnect() error {
 		<-done
 	}
 
+	// tunnel is down - no necessity to keep IPv6 blocked
+	// (do not unblock when going to reconnect)
+	if s._requiredVpnState != KeepConnection {
+		s.setBlockIPv6Required(false)
+	}
+
 	return nil
 }
 
@@ -716,19 +1484,45 @@ func (s *Service) FirewallEnabled() (bool, error) {
 }
 
 // Pause pause vpn connection
-func (s *Service) Pause() error {
+// When durationSec > 0 - the connection will be automatically resumed after the defined amount of seconds.
+// The resume timer lives in the daemon, therefore it survives UI restarts.
+func (s *Service) Pause(durationSec int64) error {
 	vpn := s._vpn
 	if vpn == nil {
 		return nil
 	}
 
+	// cancel previously scheduled automatic resume (if defined)
+	s.stopResumeTimer()
+
 	log.Info("Pausing...")
 	firewall.ClientPaused()
-	return vpn.Pause()
+	// while paused - traffic have to flow outside the tunnel (IPv6 including)
+	if err := firewall.BlockIPv6(false); err != nil {
+		log.Error("Failed to unblock IPv6: ", err)
+	}
+	if err := vpn.Pause(); err != nil {
+		return err
+	}
+
+	if durationSec > 0 {
+		log.Info(fmt.Sprintf("Automatic resume scheduled in %d seconds", durationSec))
+		s._resumeTimer = time.AfterFunc(time.Second*time.Duration(durationSec), func() {
+			log.Info("Automatic resume (timer)")
+			if err := s.Resume(); err != nil {
+				log.Error("Failed to resume connection (timer): ", err)
+			}
+		})
+	}
+
+	return nil
 }
 
 // Resume resume vpn connection
 func (s *Service) Resume() error {
+	// cancel scheduled automatic resume (if defined)
+	s.stopResumeTimer()
+
 	vpn := s._vpn
 	if vpn == nil {
 		return nil
@@ -736,9 +1530,23 @@ func (s *Service) Resume() error {
 
 	log.Info("Resuming...")
 	firewall.ClientResumed()
+	// restore IPv6 leak protection (if it is required for current connection)
+	if s._isBlockIPv6Required {
+		if err := firewall.BlockIPv6(true); err != nil {
+			log.Error("Failed to block IPv6: ", err)
+		}
+	}
 	return vpn.Resume()
 }
 
+// stopResumeTimer - cancel scheduled automatic resume (if defined)
+func (s *Service) stopResumeTimer() {
+	if s._resumeTimer != nil {
+		s._resumeTimer.Stop()
+		s._resumeTimer = nil
+	}
+}
+
 // SetManualDNS set dns
 func (s *Service) SetManualDNS(dns net.IP) error {
 	vpn := s._vpn
@@ -758,6 +1566,185 @@ func (s *Service) SetManualDNS(dns net.IP) error {
 	return err
 }
 
+// AntiTracker blocking DNS servers (accessible only through the VPN tunnel)
+var (
+	antiTrackerDNS         = net.IPv4(10, 0, 254, 2)
+	antiTrackerDNSHardcore = net.IPv4(10, 0, 254, 3)
+)
+
+// antiTrackerDNSValue - blocking DNS server of the currently selected AntiTracker mode
+func (s *Service) antiTrackerDNSValue() net.IP {
+	if s.Preferences().IsAntiTrackerHardcore {
+		return antiTrackerDNSHardcore
+	}
+	return antiTrackerDNS
+}
+
+// SetAntiTracker - enable\disable AntiTracker (ad\tracker blocking DNS)
+// The mode is persisted in preferences;
+// when VPN is connected - DNS is re-applied on the live tunnel
+func (s *Service) SetAntiTracker(enabled bool, hardcore bool) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsAntiTracker = enabled
+	prefs.IsAntiTrackerHardcore = hardcore
+	s.setPreferences(prefs)
+
+	if s.Connected() == false {
+		return nil // will be applied on next connection
+	}
+
+	if enabled {
+		return s.SetManualDNS(s.antiTrackerDNSValue())
+	}
+	// AntiTracker disabled: restore the default DNS configuration
+	return s.ResetManualDNS()
+}
+
+// leak self-test parameters
+var leakTestPublicDNS = net.IPv4(9, 9, 9, 9) // public resolver used for the DNS leak check
+
+// public IPv6 destination used for the IPv6 leak check
+const leakTestIPv6Host = "[2606:4700:4700::1111]:443"
+
+// RunLeakTest - connectivity and leak self-test (available only while connected):
+//   - external IP: requested from the IVPN API (the request goes through the tunnel)
+//   - DNS leak: a query to a public resolver using an out-of-tunnel source address must fail
+//   - IPv6 leak: when IPv6 have to be blocked (IPv4-only tunnel) -
+//     connections to IPv6 destinations must fail
+func (s *Service) RunLeakTest() (externalIP string, isDNSLeak bool, isIPv6Leak bool, err error) {
+	if s.Connected() == false {
+		return "", false, false, fmt.Errorf("unable to run leak test: VPN is not connected")
+	}
+
+	if location, e := s._api.GeoLookup(); e != nil {
+		log.Warning("Leak test: failed to obtain external IP: ", e)
+	} else {
+		externalIP = location.IPAddress
+	}
+
+	// DNS leak check: send a query to a public resolver using a physical (non-tunnel)
+	// interface as the source address; an answer means DNS requests are able to bypass the tunnel
+	if localIP, e := getOutOfTunnelIPv4(); e != nil {
+		log.Warning("Leak test: failed to detect out-of-tunnel address: ", e)
+	} else if e := queryDNSFrom(leakTestPublicDNS, localIP); e == nil {
+		isDNSLeak = true
+	}
+
+	if s._isBlockIPv6Required {
+		if conn, e := net.DialTimeout("tcp", leakTestIPv6Host, dnsVerifyTimeout); e == nil {
+			conn.Close()
+			isIPv6Leak = true
+		}
+	}
+
+	return externalIP, isDNSLeak, isIPv6Leak, nil
+}
+
+// getOutOfTunnelIPv4 - local IPv4 address of a physical (non-tunnel) network interface
+func getOutOfTunnelIPv4() (net.IP, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network interfaces: %w", err)
+	}
+
+	for _, ifs := range ifaces {
+		// skip tunnel interfaces (OpenVPN: 'tunX'\'utunX'; WireGuard: 'wg...')
+		if strings.Contains(ifs.Name, "tun") || strings.HasPrefix(ifs.Name, "wg") {
+			continue
+		}
+		addrs, _ := ifs.Addrs()
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+				continue
+			}
+			return ipNet.IP, nil
+		}
+	}
+	return nil, fmt.Errorf("no out-of-tunnel IPv4 address found")
+}
+
+// queryDNSFrom - direct DNS request to 'resolverIP' using 'localAddr' as the source address
+func queryDNSFrom(resolverIP net.IP, localAddr net.IP) error {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{
+				Timeout:   dnsVerifyTimeout,
+				LocalAddr: &net.UDPAddr{IP: localAddr}}
+			return d.DialContext(ctx, "udp", net.JoinHostPort(resolverIP.String(), "53"))
+		}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsVerifyTimeout)
+	defer cancel()
+
+	_, err := resolver.LookupHost(ctx, dnsVerifyHostname)
+	return err
+}
+
+// RepairDNS - force restoring the system DNS configuration to the OS defaults
+// (for situations when resolv.conf\network service DNS is left pointing
+// to the dead tunnel resolver after a crash)
+func (s *Service) RepairDNS() error {
+	if s.Connected() {
+		return fmt.Errorf("unable to repair DNS configuration: VPN is connected")
+	}
+	return dns.Repair()
+}
+
+// SetManualDNSServers - set custom DNS: primary + fallback servers (IPv4 or IPv6).
+// The servers are applied in order; after applying, name resolution is verified -
+// when the server is not reachable through the tunnel, the next fallback is tried.
+// When no server works - the original DNS configuration is restored.
+func (s *Service) SetManualDNSServers(servers []net.IP) error {
+	if len(servers) == 0 {
+		return s.ResetManualDNS()
+	}
+
+	var lastErr error
+	for _, dnsIP := range servers {
+		if err := s.SetManualDNS(dnsIP); err != nil {
+			lastErr = err
+			continue
+		}
+		if err := verifyDNSResolution(dnsIP); err != nil {
+			lastErr = fmt.Errorf("DNS server %s is not reachable through the tunnel: %w", dnsIP, err)
+			log.Warning(lastErr)
+			continue
+		}
+		return nil
+	}
+
+	// no server works - roll back to the default DNS configuration
+	if err := s.ResetManualDNS(); err != nil {
+		log.Error("Failed to roll back DNS configuration: ", err)
+	}
+	return lastErr
+}
+
+const (
+	// hostname used to verify that a DNS server is able to resolve names
+	dnsVerifyHostname = "ivpn.net"
+	dnsVerifyTimeout  = time.Second * 5
+)
+
+// verifyDNSResolution - check that the DNS server is responding
+// (resolve a known hostname using the defined server directly)
+func verifyDNSResolution(dnsServer net.IP) error {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: dnsVerifyTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(dnsServer.String(), "53"))
+		}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnsVerifyTimeout)
+	defer cancel()
+
+	_, err := resolver.LookupHost(ctx, dnsVerifyHostname)
+	return err
+}
+
 // ResetManualDNS set dns to default
 func (s *Service) ResetManualDNS() error {
 	vpn := s._vpn
@@ -778,12 +1765,12 @@ func (s *Service) ResetManualDNS() error {
 }
 
 // PingServers ping vpn servers
-func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, error) {
+func (s *Service) PingServers(retryCount int, timeoutMs int, intervalMs int, maxConcurrent int, hosts []string) (map[string]int, error) {
 
 	// do not allow multiple ping request simultaneously
 	if s._isServersPingInProgress {
 		log.Info("Servers pinging skipped. Ping already in progress")
-		return nil, nil
+		return s.pingResultsCache(), nil
 	}
 	defer func() { s._isServersPingInProgress = false }()
 	s._isServersPingInProgress = true
@@ -791,7 +1778,7 @@ func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, er
 	vpn := s._vpn
 	if vpn != nil {
 		log.Info("Servers pinging skipped due to connected state")
-		return nil, nil
+		return s.pingResultsCache(), nil
 	}
 
 	if retryCount <= 0 || timeoutMs <= 0 {
@@ -799,6 +1786,27 @@ func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, er
 		return nil, nil
 	}
 
+	if intervalMs <= 0 {
+		// do not use small interval (<350ms). Possible unexpected behavior: pings never return sometimes
+		intervalMs = 1000
+	}
+	if maxConcurrent <= 0 {
+		maxConcurrent = defaultPingMaxConcurrent
+	}
+
+	// ping only the requested subset of hosts (when defined)
+	hostsFilter := make(map[string]struct{}, len(hosts))
+	for _, host := range hosts {
+		hostsFilter[host] = struct{}{}
+	}
+	isHostAllowed := func(host string) bool {
+		if len(hostsFilter) == 0 {
+			return true
+		}
+		_, ok := hostsFilter[host]
+		return ok
+	}
+
 	// get servers info
 	servers, err := s._serversUpdater.GetServers()
 	if err != nil {
@@ -821,11 +1829,16 @@ func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, er
 	}
 
 	resultChan := make(chan pair, 1)
+	// limit amount of hosts pinged in parallel
+	concurrencySem := make(chan struct{}, maxConcurrent)
 	// define generic ping function
 	pingFunc := func(ip string) {
 		// notify waiter: goroutine is finished
 		defer waiter.Done()
 
+		concurrencySem <- struct{}{}
+		defer func() { <-concurrencySem }()
+
 		pinger, err := ping.NewPinger(ip)
 		if err != nil {
 			log.Error("Pinger creation error: " + err.Error())
@@ -834,7 +1847,7 @@ func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, er
 
 		pinger.SetPrivileged(true)
 		pinger.Count = retryCount
-		pinger.Interval = time.Millisecond * 1000 // do not use small interval (<350ms). Possible unexpected behavior: pings never return sometimes
+		pinger.Interval = time.Millisecond * time.Duration(intervalMs)
 		pinger.Timeout = time.Millisecond * time.Duration(timeoutMs)
 
 		pinger.Run()
@@ -864,103 +1877,416 @@ func (s *Service) PingServers(retryCount int, timeoutMs int) (map[string]int, er
 			} else {
 				avgPing = stat.AvgRtt
 			}
-			//log.Debug(int(stat.AvgRtt/time.Millisecond), " -> ", int(avgPing/time.Millisecond), "\t", stat)
+			//log.Debug(int(stat.AvgRtt/time.Millisecond), " -> ", int(avgPing/time.Millisecond), "\t", stat)
+		}
+
+		resultChan <- pair{host: ip, ping: int(avgPing / time.Millisecond)}
+
+		// ... pings filtering
+
+		// Original pings data:
+		//resultChan <- pair{host: ip, ping: int(stat.AvgRtt / time.Millisecond)}
+	}
+
+	log.Info("Pinging servers...")
+
+	// ping each OpenVPN server
+	for _, s := range servers.OpenvpnServers {
+		if len(s.IPAddresses) <= 0 || isHostAllowed(s.IPAddresses[0]) == false {
+			continue
+		}
+		waiter.Add(1) // +1 goroutine to wait
+		go pingFunc(s.IPAddresses[0])
+	}
+
+	// ping each WireGuard server
+	for _, s := range servers.WireguardServers {
+		if len(s.Hosts) <= 0 || isHostAllowed(s.Hosts[0].Host) == false {
+			continue
+		}
+		waiter.Add(1) // +1 goroutine to wait
+		go pingFunc(s.Hosts[0].Host)
+	}
+
+	successfullyPinged := 0
+	retMap := make(map[string]int)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case r := <-resultChan:
+				retMap[r.host] = r.ping
+				if r.ping > 0 {
+					successfullyPinged = successfullyPinged + 1
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	waiter.Wait()
+	done <- true
+
+	log.Info(fmt.Sprintf("Pinged %d of %d servers (%d successfully)", len(retMap), len(servers.OpenvpnServers)+len(servers.WireguardServers), successfullyPinged))
+
+	s.updatePingResultsCache(retMap)
+
+	return retMap, nil
+}
+
+// PingResults returns the cached results of the last successful servers pinging
+// (host -> ping time in milliseconds; in use by the daemon-side server selection)
+func (s *Service) PingResults() map[string]int {
+	return s.pingResultsCache()
+}
+
+// pingResultsCache returns a copy of the results of the last successful pinging
+func (s *Service) pingResultsCache() map[string]int {
+	s._pingResultsMutex.Lock()
+	defer s._pingResultsMutex.Unlock()
+
+	if len(s._pingResults) == 0 {
+		return nil
+	}
+
+	results := make(map[string]int, len(s._pingResults))
+	for host, ping := range s._pingResults {
+		results[host] = ping
+	}
+	return results
+}
+
+// updatePingResultsCache - merge new ping results into the cache
+// (results of the hosts which were not pinged this time stay untouched)
+func (s *Service) updatePingResultsCache(results map[string]int) {
+	s._pingResultsMutex.Lock()
+	defer s._pingResultsMutex.Unlock()
+
+	if s._pingResults == nil {
+		s._pingResults = make(map[string]int)
+	}
+	for host, ping := range results {
+		if ping > 0 {
+			s._pingResults[host] = ping
+		}
+	}
+}
+
+// GetGeoLookup returns information about the current location (as seen by the API servers).
+// The result is cached until the connection state changes, so multiple clients
+// share one implementation and do not flood the API with geo-lookup requests.
+func (s *Service) GetGeoLookup() (*types.GeoLookupResponse, error) {
+	s._geoLookupMutex.Lock()
+	defer s._geoLookupMutex.Unlock()
+
+	if s._geoLookupCache != nil {
+		return s._geoLookupCache, nil
+	}
+
+	location, err := s._api.GeoLookup()
+	if err != nil {
+		return nil, fmt.Errorf("geo-lookup request failed: %w", err)
+	}
+
+	s._geoLookupCache = location
+	return location, nil
+}
+
+// invalidateGeoLookupCache erases the cached geo-lookup result
+// (must be called on each connection state change: the external IP is not actual anymore)
+func (s *Service) invalidateGeoLookupCache() {
+	s._geoLookupMutex.Lock()
+	defer s._geoLookupMutex.Unlock()
+
+	s._geoLookupCache = nil
+}
+
+// SetKillSwitchState enable\disable killswitch
+func (s *Service) SetKillSwitchState(isEnabled bool) error {
+	err := firewall.SetEnabled(isEnabled)
+	if err == nil {
+		s._evtReceiver.OnKillSwitchStateChanged()
+	}
+	return err
+}
+
+// KillSwitchState returns killswitch state
+func (s *Service) KillSwitchState() (isEnabled, isPersistant, isAllowLAN, isAllowLanMulticast bool, err error) {
+	prefs := s._preferences.GetSnapshot()
+	enabled, err := firewall.GetEnabled()
+	return enabled, prefs.IsFwPersistant, prefs.IsFwAllowLAN, prefs.IsFwAllowLANMulticast, err
+}
+
+// SetKillSwitchSoftMode - enable\disable the 'soft' kill-switch mode:
+// traffic is blocked only when the tunnel drops unexpectedly;
+// a deliberate disconnect keeps connectivity
+func (s *Service) SetKillSwitchSoftMode(isEnabled bool) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsFwSoftMode = isEnabled
+	s.setPreferences(prefs)
+
+	err := firewall.SetSoftMode(isEnabled)
+	if err == nil {
+		s._evtReceiver.OnKillSwitchStateChanged()
+	}
+	return err
+}
+
+// KillSwitchSoftModeTriggered - true when the kill-switch rules are currently applied
+// by the 'soft' mode due to an unexpected tunnel drop
+func (s *Service) KillSwitchSoftModeTriggered() bool {
+	_, isTriggered := firewall.SoftModeStatus()
+	return isTriggered
+}
+
+// SetKillSwitchIsPersistent change kill-switch value
+func (s *Service) SetKillSwitchIsPersistent(isPersistant bool) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsFwPersistant = isPersistant
+	s.setPreferences(prefs)
+
+	err := firewall.SetPersistant(isPersistant)
+	if err == nil {
+		s._evtReceiver.OnKillSwitchStateChanged()
+	}
+	return err
+}
+
+// SetKillSwitchLanAllowedAddresses - define user-managed list of specific LAN addresses
+// (single IPs or subnets in CIDR notation) which stay reachable when the firewall is enabled
+func (s *Service) SetKillSwitchLanAllowedAddresses(addresses []string) error {
+	// only LAN (private or link-local) address ranges are acceptable
+	for _, addr := range addresses {
+		ipNet, err := parseLanAddress(addr)
+		if err != nil {
+			return err
+		}
+		if isLanNetwork(ipNet) == false {
+			return fmt.Errorf("'%s' does not belong to a LAN address range", addr)
+		}
+	}
+
+	if err := firewall.SetLanAllowedAddresses(addresses); err != nil {
+		return err
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	prefs.FwLanAllowedAddresses = addresses
+	s.setPreferences(prefs)
+
+	s._evtReceiver.OnKillSwitchStateChanged()
+	return nil
+}
+
+// parseLanAddress - parse single IP or subnet in CIDR notation
+func parseLanAddress(addr string) (*net.IPNet, error) {
+	if ip := net.ParseIP(addr); ip != nil {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse address '%s': %w", addr, err)
+	}
+	return ipNet, nil
+}
+
+// isLanNetwork - check that the network belongs to a private or link-local address range
+func isLanNetwork(ipNet *net.IPNet) bool {
+	lanRanges := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "169.254.0.0/16"}
+	for _, lan := range lanRanges {
+		_, lanNet, _ := net.ParseCIDR(lan)
+		ones, _ := ipNet.Mask.Size()
+		lanOnes, _ := lanNet.Mask.Size()
+		if lanNet.Contains(ipNet.IP) && ones >= lanOnes {
+			return true
+		}
+	}
+	return false
+}
+
+// SetKillSwitchUserExceptions - define user-managed list of 'always allowed' destinations
+// (single IPs, 'host:port' entries, subnets in CIDR notation or hostnames)
+// injected into the kill-switch ruleset
+// An exception is not allowed to be broader than a /24 subnet.
+// Hostname entries are resolved by the daemon and re-resolved periodically
+// to keep the rules current (see firewall_hostname_exceptions.go).
+// Note: port granularity is not supported by the current firewall backends - the whole host is allowed
+func (s *Service) SetKillSwitchUserExceptions(exceptions []string) error {
+	// validate all entries before applying anything
+	for _, entry := range exceptions {
+		if _, _, err := parseUserException(entry); err != nil {
+			return err
+		}
+	}
+
+	if err := s.applyUserExceptionsToFirewall(exceptions); err != nil {
+		return err
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	prefs.FwUserExceptions = exceptions
+	s.setPreferences(prefs)
+
+	s._evtReceiver.OnKillSwitchStateChanged()
+	return nil
+}
+
+// applyApiServersExceptions - apply the API access policy to the firewall:
+// when allowed - the known API server IP addresses stay reachable
+// even when the firewall is enabled but VPN is not connected
+func (s *Service) applyApiServersExceptions(isAllow bool) {
+	if isAllow == false {
+		if err := firewall.SetApiServersExceptions(nil); err != nil {
+			log.Error("Failed to remove API servers exceptions: ", err)
+		}
+		return
+	}
+
+	ips := make([]net.IP, 0)
+	if servers, err := s.ServersList(); err != nil {
+		log.Warning("Unable to apply API servers exceptions (servers info not available): ", err)
+	} else {
+		for _, ipStr := range servers.Config.API.IPAddresses {
+			if ip := net.ParseIP(ipStr); ip != nil {
+				ips = append(ips, ip)
+			}
 		}
+	}
 
-		resultChan <- pair{host: ip, ping: int(avgPing / time.Millisecond)}
+	if len(ips) == 0 {
+		return
+	}
 
-		// ... pings filtering
+	if err := firewall.SetApiServersExceptions(ips); err != nil {
+		log.Error("Failed to apply API servers exceptions: ", err)
+	}
+}
 
-		// Original pings data:
-		//resultChan <- pair{host: ip, ping: int(stat.AvgRtt / time.Millisecond)}
+// applyMetricsPreference - start\stop the localhost observability metrics endpoint
+// (see the 'metrics' package)
+func (s *Service) applyMetricsPreference(isEnabled bool, port int) {
+	if isEnabled == false {
+		metrics.Stop()
+		return
 	}
 
-	log.Info("Pinging servers...")
+	if port <= 0 {
+		port = defaultMetricsPort
+	}
+	if err := metrics.Start(port); err != nil {
+		log.Error("Failed to start metrics endpoint: ", err)
+	}
+}
 
-	// ping each OpenVPN server
-	for _, s := range servers.OpenvpnServers {
-		if len(s.IPAddresses) <= 0 {
-			continue
+// SetDnsProxyConfig - configure local DNS proxy (daemon-hosted DoH\DoT resolver)
+// The configuration is persisted in preferences;
+// when VPN is connected - the changes are applied immediately
+func (s *Service) SetDnsProxyConfig(enabled bool, encryption string, resolver string) error {
+	if enabled {
+		if err := dnsproxy.CheckConfig(dnsproxy.Config{Encryption: encryption, Resolver: resolver}); err != nil {
+			return err
 		}
-		waiter.Add(1) // +1 goroutine to wait
-		go pingFunc(s.IPAddresses[0])
 	}
 
-	// ping each WireGuard server
-	for _, s := range servers.WireguardServers {
-		if len(s.Hosts) <= 0 {
-			continue
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsDnsProxy = enabled
+	prefs.DnsProxyEncryption = encryption
+	prefs.DnsProxyResolver = resolver
+	s.setPreferences(prefs)
+
+	if !enabled {
+		if err := dnsproxy.Stop(); err != nil {
+			return err
 		}
-		waiter.Add(1) // +1 goroutine to wait
-		go pingFunc(s.Hosts[0].Host)
+		// reset system DNS (if it was pointing to the local proxy)
+		if s.Connected() && dnsproxy.ListenIP().Equal(s._manualDNS) {
+			return s.ResetManualDNS()
+		}
+		return nil
 	}
 
-	successfullyPinged := 0
-	retMap := make(map[string]int)
-	done := make(chan bool)
-	go func() {
-		for {
-			select {
-			case r := <-resultChan:
-				retMap[r.host] = r.ping
-				if r.ping > 0 {
-					successfullyPinged = successfullyPinged + 1
-				}
-			case <-done:
-				return
-			}
+	if s.Connected() {
+		if err := s.startDNSProxy(); err != nil {
+			return err
 		}
-	}()
+		return s.SetManualDNS(dnsproxy.ListenIP())
+	}
+	return nil
+}
 
-	waiter.Wait()
-	done <- true
+// DnsProxyConfig - current local DNS proxy configuration
+func (s *Service) DnsProxyConfig() (enabled bool, encryption string, resolver string) {
+	prefs := s._preferences.GetSnapshot()
+	return prefs.IsDnsProxy, prefs.DnsProxyEncryption, prefs.DnsProxyResolver
+}
 
-	log.Info(fmt.Sprintf("Pinged %d of %d servers (%d successfully)", len(retMap), len(servers.OpenvpnServers)+len(servers.WireguardServers), successfullyPinged))
+// startDNSProxy - (re)start local DNS proxy with the configuration from preferences
+func (s *Service) startDNSProxy() error {
+	prefs := s._preferences.GetSnapshot()
+	return dnsproxy.Start(dnsproxy.Config{
+		Encryption: prefs.DnsProxyEncryption,
+		Resolver:   prefs.DnsProxyResolver})
+}
 
-	return retMap, nil
+// KillSwitchBlockedDNSCount - DNS leak protection statistics:
+// amount of out-of-tunnel DNS packets blocked by the firewall
+func (s *Service) KillSwitchBlockedDNSCount() (uint64, error) {
+	return firewall.GetBlockedDNSCount()
 }
 
-// SetKillSwitchState enable\disable killswitch
-func (s *Service) SetKillSwitchState(isEnabled bool) error {
-	err := firewall.SetEnabled(isEnabled)
-	if err == nil {
-		s._evtReceiver.OnKillSwitchStateChanged()
+// parseUserException - parse kill-switch exception entry
+// Returns the host part (single IP, subnet or hostname) which have to be allowed by the firewall
+// isHostname == true - the host is a hostname which have to be resolved by the daemon
+// (see firewall_hostname_exceptions.go)
+func parseUserException(entry string) (host string, isHostname bool, retErr error) {
+	// single IP
+	if ip := net.ParseIP(entry); ip != nil {
+		return entry, false, nil
 	}
-	return err
-}
 
-// KillSwitchState returns killswitch state
-func (s *Service) KillSwitchState() (isEnabled, isPersistant, isAllowLAN, isAllowLanMulticast bool, err error) {
-	prefs := s._preferences
-	enabled, err := firewall.GetEnabled()
-	return enabled, prefs.IsFwPersistant, prefs.IsFwAllowLAN, prefs.IsFwAllowLANMulticast, err
-}
+	// 'host:port'
+	if host, port, err := net.SplitHostPort(entry); err == nil {
+		if _, err := strconv.Atoi(port); err != nil {
+			return "", false, fmt.Errorf("failed to parse exception '%s': invalid port", entry)
+		}
+		if net.ParseIP(host) != nil {
+			return host, false, nil
+		}
+		if isValidExceptionHostname(host) {
+			return host, true, nil
+		}
+		return "", false, fmt.Errorf("failed to parse exception '%s': invalid IP address or hostname", entry)
+	}
 
-// SetKillSwitchIsPersistent change kill-switch value
-func (s *Service) SetKillSwitchIsPersistent(isPersistant bool) error {
-	prefs := s._preferences
-	prefs.IsFwPersistant = isPersistant
-	s.setPreferences(prefs)
+	// subnet in CIDR notation (not broader than /24)
+	if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+		if ones, _ := ipNet.Mask.Size(); ones < 24 {
+			return "", false, fmt.Errorf("exception '%s' is too broad (not allowed to be broader than /24)", entry)
+		}
+		return entry, false, nil
+	}
 
-	err := firewall.SetPersistant(isPersistant)
-	if err == nil {
-		s._evtReceiver.OnKillSwitchStateChanged()
+	// hostname (resolved by the daemon; the rules are kept current by periodic re-resolution)
+	if isValidExceptionHostname(entry) {
+		return entry, true, nil
 	}
-	return err
+
+	return "", false, fmt.Errorf("failed to parse exception '%s'", entry)
 }
 
 // SetKillSwitchAllowLAN change kill-switch value
 func (s *Service) SetKillSwitchAllowLAN(isAllowLan bool) error {
-	return s.setKillSwitchAllowLAN(isAllowLan, s._preferences.IsFwAllowLANMulticast)
+	return s.setKillSwitchAllowLAN(isAllowLan, s.Preferences().IsFwAllowLANMulticast)
 }
 
 // SetKillSwitchAllowLANMulticast change kill-switch value
 func (s *Service) SetKillSwitchAllowLANMulticast(isAllowLanMulticast bool) error {
-	return s.setKillSwitchAllowLAN(s._preferences.IsFwAllowLAN, isAllowLanMulticast)
+	return s.setKillSwitchAllowLAN(s.Preferences().IsFwAllowLAN, isAllowLanMulticast)
 }
 
 func (s *Service) setKillSwitchAllowLAN(isAllowLan bool, isAllowLanMulticast bool) error {
-	prefs := s._preferences
+	prefs := s._preferences.GetSnapshot()
 	prefs.IsFwAllowLAN = isAllowLan
 	prefs.IsFwAllowLANMulticast = isAllowLanMulticast
 	s.setPreferences(prefs)
@@ -972,9 +2298,24 @@ func (s *Service) setKillSwitchAllowLAN(isAllowLan bool, isAllowLanMulticast boo
 	return err
 }
 
+// SetKillSwitchAllowServiceDiscovery - allow\forbid local service discovery multicast
+// (mDNS\Bonjour and SSDP) for the kill-switch; independent from the blanket
+// LAN-multicast permission: only the specific discovery multicast groups are allowed
+func (s *Service) SetKillSwitchAllowServiceDiscovery(isAllow bool) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsFwAllowServiceDiscovery = isAllow
+	s.setPreferences(prefs)
+
+	err := firewall.AllowServiceDiscovery(isAllow)
+	if err == nil {
+		s._evtReceiver.OnKillSwitchStateChanged()
+	}
+	return err
+}
+
 // SetPreference set preference value
 func (s *Service) SetPreference(key string, val string) error {
-	prefs := s._preferences
+	prefs := s._preferences.GetSnapshot()
 
 	switch key {
 	case "enable_logging":
@@ -983,6 +2324,13 @@ func (s *Service) SetPreference(key string, val string) error {
 			logger.Enable(val)
 		}
 		break
+	case "system_log_target":
+		// duplicate log messages into the system log (applied immediately)
+		if err := logger.SetSystemLogTarget(val); err != nil {
+			return err
+		}
+		prefs.SystemLogTarget = val
+		break
 	case "is_stop_server_on_client_disconnect":
 		if val, err := strconv.ParseBool(val); err == nil {
 			prefs.IsStopOnClientDisconnect = val
@@ -993,6 +2341,242 @@ func (s *Service) SetPreference(key string, val string) error {
 			prefs.IsObfsproxy = val
 		}
 		break
+	case "obfsproxy_version":
+		if ver, err := strconv.Atoi(val); err == nil {
+			if ver != int(obfsproxy.OBFS3) && ver != int(obfsproxy.OBFS4) {
+				return fmt.Errorf("unsupported obfsproxy version: %d", ver)
+			}
+			prefs.ObfsproxyVersion = ver
+		}
+		break
+	case "connection_fallback":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsConnectionFallback = val
+		}
+		break
+	case "wireguard_mtu":
+		if val, err := strconv.Atoi(val); err == nil {
+			prefs.WireGuardMTU = val
+		}
+		break
+	case "wireguard_keepalive":
+		if val, err := strconv.Atoi(val); err == nil {
+			prefs.WireGuardKeepAlive = val
+		}
+		break
+	case "wireguard_listen_port":
+		if val, err := strconv.Atoi(val); err == nil {
+			if val < 0 || val > 65535 {
+				return fmt.Errorf("invalid port value: %d", val)
+			}
+			prefs.WireGuardListenPort = val
+		}
+		break
+	case "wireguard_allowed_ips":
+		// comma-separated list of networks to tunnel (partial tunneling; empty - tunnel everything)
+		allowedIPs := make([]string, 0)
+		for _, cidr := range strings.Split(val, ",") {
+			if cidr = strings.TrimSpace(cidr); len(cidr) > 0 {
+				if _, _, err := net.ParseCIDR(cidr); err != nil {
+					return fmt.Errorf("unable to parse CIDR '%s': %w", cidr, err)
+				}
+				allowedIPs = append(allowedIPs, cidr)
+			}
+		}
+		prefs.WireGuardAllowedIPs = allowedIPs
+		break
+	case "wireguard_renew_ip_on_conflict":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsWGIPConflictRenew = val
+		}
+		break
+	case "wireguard_handshake_timeout":
+		if val, err := strconv.Atoi(val); err == nil {
+			prefs.WireGuardHandshakeTimeout = val
+		}
+		break
+	case "auto_reconnect":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsAutoReconnect = val
+		}
+		break
+	case "reconnect_max_retries":
+		if val, err := strconv.Atoi(val); err == nil {
+			prefs.ReconnectMaxRetries = val
+		}
+		break
+	case "vpn_coexistence_policy":
+		switch val {
+		case "", "takeover", "refuse", "nested":
+			prefs.VpnCoexistencePolicy = val
+		default:
+			return fmt.Errorf("unexpected VPN coexistence policy: '%s' (acceptable values: 'refuse', 'takeover', 'nested')", val)
+		}
+		break
+	case "autoconnect_on_launch":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsAutoConnectOnLaunch = val
+		}
+		break
+	case "always_on_vpn":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsAlwaysOnVPN = val
+		}
+		break
+	case "connection_history":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsConnectionHistory = val
+			if val == false {
+				// erase already collected records when the user opts out
+				s.clearConnectionHistory()
+			}
+		}
+		break
+	case "socks_proxy":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsSocksProxy = val
+			s.setPreferences(prefs)
+			if err := s.applySocksProxyPreference(); err != nil {
+				return err
+			}
+		}
+		break
+	case "socks_proxy_port":
+		if port, err := strconv.Atoi(val); err == nil {
+			if port < 0 || port > 65535 {
+				return fmt.Errorf("invalid port value: %d", port)
+			}
+			prefs.SocksProxyPort = port
+			s.setPreferences(prefs)
+			if prefs.IsSocksProxy {
+				if err := s.applySocksProxyPreference(); err != nil {
+					return err
+				}
+			}
+		}
+		break
+	case "socks_proxy_mode":
+		if val != "vpn" && val != "bypass" {
+			return fmt.Errorf("unsupported SOCKS proxy mode: '%s'", val)
+		}
+		prefs.SocksProxyMode = val
+		s.setPreferences(prefs)
+		if prefs.IsSocksProxy {
+			if err := s.applySocksProxyPreference(); err != nil {
+				return err
+			}
+		}
+		break
+	case "http_proxy":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsHTTPProxy = val
+			s.setPreferences(prefs)
+			if err := s.applyHTTPProxyPreference(); err != nil {
+				return err
+			}
+		}
+		break
+	case "http_proxy_port":
+		if port, err := strconv.Atoi(val); err == nil {
+			if port < 0 || port > 65535 {
+				return fmt.Errorf("invalid port value: %d", port)
+			}
+			prefs.HTTPProxyPort = port
+			s.setPreferences(prefs)
+			if prefs.IsHTTPProxy {
+				if err := s.applyHTTPProxyPreference(); err != nil {
+					return err
+				}
+			}
+		}
+		break
+	case "http_proxy_domains":
+		// comma-separated list of domains routed through the VPN according to the PAC file
+		domains := make([]string, 0)
+		for _, domain := range strings.Split(val, ",") {
+			if domain = strings.TrimSpace(domain); len(domain) > 0 {
+				domains = append(domains, domain)
+			}
+		}
+		prefs.HTTPProxyPacDomains = domains
+		s.setPreferences(prefs)
+		if prefs.IsHTTPProxy {
+			if err := s.applyHTTPProxyPreference(); err != nil {
+				return err
+			}
+		}
+		break
+	case "datacap_daily_limit_mb":
+		if val, err := strconv.Atoi(val); err == nil {
+			if val < 0 {
+				return fmt.Errorf("invalid data-cap limit: %d", val)
+			}
+			prefs.DataCapDailyLimitMB = val
+		}
+		break
+	case "datacap_monthly_limit_mb":
+		if val, err := strconv.Atoi(val); err == nil {
+			if val < 0 {
+				return fmt.Errorf("invalid data-cap limit: %d", val)
+			}
+			prefs.DataCapMonthlyLimitMB = val
+		}
+		break
+	case "datacap_pause_on_limit":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsDataCapPauseOnLimit = val
+		}
+		break
+	case "firewall_allow_api_servers":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsFwAllowApiServers = val
+			s.applyApiServersExceptions(val)
+		}
+		break
+	case "websocket_bridge":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsWebSocketBridge = val
+			log.Info("WebSocket bridge preference changed (takes effect after the daemon restart)")
+		}
+		break
+	case "websocket_bridge_port":
+		if port, err := strconv.Atoi(val); err == nil && port > 0 {
+			prefs.WebSocketBridgePort = port
+			log.Info("WebSocket bridge port changed (takes effect after the daemon restart)")
+		}
+		break
+	case "metrics":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsMetrics = val
+			s.applyMetricsPreference(val, prefs.MetricsPort)
+		}
+		break
+	case "metrics_port":
+		if port, err := strconv.Atoi(val); err == nil && port > 0 {
+			prefs.MetricsPort = port
+			if prefs.IsMetrics {
+				// restart the endpoint on the new port
+				s.applyMetricsPreference(false, 0)
+				s.applyMetricsPreference(true, port)
+			}
+		}
+		break
+	case "firewall_block_dns":
+		if val, err := strconv.ParseBool(val); err == nil {
+			prefs.IsFwBlockDNS = val
+			if err := firewall.SetDNSLeakProtection(val); err != nil {
+				log.Error("Failed to change DNS leak protection state: ", err)
+			}
+		}
+		break
+	case "open_vpn_extra_parameters":
+		// safety validation: reject directives which are able to execute external commands
+		// or to break the daemon control over the OpenVPN process
+		if _, rejected := openvpn.FilterExtraParameters(val); len(rejected) > 0 {
+			return fmt.Errorf("OpenVPN directives not allowed: %s", strings.Join(rejected, ", "))
+		}
+		prefs.OpenVpnExtraParameters = val
+		break
 	case "firewall_is_persistent":
 		log.Debug("Skipping 'firewall_is_persistent' value. IVPNKillSwitchSetIsPersistentRequest should be used")
 		break
@@ -1008,7 +2592,16 @@ func (s *Service) SetPreference(key string, val string) error {
 
 // Preferences returns preferences
 func (s *Service) Preferences() preferences.Preferences {
-	return s._preferences
+	return s._preferences.GetSnapshot()
+}
+
+// SetLastConnectionParams - save raw JSON of the last 'Connect' request
+// (in use by 'auto-connect on launch' functionality)
+func (s *Service) SetLastConnectionParams(params string) error {
+	prefs := s._preferences.GetSnapshot()
+	prefs.LastConnectionParams = params
+	s.setPreferences(prefs)
+	return nil
 }
 
 //////////////////////////////////////////////////////////
@@ -1027,8 +2620,9 @@ func (s *Service) setCredentials(accountID, session, vpnUser, vpnPass, wgPublicK
 
 	// manually set info about WG keys timestamp
 	if wgKeyGenerated > 0 {
-		s._preferences.Session.WGKeyGenerated = time.Unix(wgKeyGenerated, 0)
-		s._preferences.SavePreferences()
+		s._preferences.Modify(func(p *preferences.Preferences) {
+			p.Session.WGKeyGenerated = time.Unix(wgKeyGenerated, 0)
+		})
 	}
 
 	// notify clients about session update
@@ -1049,14 +2643,19 @@ func (s *Service) SetRawCredentials(accountID, session, vpnUser, vpnPass, wgPubl
 }
 
 // SessionNew creates new session
-func (s *Service) SessionNew(accountID string, forceLogin bool) (
+// The 2FA code and the captcha data are defined only for a follow-up login attempt
+// (when the previous attempt was rejected with a '2FA required'\'captcha required' error;
+// the rejection is reported by the 'apiCode' value and, for captcha, by the challenge data)
+func (s *Service) SessionNew(accountID string, forceLogin bool, captchaID string, captcha string, confirmation2FA string) (
 	apiCode int,
 	apiErrorMsg string,
 	accountInfo preferences.AccountStatus,
+	respCaptchaID string,
+	respCaptchaImage string,
 	err error) {
 
 	// delete current session (if exists)
-	if err := s.SessionDelete(); err != nil {
+	if _, err := s.SessionDelete(); err != nil {
 		log.Error("Creating new session -> Failed to delete active session: ", err)
 	}
 
@@ -1075,7 +2674,11 @@ func (s *Service) SessionNew(accountID string, forceLogin bool) (
 
 		}
 	}()
-	successResp, errorLimitResp, apiErr, err := s._api.SessionNew(accountID, publicKey, forceLogin)
+	// human-readable device name registered with the session
+	// (shown in the device list of the account)
+	deviceName, _ := os.Hostname()
+
+	successResp, errorLimitResp, errorCaptchaResp, apiErr, err := s._api.SessionNew(accountID, publicKey, forceLogin, captchaID, captcha, confirmation2FA, deviceName)
 
 	apiCode = 0
 	if apiErr != nil {
@@ -1086,24 +2689,30 @@ func (s *Service) SessionNew(accountID string, forceLogin bool) (
 		// if SessionsLimit response
 		if errorLimitResp != nil {
 			accountInfo = s.createAccountStatus(errorLimitResp.SessionLimitData)
-			return apiCode, apiErr.Message, accountInfo, err
+			return apiCode, apiErr.Message, accountInfo, "", "", err
 		}
 
-		// in case of other API error
+		// if captcha challenge response - forward the challenge data to the client
+		if errorCaptchaResp != nil {
+			return apiCode, apiErr.Message, accountInfo, errorCaptchaResp.CaptchaID, errorCaptchaResp.CaptchaImage, err
+		}
+
+		// in case of other API error ('2FA required' is reported this way: by the api code only)
 		if apiErr != nil {
-			return apiCode, apiErr.Message, accountInfo, err
+			return apiCode, apiErr.Message, accountInfo, "", "", err
 		}
 
 		// not API error
-		return apiCode, "", accountInfo, err
+		return apiCode, "", accountInfo, "", "", err
 	}
 
 	if successResp == nil {
-		return apiCode, "", accountInfo, fmt.Errorf("unexpected error when creating a new session")
+		return apiCode, "", accountInfo, "", "", fmt.Errorf("unexpected error when creating a new session")
 	}
 
 	// get account status info
 	accountInfo = s.createAccountStatus(successResp.ServiceStatus)
+	s.saveAccountStatus(accountInfo)
 
 	s.setCredentials(accountID,
 		successResp.Token,
@@ -1113,15 +2722,16 @@ func (s *Service) SessionNew(accountID string, forceLogin bool) (
 		privateKey,
 		successResp.WireGuard.IPAddress, 0)
 
-	return apiCode, "", accountInfo, nil
+	return apiCode, "", accountInfo, "", "", nil
 }
 
-// SessionDelete removes session info
-func (s *Service) SessionDelete() error {
+// SessionDelete removes session info.
+// Returns descriptions of the credential items which were securely wiped
+func (s *Service) SessionDelete() (wipedItems []string, err error) {
 	return s.logOut(true)
 }
 
-func (s *Service) logOut(needToDeleteOnBackend bool) error {
+func (s *Service) logOut(needToDeleteOnBackend bool) (wipedItems []string, err error) {
 
 	// stop session checker (use goroutine to avoid deadlocks)
 	go s.stopSessionChecker()
@@ -1135,17 +2745,74 @@ func (s *Service) logOut(needToDeleteOnBackend bool) error {
 			log.Info("Logging out")
 			err := s._api.SessionDelete(session.Session)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
+	// zero the old credentials on disk before the session-less settings are saved
+	// (the settings file contains the session token, the OpenVPN credentials
+	// and the WG private key)
+	wipedItems = s.wipeStoredCredentials()
+
+	// overwrite the in-memory Session object (best effort: Go strings are immutable,
+	// the old values stay in memory until collected by GC)
 	s._preferences.SetSession("", "", "", "", "", "", "")
 
 	// notify clients about session update
 	s._evtReceiver.OnServiceSessionChanged()
 
-	return nil
+	return wipedItems, nil
+}
+
+// wipeStoredCredentials - securely delete (overwrite with zeros, then remove)
+// all the files containing credentials: the settings file and the generated
+// configuration files. The settings file is saved again (without the session
+// data) right after the wipe.
+// Returns descriptions of the wiped items
+func (s *Service) wipeStoredCredentials() (wipedItems []string) {
+	files := []struct {
+		description string
+		path        string
+	}{
+		{"settings file (session token, VPN credentials)", platform.SettingsFile()},
+		{"WireGuard configuration (private key)", platform.WGConfigFilePath()},
+		{"OpenVPN proxy credentials", platform.OpenvpnProxyAuthFile()},
+		{"OpenVPN configuration", platform.OpenvpnConfigFile()}}
+
+	for _, f := range files {
+		if len(f.path) == 0 || helpers.FileExists(f.path) == false {
+			continue
+		}
+		if err := helpers.WipeFile(f.path); err != nil {
+			log.Warning(fmt.Sprintf("Failed to wipe '%s': ", f.path), err)
+			continue
+		}
+		wipedItems = append(wipedItems, f.description)
+	}
+	return wipedItems
+}
+
+// DeviceList - get devices registered to the account
+func (s *Service) DeviceList() ([]types.DeviceInfo, error) {
+	session := s.Preferences().Session
+	if session.IsLoggedIn() == false {
+		return nil, ErrorNotLoggedIn{}
+	}
+	return s._api.DeviceList(session.Session)
+}
+
+// DeviceDelete - remove a device registered to the account (logs out that device)
+// Allows the user to resolve the 'session limit' situation from within the app
+func (s *Service) DeviceDelete(deviceID string) error {
+	session := s.Preferences().Session
+	if session.IsLoggedIn() == false {
+		return ErrorNotLoggedIn{}
+	}
+	if len(deviceID) == 0 {
+		return fmt.Errorf("device ID not defined")
+	}
+	return s._api.DeviceDelete(session.Session, deviceID)
 }
 
 // RequestSessionStatus receives session status
@@ -1181,7 +2848,19 @@ func (s *Service) RequestSessionStatus() (
 		if apiCode == types.SessionNotFound {
 			// Logging out now
 			log.Info("Session not found. Logging out.")
+
+			// the session is not valid anymore - the tunnel can not be kept alive
+			// (the next key rotation would fail); disconnecting before logging out
+			if s.Connected() {
+				if err := s.Disconnect(); err != nil {
+					log.Error("Failed to disconnect (session expired): ", err)
+				}
+			}
+
 			s.logOut(false)
+
+			// notify clients: UIs are expected to prompt the user to log in again
+			s._evtReceiver.OnSessionExpired("Session not found (logged out from another device?)")
 		}
 
 		// notify clients that account not active
@@ -1208,6 +2887,8 @@ func (s *Service) RequestSessionStatus() (
 
 	// get account status info
 	accountInfo = s.createAccountStatus(*stat)
+	// persist the status and notify about expiry\downgrade conditions
+	s.saveAccountStatus(accountInfo)
 	// notify about account status
 	s._evtReceiver.OnAccountStatus(session.Session, accountInfo)
 
@@ -1215,6 +2896,51 @@ func (s *Service) RequestSessionStatus() (
 	return apiCode, "", session.Session, accountInfo, nil
 }
 
+// saveAccountStatus persists the last known account status and notifies clients
+// when the subscription is about to expire, already in the grace period
+// or some capabilities were revoked since the previous check (subscription downgrade)
+func (s *Service) saveAccountStatus(account preferences.AccountStatus) {
+	oldAccount := s.Preferences().Account
+	s._preferences.UpdateAccountStatus(account)
+
+	// capabilities which were available before and are not anymore
+	newCaps := make(map[string]struct{}, len(account.Capabilities))
+	for _, c := range account.Capabilities {
+		newCaps[c] = struct{}{}
+	}
+	revoked := make([]string, 0)
+	for _, c := range oldAccount.Capabilities {
+		if _, ok := newCaps[c]; ok == false {
+			revoked = append(revoked, c)
+		}
+	}
+
+	secondsLeft := account.ActiveUntil - time.Now().Unix()
+	isAboutToExpire := account.WillAutoRebill == false && secondsLeft > 0 && secondsLeft <= int64(accountExpiryWarningPeriod/time.Second)
+	// grace period: account is still marked as active but the paid period is over
+	isGracePeriod := account.Active && account.ActiveUntil > 0 && secondsLeft <= 0
+
+	if isAboutToExpire || isGracePeriod || len(revoked) > 0 {
+		s._evtReceiver.OnAccountSubscriptionStatus(account, isGracePeriod, revoked)
+	}
+}
+
+// isAccountCapabilityAvailable - check feature availability for the current account
+// (daemon-side gating, e.g. port-forwarding\multihop;
+// when account status was never received - functionality is not blocked)
+func (s *Service) isAccountCapabilityAvailable(capability string) bool {
+	account := s.Preferences().Account
+	if account.ActiveUntil == 0 {
+		return true
+	}
+	for _, c := range account.Capabilities {
+		if strings.EqualFold(c, capability) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Service) createAccountStatus(apiResp types.ServiceStatusAPIResp) preferences.AccountStatus {
 	return preferences.AccountStatus{
 		Active:         apiResp.Active,
@@ -1305,8 +3031,9 @@ func (s *Service) WireGuardSaveNewKeys(wgPublicKey string, wgPrivateKey string,
 
 // WireGuardSetKeysRotationInterval change WG key rotation interval
 func (s *Service) WireGuardSetKeysRotationInterval(interval int64) {
-	s._preferences.Session.WGKeysRegenInerval = time.Second * time.Duration(interval)
-	s._preferences.SavePreferences()
+	s._preferences.Modify(func(p *preferences.Preferences) {
+		p.Session.WGKeysRegenInerval = time.Second * time.Duration(interval)
+	})
 
 	// restart WG keys rotation
 	if err := s._wgKeysMgr.StartKeysRotation(); err != nil {
@@ -1319,7 +3046,7 @@ func (s *Service) WireGuardSetKeysRotationInterval(interval int64) {
 
 // WireGuardGetKeys get WG keys
 func (s *Service) WireGuardGetKeys() (session, wgPublicKey, wgPrivateKey, wgLocalIP string, generatedTime time.Time, updateInterval time.Duration) {
-	p := s._preferences
+	p := s._preferences.GetSnapshot()
 
 	interval := p.Session.WGKeysRegenInerval
 
@@ -1340,7 +3067,8 @@ func (s *Service) WireGuardGetKeys() (session, wgPublicKey, wgPrivateKey, wgLoca
 
 // WireGuardGenerateKeys - generate new wireguard keys
 func (s *Service) WireGuardGenerateKeys(updateIfNecessary bool) error {
-	if s._preferences.Session.IsLoggedIn() == false {
+	session := s.Preferences().Session
+	if session.IsLoggedIn() == false {
 		return ErrorNotLoggedIn{}
 	}
 
@@ -1363,9 +3091,7 @@ func (s *Service) WireGuardGenerateKeys(updateIfNecessary bool) error {
 //////////////////////////////////////////////////////////
 
 func (s *Service) setPreferences(p preferences.Preferences) {
-	//if reflect.DeepEqual(s._preferences, p) == false {
-	if s._preferences != p {
-		s._preferences = p
-		s._preferences.SavePreferences()
-	}
+	s._preferences.Modify(func(prefs *preferences.Preferences) {
+		*prefs = p
+	})
 }
@@ -0,0 +1,92 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/service/socksproxy"
+)
+
+// Local SOCKS5 proxy: applications configured to use the proxy get an explicit
+// routing of their traffic - always through the VPN tunnel ('vpn' mode) or always
+// outside of it ('bypass' mode) - regardless of the split-tunneling configuration.
+// See the 'socks_proxy', 'socks_proxy_port' and 'socks_proxy_mode' preferences.
+
+// applySocksProxyPreference - start\stop the local SOCKS5 proxy according to preferences
+func (s *Service) applySocksProxyPreference() error {
+	prefs := s.Preferences()
+
+	if prefs.IsSocksProxy == false {
+		return socksproxy.Stop()
+	}
+
+	var sourceIPFunc func() (net.IP, error)
+	switch prefs.SocksProxyMode {
+	case "", "vpn":
+		// proxied traffic always goes through the tunnel
+		sourceIPFunc = s.tunnelSourceIP
+	case "bypass":
+		// proxied traffic always bypasses the tunnel
+		sourceIPFunc = outsideSourceIP
+	default:
+		return fmt.Errorf("unsupported SOCKS proxy mode: '%s'", prefs.SocksProxyMode)
+	}
+
+	return socksproxy.Start(socksproxy.Config{
+		Port:         prefs.SocksProxyPort,
+		SourceIPFunc: sourceIPFunc})
+}
+
+// tunnelSourceIP - client address inside the tunnel:
+// outgoing connections bound to this address go through the tunnel
+func (s *Service) tunnelSourceIP() (net.IP, error) {
+	tunnelIP := s._tunnelLocalIP
+	if tunnelIP == nil {
+		return nil, fmt.Errorf("VPN is not connected")
+	}
+	return tunnelIP, nil
+}
+
+// outsideSourceIP - local address of the physical interface (the interface of the default gateway):
+// outgoing connections bound to this address bypass the tunnel
+func outsideSourceIP() (net.IP, error) {
+	gatewayIP, err := netinfo.DefaultGatewayIP()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain default gateway: %w", err)
+	}
+
+	localAddresses, err := netinfo.GetAllLocalV4Addresses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain local addresses: %w", err)
+	}
+	for _, addr := range localAddresses {
+		if addr.Contains(gatewayIP) {
+			return addr.IP, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no local address in the default gateway network")
+}
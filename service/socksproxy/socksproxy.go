@@ -0,0 +1,274 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+// Package socksproxy implements a local SOCKS5 server bound to the loopback interface:
+// applications configured to use the proxy get an explicit routing of their traffic
+// (always through the VPN tunnel or always outside of it - regardless of the
+// split-tunneling configuration); no authentication, 'CONNECT' command only.
+// The routing is implemented by binding the outgoing connections to a specific
+// source address (the tunnel address or the address of the physical interface).
+package socksproxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("socks")
+}
+
+const (
+	// DefaultPort - listen port used when the configuration does not define one
+	DefaultPort = 1080
+
+	dialTimeout      = time.Second * 10
+	handshakeTimeout = time.Second * 5
+
+	socksVersion = 0x05
+	cmdConnect   = 0x01
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+
+	replySuccess         = 0x00
+	replyGeneralFailure  = 0x01
+	replyHostUnreachable = 0x04
+	replyCmdNotSupported = 0x07
+)
+
+// Config - local SOCKS5 proxy configuration
+type Config struct {
+	// Port - listen port on the loopback interface (0 - DefaultPort)
+	Port int
+	// SourceIPFunc - source address for the outgoing connections
+	// (defines whether the proxied traffic goes through the tunnel or outside of it);
+	// called for every proxied connection; an error fails the connection
+	SourceIPFunc func() (net.IP, error)
+}
+
+var (
+	mutex     sync.Mutex
+	isRunning bool
+	listener  net.Listener
+)
+
+// Start - start local SOCKS5 proxy with the defined configuration
+// (when the proxy is already running - it is restarted)
+func Start(cfg Config) error {
+	if cfg.SourceIPFunc == nil {
+		return fmt.Errorf("source address function not defined")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = DefaultPort
+	}
+
+	if err := Stop(); err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	l, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(cfg.Port))
+	if err != nil {
+		return fmt.Errorf("failed to start SOCKS5 proxy: %w", err)
+	}
+
+	isRunning = true
+	listener = l
+
+	go serve(l, cfg)
+
+	log.Info(fmt.Sprintf("Started (127.0.0.1:%d)", cfg.Port))
+	return nil
+}
+
+// Stop - stop local SOCKS5 proxy (does nothing when the proxy is not running)
+func Stop() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isRunning == false {
+		return nil
+	}
+	isRunning = false
+
+	if listener != nil {
+		listener.Close()
+		listener = nil
+	}
+
+	log.Info("Stopped")
+	return nil
+}
+
+func serve(l net.Listener, cfg Config) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return // listener closed (proxy stopped)
+		}
+
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Error("PANIC: ", r)
+				}
+				conn.Close()
+			}()
+
+			if err := processConnection(conn, cfg); err != nil {
+				log.Warning("Connection error: ", err)
+			}
+		}()
+	}
+}
+
+// processConnection - SOCKS5 handshake + request processing + data forwarding
+func processConnection(conn net.Conn, cfg Config) error {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	// greeting: VER NMETHODS METHODS...
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("failed to read greeting: %w", err)
+	}
+	if header[0] != socksVersion {
+		return fmt.Errorf("unsupported SOCKS version: %d", header[0])
+	}
+	methods := make([]byte, int(header[1]))
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return fmt.Errorf("failed to read authentication methods: %w", err)
+	}
+	// 'no authentication required' (the proxy is bound to the loopback interface)
+	if _, err := conn.Write([]byte{socksVersion, 0x00}); err != nil {
+		return err
+	}
+
+	// request: VER CMD RSV ATYP DST.ADDR DST.PORT
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return fmt.Errorf("failed to read request: %w", err)
+	}
+	if request[1] != cmdConnect {
+		sendReply(conn, replyCmdNotSupported)
+		return fmt.Errorf("unsupported command: %d", request[1])
+	}
+
+	destHost, err := readDestAddr(conn, request[3])
+	if err != nil {
+		sendReply(conn, replyGeneralFailure)
+		return err
+	}
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return fmt.Errorf("failed to read destination port: %w", err)
+	}
+	destPort := binary.BigEndian.Uint16(portBytes)
+
+	// source address defines the routing of the proxied traffic
+	sourceIP, err := cfg.SourceIPFunc()
+	if err != nil {
+		sendReply(conn, replyHostUnreachable)
+		return fmt.Errorf("no source address for proxied connection: %w", err)
+	}
+
+	dialer := net.Dialer{Timeout: dialTimeout}
+	if sourceIP != nil {
+		dialer.LocalAddr = &net.TCPAddr{IP: sourceIP}
+	}
+
+	dest := net.JoinHostPort(destHost, strconv.Itoa(int(destPort)))
+	destConn, err := dialer.Dial("tcp", dest)
+	if err != nil {
+		sendReply(conn, replyHostUnreachable)
+		return fmt.Errorf("failed to connect to '%s': %w", dest, err)
+	}
+	defer destConn.Close()
+
+	if err := sendReply(conn, replySuccess); err != nil {
+		return err
+	}
+
+	// forwarding data in both directions
+	conn.SetDeadline(time.Time{})
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(destConn, conn)
+		destConn.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, destConn)
+		conn.Close()
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+	return nil
+}
+
+// readDestAddr - read the destination address of a request (IPv4\IPv6\domain name)
+func readDestAddr(conn net.Conn, addrType byte) (string, error) {
+	switch addrType {
+	case atypIPv4:
+		addr := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		return net.IP(addr).String(), nil
+	case atypIPv6:
+		addr := make([]byte, net.IPv6len)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return "", err
+		}
+		return net.IP(addr).String(), nil
+	case atypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, int(length[0]))
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		return string(domain), nil
+	}
+	return "", fmt.Errorf("unsupported address type: %d", addrType)
+}
+
+// sendReply - send a reply with the defined status (bind address is not in use: zeroes)
+func sendReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,125 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/service/splittun"
+)
+
+// interval of checking running processes of the split-tunneling applications
+const splitTunnelMonitorInterval = time.Second * 10
+
+// SplitTunnelSet - apply and save new split-tunneling configuration
+// isInverse == true - inverse mode: only the configured applications are routed
+// through the tunnel, everything else uses the default route
+func (s *Service) SplitTunnelSet(isEnabled bool, isInverse bool, apps []string) error {
+	if err := splittun.ApplyConfig(isEnabled, isInverse, apps); err != nil {
+		return err
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	prefs.IsSplitTunnel = isEnabled
+	prefs.IsSplitTunnelInverse = isInverse
+	prefs.SplitTunnelApps = apps
+	s.setPreferences(prefs)
+
+	isEnabled, isInverse, apps, runningApps := s.SplitTunnelStatus()
+	s._evtReceiver.OnSplitTunnelStatusChanged(isEnabled, isInverse, apps, runningApps)
+	return nil
+}
+
+// SplitTunnelAddApp - add application (path to binary) to the split-tunneling configuration
+func (s *Service) SplitTunnelAddApp(appBinaryPath string) error {
+	prefs := s.Preferences()
+	for _, app := range prefs.SplitTunnelApps {
+		if app == appBinaryPath {
+			return nil // already defined
+		}
+	}
+	return s.SplitTunnelSet(prefs.IsSplitTunnel, prefs.IsSplitTunnelInverse, append(prefs.SplitTunnelApps, appBinaryPath))
+}
+
+// SplitTunnelRemoveApp - remove application from the split-tunneling configuration
+func (s *Service) SplitTunnelRemoveApp(appBinaryPath string) error {
+	prefs := s.Preferences()
+	apps := make([]string, 0, len(prefs.SplitTunnelApps))
+	for _, app := range prefs.SplitTunnelApps {
+		if app != appBinaryPath {
+			apps = append(apps, app)
+		}
+	}
+	return s.SplitTunnelSet(prefs.IsSplitTunnel, prefs.IsSplitTunnelInverse, apps)
+}
+
+// SplitTunnelSetRoutes - save per-IP\subnet split-tunneling rules
+// The rules are applied during route setup of the next VPN connection
+func (s *Service) SplitTunnelSetRoutes(bypassCIDRs []string, vpnCIDRs []string) error {
+	for _, cidr := range append(append([]string{}, bypassCIDRs...), vpnCIDRs...) {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid CIDR '%s': %w", cidr, err)
+		}
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	prefs.SplitTunnelBypassCIDRs = bypassCIDRs
+	prefs.SplitTunnelVpnCIDRs = vpnCIDRs
+	s.setPreferences(prefs)
+	return nil
+}
+
+// SplitTunnelStatus - current split-tunneling configuration
+// (including the list of configured applications which are currently running)
+func (s *Service) SplitTunnelStatus() (isEnabled bool, isInverse bool, apps []string, runningApps []string) {
+	prefs := s.Preferences()
+	return prefs.IsSplitTunnel, prefs.IsSplitTunnelInverse, prefs.SplitTunnelApps, splittun.GetRunningApps(prefs.SplitTunnelApps)
+}
+
+// startSplitTunnelMonitor - start background monitoring of the split-tunneling applications:
+// newly launched processes are registered for exclusion from the tunnel
+// and clients are notified when the set of running applications changes
+func (s *Service) startSplitTunnelMonitor() {
+	go func() {
+		lastRunning := ""
+		for {
+			time.Sleep(splitTunnelMonitorInterval)
+
+			prefs := s.Preferences()
+			if prefs.IsSplitTunnel == false || len(prefs.SplitTunnelApps) == 0 {
+				lastRunning = ""
+				continue
+			}
+
+			runningApps := splittun.GetRunningApps(prefs.SplitTunnelApps)
+			running := strings.Join(runningApps, "\n")
+			if running != lastRunning {
+				lastRunning = running
+				s._evtReceiver.OnSplitTunnelStatusChanged(prefs.IsSplitTunnel, prefs.IsSplitTunnelInverse, prefs.SplitTunnelApps, runningApps)
+			}
+		}
+	}()
+}
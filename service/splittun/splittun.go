@@ -0,0 +1,75 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package splittun
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("spltun")
+}
+
+var mutex sync.Mutex
+
+// Initialize is doing initialization stuff
+// Must be called on application start
+func Initialize() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return implInitialize()
+}
+
+// ApplyConfig - apply split-tunneling configuration
+// (when isEnabled == false - all split-tunneling rules are removed)
+// isInverse == true - inverse mode: only 'apps' are routed through the tunnel,
+// everything else uses the default route
+func ApplyConfig(isEnabled bool, isInverse bool, apps []string) error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if isEnabled {
+		log.Info("Enabling...")
+	} else {
+		log.Info("Disabling...")
+	}
+
+	if err := implApplyConfig(isEnabled, isInverse, apps); err != nil {
+		log.Error(err)
+		return fmt.Errorf("failed to apply split-tunneling configuration: %w", err)
+	}
+	return nil
+}
+
+// GetRunningApps returns the subset of configured applications which are currently running
+// (their processes are also registered for exclusion from the tunnel, when supported by the platform)
+func GetRunningApps(apps []string) []string {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return implGetRunningApps(apps)
+}
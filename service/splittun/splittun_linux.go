@@ -0,0 +1,159 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package splittun
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// Split-tunneling (Linux): processes of excluded applications are placed into
+// a net_cls cgroup; their traffic is marked by iptables and routed outside the
+// tunnel by an 'ip rule' pointing to a separate routing table with a default
+// route via the physical gateway.
+const (
+	cgroupFolder  = "/sys/fs/cgroup/net_cls/ivpn_exclude"
+	cgroupClassID = "0x49560001"
+	fwMark        = "0xca6c"
+	routingTable  = "72"
+)
+
+// true when split-tunneling rules are applied
+// (only then it makes sense to register processes in the cgroup)
+var isActive bool
+
+func implInitialize() error {
+	// remove rules which can be left after a daemon crash
+	cleanupRules()
+	return nil
+}
+
+func implApplyConfig(isEnabled bool, isInverse bool, apps []string) error {
+	cleanupRules()
+	isActive = false
+
+	if isEnabled == false || len(apps) == 0 {
+		return nil
+	}
+
+	// ensure cgroup exists
+	if err := os.MkdirAll(cgroupFolder, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup: %w", err)
+	}
+	if err := ioutil.WriteFile(path.Join(cgroupFolder, "net_cls.classid"), []byte(cgroupClassID), 0644); err != nil {
+		return fmt.Errorf("failed to set cgroup class id: %w", err)
+	}
+
+	// mark traffic of the cgroup processes
+	// (in inverse mode - mark traffic of everything EXCEPT the cgroup processes:
+	// only the configured applications stay in the tunnel)
+	markRuleArgs := []string{"-t", "mangle", "-A", "OUTPUT", "-m", "cgroup"}
+	if isInverse {
+		markRuleArgs = append(markRuleArgs, "!")
+	}
+	markRuleArgs = append(markRuleArgs, "--cgroup", cgroupClassID, "-j", "MARK", "--set-mark", fwMark)
+	if err := shell.Exec(log, "iptables", markRuleArgs...); err != nil {
+		return fmt.Errorf("failed to add iptables mark rule: %w", err)
+	}
+	// marked traffic have to leave with the source address of the physical interface
+	if err := shell.Exec(log, "iptables", "-t", "nat", "-A", "POSTROUTING", "-m", "mark", "--mark", fwMark, "-j", "MASQUERADE"); err != nil {
+		return fmt.Errorf("failed to add iptables masquerade rule: %w", err)
+	}
+
+	// route marked traffic outside the tunnel
+	gw, err := netinfo.DefaultGatewayIP()
+	if err != nil {
+		return fmt.Errorf("failed to detect default gateway: %w", err)
+	}
+	if err := shell.Exec(log, "ip", "route", "add", "default", "via", gw.String(), "table", routingTable); err != nil {
+		return fmt.Errorf("failed to add routing table: %w", err)
+	}
+	if err := shell.Exec(log, "ip", "rule", "add", "fwmark", fwMark, "table", routingTable); err != nil {
+		return fmt.Errorf("failed to add routing rule: %w", err)
+	}
+
+	isActive = true
+	return nil
+}
+
+func cleanupRules() {
+	// errors ignored here: the rules can be not defined
+	shell.Exec(nil, "iptables", "-t", "mangle", "-D", "OUTPUT", "-m", "cgroup", "--cgroup", cgroupClassID, "-j", "MARK", "--set-mark", fwMark)
+	shell.Exec(nil, "iptables", "-t", "mangle", "-D", "OUTPUT", "-m", "cgroup", "!", "--cgroup", cgroupClassID, "-j", "MARK", "--set-mark", fwMark)
+	shell.Exec(nil, "iptables", "-t", "nat", "-D", "POSTROUTING", "-m", "mark", "--mark", fwMark, "-j", "MASQUERADE")
+	shell.Exec(nil, "ip", "rule", "del", "fwmark", fwMark, "table", routingTable)
+	shell.Exec(nil, "ip", "route", "flush", "table", routingTable)
+}
+
+func implGetRunningApps(apps []string) []string {
+	var running []string
+	if len(apps) == 0 {
+		return running
+	}
+
+	procDirs, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		log.Error("Failed to read /proc: ", err)
+		return running
+	}
+
+	found := make(map[string]struct{})
+	for _, procDir := range procDirs {
+		pid, err := strconv.Atoi(procDir.Name())
+		if err != nil {
+			continue // not a process folder
+		}
+
+		exePath, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+		if err != nil {
+			continue
+		}
+
+		for _, app := range apps {
+			if app != exePath {
+				continue
+			}
+			found[app] = struct{}{}
+
+			// register process in the cgroup - exclude it from the tunnel
+			if isActive {
+				if err := ioutil.WriteFile(path.Join(cgroupFolder, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644); err != nil {
+					log.Error("Failed to register process in cgroup: ", err)
+				}
+			}
+		}
+	}
+
+	for _, app := range apps {
+		if _, ok := found[app]; ok {
+			running = append(running, app)
+		}
+	}
+	return running
+}
@@ -0,0 +1,239 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/metrics"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+)
+
+const (
+	// connection statistics polling interval
+	statsPollingInterval = time.Second * 5
+	// maximum amount of per-session records stored in the usage history file
+	statsHistoryMaxRecords = 90
+	// WireGuard handshake check interval (dead-peer detection)
+	wgHandshakeCheckInterval = time.Second * 10
+)
+
+// SessionStats - cumulative traffic counters of one VPN session (connect...disconnect)
+// Such records are stored in the usage history file (e.g. for a usage history view in UI)
+type SessionStats struct {
+	VpnType        string
+	ConnectedAt    int64 // unix time (seconds)
+	DisconnectedAt int64 // unix time (seconds)
+	BytesReceived  uint64
+	BytesSent      uint64
+}
+
+// statsHistoryFile - path to the usage history file (located near the daemon settings file)
+func statsHistoryFile() string {
+	return filepath.Join(filepath.Dir(platform.SettingsFile()), "stats_history.json")
+}
+
+// statsPoller - periodically obtain connection statistics from the active VPN process
+// and notify connected clients
+// On exit - cumulative per-session totals are saved into the usage history
+func (s *Service) statsPoller(vpnProc vpn.Process, stopChannel <-chan bool) {
+	var totalReceived, totalSent uint64
+	var lastReceived, lastSent uint64
+	connectedAt := time.Now().Unix()
+
+	// usage accounting: bytes not yet flushed into the per-day\per-server buckets
+	var usageServer string
+	var usageReceived, usageSent uint64
+	usageFlushedAt := time.Now()
+
+	defer func() {
+		s.addUsageStats(usageServer, usageReceived, usageSent)
+
+		if totalReceived == 0 && totalSent == 0 {
+			return // nothing was transferred (e.g. connection failed)
+		}
+		s.saveSessionStats(SessionStats{
+			VpnType:        vpnProc.Type().String(),
+			ConnectedAt:    connectedAt,
+			DisconnectedAt: time.Now().Unix(),
+			BytesReceived:  totalReceived,
+			BytesSent:      totalSent})
+	}()
+
+	ticker := time.NewTicker(statsPollingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.Connected() == false || vpnProc.IsPaused() {
+				continue
+			}
+
+			stats, err := vpnProc.GetStats()
+			if err != nil {
+				continue // not an error: statistics can be not available in some states (e.g. connecting)
+			}
+
+			// counters are absolute values for the current tunnel: accumulating deltas
+			// (a counter smaller than the previous value means the tunnel was re-created)
+			var deltaReceived, deltaSent uint64
+			if stats.BytesReceived >= lastReceived {
+				deltaReceived = stats.BytesReceived - lastReceived
+			} else {
+				deltaReceived = stats.BytesReceived
+			}
+			if stats.BytesSent >= lastSent {
+				deltaSent = stats.BytesSent - lastSent
+			} else {
+				deltaSent = stats.BytesSent
+			}
+			totalReceived += deltaReceived
+			totalSent += deltaSent
+			lastReceived, lastSent = stats.BytesReceived, stats.BytesSent
+
+			// usage accounting: flushing accumulated bytes once per minute
+			// (the endpoint host is the identifier of the connected server)
+			if host, _, err := net.SplitHostPort(stats.Endpoint); err == nil && len(host) > 0 {
+				usageServer = host
+			}
+			usageReceived += deltaReceived
+			usageSent += deltaSent
+			if time.Since(usageFlushedAt) >= time.Minute {
+				s.addUsageStats(usageServer, usageReceived, usageSent)
+				usageReceived, usageSent = 0, 0
+				usageFlushedAt = time.Now()
+				s.checkDataCapLimits()
+			}
+
+			s._evtReceiver.OnConnectionStats(stats.BytesReceived, stats.BytesSent, stats.LastHandshake, stats.Endpoint)
+
+			metrics.SetGauge("ivpn_bytes_received", float64(totalReceived))
+			metrics.SetGauge("ivpn_bytes_sent", float64(totalSent))
+
+		case <-stopChannel:
+			return
+		}
+	}
+}
+
+// wgHandshakeMonitor - dead-peer detection for WireGuard connections:
+// WireGuard has no disconnection event - a silently dead tunnel looks 'connected' forever.
+// The monitor tracks the latest handshake timestamp of the active peer and,
+// when there is no handshake for 'WireGuardHandshakeTimeout' - notifies the clients
+// and triggers the reconnection subsystem (which roams to an alternate server if necessary)
+func (s *Service) wgHandshakeMonitor(vpnProc vpn.Process, stopChannel <-chan bool) {
+	if vpnProc.Type() != vpn.WireGuard {
+		return // handshake monitoring is applicable only for WireGuard
+	}
+
+	timeoutSec := s.Preferences().WireGuardHandshakeTimeout
+	if timeoutSec <= 0 {
+		return // dead-peer detection disabled by preferences
+	}
+	timeout := time.Second * time.Duration(timeoutSec)
+
+	log.Info("WireGuard handshake monitor started")
+	defer log.Info("WireGuard handshake monitor stopped")
+
+	// reference point for the case when no handshake occurred yet
+	lastActivity := time.Now()
+
+	ticker := time.NewTicker(wgHandshakeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if s.Connected() == false || vpnProc.IsPaused() {
+				lastActivity = time.Now()
+				continue
+			}
+
+			stats, err := vpnProc.GetStats()
+			if err != nil {
+				continue // not an error: statistics can be not available in some states
+			}
+
+			if stats.LastHandshake > 0 {
+				handshake := time.Unix(stats.LastHandshake, 0)
+				if handshake.After(lastActivity) {
+					lastActivity = handshake
+				}
+			}
+
+			if time.Since(lastActivity) >= timeout {
+				log.Info(fmt.Sprintf("No WireGuard handshake for %s. Reconnecting...", time.Since(lastActivity).Round(time.Second)))
+				s.notifyConnectionProgress("handshake_timeout", "VPN peer is not responding (no handshake)")
+
+				// reconnect in separate routine (do not block current thread)
+				go func() {
+					defer func() {
+						if r := recover(); r != nil {
+							log.Error("PANIC: ", r)
+						}
+					}()
+					s.reconnect()
+				}()
+				return
+			}
+
+		case <-stopChannel:
+			return
+		}
+	}
+}
+
+// saveSessionStats - append session record to the usage history file
+// (only the last 'statsHistoryMaxRecords' records are kept)
+func (s *Service) saveSessionStats(record SessionStats) {
+	history := make([]SessionStats, 0, statsHistoryMaxRecords+1)
+
+	if data, err := ioutil.ReadFile(statsHistoryFile()); err == nil {
+		if err := json.Unmarshal(data, &history); err != nil {
+			log.Warning("Failed to parse usage history file (file will be overwritten): ", err)
+			history = history[:0]
+		}
+	}
+
+	history = append(history, record)
+	if len(history) > statsHistoryMaxRecords {
+		history = history[len(history)-statsHistoryMaxRecords:]
+	}
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		log.Error("Failed to serialize usage history: ", err)
+		return
+	}
+
+	if err := ioutil.WriteFile(statsHistoryFile(), data, 0600); err != nil {
+		log.Error("Failed to save usage history: ", err)
+	}
+}
@@ -0,0 +1,129 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/api/types"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/version"
+)
+
+// Update check subsystem: periodically queries the API for the latest available versions
+// of the daemon and the bundled binaries (OpenVPN, WireGuard), downloads and
+// signature-verifies a new daemon artifact into the staging folder and notifies the
+// connected clients. The actual installation is performed by the platform installer.
+
+// interval of checking for available updates
+const updateCheckInterval = time.Hour * 12
+
+// delay of the first update check after the daemon start
+// (do not interfere with the start-up activities: servers list download, session check...)
+const updateCheckStartupDelay = time.Minute * 5
+
+// updatesFolder - staging folder for the downloaded (and signature-verified) update artifacts
+func updatesFolder() string {
+	return filepath.Join(filepath.Dir(platform.SettingsFile()), "updates")
+}
+
+// startUpdateChecker - periodic check for available updates (called on service initialization)
+func (s *Service) startUpdateChecker() {
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Error("PANIC in update checker: ", r)
+			}
+		}()
+
+		time.Sleep(updateCheckStartupDelay)
+		for {
+			s.checkForUpdates()
+			time.Sleep(updateCheckInterval)
+		}
+	}()
+}
+
+// checkForUpdates - single update check iteration:
+// download the (signed) update info; when a new daemon version is available -
+// stage its artifact and notify the connected clients
+func (s *Service) checkForUpdates() {
+	updateInfo, err := s._api.DownloadUpdateInfo()
+	if err != nil {
+		log.Warning("Failed to check for updates: ", err)
+		return
+	}
+
+	daemon := updateInfo.Daemon
+	if len(daemon.Version) == 0 || daemon.Version == version.Version() {
+		return // up to date
+	}
+	if daemon.Version == s._lastNotifiedUpdateVersion {
+		return // this version was already staged and the clients are notified
+	}
+
+	log.Info(fmt.Sprintf("New daemon version available: %s (current: %s)", daemon.Version, version.Version()))
+
+	stagedPath := ""
+	if len(daemon.URLPath) > 0 {
+		if stagedPath, err = s.stageUpdateArtifact(daemon); err != nil {
+			log.Error("Failed to stage update artifact: ", err)
+			stagedPath = ""
+		}
+	}
+
+	s._lastNotifiedUpdateVersion = daemon.Version
+	s._evtReceiver.OnUpdateAvailable(*updateInfo, stagedPath)
+}
+
+// stageUpdateArtifact - download the artifact, verify its signature and save it
+// into the staging folder (only a privileged user can access the staged file)
+func (s *Service) stageUpdateArtifact(artifact types.UpdateArtifactInfo) (string, error) {
+	if len(artifact.Signature) == 0 {
+		return "", fmt.Errorf("artifact signature is not defined")
+	}
+
+	// the artifact signature is verified during download;
+	// a corrupted or unsigned artifact is rejected
+	data, err := s._api.DownloadUpdateArtifact(artifact.URLPath, artifact.Signature)
+	if err != nil {
+		return "", err
+	}
+
+	folder := updatesFolder()
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return "", fmt.Errorf("failed to create updates staging folder: %w", err)
+	}
+
+	stagedPath := filepath.Join(folder, filepath.Base(artifact.URLPath))
+	if err := ioutil.WriteFile(stagedPath, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save update artifact: %w", err)
+	}
+
+	log.Info("Update artifact staged: ", stagedPath)
+	return stagedPath, nil
+}
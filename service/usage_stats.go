@@ -0,0 +1,185 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+)
+
+// Usage accounting: bytes transferred through the VPN aggregated into
+// 'day + server' buckets and persisted locally (e.g. to track the data usage
+// on metered connections). Only aggregated counters are stored - no timestamps
+// of individual sessions and no traffic details.
+
+// _usageStatsMaxBuckets - retention limit of the usage accounting storage
+// (oldest buckets are dropped first; ~3 servers used daily fit a full year)
+const _usageStatsMaxBuckets = 1000
+
+func usageStatsFile() string {
+	return filepath.Join(filepath.Dir(platform.SettingsFile()), "usage-stats.json")
+}
+
+// addUsageStats - add transferred bytes into the bucket of the current day and the given server
+// (a new bucket is created when necessary)
+func (s *Service) addUsageStats(server string, bytesReceived uint64, bytesSent uint64) {
+	if bytesReceived == 0 && bytesSent == 0 {
+		return
+	}
+
+	s._usageStatsMutex.Lock()
+	defer s._usageStatsMutex.Unlock()
+
+	s.loadUsageStatsNoLock()
+
+	day := time.Now().Format("2006-01-02")
+
+	// the actual bucket is normally the last one - searching from the end
+	isFound := false
+	for i := len(s._usageStats) - 1; i >= 0; i-- {
+		if s._usageStats[i].Day == day && s._usageStats[i].Server == server {
+			s._usageStats[i].BytesReceived += bytesReceived
+			s._usageStats[i].BytesSent += bytesSent
+			isFound = true
+			break
+		}
+	}
+	if isFound == false {
+		s._usageStats = append(s._usageStats, preferences.UsageStatsBucket{
+			Day:           day,
+			Server:        server,
+			BytesReceived: bytesReceived,
+			BytesSent:     bytesSent})
+	}
+
+	// apply retention limit
+	if len(s._usageStats) > _usageStatsMaxBuckets {
+		s._usageStats = s._usageStats[len(s._usageStats)-_usageStatsMaxBuckets:]
+	}
+
+	if data, err := json.Marshal(s._usageStats); err != nil {
+		log.Error("Failed to serialize usage statistics: ", err)
+	} else if err := ioutil.WriteFile(usageStatsFile(), data, 0600); err != nil {
+		log.Error("Failed to save usage statistics: ", err)
+	}
+}
+
+// UsageStats returns the stored per-day\per-server usage accounting buckets
+func (s *Service) UsageStats() []preferences.UsageStatsBucket {
+	s._usageStatsMutex.Lock()
+	defer s._usageStatsMutex.Unlock()
+
+	s.loadUsageStatsNoLock()
+
+	stats := make([]preferences.UsageStatsBucket, len(s._usageStats))
+	copy(stats, s._usageStats)
+	return stats
+}
+
+// loadUsageStatsNoLock reads the usage accounting storage from disk (once)
+// Expects '_usageStatsMutex' to be acquired by the caller
+func (s *Service) loadUsageStatsNoLock() {
+	if s._isUsageStatsLoaded {
+		return
+	}
+	s._isUsageStatsLoaded = true
+
+	data, err := ioutil.ReadFile(usageStatsFile())
+	if err != nil {
+		if os.IsNotExist(err) == false {
+			log.Warning("Failed to read usage statistics: ", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &s._usageStats); err != nil {
+		log.Warning("Failed to parse usage statistics: ", err)
+		s._usageStats = nil
+	}
+}
+
+// checkDataCapLimits - verify the configured data-cap limits against the stored
+// usage accounting buckets; when a limit is reached - notify clients once per
+// day\month and (optionally) pause the VPN connection.
+// See the 'datacap_daily_limit_mb', 'datacap_monthly_limit_mb'
+// and 'datacap_pause_on_limit' preferences.
+func (s *Service) checkDataCapLimits() {
+	prefs := s.Preferences()
+	if prefs.DataCapDailyLimitMB <= 0 && prefs.DataCapMonthlyLimitMB <= 0 {
+		return
+	}
+
+	day := time.Now().Format("2006-01-02")
+	month := day[:len("2006-01")]
+
+	s._usageStatsMutex.Lock()
+	s.loadUsageStatsNoLock()
+
+	var dayBytes, monthBytes uint64
+	for _, bucket := range s._usageStats {
+		if strings.HasPrefix(bucket.Day, month) == false {
+			continue
+		}
+		monthBytes += bucket.BytesReceived + bucket.BytesSent
+		if bucket.Day == day {
+			dayBytes += bucket.BytesReceived + bucket.BytesSent
+		}
+	}
+
+	var period string
+	var limitMB int
+	var transferredBytes uint64
+	if prefs.DataCapDailyLimitMB > 0 && dayBytes >= uint64(prefs.DataCapDailyLimitMB)*1024*1024 && s._dataCapNotifiedDay != day {
+		s._dataCapNotifiedDay = day
+		period, limitMB, transferredBytes = "day", prefs.DataCapDailyLimitMB, dayBytes
+	} else if prefs.DataCapMonthlyLimitMB > 0 && monthBytes >= uint64(prefs.DataCapMonthlyLimitMB)*1024*1024 && s._dataCapNotifiedMonth != month {
+		s._dataCapNotifiedMonth = month
+		period, limitMB, transferredBytes = "month", prefs.DataCapMonthlyLimitMB, monthBytes
+	}
+	s._usageStatsMutex.Unlock()
+
+	if len(period) == 0 {
+		return // no limit reached (or the alert was already sent)
+	}
+
+	log.Info(fmt.Sprintf("Data-cap limit reached: %d MB per %s (%d bytes transferred)", limitMB, period, transferredBytes))
+
+	isConnectionPaused := false
+	if prefs.IsDataCapPauseOnLimit && s.Connected() {
+		if err := s.Pause(0); err != nil {
+			log.Error("Failed to pause connection (data-cap limit): ", err)
+		} else {
+			isConnectionPaused = true
+		}
+	}
+
+	s._evtReceiver.OnDataCapReached(period, limitMB, transferredBytes, isConnectionPaused)
+}
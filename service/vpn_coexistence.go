@@ -0,0 +1,89 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+)
+
+// VPN coexistence policy values (behavior when another active VPN tunnel is detected on connect)
+const (
+	// VpnCoexistenceRefuse - do not connect while another VPN tunnel is active
+	VpnCoexistenceRefuse = "refuse"
+	// VpnCoexistenceTakeover (default) - connect and take over the default route
+	// (traffic of the other VPN keeps flowing through its tunnel only for its explicitly routed networks)
+	VpnCoexistenceTakeover = "takeover"
+	// VpnCoexistenceNested - connect through the other tunnel (tunnel-within-tunnel):
+	// the default route of the other VPN is kept untouched
+	VpnCoexistenceNested = "nested"
+)
+
+// checkVpnCoexistence - detect other active VPN tunnel interfaces (e.g. a corporate VPN)
+// and apply the configured coexistence policy.
+// Simultaneously active VPNs compete for the default route; without an explicit policy
+// the result is an undiagnosable routing breakage.
+// Clients are notified about the detection result (if any interfaces were detected).
+//
+// Returns the interface name to route the endpoint traffic through ('nested' policy;
+// empty for the other policies) or an error when the connection has to be refused.
+func (s *Service) checkVpnCoexistence() (nestedVpnInterface string, retErr error) {
+	// our own tunnel interface is not a 'foreign' VPN
+	wgConfigPath := platform.WGConfigFilePath()
+	ownInterfaces := []string{strings.TrimSuffix(filepath.Base(wgConfigPath), filepath.Ext(wgConfigPath))}
+
+	otherVpnInterfaces, err := netinfo.ActiveVpnInterfaces(ownInterfaces)
+	if err != nil {
+		log.Warning("Failed to detect other active VPN interfaces: ", err)
+		return "", nil
+	}
+	if len(otherVpnInterfaces) == 0 {
+		return "", nil
+	}
+
+	policy := s.Preferences().VpnCoexistencePolicy
+	if len(policy) == 0 {
+		policy = VpnCoexistenceTakeover
+	}
+
+	log.Warning(fmt.Sprintf("Other active VPN interface(s) detected: %s (coexistence policy: '%s')", strings.Join(otherVpnInterfaces, ", "), policy))
+	s._evtReceiver.OnOtherVpnDetected(otherVpnInterfaces, policy)
+
+	switch policy {
+	case VpnCoexistenceRefuse:
+		return "", fmt.Errorf("refusing to connect: another VPN is active (%s); disconnect it or change the VPN coexistence policy", strings.Join(otherVpnInterfaces, ", "))
+	case VpnCoexistenceNested:
+		// default route of the other VPN stays untouched: our tunnel traffic flows through it
+		log.Info(fmt.Sprintf("Connecting through the active VPN tunnel '%s' (tunnel-within-tunnel)", otherVpnInterfaces[0]))
+		return otherVpnInterfaces[0], nil
+	default:
+		// takeover: the routes of our tunnel have a priority over the default route of the other VPN
+		log.Info("Taking over the default route from the active VPN tunnel")
+	}
+
+	return "", nil
+}
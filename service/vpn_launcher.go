@@ -0,0 +1,123 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ivpn/desktop-app-daemon/obfsproxy"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+	"github.com/ivpn/desktop-app-daemon/service/platform/filerights"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/openvpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
+)
+
+// vpnLauncher - the production implementation of IVpnLauncher:
+// creates VPN process objects which execute the real OpenVPN\WireGuard binaries
+type vpnLauncher struct{}
+
+// GetDisabledFunctions returns info about functions which are disabled
+// Some functionality can be not accessible
+// It can happen, for example, if some external binaries not installed
+// (e.g. obfsproxy or WireGuard on Linux)
+func (vpnLauncher) GetDisabledFunctions() (wgErr, ovpnErr, obfspErr error) {
+	if err := filerights.CheckFileAccessRightsExecutable(platform.OpenVpnBinaryPath()); err != nil {
+		ovpnErr = fmt.Errorf("OpenVPN binary: %w", err)
+	}
+
+	if err := filerights.CheckFileAccessRightsExecutable(platform.ObfsproxyStartScript()); err != nil {
+		obfspErr = fmt.Errorf("obfsproxy binary: %w", err)
+	}
+
+	if err := filerights.CheckFileAccessRightsExecutable(platform.WgBinaryPath()); err != nil {
+		wgErr = fmt.Errorf("WireGuard binary: %w", err)
+	} else {
+		if err := filerights.CheckFileAccessRightsExecutable(platform.WgToolBinaryPath()); err != nil {
+			wgErr = fmt.Errorf("WireGuard tools binary: %w", err)
+		}
+	}
+
+	if errors.Is(ovpnErr, os.ErrNotExist) {
+		ovpnErr = fmt.Errorf("%w. Please install OpenVPN", ovpnErr)
+	}
+	if errors.Is(obfspErr, os.ErrNotExist) {
+		obfspErr = fmt.Errorf("%w. Please install obfsproxy binary", obfspErr)
+	}
+	if errors.Is(wgErr, os.ErrNotExist) {
+		wgErr = fmt.Errorf("%w. Please install WireGuard", wgErr)
+	}
+
+	return wgErr, ovpnErr, obfspErr
+}
+
+// NewOpenVPN - create OpenVPN process object
+func (vpnLauncher) NewOpenVPN(connectionParams openvpn.ConnectionParams, isObfsproxy bool, isObfs4 bool, obfs4Cert string, extraParameters string) (vpn.Process, error) {
+	vpnObj, err := openvpn.NewOpenVpnObject(
+		platform.OpenVpnBinaryPath(),
+		platform.OpenvpnConfigFile(),
+		platform.OpenvpnLogFile(),
+		isObfsproxy,
+		extraParameters,
+		connectionParams)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new openVPN object: %w", err)
+	}
+
+	if isObfs4 {
+		vpnObj.SetObfsProxyParams(obfsproxy.OBFS4, obfs4Cert)
+	}
+
+	return vpnObj, nil
+}
+
+// NewWireGuard - create WireGuard process object
+func (vpnLauncher) NewWireGuard(connectionParams wireguard.ConnectionParams) (vpn.Process, error) {
+	vpnObj, err := wireguard.NewWireGuardObject(
+		platform.WgBinaryPath(),
+		platform.WgToolBinaryPath(),
+		platform.WGConfigFilePath(),
+		connectionParams)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new WireGuard object: %w", err)
+	}
+	return vpnObj, nil
+}
+
+// vpnLauncher - the VPN process factory in use (the production implementation by default)
+func (s *Service) vpnLauncher() IVpnLauncher {
+	if s._vpnLauncher == nil {
+		return vpnLauncher{}
+	}
+	return s._vpnLauncher
+}
+
+// SetVpnLauncher - replace the production VPN process factory
+// (in use by the integration test harness; see 'protocol/integration_test.go')
+func (s *Service) SetVpnLauncher(launcher IVpnLauncher) {
+	s._vpnLauncher = launcher
+}
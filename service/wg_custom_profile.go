@@ -0,0 +1,275 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/service/firewall"
+	"github.com/ivpn/desktop-app-daemon/service/preferences"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
+)
+
+// Custom (non-IVPN) WireGuard profiles: an arbitrary WireGuard configuration
+// (wg-quick format) can be imported, stored as a named profile and used for
+// connection with the same kill-switch\DNS machinery as the IVPN servers.
+
+// ImportWGCustomProfile - parse a WireGuard configuration (wg-quick format)
+// and store it as a custom profile (a profile with the same name is replaced)
+func (s *Service) ImportWGCustomProfile(name string, config string) error {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return fmt.Errorf("profile name not defined")
+	}
+
+	profile, err := parseWGQuickConfig(name, config)
+	if err != nil {
+		return fmt.Errorf("failed to parse WireGuard configuration: %w", err)
+	}
+
+	prefs := s._preferences.GetSnapshot()
+	profiles := make([]preferences.WGCustomProfile, 0, len(prefs.WireGuardCustomProfiles)+1)
+	for _, p := range prefs.WireGuardCustomProfiles {
+		if p.Name != name {
+			profiles = append(profiles, p)
+		}
+	}
+	prefs.WireGuardCustomProfiles = append(profiles, profile)
+	s.setPreferences(prefs)
+
+	log.Info(fmt.Sprintf("Custom WireGuard profile imported: '%s'", name))
+	return nil
+}
+
+// RemoveWGCustomProfile - remove a stored custom WireGuard profile
+func (s *Service) RemoveWGCustomProfile(name string) error {
+	prefs := s._preferences.GetSnapshot()
+	profiles := make([]preferences.WGCustomProfile, 0, len(prefs.WireGuardCustomProfiles))
+	for _, p := range prefs.WireGuardCustomProfiles {
+		if p.Name != name {
+			profiles = append(profiles, p)
+		}
+	}
+	if len(profiles) == len(prefs.WireGuardCustomProfiles) {
+		return fmt.Errorf("custom WireGuard profile '%s' not found", name)
+	}
+	prefs.WireGuardCustomProfiles = profiles
+	s.setPreferences(prefs)
+	return nil
+}
+
+// WGCustomProfiles returns the stored custom WireGuard profiles
+// (private keys are redacted: they never leave the daemon)
+func (s *Service) WGCustomProfiles() []preferences.WGCustomProfile {
+	stored := s.Preferences().WireGuardCustomProfiles
+	profiles := make([]preferences.WGCustomProfile, len(stored))
+	copy(profiles, stored)
+	for i := range profiles {
+		profiles[i].PrivateKey = ""
+	}
+	return profiles
+}
+
+// ConnectWireGuardCustomProfile establish WireGuard connection using a stored custom profile
+// (no IVPN session required: credentials are taken from the imported configuration)
+func (s *Service) ConnectWireGuardCustomProfile(profileName string, manualDNS net.IP, firewallDuringConnection bool, stateChan chan<- vpn.StateInfo) error {
+	storedProfiles := s.Preferences().WireGuardCustomProfiles
+	var profile *preferences.WGCustomProfile
+	for i := range storedProfiles {
+		if storedProfiles[i].Name == profileName {
+			profile = &storedProfiles[i]
+			break
+		}
+	}
+	if profile == nil {
+		return fmt.Errorf("custom WireGuard profile '%s' not found", profileName)
+	}
+
+	// the endpoint host can be a domain name - resolving it here
+	endpointAddr, err := net.ResolveUDPAddr("udp", profile.Endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to resolve endpoint of the custom WireGuard profile: %w", err)
+	}
+
+	clientLocalIP, hostLocalIP, err := wgProfileTunnelAddresses(*profile)
+	if err != nil {
+		return err
+	}
+
+	connectionParams := wireguard.CreateConnectionParams(
+		"",
+		endpointAddr.Port,
+		endpointAddr.IP,
+		profile.PublicKey,
+		hostLocalIP)
+
+	connectionParams.SetCredentials(profile.PrivateKey, clientLocalIP)
+	if len(profile.AllowedIPs) > 0 {
+		connectionParams.SetCustomAllowedIPs(profile.AllowedIPs)
+	}
+
+	// firewall: keep destinations outside of a partial tunnel reachable
+	if err := firewall.SetPartialTunnelNetworks(wireguard.NotCapturedNetworks(connectionParams.CustomAllowedIPs())); err != nil {
+		log.Error("Failed to apply partial-tunnel networks to firewall: ", err)
+	}
+
+	// apply per-IP\subnet split-tunneling rules (if defined)
+	connectionParams.SetCustomRoutes(s.Preferences().SplitTunnelBypassCIDRs)
+
+	// tunnel MTU (0 - automatic path MTU probing)
+	connectionParams.SetMTU(s.Preferences().WireGuardMTU)
+	// persistent-keepalive interval: the profile value has a priority over the preference
+	if profile.PersistentKeepalive > 0 {
+		connectionParams.SetKeepAlive(profile.PersistentKeepalive)
+	} else {
+		connectionParams.SetKeepAlive(s.Preferences().WireGuardKeepAlive)
+	}
+	connectionParams.SetListenPort(s.Preferences().WireGuardListenPort)
+
+	// apply the coexistence policy when another VPN is active
+	nestedVpnInterface, err := s.checkVpnCoexistence()
+	if err != nil {
+		return err
+	}
+	if len(nestedVpnInterface) > 0 {
+		connectionParams.SetEndpointRouteInterface(nestedVpnInterface)
+	}
+
+	// DNS defined in the imported configuration (an explicit request value has a priority)
+	if manualDNS == nil && len(profile.DNS) > 0 {
+		manualDNS = net.ParseIP(profile.DNS)
+	}
+
+	createVpnObjfunc := func() (vpn.Process, error) {
+		// creating WireGuard object
+		return s.vpnLauncher().NewWireGuard(connectionParams)
+	}
+
+	// IPv6 leak protection: the custom profile connection is IPv4-only - block all IPv6 traffic
+	s.setBlockIPv6Required(true)
+
+	return s.keepConnectionEx(createVpnObjfunc, manualDNS, firewallDuringConnection, stateChan, false)
+}
+
+// wgProfileTunnelAddresses - tunnel-side addresses for a custom profile connection:
+// the client address is taken from the imported configuration;
+// the gateway (used as the default in-tunnel DNS) is the DNS of the configuration or,
+// when DNS is not defined, the first address of the client tunnel network
+func wgProfileTunnelAddresses(profile preferences.WGCustomProfile) (clientLocalIP net.IP, hostLocalIP net.IP, err error) {
+	addr := profile.Address
+	clientLocalIP, ipNet, err := net.ParseCIDR(addr)
+	if err != nil {
+		if clientLocalIP = net.ParseIP(addr); clientLocalIP == nil {
+			return nil, nil, fmt.Errorf("failed to parse client address of the custom WireGuard profile: '%s'", addr)
+		}
+	}
+
+	if len(profile.DNS) > 0 {
+		if hostLocalIP = net.ParseIP(profile.DNS); hostLocalIP != nil {
+			return clientLocalIP, hostLocalIP, nil
+		}
+	}
+
+	if ipNet != nil && clientLocalIP.To4() != nil {
+		// first host of the client tunnel network (e.g. 10.0.0.1 for 10.0.0.2/24)
+		hostLocalIP = make(net.IP, net.IPv4len)
+		copy(hostLocalIP, ipNet.IP.To4())
+		hostLocalIP[net.IPv4len-1]++
+		return clientLocalIP, hostLocalIP, nil
+	}
+
+	return clientLocalIP, clientLocalIP, nil
+}
+
+// parseWGQuickConfig - parse a WireGuard configuration in wg-quick format
+// (only the directives required for connection are recognized; unknown directives are ignored)
+func parseWGQuickConfig(name string, config string) (preferences.WGCustomProfile, error) {
+	profile := preferences.WGCustomProfile{Name: name}
+
+	section := ""
+	for _, line := range strings.Split(config, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			section = strings.ToLower(strings.Trim(line, "[] \t"))
+			continue
+		}
+
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) != 2 {
+			return profile, fmt.Errorf("unexpected line: '%s'", line)
+		}
+		key := strings.ToLower(strings.TrimSpace(fields[0]))
+		value := strings.TrimSpace(fields[1])
+
+		switch section + "/" + key {
+		case "interface/privatekey":
+			profile.PrivateKey = value
+		case "interface/address":
+			// only the first (IPv4) address is in use
+			profile.Address = strings.TrimSpace(strings.Split(value, ",")[0])
+		case "interface/dns":
+			profile.DNS = strings.TrimSpace(strings.Split(value, ",")[0])
+		case "peer/publickey":
+			profile.PublicKey = value
+		case "peer/endpoint":
+			profile.Endpoint = value
+		case "peer/allowedips":
+			for _, allowedIP := range strings.Split(value, ",") {
+				if allowedIP = strings.TrimSpace(allowedIP); len(allowedIP) > 0 {
+					profile.AllowedIPs = append(profile.AllowedIPs, allowedIP)
+				}
+			}
+		case "peer/persistentkeepalive":
+			if keepAlive, err := strconv.Atoi(value); err == nil {
+				profile.PersistentKeepalive = keepAlive
+			}
+		}
+	}
+
+	// validate mandatory directives
+	if len(profile.PrivateKey) == 0 {
+		return profile, fmt.Errorf("'PrivateKey' not defined")
+	}
+	if len(profile.Address) == 0 {
+		return profile, fmt.Errorf("'Address' not defined")
+	}
+	if len(profile.PublicKey) == 0 {
+		return profile, fmt.Errorf("'PublicKey' not defined")
+	}
+	if _, _, err := net.SplitHostPort(profile.Endpoint); err != nil {
+		return profile, fmt.Errorf("bad 'Endpoint' value: %w", err)
+	}
+	if _, _, err := wgProfileTunnelAddresses(profile); err != nil {
+		return profile, err
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,124 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/service/platform"
+)
+
+// WireGuard local IP conflict detection: the API assigns the in-tunnel address
+// (e.g. 172.16.x.x) without knowing the local network environment of the client.
+// When the assigned address belongs to an already existing local subnet
+// (e.g. a 172.16/12 corporate LAN) the routing of the tunnel silently breaks -
+// such conflicts manifest as inexplicable connectivity loss.
+// Therefore the address is checked before every connection attempt:
+// a detected conflict is reported to clients as a structured warning and
+// (when the 'wireguard_renew_ip_on_conflict' preference is enabled)
+// a new address assignment is requested from the API.
+
+// checkWGLocalIPConflict - detect whether the address belongs to a subnet of an
+// existing local interface. Returns name and network of the conflicting interface
+// (empty strings - no conflict detected)
+func (s *Service) checkWGLocalIPConflict(wgLocalIP net.IP) (conflictIfName string, conflictSubnet string) {
+	if wgLocalIP == nil {
+		return "", ""
+	}
+
+	// own WG tunnel interface (e.g. a not cleaned-up interface of a previous connection)
+	// is not a conflict
+	wgConfigPath := platform.WGConfigFilePath()
+	ownIfName := strings.TrimSuffix(filepath.Base(wgConfigPath), filepath.Ext(wgConfigPath))
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		log.Error("Failed to check WG local IP for conflicts: ", err)
+		return "", ""
+	}
+
+	for _, inf := range interfaces {
+		if (inf.Flags&net.FlagUp) == 0 || (inf.Flags&net.FlagLoopback) != 0 {
+			continue
+		}
+		if inf.Name == ownIfName {
+			continue
+		}
+
+		addresses, err := netinfo.GetInterfaceV4Addresses(inf)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addresses {
+			if ones, bits := addr.Mask.Size(); ones >= bits {
+				continue // a single-address route is not a subnet conflict
+			}
+			if addr.Contains(wgLocalIP) {
+				return inf.Name, addr.String()
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// resolveWGLocalIPConflict - check the WG local address for conflicts with local
+// subnets before connecting. A detected conflict is reported to clients;
+// when the 'wireguard_renew_ip_on_conflict' preference is enabled - a new
+// address assignment is requested from the API (new key pair generation).
+// Returns the address to use (the renewed one when the renewal succeeded)
+func (s *Service) resolveWGLocalIPConflict(wgLocalIP net.IP) net.IP {
+	ifName, subnet := s.checkWGLocalIPConflict(wgLocalIP)
+	if len(ifName) == 0 {
+		return wgLocalIP
+	}
+
+	log.Warning(fmt.Sprintf("WG local IP conflict: assigned address %s belongs to local subnet %s (interface '%s')", wgLocalIP, subnet, ifName))
+
+	isNewIPRequested := false
+	if s.Preferences().IsWGIPConflictRenew {
+		// new key pair generation implies a new address assignment
+		log.Info("Requesting new WG local address assignment from API...")
+		if err := s._wgKeysMgr.GenerateKeys(); err != nil {
+			log.Error("Failed to request new WG local address: ", err)
+		} else {
+			isNewIPRequested = true
+			if newIP := net.ParseIP(s.Preferences().Session.WGLocalIP); newIP != nil {
+				wgLocalIP = newIP
+				if ifName, subnet := s.checkWGLocalIPConflict(newIP); len(ifName) > 0 {
+					log.Warning(fmt.Sprintf("Renewed WG local address %s still conflicts with local subnet %s (interface '%s')", newIP, subnet, ifName))
+				}
+			}
+		}
+	}
+
+	// structured warning for clients (even when the address was renewed:
+	// the user may want to know the VPN competes with the local network addressing)
+	s._evtReceiver.OnWGLocalIPConflict(wgLocalIP.String(), ifName, subnet, isNewIPRequested)
+
+	return wgLocalIP
+}
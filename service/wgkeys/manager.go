@@ -30,6 +30,7 @@ import (
 	"github.com/ivpn/desktop-app-daemon/api"
 	"github.com/ivpn/desktop-app-daemon/api/types"
 	"github.com/ivpn/desktop-app-daemon/logger"
+	"github.com/ivpn/desktop-app-daemon/metrics"
 	"github.com/ivpn/desktop-app-daemon/vpn/wireguard"
 )
 
@@ -160,6 +161,7 @@ func (m *KeysManager) generateKeys(onlyUpdateIfNecessary bool) (retErr error) {
 	defer func() {
 		if retErr != nil {
 			log.Error("Failed to update WG keys: ", retErr)
+			metrics.IncCounter("ivpn_wg_key_rotation_failures_total")
 		}
 	}()
 
@@ -0,0 +1,129 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package sysevents
+
+import (
+	"time"
+
+	"github.com/ivpn/desktop-app-daemon/logger"
+)
+
+var log *logger.Logger
+
+func init() {
+	log = logger.NewLogger("sysevt")
+}
+
+// Event - type of a detected system event
+type Event int
+
+// System event types
+const (
+	// Wake - the system resumed from sleep\hibernation
+	Wake Event = iota
+	// NetworkChange - the network configuration changed (links\addresses\routes)
+	NetworkChange Event = iota
+)
+
+// sleep\wake detection parameters of the portable clock-jump detector:
+// the monitor ticks every 'wakeDetectInterval'; when the wall clock jumped forward
+// for more than 'wakeDetectThreshold' over the expected interval - the system was sleeping
+const (
+	wakeDetectInterval  = time.Second * 5
+	wakeDetectThreshold = time.Second * 30
+)
+
+// Monitor - OS events monitor (sleep\wake, network configuration changes).
+// Events are detected by platform-specific sources (when implemented) and by a portable
+// clock-jump detector which notices sleep\wake on every platform
+type Monitor struct {
+	notifyChan chan<- Event
+	stopChan   chan struct{}
+
+	// Must be implemented (AND USED) in correspond file for concrete platform. Must contain platform-specified properties (or can be empty struct)
+	props osSpecificProperties
+}
+
+// Create - create new system events monitor
+func Create() *Monitor {
+	return &Monitor{}
+}
+
+// Start - start monitoring (asynchronous)
+// 'notifyChan' is a notification channel
+func (m *Monitor) Start(notifyChan chan<- Event) error {
+	// Ensure that monitor is stopped
+	m.Stop()
+
+	m.notifyChan = notifyChan
+	m.stopChan = make(chan struct{})
+
+	go m.wakeDetector()
+	// method should be implemented in platform-specific file
+	go m.doStart()
+
+	return nil
+}
+
+// Stop - stop monitoring
+func (m *Monitor) Stop() {
+	if m.stopChan != nil {
+		close(m.stopChan)
+		m.stopChan = nil
+	}
+	// method should be implemented in platform-specific file
+	m.doStop()
+}
+
+// notify - forward the detected event to the subscriber
+func (m *Monitor) notify(evt Event) {
+	notifyChan := m.notifyChan
+	if notifyChan == nil {
+		return
+	}
+	notifyChan <- evt
+}
+
+// wakeDetector - portable sleep\wake detection: when the wall clock jumped forward
+// much more than the tick interval - the system was sleeping
+func (m *Monitor) wakeDetector() {
+	stopChan := m.stopChan
+
+	ticker := time.NewTicker(wakeDetectInterval)
+	defer ticker.Stop()
+
+	lastTick := time.Now()
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if now.Sub(lastTick) > wakeDetectInterval+wakeDetectThreshold {
+				log.Info("System wake detected (clock jump)")
+				m.notify(Wake)
+			}
+			lastTick = now
+		}
+	}
+}
@@ -0,0 +1,84 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package sysevents
+
+import (
+	"syscall"
+)
+
+// structure contains properties required for for Linux implementation
+type osSpecificProperties struct {
+	netlinkSocket int
+}
+
+// rtnetlink multicast groups to subscribe for
+// (link state + IPv4 address\route changes)
+const (
+	rtmGrpLink      = 0x1  // RTMGRP_LINK
+	rtmGrpIPv4Addr  = 0x10 // RTMGRP_IPV4_IFADDR
+	rtmGrpIPv4Route = 0x40 // RTMGRP_IPV4_ROUTE
+)
+
+// doStart - subscribe to rtnetlink notifications about network configuration changes.
+// Sleep\wake detection is covered by the portable clock-jump detector
+// TODO: subscribe to the 'logind' PrepareForSleep signal to receive the event before the actual sleep
+func (m *Monitor) doStart() {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_ROUTE)
+	if err != nil {
+		log.Error("Failed to create netlink socket: ", err)
+		return
+	}
+	m.props.netlinkSocket = fd
+
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: rtmGrpLink | rtmGrpIPv4Addr | rtmGrpIPv4Route}
+
+	if err := syscall.Bind(fd, addr); err != nil {
+		log.Error("Failed to bind netlink socket: ", err)
+		m.doStop()
+		return
+	}
+
+	log.Info("Started")
+	defer log.Info("Stopped")
+
+	buf := make([]byte, 4096)
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != nil {
+			// socket is closed by doStop()
+			return
+		}
+		if n > 0 {
+			m.notify(NetworkChange)
+		}
+	}
+}
+
+func (m *Monitor) doStop() {
+	if m.props.netlinkSocket != 0 {
+		syscall.Close(m.props.netlinkSocket)
+		m.props.netlinkSocket = 0
+	}
+}
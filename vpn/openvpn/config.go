@@ -32,6 +32,7 @@ import (
 
 	"github.com/ivpn/desktop-app-daemon/logger"
 	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/obfuscation"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
 )
 
@@ -48,6 +49,53 @@ type ConnectionParams struct {
 	proxyPort         int
 	proxyUsername     string
 	proxyPassword     string
+	// per-IP\subnet split-tunneling rules
+	bypassCIDRs []string // networks which have to bypass the tunnel
+	vpnCIDRs    []string // networks which always have to use the tunnel
+	// isIPv6 == true - request IPv6 configuration inside the tunnel (when the server supports it)
+	isIPv6 bool
+
+	// obfuscation transport for this connection (empty - no obfuscation)
+	obfuscationType obfuscation.Type
+	// obfuscation endpoint port on the server (0 - use the connection port)
+	obfuscationPort int
+	// local port of the started obfuscation transport (defined during connection establishing;
+	// applicable only for the tunnel-style transports 'tlstunnel'\'websocket')
+	obfuscationLocalPort int
+	// real server IPs saved during config generation when a tunnel-style transport is in use
+	// (the traffic of the transport to these IPs has to be routed outside the tunnel)
+	obfuscationRemoteIPs []net.IP
+}
+
+// SetObfuscation - define obfuscation transport for this connection
+// (transportType: empty - no obfuscation; 'obfs4', 'tlstunnel' or 'websocket';
+// remotePort - obfuscation endpoint port on the server, 0 - use the connection port)
+func (c *ConnectionParams) SetObfuscation(transportType string, remotePort int) {
+	c.obfuscationType = obfuscation.Type(strings.ToLower(strings.TrimSpace(transportType)))
+	c.obfuscationPort = remotePort
+}
+
+// ObfuscationType - obfuscation transport requested for this connection
+func (c *ConnectionParams) ObfuscationType() obfuscation.Type {
+	return c.obfuscationType
+}
+
+// SetCustomRoutes - define per-IP\subnet split-tunneling rules:
+// traffic to 'bypassCIDRs' networks is routed outside the tunnel,
+// traffic to 'vpnCIDRs' networks is always routed through the tunnel
+func (c *ConnectionParams) SetCustomRoutes(bypassCIDRs []string, vpnCIDRs []string) {
+	c.bypassCIDRs = bypassCIDRs
+	c.vpnCIDRs = vpnCIDRs
+}
+
+// SetIPv6 - request IPv6 configuration inside the tunnel (when the server supports it)
+func (c *ConnectionParams) SetIPv6(isIPv6 bool) {
+	c.isIPv6 = isIPv6
+}
+
+// IsIPv6 returns true when IPv6 was requested for this connection
+func (c *ConnectionParams) IsIPv6() bool {
+	return c.isIPv6
 }
 
 // SetCredentials update WG credentials
@@ -62,6 +110,28 @@ func (c *ConnectionParams) SetCredentials(username, password string) {
 	}
 }
 
+// HostIPs - destination server IP addresses
+func (c *ConnectionParams) HostIPs() []net.IP {
+	return c.hostIPs
+}
+
+// SetPort - change destination port and protocol
+// (in use by the automatic transport\port connection fallback)
+func (c *ConnectionParams) SetPort(port int, tcp bool) {
+	c.hostPort = port
+	c.tcp = tcp
+}
+
+// SetPreferredPort - apply preferred port\protocol combination saved in preferences
+// (ignored when the connection request already defines a destination port)
+func (c *ConnectionParams) SetPreferredPort(port int, tcp bool) {
+	if c.hostPort > 0 {
+		return
+	}
+	c.hostPort = port
+	c.tcp = tcp
+}
+
 // CreateConnectionParams creates OpenVPN connection parameters object
 func CreateConnectionParams(
 	multihopExitSrvID string,
@@ -74,6 +144,12 @@ func CreateConnectionParams(
 	proxyUsername string,
 	proxyPassword string) ConnectionParams {
 
+	// normalize proxy type ('socks5' is an alias of 'socks')
+	proxyType = strings.ToLower(strings.TrimSpace(proxyType))
+	if proxyType == "socks5" {
+		proxyType = "socks"
+	}
+
 	return ConnectionParams{
 		multihopExitSrvID: multihopExitSrvID,
 		tcp:               tcp,
@@ -130,8 +206,18 @@ func (c *ConnectionParams) generateConfiguration(
 		c.proxyType = "socks"
 		c.proxyAddress = net.IPv4(127, 0, 0, 1) // "127.0.0.1"
 		c.proxyPort = obfsproxyPort
-		c.proxyUsername = ""
-		c.proxyPassword = ""
+		// Note: proxy credentials are not erased here:
+		// for obfs4 they carry the bridge certificate (injected over the management interface)
+	}
+
+	if c.obfuscationLocalPort > 0 {
+		// tunnel-style obfuscation transport ('tlstunnel'\'websocket'):
+		// OpenVPN connects to the transparent local endpoint of the transport;
+		// real server IPs are saved to route the transport traffic outside the tunnel
+		c.obfuscationRemoteIPs = c.hostIPs
+		c.hostIPs = []net.IP{net.IPv4(127, 0, 0, 1)}
+		c.hostPort = c.obfuscationLocalPort
+		c.tcp = true
 	}
 
 	cfg = make([]string, 0, 32)
@@ -165,28 +251,34 @@ func (c *ConnectionParams) generateConfiguration(
 	cfg = append(cfg, "keepalive 8 30")
 
 	// proxy
-	if c.proxyType == "http" || c.proxyType == "socks" {
+	if len(c.proxyType) > 0 && c.proxyType != "none" {
+		if c.proxyType != "http" && c.proxyType != "socks" {
+			return nil, fmt.Errorf("unsupported proxy type: '%s' (only 'http' and 'socks' proxy supported)", c.proxyType)
+		}
+		if c.proxyAddress == nil {
+			return nil, errors.New("unable to connect via proxy. Proxy address not defined")
+		}
+		if c.proxyPort <= 0 || c.proxyPort > 65535 {
+			return nil, errors.New("unable to connect via proxy. Invalid proxy port")
+		}
 
 		// proxy authentication
-		proxyAuthFile := ""
+		// 'auto' - OpenVPN requests the credentials over the management interface
+		// (the daemon injects them dynamically; no credentials file on disk)
+		proxyAuthParam := ""
 		if c.proxyUsername != "" && c.proxyPassword != "" {
-			proxyAuthFile = "\"" + platform.OpenvpnProxyAuthFile() + "\""
-			err := ioutil.WriteFile(platform.OpenvpnProxyAuthFile(), []byte(fmt.Sprintf("%s\n%s", c.proxyUsername, c.proxyPassword)), 0600)
-			if err != nil {
-				log.Error(err)
-				return nil, fmt.Errorf("Failed to save file with proxy credentials: %w", err)
-			}
+			proxyAuthParam = "auto"
 		}
 
 		// proxy config
 		switch c.proxyType {
 		case "http":
 			cfg = append(cfg, "http-proxy-retry")
-			cfg = append(cfg, fmt.Sprintf("http-proxy %s %d %s", c.proxyAddress.String(), c.proxyPort, proxyAuthFile))
+			cfg = append(cfg, fmt.Sprintf("http-proxy %s %d %s", c.proxyAddress.String(), c.proxyPort, proxyAuthParam))
 			break
 		case "socks":
 			cfg = append(cfg, "socks-proxy-retry")
-			cfg = append(cfg, fmt.Sprintf("socks-proxy %s %d %s", c.proxyAddress.String(), c.proxyPort, proxyAuthFile))
+			cfg = append(cfg, fmt.Sprintf("socks-proxy %s %d %s", c.proxyAddress.String(), c.proxyPort, proxyAuthParam))
 			break
 		}
 	}
@@ -195,7 +287,8 @@ func (c *ConnectionParams) generateConfiguration(
 		cfg = append(cfg, fmt.Sprintf(`log "%s"`, logFile))
 	}
 
-	cfg = append(cfg, "dev tun")
+	// tunnel device (OS-specific: e.g. macOS uses the native utun driver when available)
+	cfg = append(cfg, implTunnelDeviceConfig()...)
 
 	if c.tcp {
 		cfg = append(cfg, "proto tcp")
@@ -245,6 +338,17 @@ func (c *ConnectionParams) generateConfiguration(
 
 	cfg = append(cfg, "script-security 2")
 
+	if c.isIPv6 {
+		// accept IPv6 address\route\DNS configuration pushed by the server
+		// and route all IPv6 traffic into the tunnel
+		cfg = append(cfg, "redirect-gateway def1 ipv6 bypass-dhcp")
+	} else if isCanUseV24Params {
+		// ignore IPv6 configuration pushed by the server (tunnel stays IPv4-only;
+		// IPv6 leak protection is implemented on the firewall level)
+		cfg = append(cfg, "pull-filter ignore \"ifconfig-ipv6\"")
+		cfg = append(cfg, "pull-filter ignore \"route-ipv6\"")
+	}
+
 	if c.proxyAddress != nil && (c.proxyType == "http" || c.proxyType == "socks") {
 
 		localGatewayAddress, err := netinfo.DefaultGatewayIP()
@@ -265,6 +369,31 @@ func (c *ConnectionParams) generateConfiguration(
 		}
 	}
 
+	if len(c.obfuscationRemoteIPs) > 0 {
+		localGatewayAddress, err := netinfo.DefaultGatewayIP()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get local gateway: %w", err)
+		}
+
+		// the traffic of the obfuscation transport to the real server has to bypass the tunnel
+		for _, addr := range c.obfuscationRemoteIPs {
+			cfg = append(cfg, fmt.Sprintf("route %s 255.255.255.255 %s", addr.String(), localGatewayAddress.String()))
+		}
+	}
+
+	// per-IP\subnet split-tunneling rules
+	// ('net_gateway' - default gateway of the physical interface, 'vpn_gateway' - gateway of the tunnel)
+	for _, cidr := range c.bypassCIDRs {
+		if route := cidrToRouteDirective(cidr, "net_gateway"); len(route) > 0 {
+			cfg = append(cfg, route)
+		}
+	}
+	for _, cidr := range c.vpnCIDRs {
+		if route := cidrToRouteDirective(cidr, "vpn_gateway"); len(route) > 0 {
+			cfg = append(cfg, route)
+		}
+	}
+
 	cfg, err = addUserDefinedParameters(cfg, extraParameters)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add user-defined parameters: %w", err)
@@ -273,6 +402,59 @@ func (c *ConnectionParams) generateConfiguration(
 	return cfg, nil
 }
 
+// cidrToRouteDirective converts a CIDR into an OpenVPN 'route' directive
+// (returns empty string when the CIDR is not parsable)
+func cidrToRouteDirective(cidr string, gateway string) string {
+	ip, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+	if err != nil || ip.To4() == nil {
+		log.Warning(fmt.Sprintf("unable to parse CIDR '%s': ignored", cidr))
+		return ""
+	}
+	mask := net.IP(ipNet.Mask)
+	return fmt.Sprintf("route %s %s %s", ipNet.IP.String(), mask.String(), gateway)
+}
+
+// forbidden user-defined directives: directives which are able to execute external
+// commands or to break the daemon control over the OpenVPN process
+// ('management...' covers all management-interface related directives)
+var forbiddenExtraParameters = []string{
+	"script-security",
+	"up", "down", "up-restart", "route-up", "route-pre-down", "ipchange",
+	"plugin",
+	"iproute",
+	"tls-verify", "auth-user-pass-verify",
+	"client-connect", "client-disconnect", "learn-address"}
+
+// FilterExtraParameters - safety validation of user-defined OpenVPN configuration directives
+// Returns the parameters with forbidden directives removed and the list of rejected directives
+func FilterExtraParameters(extraParameters string) (allowed string, rejected []string) {
+	var sb strings.Builder
+
+	for _, line := range strings.Split(extraParameters, "\n") {
+		param := getParamFromConfigLine(line)
+		if len(param) == 0 {
+			continue // empty lines and comments
+		}
+
+		isForbidden := strings.HasPrefix(param, "management")
+		for _, forbidden := range forbiddenExtraParameters {
+			if param == forbidden {
+				isForbidden = true
+				break
+			}
+		}
+
+		if isForbidden {
+			rejected = append(rejected, param)
+			continue
+		}
+
+		sb.WriteString(strings.TrimSpace(line) + "\n")
+	}
+
+	return sb.String(), rejected
+}
+
 // merge current parameters with user-defined parameters
 func addUserDefinedParameters(currParams []string, userParams string) ([]string, error) {
 	if len(userParams) <= 0 {
@@ -322,5 +504,8 @@ func getParamFromConfigLine(line string) string {
 		return ""
 	}
 
-	return strings.ToLower(words[0])
+	// OpenVPN accepts a leading '--' on any directive in configuration files
+	// (stripped by its option parser) - strip it here too, otherwise e.g.
+	// '--up /bin/evil' would bypass the forbidden-directives check
+	return strings.ToLower(strings.TrimLeft(words[0], "-"))
 }
@@ -0,0 +1,62 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package openvpn
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// minimal Darwin kernel major version with reliable native utun support in OpenVPN
+// (Darwin 17 == macOS 10.13 High Sierra)
+const minDarwinVersionForUtun = 17
+
+// implTunnelDeviceConfig - tunnel device configuration for macOS.
+// Modern macOS versions deprecate third-party kernel extensions, therefore the
+// native utun driver is used whenever the OS supports it; the legacy tun kext
+// is kept only as a fallback for old systems.
+func implTunnelDeviceConfig() []string {
+	if isNativeUtunSupported() {
+		return []string{"dev utun"}
+	}
+	log.Warning("Old macOS version detected: using the legacy tun kext (native utun is not supported)")
+	return []string{"dev tun"}
+}
+
+// isNativeUtunSupported - check the Darwin kernel version (e.g. '19.6.0')
+func isNativeUtunSupported() bool {
+	osRelease, err := syscall.Sysctl("kern.osrelease")
+	if err != nil {
+		log.Error("Failed to detect the kernel version: ", err)
+		return true // assume a modern system
+	}
+
+	majorStr := strings.Split(osRelease, ".")[0]
+	major, err := strconv.Atoi(majorStr)
+	if err != nil {
+		log.Error("Failed to parse the kernel version '", osRelease, "': ", err)
+		return true
+	}
+	return major >= minDarwinVersionForUtun
+}
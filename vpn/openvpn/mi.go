@@ -48,6 +48,11 @@ type ManagementInterface struct {
 	username  string
 	password  string
 
+	// proxy credentials (injected dynamically when OpenVPN requests them;
+	// no credentials file on disk)
+	proxyUsername string
+	proxyPassword string
+
 	routeAddCmdsMutex sync.Mutex
 	routeAddCmds      []string
 
@@ -56,15 +61,22 @@ type ManagementInterface struct {
 
 	pushReplyCmds []string
 	pushReplyDNS  net.IP
+
+	// byte counters reported by OpenVPN ('bytecount' real-time messages)
+	statsMutex    sync.Mutex
+	bytesReceived uint64
+	bytesSent     uint64
 }
 
 // StartManagementInterface - starts TCP interface to communicate with IVPN application (server to listen incoming connections)
-func StartManagementInterface(username string, password string, stateChan chan<- vpn.StateInfo) (mi *ManagementInterface, err error) {
+func StartManagementInterface(username string, password string, proxyUsername string, proxyPassword string, stateChan chan<- vpn.StateInfo) (mi *ManagementInterface, err error) {
 	ret := &ManagementInterface{
-		log:       logger.NewLogger("ovpnmi"),
-		stateChan: stateChan,
-		username:  username,
-		password:  password}
+		log:           logger.NewLogger("ovpnmi"),
+		stateChan:     stateChan,
+		username:      username,
+		password:      password,
+		proxyUsername: proxyUsername,
+		proxyPassword: proxyPassword}
 
 	if err = ret.start(); err != nil {
 		return nil, fmt.Errorf("failed to start MI: %w", err)
@@ -124,6 +136,14 @@ func (i *ManagementInterface) SendDisconnect() error {
 	return i.sendResponse("signal SIGTERM")
 }
 
+// GetStats - byte counters of the current connection (reported by OpenVPN in real-time)
+func (i *ManagementInterface) GetStats() (bytesReceived uint64, bytesSent uint64) {
+	i.statsMutex.Lock()
+	defer i.statsMutex.Unlock()
+
+	return i.bytesReceived, i.bytesSent
+}
+
 // GetRouteAddCommands - return all detected route-add command
 func (i *ManagementInterface) GetRouteAddCommands() []string {
 	i.routeAddCmdsMutex.Lock()
@@ -229,7 +249,10 @@ func (i *ManagementInterface) miCommunication() {
 			continue
 		}
 
-		i.log.Info("[<-]: ", message)
+		// do not spam the log by byte counters (reported every few seconds)
+		if strings.HasPrefix(message, ">BYTECOUNT:") == false {
+			i.log.Info("[<-]: ", message)
+		}
 
 		columns := mesRegexp.FindStringSubmatch(message)
 		if len(columns) <= 2 {
@@ -241,6 +264,27 @@ func (i *ManagementInterface) miCommunication() {
 
 		switch msgSource {
 		case "LOG":
+			// detect proxy connection failures (to keep UI informed about the failure reason)
+			if additionalInfo := detectProxyError(msgText); len(additionalInfo) > 0 {
+				i.log.Warning("Proxy error detected: ", msgText)
+				i.stateChan <- vpn.StateInfo{
+					State:               vpn.RECONNECTING,
+					Description:         msgText,
+					StateAdditionalInfo: additionalInfo}
+				break
+			}
+
+			// detect known fatal failures (TLS handshake, no free TAP adapter ...)
+			// to report a typed disconnection reason instead of a generic 'disconnected'
+			if additionalInfo := detectFatalError(msgText); len(additionalInfo) > 0 {
+				i.log.Warning("Fatal error detected: ", msgText)
+				i.stateChan <- vpn.StateInfo{
+					State:               vpn.RECONNECTING,
+					Description:         msgText,
+					StateAdditionalInfo: additionalInfo}
+				break
+			}
+
 			// detect for routing change commands
 
 			// LOG:1564229538,,/sbin/route add -net 128.0.0.0 10.57.40.1 128.0.0.0
@@ -265,7 +309,27 @@ func (i *ManagementInterface) miCommunication() {
 			break
 
 		case "HOLD":
-			i.sendResponse("state on", "log on", "hold off", "hold release")
+			i.sendResponse("state on", "log on", "bytecount 5", "hold off", "hold release")
+			break
+
+		case "BYTECOUNT":
+			// >BYTECOUNT:{BYTES_IN},{BYTES_OUT}
+			cols := strings.Split(msgText, ",")
+			if len(cols) != 2 {
+				i.log.Error("BYTECOUNT format error.")
+				continue
+			}
+			bytesIn, errIn := strconv.ParseUint(strings.TrimSpace(cols[0]), 10, 64)
+			bytesOut, errOut := strconv.ParseUint(strings.TrimSpace(cols[1]), 10, 64)
+			if errIn != nil || errOut != nil {
+				i.log.Error("BYTECOUNT parse error.")
+				continue
+			}
+
+			i.statsMutex.Lock()
+			i.bytesReceived = bytesIn
+			i.bytesSent = bytesOut
+			i.statsMutex.Unlock()
 			break
 
 		case "PASSWORD":
@@ -274,11 +338,22 @@ func (i *ManagementInterface) miCommunication() {
 				break
 			}
 
-			if mesNeedPassRegexp.Match([]byte(msgText)) == false {
+			cols := mesNeedPassRegexp.FindStringSubmatch(msgText)
+			if len(cols) != 2 {
 				continue
 			}
 
-			i.sendResp(false, fmt.Sprintf("username \"Auth\" %s", i.username))
+			// OpenVPN informs which credentials are requested:
+			// 'Auth' - VPN authentication; 'HTTP Proxy'\'SOCKS Proxy' - proxy authentication
+			credentialsType := cols[1]
+			username := i.username
+			password := i.password
+			if credentialsType != "Auth" {
+				username = i.proxyUsername
+				password = i.proxyPassword
+			}
+
+			i.sendResp(false, fmt.Sprintf("username \"%s\" %s", credentialsType, username))
 
 			// Some passwords for tests in case of implementation change:
 			//
@@ -287,9 +362,9 @@ func (i *ManagementInterface) miCommunication() {
 			// ;0///$%\\\:k\\'j?\~?://f3%2,/O4x<#456!@#$%^&*()_+}{P||:?><~~
 			// ";0///$%\\\:k\\'j?\~?://""f3%2""",/O4x<#456!@#$%^&*()_+}{P||:?><~~
 			// lkhgd#;0$%:k'j?~?:f3%2,"O4x<
-			escapedPass := strings.ReplaceAll(i.password, "\\", "\\\\")
+			escapedPass := strings.ReplaceAll(password, "\\", "\\\\")
 			escapedPass = strings.ReplaceAll(escapedPass, "\"", "\\\"")
-			i.sendResp(false, fmt.Sprintf("password \"Auth\" %s", escapedPass))
+			i.sendResp(false, fmt.Sprintf("password \"%s\" %s", credentialsType, escapedPass))
 			break
 
 		case "STATE":
@@ -361,6 +436,52 @@ func (i *ManagementInterface) miCommunication() {
 
 	}
 }
+
+// detectProxyError - check OpenVPN log message for known proxy failure markers
+// Returns error classification for UI clients:
+// 'proxy-auth-error' - proxy refused the configured credentials
+// 'proxy-error' - proxy refused or dropped the connection
+// (empty string when the message is not related to a proxy failure)
+func detectProxyError(logMessage string) string {
+	msg := strings.ToLower(logMessage)
+
+	if strings.Contains(msg, "proxy requires authentication") ||
+		strings.Contains(msg, "socks_username_password_auth") {
+		return "proxy-auth-error"
+	}
+
+	if strings.Contains(msg, "http proxy returned bad status") ||
+		strings.Contains(msg, "recv_socks_reply") ||
+		strings.Contains(msg, "socks proxy error") {
+		return "proxy-error"
+	}
+
+	return ""
+}
+
+// detectFatalError - check OpenVPN log message for known fatal failure markers
+// Returns error classification for UI clients:
+// 'tls-error' - TLS handshake with the server failed (e.g. OpenVPN blocked by the network)
+// 'tap-adapters-exhausted' - no free TAP\Wintun adapter (Windows; another VPN software occupies them)
+// (empty string when the message is not related to a known fatal failure)
+func detectFatalError(logMessage string) string {
+	msg := strings.ToLower(logMessage)
+
+	if strings.Contains(msg, "tls error") ||
+		strings.Contains(msg, "tls handshake failed") ||
+		strings.Contains(msg, "tls key negotiation failed") ||
+		strings.Contains(msg, "certificate verify failed") {
+		return "tls-error"
+	}
+
+	if strings.Contains(msg, "all tap-windows adapters on this system are currently in use") ||
+		strings.Contains(msg, "all wintun adapters on this system are currently in use") {
+		return "tap-adapters-exhausted"
+	}
+
+	return ""
+}
+
 func (i *ManagementInterface) onPushReplyCommands(cmds []string) {
 	// LOG:1586341059,,PUSH: Received control message: 'PUSH_REPLY,redirect-gateway def1,explicit-exit-notify 3,comp-lzo no,route-gateway 10.34.44.1,topology subnet,ping 10,ping-restart 60,dhcp-option DNS 10.34.44.1,ifconfig 10.34.44.19 255.255.252.0,peer-id 17,cipher AES-256-GCM'
 	var dns net.IP = nil
@@ -36,6 +36,7 @@ import (
 
 	"github.com/ivpn/desktop-app-daemon/logger"
 	"github.com/ivpn/desktop-app-daemon/obfsproxy"
+	"github.com/ivpn/desktop-app-daemon/obfuscation"
 	"github.com/ivpn/desktop-app-daemon/service/platform"
 	"github.com/ivpn/desktop-app-daemon/shell"
 	"github.com/ivpn/desktop-app-daemon/vpn"
@@ -88,12 +89,18 @@ type OpenVPN struct {
 	extraParameters string // user-defined extra-parameters of OpenVPN configuration
 	connectParams   ConnectionParams
 
+	// obfuscation protocol parameters (applicable only when 'isObfsProxy' == true)
+	obfsproxyVersion obfsproxy.ObfsProxyVersion
+	obfs4BridgeCert  string
+
 	managementInterface *ManagementInterface
-	obfsproxy           *obfsproxy.Obfsproxy
+	// started obfuscation transport (obfsproxy or one of the tunnel-style transports)
+	obfuscator obfuscation.ObfuscationTransport
 
 	// current VPN state
 	state    vpn.State
 	clientIP net.IP // applicable only for 'CONNECTED' state
+	serverIP net.IP // applicable only for 'CONNECTED' state
 
 	// platform-specific properties (for macOS, Windows etc. ...)
 	psProps platformSpecificProperties
@@ -123,25 +130,48 @@ func NewOpenVpnObject(
 	}
 
 	return &OpenVPN{
-			state:           vpn.DISCONNECTED,
-			binaryPath:      binaryPath,
-			configPath:      configPath,
-			logFile:         logFile,
-			isObfsProxy:     isObfsProxy,
-			extraParameters: extraParameters,
-			connectParams:   connectionParams},
+			state:            vpn.DISCONNECTED,
+			binaryPath:       binaryPath,
+			configPath:       configPath,
+			logFile:          logFile,
+			isObfsProxy:      isObfsProxy,
+			obfsproxyVersion: obfsproxy.OBFS3,
+			extraParameters:  extraParameters,
+			connectParams:    connectionParams},
 		nil
 }
 
+// SetObfsProxyParams - define obfuscation protocol version and obfs4 bridge certificate
+// (must be called before Connect; applicable only when obfsproxy is enabled)
+func (o *OpenVPN) SetObfsProxyParams(version obfsproxy.ObfsProxyVersion, obfs4BridgeCert string) {
+	o.obfsproxyVersion = version
+	o.obfs4BridgeCert = obfs4BridgeCert
+}
+
 // DestinationIPs -  Get destination IPs (VPN host server or proxy server IP address)
 // This information if required, for example, to allow this address in firewall
 func (o *OpenVPN) DestinationIPs() []net.IP {
 	if o.connectParams.proxyAddress != nil {
 		return []net.IP{o.connectParams.proxyAddress}
 	}
+	if len(o.connectParams.obfuscationRemoteIPs) > 0 {
+		return o.connectParams.obfuscationRemoteIPs
+	}
 	return o.connectParams.hostIPs
 }
 
+// OnRoutingChanged - roaming of an established connection is not supported by OpenVPN
+// (a full reconnection is required after the network change)
+func (o *OpenVPN) OnRoutingChanged() error {
+	return fmt.Errorf("roaming is not supported for OpenVPN connection")
+}
+
+// isObfuscationEnabled returns true when any obfuscation transport is in use for this connection
+// (obfsproxy enabled by preferences or a transport requested in the connection parameters)
+func (o *OpenVPN) isObfuscationEnabled() bool {
+	return o.isObfsProxy || len(o.connectParams.obfuscationType) > 0
+}
+
 // Type just returns VPN type
 func (o *OpenVPN) Type() vpn.Type { return vpn.OpenVPN }
 
@@ -150,6 +180,10 @@ func (o *OpenVPN) Type() vpn.Type { return vpn.OpenVPN }
 //	- for WireGuard(Windows) - to ensure that WG service is fully uninstalled
 //	- for OpenVPN(Linux) - to ensure that OpenVPN has correct version
 func (o *OpenVPN) Init() error {
+	// terminate openvpn processes which are left from a previous daemon crash (if any)
+	if err := o.terminateOrphanedProcesses(); err != nil {
+		log.Warning("Failed to check for orphaned OpenVPN processes: ", err)
+	}
 	return o.implInit()
 }
 
@@ -189,12 +223,12 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 			}
 		}
 
-		obfspxy := o.obfsproxy
-		if obfspxy != nil {
-			obfspxy.Stop()
+		obfusc := o.obfuscator
+		if obfusc != nil {
+			obfusc.Stop()
 		}
 
-		o.obfsproxy = nil
+		o.obfuscator = nil
 
 		if err := o.implOnDisconnected(); err != nil {
 			log.Error(err)
@@ -204,8 +238,8 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 		routinesWaiter.Wait()
 	}()
 
-	if o.isObfsProxy && len(o.connectParams.hostIPs) > 0 {
-		// in case of obfsproxy and multiple hostIPs - we are unable to determine which server we are connected for
+	if o.isObfuscationEnabled() && len(o.connectParams.hostIPs) > 0 {
+		// in case of obfuscation and multiple hostIPs - we are unable to determine which server we are connected for
 		// Therefore, we are using only one random serverIP to connect
 		o.connectParams.hostIPs = []net.IP{o.connectParams.hostIPs[rand.Intn(len(o.connectParams.hostIPs))]}
 	}
@@ -229,17 +263,24 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 					// notify about correct local IP in VPN network
 					o.clientIP = stateInf.ClientIP
 
-					if o.isObfsProxy {
-						// in case of obfsproxy - 'stateInf.ServerIP' returns local IP (IP of obfsproxy 127.0.0.1)
+					if o.isObfuscationEnabled() {
+						// in case of obfuscation - 'stateInf.ServerIP' returns local IP (IP of the local obfuscation endpoint 127.0.0.1)
 						// We must notify about real remote ServerIP, therefore we modifying this parameter before notifying about successful connection
 
-						// for obfsproxy, there should be only one hostIP, therefore we are taking first from the list
-						stateInf.ServerIP = o.connectParams.hostIPs[0]
+						// for obfuscation, there should be only one hostIP, therefore we are taking first from the list
+						// (for the tunnel-style transports the real server IPs are kept separately)
+						if len(o.connectParams.obfuscationRemoteIPs) > 0 {
+							stateInf.ServerIP = o.connectParams.obfuscationRemoteIPs[0]
+						} else {
+							stateInf.ServerIP = o.connectParams.hostIPs[0]
+						}
 					}
+					o.serverIP = stateInf.ServerIP
 
 					o.implOnConnected() // process "on connected" event (if necessary)
 				} else {
 					o.clientIP = nil
+					o.serverIP = nil
 				}
 
 				// forward state
@@ -259,35 +300,82 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 
 	var err error
 	obfsproxyPort := 0
-	// start Obfsproxy (if necessary)
-	if o.isObfsProxy {
-		o.obfsproxy = obfsproxy.CreateObfsproxy(platform.ObfsproxyStartScript())
-		if obfsproxyPort, err = o.obfsproxy.Start(); err != nil {
+	// start obfuscation transport (if necessary)
+	if o.isObfsProxy || o.connectParams.obfuscationType == obfuscation.Obfs4 {
+		obfsproxyVersion := o.obfsproxyVersion
+		if o.connectParams.obfuscationType == obfuscation.Obfs4 {
+			// 'obfs4' transport requested for this connection - served by the obfs4proxy integration
+			obfsproxyVersion = obfsproxy.OBFS4
+		}
+
+		obfsBinary := platform.ObfsproxyStartScript()
+		if obfsproxyVersion == obfsproxy.OBFS4 {
+			obfsBinary = platform.Obfs4proxyBinaryPath()
+			if len(o.obfs4BridgeCert) == 0 {
+				return errors.New("unable to initialize OpenVPN: obfs4 bridge certificate not defined for the selected server")
+			}
+			// obfs4 bridge certificate is passed to obfs4proxy as SOCKS5 credentials
+			o.connectParams.proxyUsername, o.connectParams.proxyPassword = obfsproxy.Obfs4AuthArgs(o.obfs4BridgeCert)
+		}
+
+		o.obfuscator = obfsproxy.CreateObfsproxy(obfsBinary, obfsproxyVersion)
+		if obfsproxyPort, err = o.obfuscator.Start(); err != nil {
 			return errors.New("unable to initialize OpenVPN (obfsproxy not started): " + err.Error())
 		}
+	} else if len(o.connectParams.obfuscationType) > 0 {
+		// tunnel-style obfuscation transport ('tlstunnel'\'websocket'):
+		// the transport exposes a transparent local TCP endpoint and forwards
+		// the traffic to the obfuscation endpoint on the server
+		if len(o.connectParams.hostIPs) < 1 {
+			return errors.New("unable to initialize obfuscation transport: host IP not defined")
+		}
+
+		remotePort := o.connectParams.obfuscationPort
+		if remotePort <= 0 {
+			remotePort = o.connectParams.hostPort
+		}
+		remoteEndpoint := net.JoinHostPort(o.connectParams.hostIPs[0].String(), strconv.Itoa(remotePort))
+
+		transport, err := obfuscation.CreateTransport(o.connectParams.obfuscationType, remoteEndpoint)
+		if err != nil {
+			return fmt.Errorf("unable to initialize obfuscation transport: %w", err)
+		}
+
+		localPort, err := transport.Start()
+		if err != nil {
+			return errors.New("unable to initialize OpenVPN (obfuscation transport not started): " + err.Error())
+		}
 
-		// detect obfsproxy ptocess stop
+		o.obfuscator = transport
+		o.connectParams.obfuscationLocalPort = localPort
+	}
+
+	if o.obfuscator != nil {
+		// detect obfuscation transport stop
 		routinesWaiter.Add(1)
 		go func() {
 			defer routinesWaiter.Done()
 
-			opxy := o.obfsproxy
-			if opxy == nil {
+			obfusc := o.obfuscator
+			if obfusc == nil {
 				return
 			}
 
-			// wait for obfsproxy stop
-			opxy.Wait()
+			// wait for obfuscation transport stop
+			obfusc.Wait()
 			if o.isDisconnectRequested == false {
-				// If obfsproxy stopped unexpectedly - disconnect VPN
-				log.Error("Obfsproxy stopped unexpectedly. Disconnecting VPN...")
+				// If the obfuscation transport stopped unexpectedly - disconnect VPN
+				log.Error("Obfuscation transport stopped unexpectedly. Disconnecting VPN...")
 				o.doDisconnect()
 			}
 		}()
 	}
 
 	// start new management interface
-	mi, err := StartManagementInterface(o.connectParams.username, o.connectParams.password, internalStateChan)
+	mi, err := StartManagementInterface(
+		o.connectParams.username, o.connectParams.password,
+		o.connectParams.proxyUsername, o.connectParams.proxyPassword,
+		internalStateChan)
 	if err != nil {
 		return fmt.Errorf("failed to start MI: %w", err)
 	}
@@ -341,6 +429,11 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 
 	// SYNCHRONOUSLY execute openvpn process (wait until it finished)
 	if err = shell.ExecAndProcessOutput(log, outProcessFunc, "", o.binaryPath, "--config", o.configPath); err != nil {
+		if exitCode, e := shell.GetCmdExitCode(err); e == nil {
+			log.Error(fmt.Sprintf("OpenVPN process exited abnormally (exit code %d)", exitCode))
+		}
+		o.dumpProcessLogTail()
+
 		if strOut.Len() > 0 {
 			log.Info(fmt.Sprintf("OpenVPN start ERROR. Output: %s...", strOut.String()))
 		}
@@ -354,6 +447,12 @@ func (o *OpenVPN) Connect(stateChan chan<- vpn.StateInfo) (retErr error) {
 		return fmt.Errorf("failed to start OpenVPN process: %w", err)
 	}
 
+	if o.isDisconnectRequested == false {
+		// process stopped without a disconnect request - keep its log tail for analysis
+		log.Warning("OpenVPN process stopped unexpectedly")
+		o.dumpProcessLogTail()
+	}
+
 	return nil
 }
 
@@ -482,3 +581,20 @@ func (o *OpenVPN) SetManualDNS(addr net.IP) error {
 func (o *OpenVPN) ResetManualDNS() error {
 	return o.implOnResetManualDNS()
 }
+
+// GetStats - current connection statistics
+// (byte counters are reported by OpenVPN in real-time over the management interface)
+func (o *OpenVPN) GetStats() (vpn.Statistics, error) {
+	mi := o.managementInterface
+	if mi == nil || o.state != vpn.CONNECTED {
+		return vpn.Statistics{}, fmt.Errorf("unable to get connection statistics: VPN is not connected")
+	}
+
+	bytesReceived, bytesSent := mi.GetStats()
+
+	ret := vpn.Statistics{BytesReceived: bytesReceived, BytesSent: bytesSent}
+	if o.serverIP != nil {
+		ret.Endpoint = o.serverIP.String()
+	}
+	return ret, nil
+}
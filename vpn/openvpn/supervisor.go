@@ -0,0 +1,70 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package openvpn
+
+// Supervision of the OpenVPN child process:
+// - abnormal exit detection with the tail of the OpenVPN log copied into the daemon log;
+// - termination of orphaned openvpn processes left from a previous daemon crash.
+// Restart of an unexpectedly stopped connection is done by the service reconnect
+// policy (which reacts on the tunnel stop notification).
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// count of OpenVPN log lines copied into the daemon log on abnormal exit
+const _logTailLines = 30
+
+// dumpProcessLogTail - copy the last lines of the OpenVPN log into the daemon log
+// (useful to analyze an abnormal exit of the process)
+func (o *OpenVPN) dumpProcessLogTail() {
+	if len(o.logFile) == 0 {
+		return
+	}
+	if _, err := os.Stat(o.logFile); err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(o.logFile)
+	if err != nil {
+		log.Warning("Failed to read OpenVPN log: ", err)
+		return
+	}
+
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > _logTailLines {
+		lines = lines[len(lines)-_logTailLines:]
+	}
+
+	log.Info(fmt.Sprintf("Last %d lines of the OpenVPN log:\n=====================\n%s\n=====================",
+		len(lines), strings.Join(lines, "\n")))
+}
+
+// terminateOrphanedProcesses - detect and terminate openvpn processes left running
+// from a previous daemon crash (the daemon is the only expected parent of openvpn)
+func (o *OpenVPN) terminateOrphanedProcesses() error {
+	return implTerminateOrphanedProcesses(o.binaryPath)
+}
@@ -0,0 +1,63 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package openvpn
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// implTerminateOrphanedProcesses - terminate openvpn processes (started from our binary)
+// which are left from a previous daemon crash
+func implTerminateOrphanedProcesses(binaryPath string) error {
+	imageName := filepath.Base(binaryPath)
+
+	pids := make([]int, 0, 2)
+	outProcessFunc := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		// CSV format: "imageName","PID",...
+		fields := strings.Split(text, "\",\"")
+		if len(fields) < 2 {
+			return
+		}
+		if pid, err := strconv.Atoi(strings.TrimSpace(fields[1])); err == nil && pid > 0 {
+			pids = append(pids, pid)
+		}
+	}
+
+	// 'tasklist' returns an info message (to stderr) when nothing found - this is not an error
+	shell.ExecAndProcessOutput(nil, outProcessFunc, "", "tasklist.exe", "/FI", "IMAGENAME eq "+imageName, "/FO", "CSV", "/NH")
+
+	for _, pid := range pids {
+		log.Warning("Terminating orphaned OpenVPN process (pid=", pid, ")")
+		if err := shell.Exec(log, "taskkill.exe", "/F", "/PID", strconv.Itoa(pid)); err != nil {
+			log.Error("Failed to terminate orphaned OpenVPN process: ", err)
+		}
+	}
+	return nil
+}
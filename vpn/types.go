@@ -156,6 +156,17 @@ func NewStateInfoConnected(clientIP net.IP, serverIP net.IP) StateInfo {
 		IsAuthError: false}
 }
 
+// Statistics - transfer statistics of the active VPN connection
+type Statistics struct {
+	BytesReceived uint64
+	BytesSent     uint64
+	// LastHandshake - unix time of the last successful handshake
+	// (0 when not applicable; in use only by WireGuard)
+	LastHandshake int64
+	// Endpoint - current peer endpoint
+	Endpoint string
+}
+
 // Process represents VPN object operations
 type Process interface {
 	// Type just returns VPN type
@@ -175,7 +186,17 @@ type Process interface {
 	SetManualDNS(addr net.IP) error
 	ResetManualDNS() error
 
+	// GetStats - current connection statistics (byte counters, handshake info...)
+	GetStats() (Statistics, error)
+
 	// DestinationIPs -  Get destination IPs (VPN host server or proxy server IP address)
 	// This information if required, for example, to allow this address in firewall
 	DestinationIPs() []net.IP
+
+	// OnRoutingChanged - notification about the change of the default route\interface
+	// (e.g. switch from Wi-Fi to a wired\mobile network).
+	// The implementation should try to keep the established tunnel by re-applying
+	// the peer endpoint (roaming); returned error means roaming is not supported
+	// for this connection and a full reconnection is required
+	OnRoutingChanged() error
 }
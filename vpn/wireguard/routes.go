@@ -0,0 +1,132 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Per-IP\subnet split-tunneling support:
+// 'bypass' networks are subtracted from the peer AllowedIPs,
+// so their traffic is routed outside the tunnel (IPv4 only)
+
+// ipv4Net - IPv4 network in CIDR notation
+type ipv4Net struct {
+	base   uint32
+	prefix uint
+}
+
+func parseIPv4Net(cidr string) (ipv4Net, error) {
+	var a, b, c, d, prefix uint
+	if _, err := fmt.Sscanf(strings.TrimSpace(cidr), "%d.%d.%d.%d/%d", &a, &b, &c, &d, &prefix); err != nil {
+		return ipv4Net{}, fmt.Errorf("failed to parse CIDR '%s': %w", cidr, err)
+	}
+	if a > 255 || b > 255 || c > 255 || d > 255 || prefix > 32 {
+		return ipv4Net{}, fmt.Errorf("failed to parse CIDR '%s'", cidr)
+	}
+	n := ipv4Net{base: uint32(a)<<24 | uint32(b)<<16 | uint32(c)<<8 | uint32(d), prefix: prefix}
+	n.base &= n.mask()
+	return n, nil
+}
+
+func (n ipv4Net) mask() uint32 {
+	if n.prefix == 0 {
+		return 0
+	}
+	return ^uint32(0) << (32 - n.prefix)
+}
+
+func (n ipv4Net) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d/%d", byte(n.base>>24), byte(n.base>>16), byte(n.base>>8), byte(n.base), n.prefix)
+}
+
+// contains - true when 'n' covers the whole network 'o'
+func (n ipv4Net) contains(o ipv4Net) bool {
+	return n.prefix <= o.prefix && o.base&n.mask() == n.base
+}
+
+func (n ipv4Net) overlaps(o ipv4Net) bool {
+	return n.contains(o) || o.contains(n)
+}
+
+// subtract - remove network 'ex' from the list of networks
+// (overlapping networks are split in halves until the excluded network can be cut out)
+func subtract(networks []ipv4Net, ex ipv4Net) []ipv4Net {
+	var ret []ipv4Net
+	for _, n := range networks {
+		if n.overlaps(ex) == false {
+			ret = append(ret, n)
+			continue
+		}
+		if ex.contains(n) {
+			continue // network is fully excluded
+		}
+
+		// 'ex' is a subnet of 'n': split 'n' in halves and process them recursively
+		left := ipv4Net{base: n.base, prefix: n.prefix + 1}
+		right := ipv4Net{base: n.base | 1<<(32-left.prefix), prefix: n.prefix + 1}
+		ret = append(ret, subtract([]ipv4Net{left, right}, ex)...)
+	}
+	return ret
+}
+
+// subtractAllowedIPs - remove 'bypass' networks from an AllowedIPs set
+// Networks which failed to parse are ignored (with a log message)
+func subtractAllowedIPs(allowedList []string, bypassList []string) []string {
+	var networks []ipv4Net
+	for _, cidr := range allowedList {
+		n, err := parseIPv4Net(cidr)
+		if err != nil {
+			log.Warning(err)
+			continue
+		}
+		networks = append(networks, n)
+	}
+
+	for _, cidr := range bypassList {
+		n, err := parseIPv4Net(cidr)
+		if err != nil {
+			log.Warning(err)
+			continue
+		}
+		networks = subtract(networks, n)
+	}
+
+	ret := make([]string, 0, len(networks))
+	for _, n := range networks {
+		ret = append(ret, n.String())
+	}
+	return ret
+}
+
+// NotCapturedNetworks - IPv4 networks which are NOT captured by the tunnel for the
+// given AllowedIPs set (the complement of the set). Empty result - full default-route
+// capture (including an empty 'allowedIPs' argument: the platform defaults capture everything).
+// In use by the firewall to keep the non-captured destinations reachable in partial-tunnel mode.
+func NotCapturedNetworks(allowedIPs []string) []string {
+	if len(allowedIPs) == 0 {
+		return nil
+	}
+	return subtractAllowedIPs([]string{"0.0.0.0/0"}, allowedIPs)
+}
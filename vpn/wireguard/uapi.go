@@ -0,0 +1,138 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// uapiError - error reported by the WireGuard UAPI ('errno' value of the device response)
+type uapiError struct {
+	errno int
+}
+
+func (e *uapiError) Error() string {
+	return fmt.Sprintf("WireGuard UAPI error (errno=%d)", e.errno)
+}
+
+// wgKeyToHex - convert a WireGuard key from its base64 representation
+// into the hex representation expected by the UAPI
+func wgKeyToHex(keyBase64 string) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyBase64))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode WireGuard key: %w", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// configureDeviceUAPI - direct configuration of a (userspace) WireGuard device
+// over its UAPI socket: private key, listen port, peer, endpoint and AllowedIPs
+// are applied in one 'set' operation.
+// No configuration file is involved - the private key never touches the disk;
+// a device failure is returned as 'uapiError' with the reported errno value
+func (wg *WireGuard) configureDeviceUAPI(socketPath string, listenPort int, defaultAllowedIPs []string) error {
+	privateKeyHex, err := wgKeyToHex(wg.connectParams.clientPrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid client private key: %w", err)
+	}
+	publicKeyHex, err := wgKeyToHex(wg.connectParams.hostPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid host public key: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("set=1\n")
+	sb.WriteString("private_key=" + privateKeyHex + "\n")
+	if listenPort > 0 {
+		sb.WriteString("listen_port=" + strconv.Itoa(listenPort) + "\n")
+	}
+	sb.WriteString("replace_peers=true\n")
+	sb.WriteString("public_key=" + publicKeyHex + "\n")
+	sb.WriteString("endpoint=" + wg.endpoint() + "\n")
+	sb.WriteString("persistent_keepalive_interval=" + strconv.Itoa(wg.keepAliveInterval()) + "\n")
+	sb.WriteString("replace_allowed_ips=true\n")
+	for _, allowedIP := range wg.effectiveAllowedIPs(defaultAllowedIPs) {
+		sb.WriteString("allowed_ip=" + allowedIP + "\n")
+	}
+	sb.WriteString("\n")
+
+	return wg.sendUAPIRequest(socketPath, sb.String())
+}
+
+// updatePeerEndpointUAPI - re-apply the endpoint of an already configured peer
+// ('replace_peers' is not set - the existing peer is updated in place).
+// Forces the device to re-bind its UDP socket according to the current routing table
+// (used for roaming of the established tunnel after a network change)
+func (wg *WireGuard) updatePeerEndpointUAPI(socketPath string) error {
+	publicKeyHex, err := wgKeyToHex(wg.connectParams.hostPublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid host public key: %w", err)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("set=1\n")
+	sb.WriteString("public_key=" + publicKeyHex + "\n")
+	sb.WriteString("endpoint=" + wg.endpoint() + "\n")
+	sb.WriteString("\n")
+
+	return wg.sendUAPIRequest(socketPath, sb.String())
+}
+
+// sendUAPIRequest - send a 'set' request to the device UAPI socket and parse the response
+func (wg *WireGuard) sendUAPIRequest(socketPath string, request string) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect to WireGuard UAPI socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(request)); err != nil {
+		return fmt.Errorf("failed to send UAPI request: %w", err)
+	}
+
+	// response example: 'errno=0' followed by an empty line
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			break
+		}
+		if strings.HasPrefix(line, "errno=") {
+			errno, err := strconv.Atoi(strings.TrimPrefix(line, "errno="))
+			if err != nil {
+				return fmt.Errorf("unexpected UAPI response: '%s'", line)
+			}
+			if errno != 0 {
+				return &uapiError{errno: errno}
+			}
+		}
+	}
+
+	return scanner.Err()
+}
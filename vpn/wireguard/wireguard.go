@@ -31,6 +31,8 @@ import (
 
 	"github.com/ivpn/desktop-app-daemon/logger"
 	"github.com/ivpn/desktop-app-daemon/netinfo"
+	"github.com/ivpn/desktop-app-daemon/obfuscation"
+	"github.com/ivpn/desktop-app-daemon/shell"
 	"github.com/ivpn/desktop-app-daemon/vpn"
 )
 
@@ -42,12 +44,123 @@ func init() {
 
 // ConnectionParams contains all information to make new connection
 type ConnectionParams struct {
-	clientLocalIP    net.IP
-	clientPrivateKey string
-	hostPort         int
-	hostIP           net.IP
-	hostPublicKey    string
-	hostLocalIP      net.IP
+	clientLocalIP     net.IP
+	clientPrivateKey  string
+	hostPort          int
+	hostIP            net.IP
+	hostPublicKey     string
+	hostLocalIP       net.IP
+	multihopExitSrvID string
+	// hostLocalIPv6 - IPv6 address of the host inside the tunnel
+	// (defined only when the server supports IPv6 and IPv6 was requested for this connection)
+	hostLocalIPv6 net.IP
+	// networks which have to bypass the tunnel (subtracted from the peer AllowedIPs)
+	bypassCIDRs []string
+	// peer AllowedIPs defined by a custom (non-IVPN) profile
+	// (when defined - used instead of the platform defaults)
+	customAllowedIPs []string
+	// network interface to route the endpoint traffic through (tunnel-within-tunnel:
+	// the handshake\data of this connection flow through another active VPN tunnel;
+	// empty - the endpoint traffic bypasses all tunnels via the default gateway)
+	endpointRouteInterface string
+
+	// isObfuscation == true - encapsulate the WireGuard UDP traffic into a TLS\TCP stream
+	// to the obfuscation endpoint on the server (for networks where plain WireGuard is blocked)
+	isObfuscation bool
+	// obfuscation endpoint port on the server (0 - use the connection port)
+	obfuscationPort int
+	// local UDP port of the started obfuscation wrapper (defined during connection establishing)
+	obfuscationLocalPort int
+
+	// mtu - MTU of the tunnel interface (0 - automatic: path MTU probing)
+	mtu int
+
+	// keepAlive - peer persistent-keepalive interval in seconds (0 - use the default interval)
+	keepAlive int
+	// listenPort - fixed local listen port (0 - use a random free port)
+	listenPort int
+}
+
+// SetMTU - define MTU of the tunnel interface (0 - automatic: path MTU probing)
+func (cp *ConnectionParams) SetMTU(mtu int) {
+	cp.mtu = mtu
+}
+
+// SetKeepAlive - define peer persistent-keepalive interval in seconds
+// (0 - use the default interval; useful for strict NATs which drop UDP mappings quickly)
+func (cp *ConnectionParams) SetKeepAlive(keepAlive int) {
+	cp.keepAlive = keepAlive
+}
+
+// SetListenPort - define fixed local listen port (0 - use a random free port;
+// a predictable source port is useful for router firewall rules)
+func (cp *ConnectionParams) SetListenPort(listenPort int) {
+	cp.listenPort = listenPort
+}
+
+// SetPreferredPort - apply preferred connection port saved in preferences
+// (ignored when the connection request already defines a destination port)
+func (cp *ConnectionParams) SetPreferredPort(port int) {
+	if cp.hostPort > 0 {
+		return
+	}
+	cp.hostPort = port
+}
+
+// SetObfuscation - encapsulate the WireGuard UDP traffic into a TLS\TCP stream to the server
+// (remotePort - obfuscation endpoint port on the server, 0 - use the connection port)
+func (cp *ConnectionParams) SetObfuscation(isObfuscation bool, remotePort int) {
+	cp.isObfuscation = isObfuscation
+	cp.obfuscationPort = remotePort
+}
+
+// SetCustomRoutes - define per-IP\subnet split-tunneling rules:
+// traffic to 'bypassCIDRs' networks is routed outside the tunnel
+func (cp *ConnectionParams) SetCustomRoutes(bypassCIDRs []string) {
+	cp.bypassCIDRs = bypassCIDRs
+}
+
+// SetCustomAllowedIPs - define the peer AllowedIPs set explicitly
+// (partial tunneling; when defined - the platform defaults are not used)
+func (cp *ConnectionParams) SetCustomAllowedIPs(allowedIPs []string) {
+	cp.customAllowedIPs = allowedIPs
+}
+
+// CustomAllowedIPs returns the explicitly defined peer AllowedIPs set
+// (empty - full default-route capture using the platform defaults)
+func (cp *ConnectionParams) CustomAllowedIPs() []string {
+	return cp.customAllowedIPs
+}
+
+// SetEndpointRouteInterface - route the endpoint traffic through the defined network
+// interface (another active VPN tunnel) instead of the default gateway.
+// In use by the 'nested' VPN coexistence policy (tunnel-within-tunnel).
+// Note: has no effect on Windows - the WireGuard tunnel service manages routes itself.
+func (cp *ConnectionParams) SetEndpointRouteInterface(interfaceName string) {
+	cp.endpointRouteInterface = interfaceName
+}
+
+// SetIPv6 - enable IPv6 inside the tunnel
+// hostLocalIPv6 - IPv6 address of the host inside the tunnel (IPv6 stays disabled when nil)
+func (cp *ConnectionParams) SetIPv6(hostLocalIPv6 net.IP) {
+	cp.hostLocalIPv6 = hostLocalIPv6
+}
+
+// IsIPv6 returns true when IPv6 is enabled inside the tunnel
+func (cp *ConnectionParams) IsIPv6() bool {
+	return cp.hostLocalIPv6 != nil
+}
+
+// clientLocalIPv6 - IPv6 address of the client inside the tunnel:
+// the host network (/64) with an interface identifier based on the client IPv4 tunnel address
+func (cp *ConnectionParams) clientLocalIPv6() net.IP {
+	if cp.hostLocalIPv6 == nil || cp.clientLocalIP == nil {
+		return nil
+	}
+	ret := make(net.IP, net.IPv6len)
+	copy(ret, cp.hostLocalIPv6.To16())
+	copy(ret[net.IPv6len-net.IPv4len:], cp.clientLocalIP.To4())
+	return ret
 }
 
 // SetCredentials update WG credentials
@@ -57,17 +170,21 @@ func (cp *ConnectionParams) SetCredentials(privateKey string, localIP net.IP) {
 }
 
 // CreateConnectionParams initializing connection parameters object
+// For a multihop connection: hostIP is the entry server IP, but hostPort and hostPublicKey
+// belong to the exit server (multihopExitSrvID - gateway ID of the exit server)
 func CreateConnectionParams(
+	multihopExitSrvID string,
 	hostPort int,
 	hostIP net.IP,
 	hostPublicKey string,
 	hostLocalIP net.IP) ConnectionParams {
 
 	return ConnectionParams{
-		hostPort:      hostPort,
-		hostIP:        hostIP,
-		hostPublicKey: hostPublicKey,
-		hostLocalIP:   hostLocalIP}
+		multihopExitSrvID: multihopExitSrvID,
+		hostPort:          hostPort,
+		hostIP:            hostIP,
+		hostPublicKey:     hostPublicKey,
+		hostLocalIP:       hostLocalIP}
 }
 
 // WireGuard structure represents all data of wireguard connection
@@ -77,6 +194,9 @@ type WireGuard struct {
 	configFilePath string
 	connectParams  ConnectionParams
 
+	// started obfuscation wrapper (defined only when obfuscation requested for this connection)
+	obfuscator obfuscation.ObfuscationTransport
+
 	// Must be implemented (AND USED) in correspond file for concrete platform. Must contain platform-specified properties (or can be empty struct)
 	internals internalVariables
 }
@@ -121,6 +241,29 @@ func (wg *WireGuard) Connect(stateChan chan<- vpn.StateInfo) error {
 		stateChan <- vpn.NewStateInfo(vpn.DISCONNECTED, disconnectDescription)
 	}()
 
+	// start obfuscation wrapper (if requested):
+	// the WireGuard UDP traffic is encapsulated into a TLS\TCP stream to the server
+	if wg.connectParams.isObfuscation {
+		remotePort := wg.connectParams.obfuscationPort
+		if remotePort <= 0 {
+			remotePort = wg.connectParams.hostPort
+		}
+
+		wrapper := obfuscation.NewUDPTLSWrapper(net.JoinHostPort(wg.connectParams.hostIP.String(), strconv.Itoa(remotePort)))
+		localPort, err := wrapper.Start()
+		if err != nil {
+			disconnectDescription = err.Error()
+			return fmt.Errorf("failed to start obfuscation wrapper: %w", err)
+		}
+
+		wg.obfuscator = wrapper
+		wg.connectParams.obfuscationLocalPort = localPort
+		defer func() {
+			wrapper.Stop()
+			wg.obfuscator = nil
+		}()
+	}
+
 	err := wg.connect(stateChan)
 
 	if err != nil {
@@ -130,11 +273,30 @@ func (wg *WireGuard) Connect(stateChan chan<- vpn.StateInfo) error {
 	return err
 }
 
+// notifyConnectedStat - send 'CONNECTED' state with connection details
+// (including exit server ID for multihop connections)
+func (wg *WireGuard) notifyConnectedStat(stateChan chan<- vpn.StateInfo) {
+	si := vpn.NewStateInfoConnected(wg.connectParams.clientLocalIP, wg.connectParams.hostIP)
+	si.ExitServerID = wg.connectParams.multihopExitSrvID
+	stateChan <- si
+}
+
 // Disconnect stops the connection
 func (wg *WireGuard) Disconnect() error {
 	return wg.disconnect()
 }
 
+// OnRoutingChanged - the default route\interface changed: re-apply the peer endpoint
+// so the established tunnel roams to the new network instead of a full reconnection.
+// Not applicable when the obfuscation wrapper is active
+// (its TCP stream is bound to the old interface; a reconnection is required)
+func (wg *WireGuard) OnRoutingChanged() error {
+	if wg.connectParams.isObfuscation {
+		return fmt.Errorf("roaming is not applicable for an obfuscated connection")
+	}
+	return wg.onRoutingChanged()
+}
+
 // IsPaused checking if we are in paused state
 func (wg *WireGuard) IsPaused() bool {
 	return wg.isPaused()
@@ -160,6 +322,52 @@ func (wg *WireGuard) ResetManualDNS() error {
 	return wg.resetManualDNS()
 }
 
+// GetStats - current connection statistics (as reported by the 'wg' tool)
+func (wg *WireGuard) GetStats() (vpn.Statistics, error) {
+	tunName := wg.getTunnelName()
+	if len(tunName) == 0 {
+		return vpn.Statistics{}, fmt.Errorf("unable to get connection statistics: tunnel interface not defined")
+	}
+
+	var ret vpn.Statistics
+	isPeerFound := false
+
+	// 'wg show <interface> dump' output (tab-separated):
+	// first line - interface info; every next line describes a peer:
+	// public-key, preshared-key, endpoint, allowed-ips, latest-handshake, transfer-rx, transfer-tx, persistent-keepalive
+	outParse := func(text string, isError bool) {
+		if isError || isPeerFound {
+			return
+		}
+		cols := strings.Split(text, "\t")
+		if len(cols) < 7 {
+			return // interface description line
+		}
+		isPeerFound = true
+
+		ret.Endpoint = cols[2]
+		if v, err := strconv.ParseInt(cols[4], 10, 64); err == nil {
+			ret.LastHandshake = v
+		}
+		if v, err := strconv.ParseUint(cols[5], 10, 64); err == nil {
+			ret.BytesReceived = v
+		}
+		if v, err := strconv.ParseUint(cols[6], 10, 64); err == nil {
+			ret.BytesSent = v
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", wg.toolBinaryPath, "show", tunName, "dump"); err != nil {
+		return vpn.Statistics{}, fmt.Errorf("failed to get WireGuard statistics: %w", err)
+	}
+
+	if isPeerFound == false {
+		return vpn.Statistics{}, fmt.Errorf("failed to get WireGuard statistics: peer not found")
+	}
+
+	return ret, nil
+}
+
 func (wg *WireGuard) generateAndSaveConfigFile(cfgFilePath string) error {
 	cfg, err := wg.generateConfig()
 	if err != nil {
@@ -182,8 +390,96 @@ func (wg *WireGuard) generateAndSaveConfigFile(cfgFilePath string) error {
 	return nil
 }
 
+// wgEncapsulationOverhead - WireGuard encapsulation overhead (bytes)
+// subtracted from the probed path MTU (the value covers IPv6 encapsulation as well)
+const wgEncapsulationOverhead = 80
+
+// minimum MTU of the tunnel interface (the IPv6 minimum link MTU)
+const wgMinimumMTU = 1280
+
+// tunnelMTU - MTU for the tunnel interface:
+// the user-defined value when set; otherwise the path MTU to the server is probed
+// and the WireGuard encapsulation overhead subtracted
+// (users on PPPoE\LTE links suffer silent fragmentation failures with the default MTU)
+// Returns 0 when probing failed - the platform default MTU stays untouched
+func (wg *WireGuard) tunnelMTU() int {
+	if wg.connectParams.mtu > 0 {
+		return wg.connectParams.mtu
+	}
+
+	pathMTU, err := netinfo.GetPathMTU(wg.connectParams.hostIP)
+	if err != nil {
+		log.Warning("Failed to probe path MTU (keeping default MTU): ", err)
+		return 0
+	}
+
+	mtu := pathMTU - wgEncapsulationOverhead
+	if mtu < wgMinimumMTU {
+		mtu = wgMinimumMTU
+	}
+
+	log.Info(fmt.Sprintf("Path MTU to the server: %d (tunnel MTU: %d)", pathMTU, mtu))
+	return mtu
+}
+
+// default peer persistent-keepalive interval (seconds)
+const wgDefaultKeepAlive = 25
+
+// keepAliveInterval - peer persistent-keepalive interval for this connection (seconds)
+func (wg *WireGuard) keepAliveInterval() int {
+	if wg.connectParams.keepAlive > 0 {
+		return wg.connectParams.keepAlive
+	}
+	return wgDefaultKeepAlive
+}
+
+// localListenPort - local listen port for this connection
+// (the user-defined fixed port or a random free one)
+func (wg *WireGuard) localListenPort() (int, error) {
+	if wg.connectParams.listenPort > 0 {
+		return wg.connectParams.listenPort, nil
+	}
+	return netinfo.GetFreePort()
+}
+
+// endpoint - peer endpoint 'host:port'
+// (the local endpoint of the obfuscation wrapper when it is started)
+func (wg *WireGuard) endpoint() string {
+	if wg.connectParams.obfuscationLocalPort > 0 {
+		return "127.0.0.1:" + strconv.Itoa(wg.connectParams.obfuscationLocalPort)
+	}
+	return wg.connectParams.hostIP.String() + ":" + strconv.Itoa(wg.connectParams.hostPort)
+}
+
+// effectiveAllowedIPs - effective peer AllowedIPs set for this connection:
+// 'defaultAllowed' (platform-specific defaults) with the split-tunneling 'bypass'
+// networks subtracted; '::/0' appended when IPv6 is enabled for this connection
+func (wg *WireGuard) effectiveAllowedIPs(defaultAllowed []string) []string {
+	bypassCIDRs := wg.connectParams.bypassCIDRs
+	if wg.connectParams.obfuscationLocalPort > 0 {
+		// the traffic of the obfuscation wrapper to the real server has to bypass the tunnel
+		bypassCIDRs = append(append([]string{}, bypassCIDRs...), wg.connectParams.hostIP.String()+"/32")
+	}
+
+	allowed := defaultAllowed
+	isCustomAllowedIPs := len(wg.connectParams.customAllowedIPs) > 0
+	if isCustomAllowedIPs {
+		// custom (non-IVPN) profile: the imported AllowedIPs set is authoritative
+		allowed = wg.connectParams.customAllowedIPs
+	}
+	if len(bypassCIDRs) > 0 {
+		allowed = subtractAllowedIPs(allowed, bypassCIDRs)
+	}
+
+	if wg.connectParams.IsIPv6() && isCustomAllowedIPs == false {
+		allowed = append(allowed, "::/0")
+	}
+
+	return allowed
+}
+
 func (wg *WireGuard) generateConfig() ([]string, error) {
-	listenPort, err := netinfo.GetFreePort()
+	listenPort, err := wg.localListenPort()
 	if err != nil {
 		return nil, fmt.Errorf("unable to obtain free local port: %w", err)
 	}
@@ -193,16 +489,39 @@ func (wg *WireGuard) generateConfig() ([]string, error) {
 		"PrivateKey = " + wg.connectParams.clientPrivateKey,
 		"ListenPort = " + strconv.Itoa(listenPort)}
 
+	// user-defined MTU (automatic path-MTU probing is not applicable for the config-based setup)
+	if wg.connectParams.mtu > 0 {
+		interfaceCfg = append(interfaceCfg, "MTU = "+strconv.Itoa(wg.connectParams.mtu))
+	}
+
 	peerCfg := []string{
 		"[Peer]",
 		"PublicKey = " + wg.connectParams.hostPublicKey,
-		"Endpoint = " + wg.connectParams.hostIP.String() + ":" + strconv.Itoa(wg.connectParams.hostPort),
-		"PersistentKeepalive = 25"}
+		"Endpoint = " + wg.endpoint(),
+		"PersistentKeepalive = " + strconv.Itoa(wg.keepAliveInterval())}
 
 	// add some OS-specific configurations (if necessary)
 	iCfg, pCgf := wg.getOSSpecificConfigParams()
 	interfaceCfg = append(interfaceCfg, iCfg...)
 	peerCfg = append(peerCfg, pCgf...)
 
+	// effective peer AllowedIPs: split-tunneling 'bypass' networks are subtracted
+	// from the platform defaults; '::/0' is appended when IPv6 is enabled
+	for i, line := range peerCfg {
+		if strings.HasPrefix(line, "AllowedIPs") == false {
+			continue
+		}
+		allowed := strings.Split(strings.TrimSpace(strings.TrimPrefix(line, "AllowedIPs =")), ",")
+		for j := range allowed {
+			allowed[j] = strings.TrimSpace(allowed[j])
+		}
+		peerCfg[i] = "AllowedIPs = " + strings.Join(wg.effectiveAllowedIPs(allowed), ", ")
+	}
+
+	// IPv6 address inside the tunnel (when enabled for this connection)
+	if wg.connectParams.IsIPv6() {
+		interfaceCfg = append(interfaceCfg, "Address = "+wg.connectParams.clientLocalIPv6().String()+"/128")
+	}
+
 	return append(interfaceCfg, peerCfg...), nil
 }
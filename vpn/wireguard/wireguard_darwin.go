@@ -24,14 +24,15 @@ package wireguard
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
-	"os"
 	"os/exec"
 	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/ivpn/desktop-app-daemon/netinfo"
@@ -43,9 +44,8 @@ import (
 
 //TODO: BE CAREFUL! Constant string! (can be changed after WireGuard update)
 const (
-	strTriggerSuccessInit      string = "UAPI listener started"
-	strTriggerInterfaceDown    string = "Interface set down"
-	strTriggerAddrAlreadyInUse string = "Address already in use"
+	strTriggerSuccessInit   string = "UAPI listener started"
+	strTriggerInterfaceDown string = "Interface set down"
 )
 
 const subnetMask string = "255.0.0.0"
@@ -57,6 +57,13 @@ type internalVariables struct {
 	isGoingToStop bool
 	isPaused      bool
 	defGateway    net.IP
+	utunName      string // name of the 'utun' interface of the active connection
+}
+
+// getTunnelName - name of the WG network interface
+// (on macOS the 'utun' interface is allocated dynamically on each connection)
+func (wg *WireGuard) getTunnelName() string {
+	return wg.internals.utunName
 }
 
 func (wg *WireGuard) init() error {
@@ -111,6 +118,8 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 		return fmt.Errorf("unable to start WireGuard. Failed to obtain free utun interface: %w", err)
 	}
 
+	wg.internals.utunName = utunName
+
 	log.Info("Starting WireGuard in interface ", utunName)
 	wg.internals.command = exec.Command(wg.binaryPath, "-f", utunName)
 
@@ -181,7 +190,7 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 			} else {
 				log.Info("Started")
 				// CONNECTED
-				stateChan <- vpn.NewStateInfoConnected(wg.connectParams.clientLocalIP, wg.connectParams.hostIP)
+				wg.notifyConnectedStat(stateChan)
 			}
 
 		case <-time.After(time.Second * 5):
@@ -228,6 +237,47 @@ func (wg *WireGuard) disconnect() error {
 	return cmd.Process.Kill()
 }
 
+// onRoutingChanged - roam the established tunnel to the new network:
+// the host route to the server is refreshed (it was bound to the old default gateway)
+// and the peer endpoint is re-applied over the UAPI socket - the device re-binds
+// its UDP socket according to the new routing table
+func (wg *WireGuard) onRoutingChanged() error {
+	utunName := wg.internals.utunName
+	if len(utunName) == 0 {
+		return fmt.Errorf("tunnel is not started")
+	}
+
+	// refresh the host route to the real server (the traffic to the server has to bypass our tunnel)
+	if nestedIf := wg.connectParams.endpointRouteInterface; len(nestedIf) > 0 {
+		// 'nested' coexistence policy: the endpoint route stays bound to the other VPN tunnel
+		// example command: sudo route -n add -host 145.239.239.55 -interface utun5
+		shell.Exec(log, "route", "-n", "delete", wg.connectParams.hostIP.String())
+		if err := shell.Exec(log, "route", "-n", "add", "-host", wg.connectParams.hostIP.String(), "-interface", nestedIf); err != nil {
+			return fmt.Errorf("failed to update route to the VPN server (via '%s'): %w", nestedIf, err)
+		}
+	} else {
+		defaultGwIP, err := netinfo.DefaultGatewayIP()
+		if err != nil {
+			return fmt.Errorf("failed to get local gateway: %w", err)
+		}
+		// example commands:	sudo route -n delete 145.239.239.55
+		//						sudo route -n add -net 145.239.239.55 192.168.1.1 255.255.255.255
+		shell.Exec(log, "route", "-n", "delete", wg.connectParams.hostIP.String())
+		if err := shell.Exec(log, "route", "-n", "add", "-net", wg.connectParams.hostIP.String(), defaultGwIP.String(), "255.255.255.255"); err != nil {
+			return fmt.Errorf("failed to update route to the VPN server: %w", err)
+		}
+		wg.internals.defGateway = defaultGwIP
+	}
+
+	// re-apply the peer endpoint
+	socketPath := fmt.Sprintf("/var/run/wireguard/%s.sock", utunName)
+	if err := wg.updatePeerEndpointUAPI(socketPath); err != nil {
+		return fmt.Errorf("failed to re-apply WireGuard peer endpoint: %w", err)
+	}
+
+	return nil
+}
+
 func (wg *WireGuard) isPaused() bool {
 	return wg.internals.isPaused
 }
@@ -300,13 +350,25 @@ func (wg *WireGuard) initializeConfiguration(utunName string) error {
 // Configure WireGuard interface
 // example command: ipconfig set utun7 MANUAL 10.0.0.121 255.255.255.0
 func (wg *WireGuard) initializeUnunInterface(utunName string) error {
-	return shell.Exec(log, "ipconfig", "set", utunName, "MANUAL", wg.connectParams.clientLocalIP.String(), subnetMask)
+	if err := shell.Exec(log, "ipconfig", "set", utunName, "MANUAL", wg.connectParams.clientLocalIP.String(), subnetMask); err != nil {
+		return err
+	}
+
+	// tunnel MTU: user-defined or probed path MTU (0 - keep the default MTU)
+	if mtu := wg.tunnelMTU(); mtu > 0 {
+		// example command: ifconfig utun7 mtu 1420
+		if err := shell.Exec(log, "ifconfig", utunName, "mtu", strconv.Itoa(mtu)); err != nil {
+			return fmt.Errorf("failed to set tunnel MTU: %w", err)
+		}
+	}
+
+	return nil
 }
 
-// WireGuard configuration
+// WireGuard configuration: the device is configured directly over its UAPI socket
+// (no configuration file on disk - the private key never touches the filesystem)
 func (wg *WireGuard) setWgConfiguration(utunName string) error {
-	// do not forget to remove config file after finishing configuration
-	defer os.Remove(wg.configFilePath)
+	socketPath := fmt.Sprintf("/var/run/wireguard/%s.sock", utunName)
 
 	for retries := 0; ; retries++ {
 		// few retries if local port is already in use
@@ -315,33 +377,24 @@ func (wg *WireGuard) setWgConfiguration(utunName string) error {
 			return fmt.Errorf("failed to set wireguard configuration")
 		}
 
-		// generate configuration
-		err := wg.generateAndSaveConfigFile(wg.configFilePath)
+		listenPort, err := wg.localListenPort()
 		if err != nil {
-			return fmt.Errorf("failed to save WG config file: %w", err)
+			return fmt.Errorf("unable to obtain free local port: %w", err)
 		}
 
-		// define output processing function
-		isPortInUse := false
-		errParse := func(text string, isError bool) {
-			if isError {
-				log.Debug("[wgconf error] ", text)
-			} else {
-				log.Debug("[wgconf out] ", text)
-			}
-			if strings.Contains(text, strTriggerAddrAlreadyInUse) {
-				isPortInUse = true
-			}
+		err = wg.configureDeviceUAPI(socketPath, listenPort, []string{"128.0.0.0/1", "0.0.0.0/1"})
+		if err == nil {
+			return nil
 		}
 
-		// Configure WireGuard
-		// example command: wg setconf utun7 wireguard.conf
-		err = shell.ExecAndProcessOutput(log, errParse, "", wg.toolBinaryPath,
-			"setconf", utunName, wg.configFilePath)
-
-		if isPortInUse == false {
-			return err
+		// retry with another port when the selected local port is already in use
+		var devErr *uapiError
+		if errors.As(err, &devErr) && devErr.errno == int(syscall.EADDRINUSE) {
+			log.Warning(fmt.Sprintf("local port %d already in use. Retrying...", listenPort))
+			continue
 		}
+
+		return err
 	}
 }
 
@@ -359,9 +412,17 @@ func (wg *WireGuard) setRoutes() error {
 	}
 
 	// Update routing to remote server (remote_server default_router 255.255.255)
-	// example command: sudo route -n add -net 145.239.239.55 192.168.1.1 255.255.255.255
-	if err := shell.Exec(log, "route", "-n", "add", "-net", wg.connectParams.hostIP.String(), wg.internals.defGateway.String(), "255.255.255.255"); err != nil {
-		return fmt.Errorf("adding route shell comand error : %w", err)
+	// 'nested' coexistence policy - route the server traffic through another active VPN tunnel
+	// example command: sudo route -n add -host 145.239.239.55 -interface utun5
+	if nestedIf := wg.connectParams.endpointRouteInterface; len(nestedIf) > 0 {
+		if err := shell.Exec(log, "route", "-n", "add", "-host", wg.connectParams.hostIP.String(), "-interface", nestedIf); err != nil {
+			return fmt.Errorf("adding route shell comand error : %w", err)
+		}
+	} else {
+		// example command: sudo route -n add -net 145.239.239.55 192.168.1.1 255.255.255.255
+		if err := shell.Exec(log, "route", "-n", "add", "-net", wg.connectParams.hostIP.String(), wg.internals.defGateway.String(), "255.255.255.255"); err != nil {
+			return fmt.Errorf("adding route shell comand error : %w", err)
+		}
 	}
 
 	// Update routing table
@@ -25,13 +25,14 @@ package wireguard
 import (
 	"fmt"
 	"net"
-	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/ivpn/desktop-app-daemon/netinfo"
 	"github.com/ivpn/desktop-app-daemon/service/dns"
 	"github.com/ivpn/desktop-app-daemon/shell"
 	"github.com/ivpn/desktop-app-daemon/vpn"
@@ -43,13 +44,19 @@ type internalVariables struct {
 	isRunning bool
 }
 
+// getTunnelName - name of the WG network interface
+// (on Linux it is defined by the configuration file name: 'wgivpn')
+func (wg *WireGuard) getTunnelName() string {
+	wgInterfaceName := filepath.Base(wg.configFilePath)
+	return strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
+}
+
 func (wg *WireGuard) init() error {
 	// It can happen that ivpn-daemon was not correctly stopped during WireGuard connection
 	// (e.g. process was terminated)
 	// In such situation, the 'wgivpn' keeps active.
 	// We should close it in this case. Otherwise, new connection would not be established
-	wgInterfaceName := filepath.Base(wg.configFilePath)
-	wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
+	wgInterfaceName := wg.getTunnelName()
 	// stop current WG connection (if exists)
 	i, _ := net.InterfaceByName(wgInterfaceName)
 	if i != nil {
@@ -70,12 +77,16 @@ func (wg *WireGuard) init() error {
 // connect - SYNCHRONOUSLY execute openvpn process (wait until it finished)
 func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 
+	wgInterfaceName := wg.getTunnelName()
+
 	wg.internals.isRunning = true
+	isConfigured := false
 	defer func() {
 		wg.internals.isRunning = false
-		// do not forget to remove config file after finishing configuration
-		if err := os.Remove(wg.configFilePath); err != nil {
-			log.Warning(fmt.Sprintf("failed to remove WG configuration: %s", err))
+
+		if isConfigured == false {
+			// remove the interface when the configuration was not finished
+			shell.Exec(log, "ip", "link", "delete", wgInterfaceName)
 		}
 
 		// restore DNS configuration
@@ -84,10 +95,43 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 		}
 	}()
 
-	// generate configuration
-	err := wg.generateAndSaveConfigFile(wg.configFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to save WG config file: %w", err)
+	// create WireGuard network interface
+	// command: sudo ip link add dev wgivpn type wireguard
+	if err := shell.Exec(log, "ip", "link", "add", "dev", wgInterfaceName, "type", "wireguard"); err != nil {
+		return fmt.Errorf("failed to create WireGuard interface: %w", err)
+	}
+
+	// configure the device directly (no configuration file on disk)
+	if err := wg.setDeviceConfig(wgInterfaceName); err != nil {
+		return err
+	}
+
+	// assign tunnel IP address
+	// command: sudo ip -4 address add <client_ip>/32 dev wgivpn
+	if err := shell.Exec(log, "ip", "-4", "address", "add", wg.connectParams.clientLocalIP.String()+"/32", "dev", wgInterfaceName); err != nil {
+		return fmt.Errorf("failed to set tunnel IP address: %w", err)
+	}
+	if wg.connectParams.IsIPv6() {
+		if err := shell.Exec(log, "ip", "-6", "address", "add", wg.connectParams.clientLocalIPv6().String()+"/128", "dev", wgInterfaceName); err != nil {
+			return fmt.Errorf("failed to set tunnel IPv6 address: %w", err)
+		}
+	}
+
+	// tunnel MTU: user-defined or probed path MTU (0 - keep the default MTU)
+	if mtu := wg.tunnelMTU(); mtu > 0 {
+		// command: sudo ip link set mtu <mtu> dev wgivpn
+		if err := shell.Exec(log, "ip", "link", "set", "mtu", strconv.Itoa(mtu), "dev", wgInterfaceName); err != nil {
+			return fmt.Errorf("failed to set tunnel MTU: %w", err)
+		}
+	}
+
+	// command: sudo ip link set up dev wgivpn
+	if err := shell.Exec(log, "ip", "link", "set", "up", "dev", wgInterfaceName); err != nil {
+		return fmt.Errorf("failed to bring WireGuard interface up: %w", err)
+	}
+
+	if err := wg.setRoutes(wgInterfaceName); err != nil {
+		return err
 	}
 
 	// update DNS configuration
@@ -97,28 +141,16 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 		}
 	}
 
-	// start WG
-	log.Info("Shell exec: ", wg.binaryPath, " up ", wg.configFilePath)
-	cmd := exec.Command(wg.binaryPath, "up", wg.configFilePath)
-	outBytes, err := cmd.CombinedOutput()
-	if err != nil {
-		if len(outBytes) > 0 {
-			log.Error(fmt.Sprintf("'%s' error. Output: %s", wg.binaryPath, string(outBytes)))
-		}
-		return fmt.Errorf("failed to start WireGuard: %w", err)
-	}
+	isConfigured = true
 
 	// notify connected
-	stateChan <- vpn.NewStateInfoConnected(wg.connectParams.clientLocalIP, wg.connectParams.hostIP)
+	wg.notifyConnectedStat(stateChan)
 
-	wgInterfaceName := filepath.Base(wg.configFilePath)
-	wgInterfaceName = strings.TrimSuffix(wgInterfaceName, path.Ext(wgInterfaceName))
 	// wait until wireguard interface is available
 	for {
 		time.Sleep(time.Millisecond * 500)
 		i, err := net.InterfaceByName(wgInterfaceName)
 		if err != nil {
-			fmt.Println(err)
 			break
 		}
 		if i == nil {
@@ -129,9 +161,123 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 	return nil
 }
 
-func (wg *WireGuard) disconnect() error {
-	err := shell.Exec(log, wg.binaryPath, "down", wg.configFilePath)
+// setDeviceConfig - direct WireGuard device configuration:
+// private key, listen port, peer, endpoint and AllowedIPs are applied in one 'wg set' call;
+// the private key is passed over stdin and never stored on disk
+func (wg *WireGuard) setDeviceConfig(wgInterfaceName string) error {
+	listenPort, err := wg.localListenPort()
 	if err != nil {
+		return fmt.Errorf("unable to obtain free local port: %w", err)
+	}
+
+	allowedIPs := wg.effectiveAllowedIPs([]string{"0.0.0.0/1", "128.0.0.0/1"})
+
+	// command: sudo wg set wgivpn listen-port <port> private-key /dev/stdin
+	//		peer <public_key> endpoint <host:port> persistent-keepalive 25 allowed-ips <cidr,...>
+	cmd := exec.Command(wg.toolBinaryPath, "set", wgInterfaceName,
+		"listen-port", strconv.Itoa(listenPort),
+		"private-key", "/dev/stdin",
+		"peer", wg.connectParams.hostPublicKey,
+		"endpoint", wg.endpoint(),
+		"persistent-keepalive", strconv.Itoa(wg.keepAliveInterval()),
+		"allowed-ips", strings.Join(allowedIPs, ","))
+	cmd.Stdin = strings.NewReader(wg.connectParams.clientPrivateKey + "\n")
+
+	if outBytes, err := cmd.CombinedOutput(); err != nil {
+		if len(outBytes) > 0 {
+			log.Error(fmt.Sprintf("'%s' error. Output: %s", wg.toolBinaryPath, string(outBytes)))
+		}
+		return fmt.Errorf("failed to configure WireGuard device: %w", err)
+	}
+
+	return nil
+}
+
+// setRoutes - one route per AllowedIPs network + a host route to the real server
+// (the '0.0.0.0/1'+'128.0.0.0/1' pair is in use instead of '0.0.0.0/0'
+// to take precedence over the default route without replacing it)
+func (wg *WireGuard) setRoutes(wgInterfaceName string) error {
+	for _, allowedIP := range wg.effectiveAllowedIPs([]string{"0.0.0.0/1", "128.0.0.0/1"}) {
+		ipVer := "-4"
+		if strings.Contains(allowedIP, ":") {
+			ipVer = "-6"
+		}
+		// command: sudo ip route add <cidr> dev wgivpn
+		if err := shell.Exec(log, "ip", ipVer, "route", "add", allowedIP, "dev", wgInterfaceName); err != nil {
+			return fmt.Errorf("failed to set route '%s': %w", allowedIP, err)
+		}
+	}
+
+	// the traffic to the real server has to be routed outside of our tunnel:
+	// through another active VPN tunnel ('nested' coexistence policy) or via the default gateway
+	if nestedIf := wg.connectParams.endpointRouteInterface; len(nestedIf) > 0 {
+		// command: sudo ip route add <server_ip>/32 dev <other_vpn_interface>
+		if err := shell.Exec(log, "ip", "route", "add", wg.connectParams.hostIP.String()+"/32", "dev", nestedIf); err != nil {
+			return fmt.Errorf("failed to set route to the VPN server (via '%s'): %w", nestedIf, err)
+		}
+		return nil
+	}
+
+	// command: sudo ip route add <server_ip>/32 via <default_gateway>
+	defaultGwIP, err := netinfo.DefaultGatewayIP()
+	if err != nil {
+		return fmt.Errorf("failed to get local gateway: %w", err)
+	}
+	if err := shell.Exec(log, "ip", "route", "add", wg.connectParams.hostIP.String()+"/32", "via", defaultGwIP.String()); err != nil {
+		return fmt.Errorf("failed to set route to the VPN server: %w", err)
+	}
+
+	return nil
+}
+
+// onRoutingChanged - roam the established tunnel to the new network:
+// the host route to the server is refreshed (it was bound to the old default gateway)
+// and the peer endpoint is re-applied - the kernel re-binds the UDP socket
+// source address according to the new routing table
+func (wg *WireGuard) onRoutingChanged() error {
+	wgInterfaceName := wg.getTunnelName()
+	if i, _ := net.InterfaceByName(wgInterfaceName); i == nil {
+		return fmt.Errorf("tunnel interface '%s' is not active", wgInterfaceName)
+	}
+
+	// refresh the host route to the real server (the traffic to the server has to bypass our tunnel)
+	if nestedIf := wg.connectParams.endpointRouteInterface; len(nestedIf) > 0 {
+		// 'nested' coexistence policy: the endpoint route stays bound to the other VPN tunnel
+		// command: sudo ip route replace <server_ip>/32 dev <other_vpn_interface>
+		if err := shell.Exec(log, "ip", "route", "replace", wg.connectParams.hostIP.String()+"/32", "dev", nestedIf); err != nil {
+			return fmt.Errorf("failed to update route to the VPN server (via '%s'): %w", nestedIf, err)
+		}
+	} else {
+		defaultGwIP, err := netinfo.DefaultGatewayIP()
+		if err != nil {
+			return fmt.Errorf("failed to get local gateway: %w", err)
+		}
+		// command: sudo ip route replace <server_ip>/32 via <default_gateway>
+		if err := shell.Exec(log, "ip", "route", "replace", wg.connectParams.hostIP.String()+"/32", "via", defaultGwIP.String()); err != nil {
+			return fmt.Errorf("failed to update route to the VPN server: %w", err)
+		}
+	}
+
+	// re-apply the peer endpoint
+	// command: sudo wg set wgivpn peer <public_key> endpoint <host:port>
+	if err := shell.Exec(log, wg.toolBinaryPath, "set", wgInterfaceName,
+		"peer", wg.connectParams.hostPublicKey,
+		"endpoint", wg.endpoint()); err != nil {
+		return fmt.Errorf("failed to re-apply WireGuard peer endpoint: %w", err)
+	}
+
+	return nil
+}
+
+func (wg *WireGuard) disconnect() error {
+	wgInterfaceName := wg.getTunnelName()
+
+	// the host route to the server is not bound to the WG interface - remove it explicitly
+	shell.Exec(log, "ip", "route", "delete", wg.connectParams.hostIP.String()+"/32")
+
+	// removing the interface removes its routes as well
+	// command: sudo ip link delete wgivpn
+	if err := shell.Exec(log, "ip", "link", "delete", wgInterfaceName); err != nil {
 		return fmt.Errorf("failed to stop WireGuard: %w", err)
 	}
 	return nil
@@ -0,0 +1,512 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wireguard
+
+// Support of the 'wireguard-nt' kernel driver (the embeddable 'wireguard.dll' API):
+// the adapter is created and configured directly in memory - no tunnel service
+// and no plaintext configuration file on disk.
+// API reference: https://git.zx2c4.com/wireguard-nt (wireguard.h)
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/ivpn/desktop-app-daemon/service/dns"
+	"github.com/ivpn/desktop-app-daemon/shell"
+	"github.com/ivpn/desktop-app-daemon/vpn"
+
+	"golang.org/x/sys/windows"
+)
+
+// WIREGUARD_INTERFACE_FLAG
+const (
+	_ntIfaceHasPrivateKey = 0x2
+	_ntIfaceReplacePeers  = 0x8
+)
+
+// WIREGUARD_PEER_FLAG
+const (
+	_ntPeerHasPublicKey           = 0x1
+	_ntPeerHasPersistentKeepalive = 0x4
+	_ntPeerHasEndpoint            = 0x8
+	_ntPeerReplaceAllowedIPs      = 0x20
+)
+
+// WIREGUARD_ADAPTER_STATE
+const (
+	_ntAdapterStateDown = 0
+	_ntAdapterStateUp   = 1
+)
+
+// fixed adapter GUID: keeps the network profile stable between connections
+var _ntAdapterGUID = windows.GUID{Data1: 0xafe43773, Data2: 0xe1f8, Data3: 0x4ebb,
+	Data4: [8]byte{0x85, 0x36, 0x57, 0x6a, 0xb8, 0x6a, 0xfe, 0x9a}}
+
+// WIREGUARD_INTERFACE (the driver structures are 8-byte aligned)
+type ntInterfaceConfig struct {
+	Flags      uint32
+	ListenPort uint16
+	PrivateKey [32]byte
+	PublicKey  [32]byte
+	PeersCount uint32
+	_          [4]byte
+}
+
+// WIREGUARD_PEER
+type ntPeerConfig struct {
+	Flags               uint32
+	Reserved            uint32
+	PublicKey           [32]byte
+	PresharedKey        [32]byte
+	PersistentKeepalive uint16
+	_                   [2]byte
+	Endpoint            [28]byte // SOCKADDR_INET
+	TxBytes             uint64
+	RxBytes             uint64
+	LastHandshake       uint64
+	AllowedIPsCount     uint32
+	_                   [4]byte
+}
+
+// WIREGUARD_ALLOWED_IP
+type ntAllowedIPConfig struct {
+	Address       [16]byte
+	AddressFamily uint16
+	Cidr          uint8
+	_             [5]byte
+}
+
+// ntAdapter - an active wireguard-nt adapter
+type ntAdapter struct {
+	dll              *windows.DLL
+	handle           uintptr
+	procCloseAdapter *windows.Proc
+	procSetState     *windows.Proc
+}
+
+// ntDriverDllPath - 'wireguard.dll' is expected next to the WireGuard binaries
+func (wg *WireGuard) ntDriverDllPath() string {
+	return filepath.Join(filepath.Dir(wg.binaryPath), "wireguard.dll")
+}
+
+// isNtDriverAvailable - check if the embeddable wireguard-nt DLL is installed
+func (wg *WireGuard) isNtDriverAvailable() bool {
+	dll, err := windows.LoadDLL(wg.ntDriverDllPath())
+	if err != nil {
+		return false
+	}
+	defer dll.Release()
+	if _, err := dll.FindProc("WireGuardCreateAdapter"); err != nil {
+		return false
+	}
+	return true
+}
+
+// connectNtDriver - SYNCHRONOUSLY establish connection using the wireguard-nt driver
+// (wait until disconnected; mirrors the service-based 'connect' loop)
+func (wg *WireGuard) connectNtDriver(stateChan chan<- vpn.StateInfo) error {
+	log.Info("Using the wireguard-nt kernel driver")
+	wg.internals.useNtDriver = true
+
+	if err := wg.ntStartTunnel(stateChan); err != nil {
+		return fmt.Errorf("failed to start wireguard-nt tunnel: %w", err)
+	}
+
+	if wg.internals.isDisconnectRequested {
+		// there is chance that disconnection request come during WG was establishing connection
+		// in this case - perform disconnection
+		log.Info("Disconnection was requested")
+		return wg.ntStopTunnel()
+	}
+
+	wg.internals.pauseRequireChan = make(chan operation, 1)
+
+	for ; ; time.Sleep(time.Millisecond * 50) {
+		if wg.internals.ntAdapter == nil {
+			break
+		}
+
+		// PAUSE\RESUME
+		select {
+		case toDoOperation := <-wg.internals.pauseRequireChan:
+			if toDoOperation == pause {
+				wg.internals.isPaused = true
+				defer func() {
+					// do not forget to mark connection as resumed
+					wg.internals.isPaused = false
+				}()
+
+				log.Info("Pausing...")
+
+				if err := wg.ntStopTunnel(); err != nil {
+					log.Error("failed to pause connection (disconnetion error):", err.Error())
+					return err
+				}
+
+				log.Info("Paused")
+
+				// waiting to resume or stop request
+				for {
+					toDoOperation = <-wg.internals.pauseRequireChan
+					if toDoOperation != pause { // ignore consequent 'pause' requests
+						break
+					}
+				}
+
+				if wg.internals.isDisconnectRequested {
+					break
+				}
+
+				if toDoOperation == resume {
+					log.Info("Resuming...")
+
+					if err := wg.ntStartTunnel(stateChan); err != nil {
+						log.Error("failed to resume connection (new connetion error):", err.Error())
+						return err
+					}
+
+					// reconnected successfully
+					log.Info("Resumed")
+					break
+				}
+			}
+		default:
+			// no pause required
+		}
+
+		// Check is reconnection required
+		// It can happen when configuration parameters were changed (e.g. ManualDNS value)
+		if wg.internals.isRestartRequired {
+			wg.internals.isRestartRequired = false
+
+			stateChan <- vpn.NewStateInfo(vpn.RECONNECTING, "Reconnecting with new connection parameters")
+
+			log.Info("Restarting...")
+			if err := wg.ntStopTunnel(); err != nil {
+				log.Error("failed to restart connection (disconnetion error):", err.Error())
+			} else {
+				if err := wg.ntStartTunnel(stateChan); err != nil {
+					log.Error("failed to restart connection (new connetion error):", err.Error())
+				} else {
+					// reconnected successfully
+					log.Info("Connection restarted")
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ntStartTunnel - create, configure and bring up the wireguard-nt adapter
+func (wg *WireGuard) ntStartTunnel(stateChan chan<- vpn.StateInfo) error {
+	// NO parallel operations of tunnel start OR stop should be performed!
+	_globalInitMutex.Lock()
+	defer _globalInitMutex.Unlock()
+
+	log.Info("Connecting...")
+
+	adapter, err := ntCreateAdapter(wg.ntDriverDllPath(), wg.getTunnelName())
+	if err != nil {
+		return err
+	}
+
+	isSuccess := false
+	defer func() {
+		if isSuccess == false {
+			adapter.close()
+		}
+	}()
+
+	// apply configuration (in-memory; the private key never touches the disk)
+	config, err := wg.ntMarshalConfig()
+	if err != nil {
+		return err
+	}
+	if err := adapter.setConfiguration(config); err != nil {
+		return err
+	}
+	if err := adapter.setState(_ntAdapterStateUp); err != nil {
+		return err
+	}
+
+	// the driver does not configure IP\routes\DNS - do it here
+	if err := wg.ntConfigureNetwork(); err != nil {
+		return err
+	}
+
+	// WireGuard interface is configured to correct DNS.
+	// But we must to be sure if non-ivpn interfaces are configured to our DNS
+	// (it needed ONLY if DNS IP located in local network)
+	// Also, it is needed to inform 'dns' package about last DNS value (used by 'protocol' to ptovide dns status to clients)
+	manualDNS := wg.internals.manualDNS
+	if manualDNS != nil {
+		dns.SetManual(manualDNS, nil)
+	} else {
+		// delete manual DNS (if defined)
+		dns.DeleteManual(nil)
+	}
+
+	isSuccess = true
+	wg.internals.ntAdapter = adapter
+
+	// CONNECTED
+	log.Info("Connection started")
+	// Send 'connected' notification only after 'dns' package informed about correct DNS value
+	wg.notifyConnectedStat(stateChan)
+
+	return nil
+}
+
+// ntStopTunnel - bring down and destroy the wireguard-nt adapter
+func (wg *WireGuard) ntStopTunnel() error {
+	// NO parallel operations of tunnel start OR stop should be performed!
+	_globalInitMutex.Lock()
+	defer _globalInitMutex.Unlock()
+
+	adapter := wg.internals.ntAdapter
+	if adapter == nil {
+		return nil // nothing to stop
+	}
+	wg.internals.ntAdapter = nil
+
+	log.Info("Destroying adapter...")
+	adapter.close()
+	log.Info("Adapter destroyed")
+	return nil
+}
+
+// ntConfigureNetwork - assign the tunnel IP address, routes and interface DNS
+func (wg *WireGuard) ntConfigureNetwork() error {
+	ifaceName := wg.getTunnelName()
+
+	// tunnel address
+	if err := shell.Exec(log, "netsh.exe", "interface", "ipv4", "set", "address",
+		"name="+ifaceName, "static", wg.connectParams.clientLocalIP.String(), "255.255.255.255"); err != nil {
+		return fmt.Errorf("failed to set tunnel IP address: %w", err)
+	}
+
+	// routes according to AllowedIPs
+	// Same as "0.0.0.0/0" but such type of configuration is disabling internal WireGuard-s Firewall
+	// (we have our own implementation of firewall)
+	for _, allowedIP := range wg.effectiveAllowedIPs([]string{"128.0.0.0/1", "0.0.0.0/1"}) {
+		ip, _, err := net.ParseCIDR(strings.TrimSpace(allowedIP))
+		if err != nil || ip.To4() == nil {
+			continue // only IPv4 destinations are routed here
+		}
+		if err := shell.Exec(log, "netsh.exe", "interface", "ipv4", "add", "route",
+			"prefix="+strings.TrimSpace(allowedIP), "interface="+ifaceName, "store=active"); err != nil {
+			return fmt.Errorf("failed to add route '%s': %w", allowedIP, err)
+		}
+	}
+
+	// interface DNS
+	dnsIP := wg.connectParams.hostLocalIP
+	if wg.internals.manualDNS != nil {
+		dnsIP = wg.internals.manualDNS
+	}
+	if err := shell.Exec(log, "netsh.exe", "interface", "ipv4", "set", "dnsservers",
+		"name="+ifaceName, "static", dnsIP.String(), "validate=no"); err != nil {
+		return fmt.Errorf("failed to set tunnel DNS: %w", err)
+	}
+
+	return nil
+}
+
+// ntMarshalConfig - marshal the connection parameters into the binary
+// WIREGUARD_INTERFACE + WIREGUARD_PEER + WIREGUARD_ALLOWED_IP... layout
+// which is expected by WireGuardSetConfiguration
+func (wg *WireGuard) ntMarshalConfig() ([]byte, error) {
+	privateKey, err := ntDecodeKey(wg.connectParams.clientPrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid client private key: %w", err)
+	}
+	publicKey, err := ntDecodeKey(wg.connectParams.hostPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host public key: %w", err)
+	}
+
+	// allowed IPs (IPv4 only for this implementation)
+	allowedIPs := make([]ntAllowedIPConfig, 0, 2)
+	for _, cidr := range wg.effectiveAllowedIPs([]string{"128.0.0.0/1", "0.0.0.0/1"}) {
+		ip, ipNet, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil || ip.To4() == nil {
+			continue
+		}
+		maskOnes, _ := ipNet.Mask.Size()
+		allowed := ntAllowedIPConfig{AddressFamily: windows.AF_INET, Cidr: uint8(maskOnes)}
+		copy(allowed.Address[:], ip.To4())
+		allowedIPs = append(allowedIPs, allowed)
+	}
+
+	iface := ntInterfaceConfig{
+		Flags:      _ntIfaceHasPrivateKey | _ntIfaceReplacePeers,
+		PrivateKey: privateKey,
+		PeersCount: 1}
+
+	peer := ntPeerConfig{
+		Flags:               _ntPeerHasPublicKey | _ntPeerHasEndpoint | _ntPeerHasPersistentKeepalive | _ntPeerReplaceAllowedIPs,
+		PublicKey:           publicKey,
+		PersistentKeepalive: uint16(wg.keepAliveInterval()),
+		AllowedIPsCount:     uint32(len(allowedIPs))}
+	if err := ntEncodeEndpoint(wg.endpoint(), &peer.Endpoint); err != nil {
+		return nil, err
+	}
+
+	ifaceSize := int(unsafe.Sizeof(iface))
+	peerSize := int(unsafe.Sizeof(peer))
+	allowedSize := int(unsafe.Sizeof(ntAllowedIPConfig{}))
+
+	buff := make([]byte, ifaceSize+peerSize+allowedSize*len(allowedIPs))
+	*(*ntInterfaceConfig)(unsafe.Pointer(&buff[0])) = iface
+	*(*ntPeerConfig)(unsafe.Pointer(&buff[ifaceSize])) = peer
+	for i, allowed := range allowedIPs {
+		*(*ntAllowedIPConfig)(unsafe.Pointer(&buff[ifaceSize+peerSize+allowedSize*i])) = allowed
+	}
+	return buff, nil
+}
+
+// ntDecodeKey - decode a base64 WireGuard key into its binary representation
+func ntDecodeKey(keyBase64 string) (key [32]byte, err error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(keyBase64))
+	if err != nil {
+		return key, err
+	}
+	if len(data) != len(key) {
+		return key, fmt.Errorf("unexpected WireGuard key length: %d", len(data))
+	}
+	copy(key[:], data)
+	return key, nil
+}
+
+// ntEncodeEndpoint - fill a SOCKADDR_INET (IPv4) with the peer endpoint 'host:port'
+func ntEncodeEndpoint(endpoint string, out *[28]byte) error {
+	host, portStr, err := net.SplitHostPort(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint '%s': %w", endpoint, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid endpoint port '%s'", portStr)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() == nil {
+		return fmt.Errorf("invalid endpoint address '%s'", host)
+	}
+
+	// SOCKADDR_IN: family (LE), port (network byte order), address
+	out[0] = byte(windows.AF_INET)
+	out[1] = byte(windows.AF_INET >> 8)
+	out[2] = byte(port >> 8)
+	out[3] = byte(port)
+	copy(out[4:8], ip.To4())
+	return nil
+}
+
+// ntCreateAdapter - load the DLL and create the adapter
+func ntCreateAdapter(dllPath string, name string) (*ntAdapter, error) {
+	dll, err := windows.LoadDLL(dllPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load wireguard-nt DLL: %w", err)
+	}
+
+	procCreate, err := dll.FindProc("WireGuardCreateAdapter")
+	if err != nil {
+		dll.Release()
+		return nil, fmt.Errorf("unexpected wireguard-nt DLL: %w", err)
+	}
+	procClose, err := dll.FindProc("WireGuardCloseAdapter")
+	if err != nil {
+		dll.Release()
+		return nil, fmt.Errorf("unexpected wireguard-nt DLL: %w", err)
+	}
+	procSetState, err := dll.FindProc("WireGuardSetAdapterState")
+	if err != nil {
+		dll.Release()
+		return nil, fmt.Errorf("unexpected wireguard-nt DLL: %w", err)
+	}
+
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		dll.Release()
+		return nil, err
+	}
+	tunnelTypePtr, err := windows.UTF16PtrFromString("IVPN")
+	if err != nil {
+		dll.Release()
+		return nil, err
+	}
+
+	handle, _, callErr := procCreate.Call(
+		uintptr(unsafe.Pointer(namePtr)),
+		uintptr(unsafe.Pointer(tunnelTypePtr)),
+		uintptr(unsafe.Pointer(&_ntAdapterGUID)))
+	if handle == 0 {
+		dll.Release()
+		return nil, fmt.Errorf("failed to create wireguard-nt adapter: %w", callErr)
+	}
+
+	return &ntAdapter{
+		dll:              dll,
+		handle:           handle,
+		procCloseAdapter: procClose,
+		procSetState:     procSetState}, nil
+}
+
+func (a *ntAdapter) setConfiguration(config []byte) error {
+	proc, err := a.dll.FindProc("WireGuardSetConfiguration")
+	if err != nil {
+		return fmt.Errorf("unexpected wireguard-nt DLL: %w", err)
+	}
+	ret, _, callErr := proc.Call(a.handle, uintptr(unsafe.Pointer(&config[0])), uintptr(len(config)))
+	if ret == 0 {
+		return fmt.Errorf("failed to configure wireguard-nt adapter: %w", callErr)
+	}
+	return nil
+}
+
+func (a *ntAdapter) setState(state uintptr) error {
+	ret, _, callErr := a.procSetState.Call(a.handle, state)
+	if ret == 0 {
+		return fmt.Errorf("failed to change wireguard-nt adapter state: %w", callErr)
+	}
+	return nil
+}
+
+func (a *ntAdapter) close() {
+	if a.handle != 0 {
+		a.procSetState.Call(a.handle, _ntAdapterStateDown)
+		a.procCloseAdapter.Call(a.handle)
+		a.handle = 0
+	}
+	if a.dll != nil {
+		a.dll.Release()
+		a.dll = nil
+	}
+}
@@ -60,6 +60,10 @@ type internalVariables struct {
 	pauseRequireChan      chan operation // control connection pause\resume or disconnect from paused state
 	isDisconnectRequested bool
 	isPaused              bool
+
+	// wireguard-nt driver support (used when the embeddable 'wireguard.dll' is installed)
+	useNtDriver bool
+	ntAdapter   *ntAdapter
 }
 
 const (
@@ -102,6 +106,12 @@ func (wg *WireGuard) connect(stateChan chan<- vpn.StateInfo) error {
 		return fmt.Errorf("failed to disconnect before new connection: %w", err)
 	}
 
+	// prefer the wireguard-nt kernel driver (when the embeddable DLL is installed):
+	// better throughput and no plaintext config file on disk
+	if wg.isNtDriverAvailable() {
+		return wg.connectNtDriver(stateChan)
+	}
+
 	// connect to service maneger
 	m, err := mgr.Connect()
 	if err != nil {
@@ -223,9 +233,28 @@ func (wg *WireGuard) disconnectInternal() error {
 
 	wg.requireOperation(resume) // resume (if we are in paused state)
 
+	if wg.internals.useNtDriver {
+		return wg.ntStopTunnel()
+	}
 	return wg.uninstallService()
 }
 
+// isTunnelRunning - check tunnel state regardless of the used backend
+// (wireguard-nt adapter or the WireGuard tunnel service)
+func (wg *WireGuard) isTunnelRunning() (bool, error) {
+	if wg.internals.useNtDriver {
+		return wg.internals.ntAdapter != nil, nil
+	}
+	return wg.isServiceRunning()
+}
+
+// onRoutingChanged - roaming is not implemented for Windows:
+// the tunnel is managed by the installed WireGuard service
+// (a full reconnection is required after the network change)
+func (wg *WireGuard) onRoutingChanged() error {
+	return fmt.Errorf("roaming is not supported by the WireGuard service implementation")
+}
+
 func (wg *WireGuard) isPaused() bool {
 	return wg.internals.isPaused
 }
@@ -255,7 +284,7 @@ func (wg *WireGuard) setManualDNS(addr net.IP) error {
 
 	wg.internals.manualDNS = addr
 
-	if running, err := wg.isServiceRunning(); err != nil || running == false {
+	if running, err := wg.isTunnelRunning(); err != nil || running == false {
 		return err
 	}
 
@@ -273,7 +302,7 @@ func (wg *WireGuard) resetManualDNS() error {
 
 	wg.internals.manualDNS = nil
 
-	if running, err := wg.isServiceRunning(); err != nil || running == false {
+	if running, err := wg.isTunnelRunning(); err != nil || running == false {
 		return err
 	}
 
@@ -454,7 +483,7 @@ func (wg *WireGuard) installService(stateChan chan<- vpn.StateInfo) error {
 	// CONNECTED
 	log.Info("Connection started")
 	// Send 'connected' notification only after 'dns' package informed about correct DNS value
-	stateChan <- vpn.NewStateInfoConnected(wg.connectParams.clientLocalIP, wg.connectParams.hostIP)
+	wg.notifyConnectedStat(stateChan)
 
 	return nil
 }
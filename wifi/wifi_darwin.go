@@ -0,0 +1,50 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wifi
+
+import (
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+const airportBinaryPath = "/System/Library/PrivateFrameworks/Apple80211.framework/Versions/Current/Resources/airport"
+
+// implGetCurrentSSID returns current SSID (using 'airport' utility)
+func implGetCurrentSSID() string {
+	ssid := ""
+	outParse := func(text string, isError bool) {
+		if isError {
+			return
+		}
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "SSID:") {
+			ssid = strings.TrimSpace(strings.TrimPrefix(text, "SSID:"))
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", airportBinaryPath, "-I"); err != nil {
+		return ""
+	}
+	return ssid
+}
@@ -0,0 +1,45 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wifi
+
+import (
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// implGetCurrentSSID returns current SSID ('iwgetid' is a part of 'wireless-tools' package)
+func implGetCurrentSSID() string {
+	ssid := ""
+	outParse := func(text string, isError bool) {
+		if isError == false && len(ssid) == 0 {
+			ssid = strings.TrimSpace(text)
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", "/usr/sbin/iwgetid", "-r"); err != nil {
+		// no Wi-Fi connection or 'iwgetid' not installed
+		return ""
+	}
+	return ssid
+}
@@ -0,0 +1,52 @@
+//
+//  Daemon for IVPN Client Desktop
+//  https://github.com/ivpn/desktop-app-daemon
+//
+//  Created by Stelnykovych Alexandr.
+//  Copyright (c) 2020 Privatus Limited.
+//
+//  This file is part of the Daemon for IVPN Client Desktop.
+//
+//  The Daemon for IVPN Client Desktop is free software: you can redistribute it and/or
+//  modify it under the terms of the GNU General Public License as published by the Free
+//  Software Foundation, either version 3 of the License, or (at your option) any later version.
+//
+//  The Daemon for IVPN Client Desktop is distributed in the hope that it will be useful,
+//  but WITHOUT ANY WARRANTY; without even the implied warranty of MERCHANTABILITY
+//  or FITNESS FOR A PARTICULAR PURPOSE.  See the GNU General Public License for more
+//  details.
+//
+//  You should have received a copy of the GNU General Public License
+//  along with the Daemon for IVPN Client Desktop. If not, see <https://www.gnu.org/licenses/>.
+//
+
+package wifi
+
+import (
+	"strings"
+
+	"github.com/ivpn/desktop-app-daemon/shell"
+)
+
+// implGetCurrentSSID returns current SSID (using 'netsh' utility)
+func implGetCurrentSSID() string {
+	ssid := ""
+	outParse := func(text string, isError bool) {
+		if isError || len(ssid) > 0 {
+			return
+		}
+		text = strings.TrimSpace(text)
+		// first 'SSID' line of 'netsh wlan show interfaces' output (not 'BSSID')
+		if strings.HasPrefix(text, "SSID") {
+			cols := strings.SplitN(text, ":", 2)
+			if len(cols) == 2 {
+				ssid = strings.TrimSpace(cols[1])
+			}
+		}
+	}
+
+	if err := shell.ExecAndProcessOutput(nil, outParse, "", "netsh", "wlan", "show", "interfaces"); err != nil {
+		return ""
+	}
+	return ssid
+}